@@ -0,0 +1,68 @@
+package flex
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+)
+
+// logBanner logs the "service starting" entry listing the workers of the
+// manager, its namespaces under "namespace.<name>" with the number of their
+// workers, and the disabled workers, if enabled with WithBanner.
+func (m *Manager) logBanner(ctx context.Context) {
+	if !m.opts.banner {
+		return
+	}
+
+	var args []any
+	if id, ok := IdentityFromContext(ctx); ok {
+		args = append(args, "service", id.Service, "instance", id.InstanceID)
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		args = append(args, "version", info.Main.Version)
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				args = append(args, "revision", setting.Value)
+			}
+		}
+		args = append(args, "go", info.GoVersion)
+	}
+
+	if m.opts.profile != "" {
+		args = append(args, "profile", m.opts.profile)
+	}
+	args = append(args, "workers", len(m.workers))
+	for _, worker := range m.workers {
+		if nw, ok := worker.Worker.(*namespaceWorker); ok {
+			args = append(args, slog.Group("namespace."+nw.ns.name, "workers", len(nw.ns.Workers())))
+			continue
+		}
+		attrs := []any{"type", fmt.Sprintf("%T", worker.Worker)}
+		if addr := workerAddr(worker.Worker); addr != "" {
+			attrs = append(attrs, "addr", addr)
+		}
+		args = append(args, slog.Group(worker.name(), attrs...))
+	}
+	if len(m.disabled) > 0 {
+		args = append(args, "disabled", strings.Join(m.DisabledWorkers(), ","))
+	}
+
+	m.log(ctx, slog.LevelInfo, "service starting", args...)
+}
+
+// workerAddr returns the address w listens on, if known: that returned by its
+// Addr method, or else its "addr" detail if it implements Describer.
+func workerAddr(w Worker) string {
+	if a, ok := w.(interface{ Addr() net.Addr }); ok {
+		if addr := a.Addr(); addr != nil {
+			return addr.String()
+		}
+	}
+	if d, ok := w.(Describer); ok {
+		return d.Describe()["addr"]
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package flex_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// addrMockWorker describes the address it listens on.
+type addrMockWorker struct{ mockWorker }
+
+func (a *addrMockWorker) Describe() map[string]string { return map[string]string{"addr": ":8080"} }
+
+func TestWithBanner(t *testing.T) {
+	t.Run("the workers must be listed when starting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger), flex.WithBanner(),
+			flex.WithIdentity(flex.Identity{Service: "billing", InstanceID: "billing-0"}))
+		m.Add(&addrMockWorker{mockWorker{t: t, name: "api"}}, flex.WithName("api"))
+		m.Add(&mockWorker{t: t, name: "consumer"}, flex.WithName("consumer"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := logger.find("service starting")
+		if !ok {
+			t.Fatal("expected the banner to be logged")
+		}
+		attrs := make(map[string]slog.Value)
+		for _, attr := range flex.Attrs(e.args...) {
+			attrs[attr.Key] = attr.Value
+		}
+		if attrs["service"].String() != "billing" || attrs["instance"].String() != "billing-0" || attrs["workers"].Int64() != 2 {
+			t.Errorf("unexpected banner: %v", e.args)
+		}
+		if _, ok := attrs["go"]; !ok {
+			t.Errorf("expected the build info to be logged but got: %v", e.args)
+		}
+		if api := attrs["api"].String(); api != "[type=*flex_test.addrMockWorker addr=:8080]" {
+			t.Errorf("unexpected worker: %s", api)
+		}
+		if consumer := attrs["consumer"].String(); consumer != "[type=*flex_test.mockWorker]" {
+			t.Errorf("unexpected worker: %s", consumer)
+		}
+	})
+	t.Run("disabled workers must be listed when starting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger), flex.WithBanner(), flex.WithDisabledWorkers("pprof", "cron"))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+		m.Add(&mockWorker{t: t, name: "pprof"}, flex.WithName("pprof"))
+		m.Add(&mockWorker{t: t, name: "cron"}, flex.WithName("cron"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := logger.find("service starting")
+		if !ok {
+			t.Fatal("expected the banner to be logged")
+		}
+		attrs := make(map[string]slog.Value)
+		for _, attr := range flex.Attrs(e.args...) {
+			attrs[attr.Key] = attr.Value
+		}
+		if attrs["workers"].Int64() != 1 || attrs["disabled"].String() != "pprof,cron" {
+			t.Errorf("unexpected banner: %v", e.args)
+		}
+	})
+	t.Run("namespaces must be listed by the banner of the manager only", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		logger := &recordingLogger{}
+		worker := &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger), flex.WithBanner())
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+		if err := m.Namespace("tenant-a").Add(worker); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+		cancel()
+		if err := <-errC; err != nil {
+			t.Fatal(err)
+		}
+
+		var banners []entry
+		logger.mu.Lock()
+		for _, e := range logger.entries {
+			if e.msg == "service starting" {
+				banners = append(banners, e)
+			}
+		}
+		logger.mu.Unlock()
+		if len(banners) != 1 {
+			t.Fatalf("expected a single banner but got %d", len(banners))
+		}
+
+		attrs := make(map[string]slog.Value)
+		for _, attr := range flex.Attrs(banners[0].args...) {
+			attrs[attr.Key] = attr.Value
+		}
+		if ns := attrs["namespace.tenant-a"].String(); ns != "[workers=1]" {
+			t.Errorf("unexpected namespace: %v", banners[0].args)
+		}
+	})
+	t.Run("the banner must not be logged by default", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger))
+		m.Add(&mockWorker{t: t, name: "consumer"})
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := logger.find("service starting"); ok {
+			t.Error("expected no banner")
+		}
+	})
+}
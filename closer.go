@@ -0,0 +1,43 @@
+package flex
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// CloserWorker returns a Worker for a resource which only needs to be closed
+// on shutdown, such as a tracer provider, a log sink or a client. Its Run
+// blocks until the worker is halted or its context is done, and its Halt
+// closes the resource, once. The worker is reported under name, unless the
+// WithName option is given when adding it.
+func CloserWorker(name string, closer io.Closer) Worker {
+	return &closerWorker{name: name, closer: closer, halted: make(chan struct{})}
+}
+
+// closerWorker is the Worker returned by CloserWorker.
+type closerWorker struct {
+	name   string
+	closer io.Closer
+
+	once   sync.Once
+	halted chan struct{}
+	err    error
+}
+
+func (w *closerWorker) Run(ctx context.Context) error {
+	Ready(ctx)
+	select {
+	case <-ctx.Done():
+	case <-w.halted:
+	}
+	return nil
+}
+
+func (w *closerWorker) Halt(context.Context) error {
+	w.once.Do(func() {
+		close(w.halted)
+		w.err = w.closer.Close()
+	})
+	return w.err
+}
@@ -0,0 +1,71 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// mockCloser counts how many times it is closed.
+type mockCloser struct {
+	closed atomic.Int32
+	err    error
+}
+
+func (c *mockCloser) Close() error {
+	c.closed.Add(1)
+	return c.err
+}
+
+func TestCloserWorker(t *testing.T) {
+	t.Run("the closer must be closed once on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		closer := &mockCloser{}
+		m := flex.New(flex.WithSignals())
+		m.Add(flex.CloserWorker("tracer", closer))
+		events := m.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		if e := await(events, flex.EventWorkerStarted); e.WorkerName != "tracer" {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		cancel()
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if n := closer.closed.Load(); n != 1 {
+			t.Errorf("expected the closer to be closed %d time but got: %d", 1, n)
+		}
+	})
+	t.Run("halting must stop Run and return the error of Close", func(t *testing.T) {
+		t.Parallel()
+
+		closer := &mockCloser{err: errors.New("flush failed")}
+		w := flex.CloserWorker("sink", closer)
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		for range 2 {
+			if err := w.Halt(context.Background()); !errors.Is(err, closer.err) {
+				t.Errorf("expected %v but got: %v", closer.err, err)
+			}
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if n := closer.closed.Load(); n != 1 {
+			t.Errorf("expected the closer to be closed %d time but got: %d", 1, n)
+		}
+	})
+}
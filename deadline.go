@@ -0,0 +1,110 @@
+package flex
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GracePeriodEnv is the environment variable read by DetectRuntime for the
+// grace period of Kubernetes, in seconds, which is meant to be set to the
+// terminationGracePeriodSeconds of the pod, so that it is configured in one
+// place:
+//
+//	spec:
+//	  terminationGracePeriodSeconds: 60
+//	  containers:
+//	    - env:
+//	        - name: TERMINATION_GRACE_PERIOD_SECONDS
+//	          value: "60"
+const GracePeriodEnv = "TERMINATION_GRACE_PERIOD_SECONDS"
+
+// Runtime describes a platform which kills the process some time after
+// asking it to shut down.
+type Runtime struct {
+	// Name identifies the runtime.
+	Name string
+	// GracePeriod is the time between the runtime asking the process to shut
+	// down and killing it.
+	GracePeriod time.Duration
+}
+
+// Known runtimes, along with the grace period they give by default.
+//
+// The grace period of RuntimeLambda is an approximation: Lambda gives the
+// remaining time of an invocation only to its handler, through the deadline
+// of the handler's context, and not to the process, so that the 500ms it
+// gives extensions to shut down are used instead. Deadline returns the
+// deadline of the invocation when given a context carrying it, such as the
+// context of an aws-lambda-go handler, as it is earlier.
+var (
+	RuntimeLambda     = Runtime{Name: "lambda", GracePeriod: 500 * time.Millisecond}
+	RuntimeCloudRun   = Runtime{Name: "cloudrun", GracePeriod: 10 * time.Second}
+	RuntimeKubernetes = Runtime{Name: "kubernetes", GracePeriod: 30 * time.Second}
+)
+
+// DetectRuntime returns the runtime the process is running in, based on the
+// environment variables each runtime sets, and whether one was detected. The
+// grace period of Kubernetes is read from GracePeriodEnv when it is set.
+func DetectRuntime() (Runtime, bool) {
+	switch {
+	case os.Getenv("AWS_LAMBDA_RUNTIME_API") != "":
+		return RuntimeLambda, true
+	case os.Getenv("K_SERVICE") != "":
+		return RuntimeCloudRun, true
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		runtime := RuntimeKubernetes
+		if seconds, err := strconv.Atoi(os.Getenv(GracePeriodEnv)); err == nil && seconds >= 0 {
+			runtime.GracePeriod = time.Duration(seconds) * time.Second
+		} else if v := os.Getenv(GracePeriodEnv); v != "" {
+			logger.Printf("ignoring %s=%q: not a number of seconds", GracePeriodEnv, v)
+		}
+		return runtime, true
+	}
+	return Runtime{}, false
+}
+
+// Deadline returns the time by which the worker owning ctx must be done, and
+// whether there is such a time. Once a shutdown has begun in a known runtime,
+// it is the time at which the runtime will kill the process, which lets
+// workers size their cleanup accordingly. A deadline set on ctx itself is
+// returned instead when it is earlier.
+func Deadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Deadline()
+	if state, found := ctx.Value(shutdownKey{}).(*shutdownState); found {
+		if at, set := state.deadline(); set && (!ok || at.Before(deadline)) {
+			deadline, ok = at, true
+		}
+	}
+	return deadline, ok
+}
+
+// shutdownKey is the context key under which the shutdown state is stored.
+type shutdownKey struct{}
+
+// shutdownState records when the runtime will kill the process.
+type shutdownState struct {
+	runtime *Runtime
+
+	mu sync.Mutex
+	at time.Time
+}
+
+// begin records that a shutdown began at now.
+func (s *shutdownState) begin(now time.Time) {
+	if s.runtime == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.at = now.Add(s.runtime.GracePeriod)
+}
+
+// deadline returns the time at which the process will be killed, if known.
+func (s *shutdownState) deadline() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.at, !s.at.IsZero()
+}
@@ -0,0 +1,130 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// deadlineMockWorker records the deadline it sees while running and halting.
+type deadlineMockWorker struct {
+	mockWorker
+	runDeadline, haltDeadline time.Time
+	runOk, haltOk             bool
+}
+
+func (d *deadlineMockWorker) Run(ctx context.Context) error {
+	d.runDeadline, d.runOk = flex.Deadline(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (d *deadlineMockWorker) Halt(ctx context.Context) error {
+	d.haltDeadline, d.haltOk = flex.Deadline(ctx)
+	return nil
+}
+
+func TestDeadline(t *testing.T) {
+	t.Run("a runtime must set a deadline once the shutdown begins", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		worker := &deadlineMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithRuntime(flex.Runtime{Name: "test", GracePeriod: time.Minute}))
+		m.Add(worker)
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if worker.runOk {
+			t.Error("expected no deadline while running")
+		}
+		if !worker.haltOk {
+			t.Fatal("expected a deadline while halting")
+		}
+		if until := time.Until(worker.haltDeadline); until < 50*time.Second || until > time.Minute {
+			t.Errorf("expected the deadline to be about a minute away, but got %s", until)
+		}
+	})
+	t.Run("workers must be abandoned the halt margin before the runtime kills the process", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		worker := &stuckMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, release: make(chan struct{})}
+		defer close(worker.release)
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltMargin(100*time.Millisecond),
+			flex.WithRuntime(flex.Runtime{Name: "test", GracePeriod: 200 * time.Millisecond}))
+		m.Add(worker)
+
+		start := time.Now()
+		if err := m.Start(ctx); !errors.Is(err, flex.ErrHaltTimeout) {
+			t.Errorf("expected %v but got: %v", flex.ErrHaltTimeout, err)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("expected the worker to be abandoned before the margin but it was after: %s", elapsed)
+		}
+	})
+	t.Run("an earlier context deadline must take precedence", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		worker := &deadlineMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithRuntime(flex.Runtime{Name: "test", GracePeriod: time.Minute}))
+		m.Add(worker)
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		expected, _ := ctx.Deadline()
+		if !worker.runOk || !worker.haltOk || !worker.haltDeadline.Equal(expected) {
+			t.Errorf("expected the context deadline %v, but got %v", expected, worker.haltDeadline)
+		}
+	})
+}
+
+func TestDetectRuntime(t *testing.T) {
+	for _, tc := range []struct {
+		env      string
+		expected flex.Runtime
+	}{
+		{env: "AWS_LAMBDA_RUNTIME_API", expected: flex.RuntimeLambda},
+		{env: "K_SERVICE", expected: flex.RuntimeCloudRun},
+		{env: "KUBERNETES_SERVICE_HOST", expected: flex.RuntimeKubernetes},
+	} {
+		t.Run(tc.expected.Name+" must be detected", func(t *testing.T) {
+			t.Setenv("AWS_LAMBDA_RUNTIME_API", "")
+			t.Setenv("K_SERVICE", "")
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			t.Setenv(tc.env, "set")
+
+			runtime, ok := flex.DetectRuntime()
+			if !ok || runtime != tc.expected {
+				t.Errorf("expected %+v but got %+v", tc.expected, runtime)
+			}
+		})
+	}
+	t.Run("the grace period of kubernetes must be read from the environment", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_RUNTIME_API", "")
+		t.Setenv("K_SERVICE", "")
+		t.Setenv("KUBERNETES_SERVICE_HOST", "set")
+		t.Setenv(flex.GracePeriodEnv, "90")
+
+		if runtime, _ := flex.DetectRuntime(); runtime.GracePeriod != 90*time.Second {
+			t.Errorf("expected %s but got %s", 90*time.Second, runtime.GracePeriod)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package flex
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// dependencyNode is a worker of a dependency graph, see WithDependsOn.
+type dependencyNode struct {
+	name      string
+	group     string
+	dependsOn []string
+}
+
+// checkDependencies returns an error wrapping ErrDependency if a node depends
+// on a name matching no node, unless it is ignored, or on itself through
+// other nodes.
+func checkDependencies(nodes []dependencyNode, ignored []string) error {
+	resolve := func(name string) []int {
+		var matches []int
+		for i, node := range nodes {
+			if node.name == name || node.group != "" && node.group == name {
+				matches = append(matches, i)
+			}
+		}
+		return matches
+	}
+
+	for _, node := range nodes {
+		for _, dep := range node.dependsOn {
+			if len(resolve(dep)) == 0 && !slices.Contains(ignored, dep) {
+				return fmt.Errorf("%w: %q depends on unknown worker %q", ErrDependency, node.name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(nodes))
+	var path []int
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visiting:
+			var names []string
+			for _, j := range path[slices.Index(path, i):] {
+				names = append(names, nodes[j].name)
+			}
+			return fmt.Errorf("%w: cycle %s", ErrDependency, strings.Join(append(names, nodes[i].name), " -> "))
+		case visited:
+			return nil
+		}
+
+		states[i] = visiting
+		path = append(path, i)
+		for _, dep := range nodes[i].dependsOn {
+			for _, j := range resolve(dep) {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+		return nil
+	}
+
+	for i := range nodes {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDependencies checks the dependencies of the workers of the manager,
+// ignoring those on disabled workers.
+func (m *Manager) checkDependencies() error {
+	nodes := make([]dependencyNode, 0, len(m.workers))
+	for _, worker := range m.workers {
+		nodes = append(nodes, dependencyNode{name: worker.name(), group: worker.opts.group, dependsOn: worker.opts.dependsOn})
+	}
+
+	var ignored []string
+	for _, worker := range m.disabled {
+		ignored = append(ignored, worker.name())
+		if worker.opts.group != "" {
+			ignored = append(ignored, worker.opts.group)
+		}
+	}
+	return checkDependencies(nodes, ignored)
+}
+
+// dependsOn reports whether worker depends on dep, by name or group.
+func (worker *managedWorker) dependsOn(dep *managedWorker) bool {
+	if worker == dep {
+		return false
+	}
+	return slices.Contains(worker.opts.dependsOn, dep.name()) ||
+		dep.opts.group != "" && slices.Contains(worker.opts.dependsOn, dep.opts.group)
+}
+
+// awaitDependencies waits for the workers worker depends on to have started,
+// and reports whether they did before ctx was done.
+func (m *Manager) awaitDependencies(ctx context.Context, worker *managedWorker) bool {
+	for _, dep := range m.workers {
+		if !worker.dependsOn(dep) {
+			continue
+		}
+		select {
+		case <-dep.started:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// awaitDependents waits for the workers of band depending on worker to have
+// returned from Halt, as reported by the channels of halted.
+func (m *Manager) awaitDependents(worker *managedWorker, halted map[*managedWorker]chan struct{}) {
+	for dependent, done := range halted {
+		if dependent.dependsOn(worker) {
+			<-done
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// orderLog records the order in which workers run and halt.
+type orderLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *orderLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *orderLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+// orderedMockWorker records its Run, once ready after delay, and its Halt.
+type orderedMockWorker struct {
+	name  string
+	delay time.Duration
+	log   *orderLog
+}
+
+func (o *orderedMockWorker) Run(ctx context.Context) error {
+	time.Sleep(o.delay)
+	o.log.add("run " + o.name)
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (o *orderedMockWorker) Halt(context.Context) error {
+	time.Sleep(o.delay)
+	o.log.add("halt " + o.name)
+	return nil
+}
+
+func TestWithDependsOn(t *testing.T) {
+	t.Run("workers must start after and halt before their dependencies", func(t *testing.T) {
+		t.Parallel()
+
+		log := &orderLog{}
+		m := flex.New(flex.WithSignals())
+		m.Add(&orderedMockWorker{name: "api", log: log}, flex.WithName("api"), flex.WithDependsOn("storage"))
+		m.Add(&orderedMockWorker{name: "db", delay: 20 * time.Millisecond, log: log}, flex.WithName("db"), flex.WithGroup("storage"), flex.WithStartedOnReady())
+		m.Add(&orderedMockWorker{name: "cache", delay: 10 * time.Millisecond, log: log}, flex.WithName("cache"), flex.WithGroup("storage"), flex.WithStartedOnReady())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-m.Started()
+		cancel()
+		if err := <-errC; err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		entries := log.get()
+		if i := slices.Index(entries, "run api"); i < slices.Index(entries, "run db") || i < slices.Index(entries, "run cache") {
+			t.Errorf("expected api to run after its dependencies but got: %v", entries)
+		}
+		if i := slices.Index(entries, "halt api"); i > slices.Index(entries, "halt db") || i > slices.Index(entries, "halt cache") {
+			t.Errorf("expected api to halt before its dependencies but got: %v", entries)
+		}
+	})
+	t.Run("unknown dependencies must fail to start", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"), flex.WithDependsOn("db"))
+
+		if err := m.Start(context.Background()); !errors.Is(err, flex.ErrDependency) {
+			t.Errorf("expected %v but got: %v", flex.ErrDependency, err)
+		}
+	})
+	t.Run("cyclic dependencies must fail to start", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&mockWorker{t: t, name: "a"}, flex.WithName("a"), flex.WithDependsOn("b"))
+		m.Add(&mockWorker{t: t, name: "b"}, flex.WithName("b"), flex.WithDependsOn("c"))
+		m.Add(&mockWorker{t: t, name: "c"}, flex.WithName("c"), flex.WithDependsOn("a"))
+
+		err := m.Start(context.Background())
+		if !errors.Is(err, flex.ErrDependency) {
+			t.Fatalf("expected %v but got: %v", flex.ErrDependency, err)
+		}
+		if want := "invalid worker dependency: cycle a -> b -> c -> a"; err.Error() != want {
+			t.Errorf("expected %q but got: %q", want, err)
+		}
+	})
+	t.Run("dependencies on disabled workers must be ignored", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithSignals(), flex.WithDisabledWorkers("observability"))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"), flex.WithDependsOn("observability"))
+		m.Add(&mockWorker{t: t, name: "pprof"}, flex.WithName("pprof"), flex.WithGroup("observability"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+		if want := []string{"pprof"}; !slices.Equal(m.DisabledWorkers(), want) {
+			t.Errorf("expected %v but got: %v", want, m.DisabledWorkers())
+		}
+	})
+}
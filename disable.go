@@ -0,0 +1,25 @@
+package flex
+
+import "slices"
+
+// DisabledWorkers returns the names of the workers skipped by Add, in the
+// order they were added, see WithEnabledWorkers and WithDisabledWorkers.
+func (m *Manager) DisabledWorkers() []string {
+	names := make([]string, 0, len(m.disabled))
+	for _, worker := range m.disabled {
+		names = append(names, worker.name())
+	}
+	return names
+}
+
+// enabled reports whether worker should run, matching its name and group.
+func (m *Manager) enabled(worker *managedWorker) bool {
+	matches := func(names []string) bool {
+		return slices.Contains(names, worker.name()) ||
+			worker.opts.group != "" && slices.Contains(names, worker.opts.group)
+	}
+	if len(m.opts.enabled) > 0 && !matches(m.opts.enabled) {
+		return false
+	}
+	return !matches(m.opts.disabled)
+}
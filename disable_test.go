@@ -0,0 +1,56 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestDisabledWorkers(t *testing.T) {
+	t.Run("disabled workers must not run", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		pprof := &failingMockWorker{mockWorker{t: t, name: "pprof"}}
+		m := flex.New(flex.WithSignals(), flex.WithDisabledWorkers("pprof"))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+		m.Add(pprof, flex.WithName("pprof"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Errorf("expected the failing worker not to run but got: %v", err)
+		}
+		if got := m.Workers(); len(got) != 1 {
+			t.Errorf("expected %d worker but got: %v", 1, got)
+		}
+		if want := []string{"pprof"}; !slices.Equal(m.DisabledWorkers(), want) {
+			t.Errorf("expected %v but got: %v", want, m.DisabledWorkers())
+		}
+	})
+	t.Run("only enabled workers must run", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithEnabledWorkers("api", "consumer"), flex.WithDisabledWorkers("consumer"))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+		m.Add(&mockWorker{t: t, name: "consumer"}, flex.WithName("consumer"))
+		m.Add(&mockWorker{t: t, name: "cron"}, flex.WithName("cron"))
+
+		if want := []string{"consumer", "cron"}; !slices.Equal(m.DisabledWorkers(), want) {
+			t.Errorf("expected %v but got: %v", want, m.DisabledWorkers())
+		}
+	})
+	t.Run("disabling every worker must fail to start", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithDisabledWorkers("api"))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+
+		if err := m.Start(context.Background()); !errors.Is(err, flex.ErrNoWorkers) {
+			t.Errorf("expected %v but got: %v", flex.ErrNoWorkers, err)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Plan is what Start would do, as resolved by Manager.Plan without running
+// any worker.
+type Plan struct {
+	// Manifest describes the settings of the manager and its workers.
+	Manifest Manifest
+	// Start holds the names of the workers by start stage: the workers of a
+	// stage run once those of the previous stages they depend on have
+	// started, see WithDependsOn.
+	Start [][]string
+	// Halt holds the names of the workers by halt stage: the workers of a
+	// stage are halted once those of the previous stage have returned from
+	// Halt, see WithPriority and WithDependsOn.
+	Halt [][]string
+	// Disabled holds the names of the disabled workers, see
+	// WithDisabledWorkers.
+	Disabled []string
+}
+
+// Plan resolves what Start would do: it checks the dependencies of the
+// workers and validates those implementing Validator, returning the same
+// errors as Start, and returns the order in which the workers would start
+// and halt. No worker is run, and no init job either.
+func (m *Manager) Plan(ctx context.Context) (Plan, error) {
+	if len(m.workers) < 1 {
+		return Plan{}, ErrNoWorkers
+	}
+	for _, worker := range m.workers {
+		if worker.Worker == nil {
+			return Plan{}, ErrNilWorker
+		}
+	}
+	if err := m.checkDependencies(); err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{Manifest: m.Manifest(), Disabled: m.DisabledWorkers()}
+	for _, stage := range m.startStages() {
+		plan.Start = append(plan.Start, workerNames(stage))
+	}
+	for _, band := range m.haltBands() {
+		for _, stage := range haltStages(band) {
+			plan.Halt = append(plan.Halt, workerNames(stage))
+		}
+	}
+
+	if m.opts.identity != nil {
+		ctx = withIdentity(ctx, *m.opts.identity)
+	}
+	return plan, m.validateWorkers(ctx)
+}
+
+// DryRun writes the plan of the manager to w, see Manager.Plan, one line of
+// key=value pairs for its settings, then one for every worker, every start
+// and halt stage, and every error, and returns the error of the plan, if
+// any, so that it fails CI when the service is misconfigured.
+func (m *Manager) DryRun(ctx context.Context, w io.Writer) error {
+	plan, err := m.Plan(ctx)
+
+	lines := plan.lines()
+	for _, err := range planErrors(err) {
+		line := "error=" + strconv.Quote(err.Error())
+		var werr *WorkerError
+		if errors.As(err, &werr) {
+			line = fmt.Sprintf("worker=%s phase=%s error=%s", dumpValue(werr.Worker), werr.Phase, strconv.Quote(werr.Err.Error()))
+		}
+		lines = append(lines, line)
+	}
+
+	if _, werr := io.WriteString(w, strings.Join(lines, "\n")+"\n"); werr != nil && err == nil {
+		return werr
+	}
+	return err
+}
+
+// DryRun writes the plan of a manager running workers, as Start would, to
+// stdout, see Manager.DryRun.
+func DryRun(ctx context.Context, workers ...Worker) error {
+	m := newManager(v1Options...)
+	for _, worker := range workers {
+		m.Add(worker)
+	}
+	return m.DryRun(ctx, os.Stdout)
+}
+
+// lines returns the lines of the plan written by DryRun.
+func (p Plan) lines() []string {
+	line := fmt.Sprintf("plan workers=%d", len(p.Manifest.Workers))
+	if p.Manifest.StartTimeout != "" {
+		line += " start_timeout=" + p.Manifest.StartTimeout
+	}
+	if p.Manifest.HaltTimeout != "" {
+		line += " halt_timeout=" + p.Manifest.HaltTimeout
+	}
+	if policy := p.Manifest.RestartPolicy; policy != nil {
+		line += fmt.Sprintf(" max_restarts=%d", policy.MaxRestarts)
+	}
+	lines := []string{line}
+
+	for _, mw := range p.Manifest.Workers {
+		line := fmt.Sprintf("worker=%s type=%s priority=%d", dumpValue(mw.Name), dumpValue(mw.Type), mw.Priority)
+		if mw.Group != "" {
+			line += " group=" + dumpValue(mw.Group)
+		}
+		if len(mw.DependsOn) > 0 {
+			line += " depends_on=" + dumpValue(strings.Join(mw.DependsOn, ","))
+		}
+		if mw.StartTimeout != "" {
+			line += " start_timeout=" + mw.StartTimeout
+		}
+		if policy := mw.RestartPolicy; policy != nil {
+			line += fmt.Sprintf(" max_restarts=%d", policy.MaxRestarts)
+		}
+		lines = append(lines, line)
+	}
+	for i, stage := range p.Start {
+		lines = append(lines, fmt.Sprintf("start stage=%d workers=%s", i+1, dumpValue(strings.Join(stage, ","))))
+	}
+	for i, stage := range p.Halt {
+		lines = append(lines, fmt.Sprintf("halt stage=%d workers=%s", i+1, dumpValue(strings.Join(stage, ","))))
+	}
+	if len(p.Disabled) > 0 {
+		lines = append(lines, "disabled workers="+dumpValue(strings.Join(p.Disabled, ",")))
+	}
+	return lines
+}
+
+// planErrors returns the errors held by err, a MultiError or errors joined
+// with errors.Join.
+func planErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		return multi.Unwrap()
+	}
+	return []error{err}
+}
+
+// startStages groups the workers by the depth of their dependencies, those
+// depending on no worker first.
+func (m *Manager) startStages() [][]*managedWorker {
+	depths := make(map[*managedWorker]int, len(m.workers))
+	var depth func(worker *managedWorker) int
+	depth = func(worker *managedWorker) int {
+		if d, ok := depths[worker]; ok {
+			return d
+		}
+		d := 0
+		for _, dep := range m.workers {
+			if worker.dependsOn(dep) {
+				d = max(d, depth(dep)+1)
+			}
+		}
+		depths[worker] = d
+		return d
+	}
+	return stages(m.workers, depth)
+}
+
+// haltStages groups the workers of a halt band by the depth of the workers of
+// the band depending on them, those no worker depends on first.
+func haltStages(band []*managedWorker) [][]*managedWorker {
+	depths := make(map[*managedWorker]int, len(band))
+	var depth func(worker *managedWorker) int
+	depth = func(worker *managedWorker) int {
+		if d, ok := depths[worker]; ok {
+			return d
+		}
+		d := 0
+		for _, dependent := range band {
+			if dependent.dependsOn(worker) {
+				d = max(d, depth(dependent)+1)
+			}
+		}
+		depths[worker] = d
+		return d
+	}
+	return stages(band, depth)
+}
+
+// stages groups workers by depth, in the order they were added within a
+// stage.
+func stages(workers []*managedWorker, depth func(*managedWorker) int) [][]*managedWorker {
+	var stages [][]*managedWorker
+	for _, worker := range workers {
+		d := depth(worker)
+		for len(stages) <= d {
+			stages = append(stages, nil)
+		}
+		stages[d] = append(stages[d], worker)
+	}
+	return stages
+}
+
+// workerNames returns the names of workers.
+func workerNames(workers []*managedWorker) []string {
+	names := make([]string, 0, len(workers))
+	for _, worker := range workers {
+		names = append(names, worker.name())
+	}
+	return names
+}
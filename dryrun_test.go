@@ -0,0 +1,133 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManagerDryRun(t *testing.T) {
+	t.Run("the plan must hold the start and halt order", func(t *testing.T) {
+		t.Parallel()
+
+		api := &validatingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}
+		m := flex.New(flex.WithSignals(), flex.WithStartTimeout(5*time.Second), flex.WithDisabledWorkers("pprof"))
+		m.Add(api, flex.WithName("api"), flex.WithDependsOn("storage"))
+		m.Add(&mockWorker{t: t, name: "db"}, flex.WithName("db"), flex.WithGroup("storage"))
+		m.Add(&mockWorker{t: t, name: "cache"}, flex.WithName("cache"), flex.WithGroup("storage"), flex.WithDependsOn("db"))
+		m.Add(&mockWorker{t: t, name: "metrics"}, flex.WithName("metrics"), flex.WithPriority(1))
+		m.Add(&mockWorker{t: t, name: "pprof"}, flex.WithName("pprof"))
+
+		plan, err := m.Plan(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if expected := [][]string{{"db", "metrics"}, {"cache"}, {"api"}}; !reflect.DeepEqual(plan.Start, expected) {
+			t.Errorf("expected to start %v but got: %v", expected, plan.Start)
+		}
+		if expected := [][]string{{"api"}, {"cache"}, {"db"}, {"metrics"}}; !reflect.DeepEqual(plan.Halt, expected) {
+			t.Errorf("expected to halt %v but got: %v", expected, plan.Halt)
+		}
+		if expected := []string{"pprof"}; !reflect.DeepEqual(plan.Disabled, expected) {
+			t.Errorf("expected %v to be disabled but got: %v", expected, plan.Disabled)
+		}
+		if api.ran.Load() {
+			t.Error("expected no worker to run")
+		}
+
+		var out bytes.Buffer
+		if err := m.DryRun(context.Background(), &out); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		for _, line := range []string{
+			"plan workers=4 start_timeout=5s",
+			"worker=api type=*flex_test.validatingMockWorker priority=0 depends_on=storage",
+			"worker=cache type=*flex_test.mockWorker priority=0 group=storage depends_on=db",
+			"start stage=3 workers=api",
+			"halt stage=4 workers=metrics",
+			"disabled workers=pprof",
+		} {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("expected the line %q but got:\n%s", line, out.String())
+			}
+		}
+	})
+	t.Run("validation errors must be reported", func(t *testing.T) {
+		t.Parallel()
+
+		missingDSN := errors.New("missing dsn")
+		m := flex.New(flex.WithSignals())
+		m.Add(&validatingMockWorker{mockWorker: mockWorker{t: t, name: "db"}, err: missingDSN}, flex.WithName("db"))
+
+		var out bytes.Buffer
+		if err := m.DryRun(context.Background(), &out); !errors.Is(err, missingDSN) {
+			t.Errorf("expected %v but got: %v", missingDSN, err)
+		}
+		if line := `worker=db phase=validate error="missing dsn"`; !strings.Contains(out.String(), line) {
+			t.Errorf("expected the line %q but got:\n%s", line, out.String())
+		}
+	})
+	t.Run("invalid dependencies must be reported", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"), flex.WithDependsOn("db"))
+
+		var out bytes.Buffer
+		if err := m.DryRun(context.Background(), &out); !errors.Is(err, flex.ErrDependency) {
+			t.Errorf("expected %v but got: %v", flex.ErrDependency, err)
+		}
+		if !strings.Contains(out.String(), "error=") {
+			t.Errorf("expected the error to be written but got:\n%s", out.String())
+		}
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	t.Setenv(flex.DisableWorkersEnv, "*flex_test.countingMockWorker")
+	t.Setenv(flex.HaltTimeoutEnv, "1s")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	dryRunErr := flex.DryRun(context.Background(), &countingMockWorker{mockWorker: mockWorker{t: t}})
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dryRunErr != nil {
+		t.Fatalf("expected no error but got: %v", dryRunErr)
+	}
+
+	ctx, cancel := defaultCtx()
+	defer cancel()
+	worker := &countingMockWorker{mockWorker: mockWorker{t: t}}
+	go func() {
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+		cancel()
+	}()
+	if err := flex.Start(ctx, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start ignores the environment, and so must the plan it prints.
+	if line := "plan workers=1\n"; !strings.HasPrefix(string(out), line) {
+		t.Errorf("expected the line %q but got:\n%s", line, out)
+	}
+	if strings.Contains(string(out), "disabled") {
+		t.Errorf("expected no disabled worker but got:\n%s", out)
+	}
+}
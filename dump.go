@@ -0,0 +1,142 @@
+package flex
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dump writes a diagnostic dump of the manager to w, one line of key=value
+// pairs for the manager, holding its uptime and the number of goroutines, then
+// one for every worker, holding its name, state, uptime, restarts and last
+// error, followed by its details if it implements Describer. Namespaces are
+// reported with their state and last error, followed by their workers.
+func (m *Manager) Dump(w io.Writer) error {
+	entries := m.dumpEntries()
+	lines := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		line := entry.line()
+		if i == 0 {
+			line = "dump " + line
+		}
+		lines = append(lines, line)
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// dumpEntry is an entry of a diagnostic dump, which is written as a line of
+// key=value pairs, or logged as a message with attributes.
+type dumpEntry struct {
+	msg   string
+	attrs []slog.Attr
+}
+
+// line returns the attributes of the entry as key=value pairs.
+func (e dumpEntry) line() string {
+	pairs := make([]string, 0, len(e.attrs))
+	for _, attr := range e.attrs {
+		pairs = append(pairs, dumpValue(attr.Key)+"="+dumpValue(attr.Value.String()))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// dumpEntries returns the entries of a diagnostic dump, the manager's first.
+func (m *Manager) dumpEntries() []dumpEntry {
+	now := time.Now()
+
+	m.mu.Lock()
+	startedAt := m.startedAt
+	m.mu.Unlock()
+
+	var uptime time.Duration
+	if !startedAt.IsZero() {
+		uptime = now.Sub(startedAt)
+	}
+
+	entries := []dumpEntry{{msg: "dump", attrs: []slog.Attr{
+		slog.Duration("uptime", uptime.Round(time.Millisecond)),
+		slog.Int("goroutines", runtime.NumGoroutine()),
+		slog.Int("workers", len(m.Workers())),
+	}}}
+
+	for _, worker := range m.workers {
+		if nw, ok := worker.Worker.(*namespaceWorker); ok {
+			entries = append(entries, nw.ns.dumpEntries()...)
+			continue
+		}
+
+		status := worker.snapshot()
+
+		attrs := []slog.Attr{
+			slog.String("worker", worker.name()),
+			slog.String("state", status.state.String()),
+			slog.Duration("uptime", status.uptime(now).Round(time.Millisecond)),
+		}
+		if status.restarts > 0 {
+			attrs = append(attrs, slog.Int("restarts", status.restarts))
+		}
+		if status.lastErr != nil {
+			attrs = append(attrs, slog.String("last_error", status.lastErr.Error()))
+		}
+		if describer, ok := worker.Worker.(Describer); ok {
+			details := describer.Describe()
+			for _, key := range slices.Sorted(maps.Keys(details)) {
+				attrs = append(attrs, slog.String(key, details[key]))
+			}
+		}
+		entries = append(entries, dumpEntry{msg: "worker dump", attrs: attrs})
+	}
+
+	return entries
+}
+
+// dumpEntries returns the entries reporting the namespace in the diagnostic
+// dump of its parent manager.
+func (ns *Namespace) dumpEntries() []dumpEntry {
+	state, err := ns.state()
+
+	attrs := []slog.Attr{
+		slog.String("namespace", ns.name),
+		slog.String("state", state.String()),
+		slog.Int("workers", len(ns.Workers())),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("last_error", err.Error()))
+	}
+
+	entries := []dumpEntry{{msg: "namespace dump", attrs: attrs}}
+	for _, worker := range ns.m.dumpEntries()[1:] {
+		worker.attrs = append([]slog.Attr{slog.String("namespace", ns.name)}, worker.attrs...)
+		entries = append(entries, worker)
+	}
+	return entries
+}
+
+// dumpValue returns s, quoted if it would not otherwise be read back as a
+// single value.
+func dumpValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logDump writes a diagnostic dump to the logger, one entry for the manager
+// and one for every worker, with their fields as attributes.
+func (m *Manager) logDump(ctx context.Context) error {
+	for _, entry := range m.dumpEntries() {
+		args := make([]any, len(entry.attrs))
+		for i, attr := range entry.attrs {
+			args[i] = attr
+		}
+		m.log(ctx, slog.LevelInfo, entry.msg, args...)
+	}
+	return nil
+}
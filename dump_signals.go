@@ -0,0 +1,12 @@
+//go:build !js && !plan9
+
+package flex
+
+import (
+	"os"
+	"syscall"
+)
+
+// DefaultDumpSignals are the signals which write a diagnostic dump unless
+// configured otherwise with WithDumpSignals.
+var DefaultDumpSignals = []os.Signal{syscall.SIGQUIT}
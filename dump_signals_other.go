@@ -0,0 +1,9 @@
+//go:build js || plan9
+
+package flex
+
+import "os"
+
+// DefaultDumpSignals are the signals which write a diagnostic dump unless
+// configured otherwise with WithDumpSignals. There are none on this platform.
+var DefaultDumpSignals []os.Signal
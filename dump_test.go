@@ -0,0 +1,109 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManagerDump(t *testing.T) {
+	t.Run("a dump must hold the state of the manager and its workers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true})
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}}, flex.WithStartedOnReady())
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		var dump string
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			var buf bytes.Buffer
+			if err := m.Dump(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if dump = buf.String(); strings.Contains(dump, "state=running") {
+				break
+			}
+		}
+		cancel()
+
+		lines := strings.Split(strings.TrimSpace(dump), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines but got: %q", dump)
+		}
+		if !strings.Contains(lines[0], "goroutines=") || !strings.Contains(lines[0], "workers=2") {
+			t.Errorf("unexpected manager line: %q", lines[0])
+		}
+		if !strings.Contains(lines[1], "worker=*flex_test.blockingMockWorker state=running") {
+			t.Errorf("unexpected worker line: %q", lines[1])
+		}
+		if !strings.Contains(lines[2], "state=starting") {
+			t.Errorf("unexpected worker line: %q", lines[2])
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a dump must hold the last error of failed workers", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "foo"}})
+		_ = m.Start(context.Background())
+
+		var buf bytes.Buffer
+		if err := m.Dump(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range []string{"state=failed", `last_error="run failed"`} {
+			if !strings.Contains(buf.String(), field) {
+				t.Errorf("expected %s to be dumped but got: %q", field, buf.String())
+			}
+		}
+	})
+	t.Run("workers must be idle before the manager starts", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New()
+		m.Add(&mockWorker{t: t, name: "foo"})
+
+		var buf bytes.Buffer
+		if err := m.Dump(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "state=idle") {
+			t.Errorf("expected the worker to be idle but got: %q", buf.String())
+		}
+	})
+	t.Run("a dump must hold the details of describers", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New()
+		m.Add(&describingMockWorker{mockWorker{t: t, name: "foo"}})
+
+		var buf bytes.Buffer
+		if err := m.Dump(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `state=idle uptime=0s conns=3 peer="a b"`) {
+			t.Errorf("expected the details to be dumped but got: %q", buf.String())
+		}
+	})
+}
+
+// describingMockWorker describes its connections.
+type describingMockWorker struct{ mockWorker }
+
+func (w *describingMockWorker) Describe() map[string]string {
+	return map[string]string{"peer": "a b", "conns": "3"}
+}
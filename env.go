@@ -0,0 +1,180 @@
+package flex
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by New, so that operators can tune the
+// lifecycle of a service per deployment without rebuilding it. Options given
+// to New take precedence over them.
+const (
+	// StartTimeoutEnv sets the start timeout as a duration, such as "10s",
+	// see WithStartTimeout.
+	StartTimeoutEnv = "FLEX_START_TIMEOUT"
+	// HaltTimeoutEnv sets the halt timeout as a duration, see
+	// WithHaltTimeout.
+	HaltTimeoutEnv = "FLEX_HALT_TIMEOUT"
+	// ShutdownTimeoutEnv is an alias of HaltTimeoutEnv, matching
+	// ShutdownTimeoutFlag, which is read unless HaltTimeoutEnv is set.
+	ShutdownTimeoutEnv = "FLEX_SHUTDOWN_TIMEOUT"
+	// ShutdownDelayEnv sets the shutdown delay as a duration, see
+	// WithShutdownDelay.
+	ShutdownDelayEnv = "FLEX_SHUTDOWN_DELAY"
+	// SignalsEnv sets the signals which shut the manager down, as a comma
+	// separated list of names, such as "SIGTERM,SIGINT", or numbers, or
+	// "none", see WithSignals.
+	SignalsEnv = "FLEX_SIGNALS"
+	// ReloadSignalsEnv sets the signals which reload the workers, as
+	// SignalsEnv does, see WithReloadSignals.
+	ReloadSignalsEnv = "FLEX_RELOAD_SIGNALS"
+	// DumpSignalsEnv sets the signals which write a diagnostic dump, as
+	// SignalsEnv does, see WithDumpSignals.
+	DumpSignalsEnv = "FLEX_DUMP_SIGNALS"
+	// LogLevelEnv sets the minimum level of the lifecycle logs, such as
+	// "warn", see WithLogLevel.
+	LogLevelEnv = "FLEX_LOG_LEVEL"
+	// EnableWorkersEnv sets the only workers to run, as a comma separated
+	// list of names, such as "api,consumer", see WithEnabledWorkers.
+	EnableWorkersEnv = "FLEX_ENABLE_WORKERS"
+	// DisableWorkersEnv sets the workers to skip, as a comma separated list
+	// of names, such as "pprof,cron", see WithDisabledWorkers.
+	DisableWorkersEnv = "FLEX_DISABLE_WORKERS"
+	// ProfileEnv sets the profile, such as "prod", whose options apply
+	// before those of the other environment variables, see WithProfile.
+	ProfileEnv = "FLEX_PROFILE"
+)
+
+// envOptions returns the options set by the environment variables, ignoring,
+// and logging, those which cannot be parsed.
+func envOptions() []Option {
+	var opts []Option
+
+	if v := os.Getenv(ProfileEnv); v != "" {
+		opts = append(opts, WithProfile(v))
+	}
+
+	for env, option := range map[string]func(time.Duration) Option{
+		StartTimeoutEnv:  WithStartTimeout,
+		HaltTimeoutEnv:   WithHaltTimeout,
+		ShutdownDelayEnv: WithShutdownDelay,
+	} {
+		v := os.Getenv(env)
+		if v == "" && env == HaltTimeoutEnv {
+			env, v = ShutdownTimeoutEnv, os.Getenv(ShutdownTimeoutEnv)
+		}
+		if v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				logger.Printf("ignoring %s=%q: not a duration", env, v)
+				continue
+			}
+			opts = append(opts, option(d))
+		}
+	}
+
+	for env, option := range map[string]func(...os.Signal) Option{
+		SignalsEnv:       WithSignals,
+		ReloadSignalsEnv: WithReloadSignals,
+		DumpSignalsEnv:   WithDumpSignals,
+	} {
+		if v := os.Getenv(env); v != "" {
+			sigs, err := parseSignals(v)
+			if err != nil {
+				logger.Printf("ignoring %s=%q: %v", env, v, err)
+				continue
+			}
+			opts = append(opts, option(sigs...))
+		}
+	}
+
+	if v := os.Getenv(LogLevelEnv); v != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			logger.Printf("ignoring %s=%q: not a log level", LogLevelEnv, v)
+		} else {
+			opts = append(opts, WithLogLevel(level))
+		}
+	}
+
+	for env, option := range map[string]func(...string) Option{
+		EnableWorkersEnv:  WithEnabledWorkers,
+		DisableWorkersEnv: WithDisabledWorkers,
+	} {
+		if v := os.Getenv(env); v != "" {
+			opts = append(opts, option(parseNames(v)...))
+		}
+	}
+
+	return opts
+}
+
+// parseSignals parses a comma separated list of signal names or numbers, or
+// "none" for no signal.
+func parseSignals(v string) ([]os.Signal, error) {
+	if strings.EqualFold(strings.TrimSpace(v), "none") {
+		return nil, nil
+	}
+
+	var sigs []os.Signal
+	for _, name := range strings.Split(v, ",") {
+		sig, err := parseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// parseSignal parses the name of a signal, with or without its SIG prefix,
+// or its number.
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		if sig, ok := numberedSignal(n); ok {
+			return sig, nil
+		}
+	}
+	sig, ok := signalNames[name]
+	if !ok {
+		sig, ok = signalNames["SIG"+name]
+	}
+	if !ok {
+		return nil, &unknownSignalError{name: name}
+	}
+	return sig, nil
+}
+
+// signalName returns the name of sig, see signalNames, or its number for the
+// signals which have no name there.
+func signalName(sig os.Signal) (string, bool) {
+	for name, named := range signalNames {
+		if named == sig {
+			return name, true
+		}
+	}
+	if n, ok := signalNumber(sig); ok {
+		return strconv.Itoa(n), true
+	}
+	return "", false
+}
+
+// parseNames parses a comma separated list of names, ignoring blank ones.
+func parseNames(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// unknownSignalError reports a signal name which is not known.
+type unknownSignalError struct{ name string }
+
+func (e *unknownSignalError) Error() string { return "unknown signal " + strconv.Quote(e.name) }
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package flex
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames are the signals which may be given by name in environment
+// variables, see SignalsEnv, and which manifests describe by name.
+var signalNames = map[string]os.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package flex
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames are the signals which may be given by name in environment
+// variables, see SignalsEnv, and which manifests describe by name.
+var signalNames = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
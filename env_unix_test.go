@@ -0,0 +1,93 @@
+//go:build unix
+
+package flex_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestEnvironment(t *testing.T) {
+	t.Run("defaults must be read from the environment", func(t *testing.T) {
+		t.Setenv(flex.StartTimeoutEnv, "10s")
+		t.Setenv(flex.HaltTimeoutEnv, "20s")
+		t.Setenv(flex.SignalsEnv, "SIGTERM, usr1,2")
+		t.Setenv(flex.ReloadSignalsEnv, "none")
+		t.Setenv(flex.LogLevelEnv, "warn")
+
+		manifest := flex.New().Manifest()
+		if manifest.StartTimeout != "10s" || manifest.HaltTimeout != "20s" {
+			t.Errorf("expected the timeouts to be read but got: %s and %s", manifest.StartTimeout, manifest.HaltTimeout)
+		}
+		if want := []string{"SIGTERM", "SIGUSR1", "SIGINT"}; !slices.Equal(manifest.Signals, want) {
+			t.Errorf("expected %v but got: %v", want, manifest.Signals)
+		}
+		if len(manifest.ReloadSignals) != 0 {
+			t.Errorf("expected no reload signal but got: %v", manifest.ReloadSignals)
+		}
+	})
+	t.Run("options must take precedence over the environment", func(t *testing.T) {
+		t.Setenv(flex.StartTimeoutEnv, "10s")
+		t.Setenv(flex.SignalsEnv, "SIGHUP")
+
+		manifest := flex.New(flex.WithStartTimeout(time.Second), flex.WithSignals()).Manifest()
+		if manifest.StartTimeout != "1s" || len(manifest.Signals) != 0 {
+			t.Errorf("expected the options to win but got: %s and %v", manifest.StartTimeout, manifest.Signals)
+		}
+	})
+	t.Run("the shutdown timeout must be read as the halt timeout", func(t *testing.T) {
+		t.Setenv(flex.ShutdownTimeoutEnv, "15s")
+
+		if manifest := flex.New().Manifest(); manifest.HaltTimeout != "15s" {
+			t.Errorf("expected %q but got: %q", "15s", manifest.HaltTimeout)
+		}
+		if manifest := flex.New(flex.WithHaltTimeout(time.Second)).Manifest(); manifest.HaltTimeout != "1s" {
+			t.Errorf("expected the option to win but got: %q", manifest.HaltTimeout)
+		}
+
+		t.Setenv(flex.HaltTimeoutEnv, "20s")
+		if manifest := flex.New().Manifest(); manifest.HaltTimeout != "20s" {
+			t.Errorf("expected %s to win but got: %q", flex.HaltTimeoutEnv, manifest.HaltTimeout)
+		}
+	})
+	t.Run("start must not be configured from the environment", func(t *testing.T) {
+		t.Setenv(flex.DisableWorkersEnv, "*flex_test.blockingMockWorker")
+		t.Setenv(flex.HaltTimeoutEnv, "1ns")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := flex.Start(ctx, &blockingMockWorker{mockWorker: mockWorker{t: t}}); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("workers must be disabled from the environment", func(t *testing.T) {
+		t.Setenv(flex.DisableWorkersEnv, "pprof, cron,")
+
+		m := flex.New()
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+		m.Add(&mockWorker{t: t, name: "pprof"}, flex.WithName("pprof"))
+		m.Add(&mockWorker{t: t, name: "cron"}, flex.WithName("cron"))
+
+		if want := []string{"pprof", "cron"}; !slices.Equal(m.DisabledWorkers(), want) {
+			t.Errorf("expected %v but got: %v", want, m.DisabledWorkers())
+		}
+	})
+	t.Run("invalid values must be ignored", func(t *testing.T) {
+		t.Setenv(flex.StartTimeoutEnv, "soon")
+		t.Setenv(flex.SignalsEnv, "SIGNOPE")
+		t.Setenv(flex.LogLevelEnv, "loud")
+
+		manifest := flex.New().Manifest()
+		if manifest.StartTimeout != "" {
+			t.Errorf("expected no start timeout but got: %s", manifest.StartTimeout)
+		}
+		if want := flex.New(flex.WithSignals(flex.DefaultSignals...)).Manifest().Signals; !slices.Equal(manifest.Signals, want) {
+			t.Errorf("expected %v but got: %v", want, manifest.Signals)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrNoWorkers is returned when starting a manager without any worker.
+	ErrNoWorkers = errors.New("need at least 1 worker")
+	// ErrNilWorker is returned when starting a manager holding a nil worker.
+	ErrNilWorker = errors.New("received a nil worker")
+	// ErrStartTimeout is returned when a worker did not start within its start timeout.
+	ErrStartTimeout = errors.New("worker did not start in time")
+	// ErrHaltTimeout is returned when a worker did not return from Halt within
+	// the halt timeout.
+	ErrHaltTimeout = errors.New("worker did not halt in time")
+	// ErrNoFactory is returned when booting a manager from a manifest holding
+	// a worker which has no factory.
+	ErrNoFactory = errors.New("no factory for worker")
+	// ErrHeartbeatMissed is the error of workers implementing Heartbeater
+	// which missed a heartbeat.
+	ErrHeartbeatMissed = errors.New("worker missed its heartbeat")
+	// ErrUnhealthy is the error of workers restarted as they were unhealthy
+	// for longer than their threshold, see WithRestartWhenUnhealthy.
+	ErrUnhealthy = errors.New("worker was unhealthy")
+	// ErrManifestVersion is returned when reading a manifest written with an
+	// unsupported version of the format.
+	ErrManifestVersion = errors.New("unsupported manifest version")
+	// ErrInvalidManifest is returned when booting a manager from a manifest
+	// describing workers without a name, or sharing one.
+	ErrInvalidManifest = errors.New("invalid manifest")
+	// ErrDependency is returned when starting a manager holding a worker
+	// which depends on an unknown worker, or on itself through other workers,
+	// see WithDependsOn.
+	ErrDependency = errors.New("invalid worker dependency")
+)
+
+// DefaultIgnoredErrors are the errors which workers commonly return once they
+// are shut down, and which are ignored during a shutdown unless configured
+// otherwise with WithIgnoredErrors.
+var DefaultIgnoredErrors = []error{context.Canceled, http.ErrServerClosed, net.ErrClosed}
+
+// Phase identifies the part of a worker's lifecycle an error happened in.
+type Phase int
+
+const (
+	// PhaseRun is the phase of errors returned by Run, and of workers which
+	// did not start within their start timeout.
+	PhaseRun Phase = iota + 1
+	// PhaseHalt is the phase of errors returned by Halt.
+	PhaseHalt
+	// PhaseReload is the phase of errors returned by Reload.
+	PhaseReload
+	// PhaseValidate is the phase of errors returned by Validate, see
+	// Validator.
+	PhaseValidate
+)
+
+// String returns a string representation of the Phase.
+func (p Phase) String() string {
+	switch p {
+	case PhaseRun:
+		return "run"
+	case PhaseHalt:
+		return "halt"
+	case PhaseReload:
+		return "reload"
+	case PhaseValidate:
+		return "validate"
+	default:
+		return "unknown"
+	}
+}
+
+// WorkerError is an error returned by a worker, annotated with the name of
+// the worker and the phase it happened in. Every error of a worker returned
+// by Start and Reload is a *WorkerError, which errors.As extracts.
+type WorkerError struct {
+	// Worker is the name of the worker, see WithName.
+	Worker string
+	Phase  Phase
+	Err    error
+
+	// raw makes Error return the message of Err alone, as the errors
+	// returned by the Start function always have.
+	raw bool
+}
+
+// Error returns a string representation of the WorkerError.
+func (e *WorkerError) Error() string {
+	if e.raw {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("worker %q: %s phase: %v", e.Worker, e.Phase, e.Err)
+}
+
+// Unwrap returns the error returned by the worker.
+func (e *WorkerError) Unwrap() error { return e.Err }
+
+// PhaseErrors returns the errors held by err, a MultiError or errors joined
+// with errors.Join as returned by Manager.Start and Manager.Reload, which are
+// annotated as a *WorkerError of phase. Errors which are not annotated, such
+// as ErrNoWorkers, belong to no phase.
+func PhaseErrors(err error, phase Phase) []error {
+	errs := []error{err}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = multi.Unwrap()
+	}
+
+	var matched []error
+	for _, err := range errs {
+		var werr *WorkerError
+		if errors.As(err, &werr) && werr.Phase == phase {
+			matched = append(matched, err)
+		}
+	}
+	return matched
+}
+
+// annotate wraps err, if not nil, in a *WorkerError, whose message is that of
+// err if the manager returns raw errors. Errors of namespaces are not, as
+// those of their workers already are.
+func (m *Manager) annotate(worker *managedWorker, phase Phase, err error) error {
+	if err == nil {
+		return err
+	}
+	if _, ok := worker.Worker.(*namespaceWorker); ok {
+		return err
+	}
+	return &WorkerError{Worker: worker.name(), Phase: phase, Err: err, raw: m.opts.rawErrors}
+}
+
+// ignored reports whether err is one of the ignored errors, returned once
+// the shutdown signalled by the cancellation of ctx was requested.
+func (m *Manager) ignored(ctx context.Context, err error) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	for _, ignored := range m.opts.ignoredErrors {
+		if errors.Is(err, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorHandler is called with the name of the worker, the phase and the
+// error whenever a worker fails, see WithErrorHandler.
+type ErrorHandler func(workerName string, phase Phase, err error)
+
+// ErrorReporter is called with the context of the failure, the name of the
+// worker, the phase and the error whenever a worker fails, before the error
+// handler, see WithErrorReporter.
+type ErrorReporter func(ctx context.Context, workerName string, phase Phase, err error)
+
+// handleError passes err, if not nil, to the error reporter and then the error
+// handler, if any. Errors of namespaces are not, as those of their workers
+// already were.
+func (m *Manager) handleError(ctx context.Context, worker *managedWorker, phase Phase, err error) {
+	if err == nil || (m.opts.errorReporter == nil && m.opts.errorHandler == nil) {
+		return
+	}
+	if _, ok := worker.Worker.(*namespaceWorker); ok {
+		return
+	}
+	if m.opts.errorReporter != nil {
+		m.opts.errorReporter(ctx, worker.name(), phase, err)
+	}
+	if m.opts.errorHandler != nil {
+		m.opts.errorHandler(worker.name(), phase, err)
+	}
+}
+
+// name returns the name of the worker, as set with WithName, defaulting to its type.
+func (w *managedWorker) name() string {
+	if w.opts.name != "" {
+		return w.opts.name
+	}
+	if nw, ok := w.Worker.(*namespaceWorker); ok {
+		return "namespace " + nw.ns.name
+	}
+	if cw, ok := w.Worker.(*closerWorker); ok {
+		return cw.name
+	}
+	return fmt.Sprintf("%T", w.Worker)
+}
@@ -0,0 +1,307 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// handledError is an error passed to the error handler.
+type handledError struct {
+	worker string
+	phase  flex.Phase
+	err    string
+}
+
+// recordingErrorHandler returns an error handler recording the errors it is
+// passed, and a function returning them sorted.
+func recordingErrorHandler() (flex.ErrorHandler, func() []handledError) {
+	var (
+		mu      sync.Mutex
+		handled []handledError
+	)
+	handler := func(worker string, phase flex.Phase, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, handledError{worker: worker, phase: phase, err: err.Error()})
+	}
+	return handler, func() []handledError {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.SortedFunc(slices.Values(handled), func(a, b handledError) int {
+			return strings.Compare(a.worker+a.err, b.worker+b.err)
+		})
+	}
+}
+
+func TestManagerErrorHandler(t *testing.T) {
+	t.Run("run and halt errors must be handled as they happen", func(t *testing.T) {
+		t.Parallel()
+
+		handler, handled := recordingErrorHandler()
+
+		m := flex.New(flex.WithSignals(), flex.WithErrorHandler(handler))
+		m.Add(newHaltFailingMockWorker(t, "foo"), flex.WithName("foo"))
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "bar"}})
+
+		if err := m.Start(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		expected := []handledError{
+			{worker: "*flex_test.failingMockWorker", phase: flex.PhaseRun, err: "run failed"},
+			{worker: "foo", phase: flex.PhaseHalt, err: "foo halt failed"},
+			{worker: "foo", phase: flex.PhaseRun, err: "foo run failed"},
+		}
+		if got := handled(); !slices.Equal(got, expected) {
+			t.Errorf("expected %v but got: %v", expected, got)
+		}
+	})
+	t.Run("reload errors must be handled", func(t *testing.T) {
+		t.Parallel()
+
+		handler, handled := recordingErrorHandler()
+
+		m := flex.New(flex.WithErrorHandler(handler))
+		m.Add(&reloadingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, fail: true}, flex.WithName("foo"))
+
+		if err := m.Reload(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		expected := []handledError{{worker: "foo", phase: flex.PhaseReload, err: "reload failed"}}
+		if got := handled(); !slices.Equal(got, expected) {
+			t.Errorf("expected %v but got: %v", expected, got)
+		}
+	})
+	t.Run("errors of namespaced workers must be handled once", func(t *testing.T) {
+		t.Parallel()
+
+		handler, handled := recordingErrorHandler()
+
+		m := flex.New(flex.WithSignals(), flex.WithErrorHandler(handler))
+		if err := m.Namespace("jobs").Add(&failingMockWorker{mockWorker{t: t, name: "foo"}}, flex.WithName("foo")); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return len(handled()) > 0 })
+		cancel()
+		if err := <-errC; err == nil {
+			t.Error("expected the error of the namespace to be returned")
+		}
+
+		expected := []handledError{{worker: "foo", phase: flex.PhaseRun, err: "run failed"}}
+		if got := handled(); !slices.Equal(got, expected) {
+			t.Errorf("expected %v but got: %v", expected, got)
+		}
+	})
+	t.Run("workers must be dumped by name", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New()
+		m.Add(&mockWorker{t: t, name: "foo"}, flex.WithName("foo"))
+		m.Add(&mockWorker{t: t, name: "bar"}, flex.WithName("bar worker"))
+
+		var buf bytes.Buffer
+		if err := m.Dump(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "worker=foo state=idle") || !strings.Contains(buf.String(), `worker="bar worker" state=idle`) {
+			t.Errorf("expected the workers to be dumped by name but got: %q", buf.String())
+		}
+	})
+}
+
+func TestWorkerError(t *testing.T) {
+	t.Run("errors must be annotated with their worker and phase", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(newHaltFailingMockWorker(t, "foo"), flex.WithName("foo"))
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "bar"}}, flex.WithName("bar"))
+
+		err := m.Start(context.Background())
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected an error of type %T, but got: %T", merr, err)
+		}
+
+		var annotated []string
+		for _, err := range merr.Errors {
+			var werr *flex.WorkerError
+			if !errors.As(err, &werr) {
+				t.Fatalf("expected an error of type %T, but got: %T", werr, err)
+			}
+			annotated = append(annotated, werr.Error())
+		}
+		slices.Sort(annotated)
+
+		expected := []string{
+			`worker "bar": run phase: run failed`,
+			`worker "foo": halt phase: foo halt failed`,
+			`worker "foo": run phase: foo run failed`,
+		}
+		if !slices.Equal(annotated, expected) {
+			t.Errorf("expected %q but got: %q", expected, annotated)
+		}
+	})
+	t.Run("reload errors must be annotated", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New()
+		m.Add(&reloadingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, fail: true}, flex.WithName("foo"))
+
+		var werr *flex.WorkerError
+		if err := m.Reload(context.Background()); !errors.As(err, &werr) {
+			t.Fatalf("expected an error of type %T, but got: %T", werr, err)
+		}
+		if werr.Worker != "foo" || werr.Phase != flex.PhaseReload || werr.Err.Error() != "reload failed" {
+			t.Errorf("unexpected error: %+v", werr)
+		}
+	})
+}
+
+// closingMockWorker returns err from Run once its context is done, or right
+// away when early is set, and haltErr from Halt.
+type closingMockWorker struct {
+	mockWorker
+	err, haltErr error
+	early        bool
+}
+
+func (c *closingMockWorker) Run(ctx context.Context) error {
+	if !c.early {
+		<-ctx.Done()
+	}
+	return c.err
+}
+
+func (c *closingMockWorker) Halt(context.Context) error { return c.haltErr }
+
+func TestManagerIgnoredErrors(t *testing.T) {
+	t.Run("expected errors must be ignored during a shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&closingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: http.ErrServerClosed, haltErr: context.Canceled})
+
+		if err := m.Start(ctx); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("expected errors must not be ignored before a shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&closingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: http.ErrServerClosed, early: true})
+
+		if err := m.Start(ctx); !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected %v but got: %v", http.ErrServerClosed, err)
+		}
+	})
+	t.Run("the ignored errors must be configurable", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		errIgnored := errors.New("ignored")
+
+		m := flex.New(flex.WithSignals(), flex.WithIgnoredErrors(errIgnored))
+		m.Add(&closingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: http.ErrServerClosed, haltErr: fmt.Errorf("wrapped: %w", errIgnored)})
+
+		err := m.Start(ctx)
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) || len(merr.Errors) != 1 || !errors.Is(merr.Errors[0], http.ErrServerClosed) {
+			t.Errorf("expected only %v but got: %v", http.ErrServerClosed, err)
+		}
+	})
+	t.Run("start must ignore the default errors during a shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := flex.Start(ctx, &closingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: http.ErrServerClosed})
+		if err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+}
+
+func TestPhaseErrors(t *testing.T) {
+	t.Run("run and halt errors must be told apart", func(t *testing.T) {
+		t.Parallel()
+
+		for _, opts := range [][]flex.Option{
+			{flex.WithSignals()},
+			{flex.WithSignals(), flex.WithJoinedErrors()},
+		} {
+			m := flex.New(opts...)
+			m.Add(newHaltFailingMockWorker(t, "foo"), flex.WithName("foo"))
+			m.Add(&failingMockWorker{mockWorker{t: t, name: "bar"}}, flex.WithName("bar"))
+
+			err := m.Start(context.Background())
+
+			if got := flex.PhaseErrors(err, flex.PhaseRun); len(got) != 2 {
+				t.Errorf("expected 2 run errors but got: %v", got)
+			}
+			halt := flex.PhaseErrors(err, flex.PhaseHalt)
+			if len(halt) != 1 || halt[0].Error() != `worker "foo": halt phase: foo halt failed` {
+				t.Errorf("expected a single halt error but got: %v", halt)
+			}
+
+			var merr flex.MultiError
+			if errors.As(err, &merr) && (len(merr.RunErrors()) != 2 || len(merr.HaltErrors()) != 1) {
+				t.Errorf("expected 2 run errors and 1 halt error but got: %v and %v", merr.RunErrors(), merr.HaltErrors())
+			}
+		}
+	})
+	t.Run("the errors of Start must be told apart without annotated messages", func(t *testing.T) {
+		t.Parallel()
+
+		err := flex.Start(context.Background(), newHaltFailingMockWorker(t, "foo"), &failingMockWorker{mockWorker{t: t, name: "bar"}})
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected a MultiError but got: %v", err)
+		}
+		if run := merr.RunErrors(); len(run) != 2 {
+			t.Errorf("expected 2 run errors but got: %v", run)
+		}
+		halt := merr.HaltErrors()
+		if len(halt) != 1 || halt[0].Error() != "foo halt failed" {
+			t.Errorf("expected a single halt error but got: %v", halt)
+		}
+	})
+	t.Run("errors without a phase must not match", func(t *testing.T) {
+		t.Parallel()
+
+		if got := flex.PhaseErrors(errors.New("foo"), flex.PhaseRun); len(got) != 0 {
+			t.Errorf("expected no errors but got: %v", got)
+		}
+		if got := flex.PhaseErrors(nil, flex.PhaseRun); len(got) != 0 {
+			t.Errorf("expected no errors but got: %v", got)
+		}
+	})
+}
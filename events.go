@@ -0,0 +1,157 @@
+package flex
+
+import (
+	"os"
+	"time"
+)
+
+// EventBuffer is how many events a subscriber may lag behind before further
+// events are dropped for it.
+const EventBuffer = 64
+
+// EventKind identifies a lifecycle event of a Manager.
+//
+// A worker is run as EventWorkerStarting, EventWorkerStarted once it is
+// running, then either EventWorkerStopped or EventWorkerFailed, and
+// EventWorkerHalted once halted. A shutdown is EventShutdownBegan followed by
+// EventShutdownFinished.
+type EventKind int
+
+const (
+	// EventSignalReceived is emitted when the manager receives a signal it
+	// handles, before acting on it.
+	EventSignalReceived EventKind = iota + 1
+	// EventWorkerStarted is emitted when a worker starts, that is enters Run,
+	// or calls Ready if it waits for it, see WithStartedOnReady.
+	EventWorkerStarted
+	// EventWorkerFailed is emitted when a worker returns an error from Run,
+	// or does not start within its start timeout.
+	EventWorkerFailed
+	// EventShutdownBegan is emitted once a shutdown has been requested,
+	// before the policy is consulted and any worker is halted.
+	EventShutdownBegan
+	// EventShutdownFinished is emitted once every worker has returned from
+	// Run and Halt, it is the last event of a run of the manager.
+	EventShutdownFinished
+	// EventHealthChanged is emitted when the health status of a worker
+	// differs from the one last observed by Manager.Health, see
+	// WithHealthInterval.
+	EventHealthChanged
+	// EventWorkerStarting is emitted before Run is called on a worker,
+	// including when it is restarted.
+	EventWorkerStarting
+	// EventWorkerStopped is emitted when a worker returns from Run without
+	// an error, or with one ignored during a shutdown.
+	EventWorkerStopped
+	// EventReloaded is emitted once Reload has been called on every worker
+	// implementing Reloader.
+	EventReloaded
+	// EventWorkerHalted is emitted when a worker returns from Halt, or is
+	// abandoned as it did not in time.
+	EventWorkerHalted
+)
+
+// String returns a string representation of the EventKind.
+func (k EventKind) String() string {
+	switch k {
+	case EventSignalReceived:
+		return "signal received"
+	case EventWorkerStarted:
+		return "worker started"
+	case EventWorkerFailed:
+		return "worker failed"
+	case EventShutdownBegan:
+		return "shutdown began"
+	case EventShutdownFinished:
+		return "shutdown finished"
+	case EventHealthChanged:
+		return "health changed"
+	case EventWorkerStarting:
+		return "worker starting"
+	case EventWorkerStopped:
+		return "worker stopped"
+	case EventReloaded:
+		return "reloaded"
+	case EventWorkerHalted:
+		return "worker halted"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a lifecycle event of a Manager.
+type Event struct {
+	Kind EventKind
+	// Time is when the event happened.
+	Time time.Time
+	// Worker is the worker the event is about, if any.
+	Worker Worker
+	// WorkerName is the name of the worker, see WithName.
+	WorkerName string
+	// Signal is the signal which was received.
+	Signal os.Signal
+	// Err is the error of a failed worker, the cause of a shutdown, the
+	// error Start returns once the shutdown has finished, the error Reload
+	// returned, or why the health of a worker changed, if known.
+	Err error
+	// Restarts is how many times a starting worker was restarted before.
+	Restarts int
+	// Duration is how long a started worker took to start, or a halted
+	// worker to halt.
+	Duration time.Duration
+	// OldStatus and NewStatus are the health statuses of a worker whose health
+	// changed. OldStatus is zero when its health was not observed before.
+	OldStatus, NewStatus HealthStatus
+}
+
+// Subscribe returns a channel receiving the events of the running, or next,
+// Start of the manager, it is the integration point for reacting to the
+// lifecycle of the manager. The channel is closed once the shutdown has finished.
+// Events are never waited on: those which do not fit in the EventBuffer of a
+// lagging subscriber are dropped, see Observe to receive every event.
+func (m *Manager) Subscribe() <-chan Event {
+	events := make(chan Event, EventBuffer)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, events)
+	return events
+}
+
+// Observe registers fn to be called with every event of the manager, over
+// all its runs, as flexmetrics does to count them. Unlike with Subscribe, no
+// event is dropped: fn is called from the goroutine emitting the event, and
+// must return quickly without waiting on the manager.
+func (m *Manager) Observe(fn func(Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, fn)
+}
+
+// emit sends e to every subscriber, closing their channels once the
+// shutdown has finished, and calls every observer with it.
+func (m *Manager) emit(e Event) {
+	e.Time = time.Now()
+
+	m.mu.Lock()
+	for _, events := range m.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+
+	if e.Kind == EventShutdownFinished {
+		for _, events := range m.subscribers {
+			close(events)
+		}
+		m.subscribers = nil
+	}
+	observers := m.observers
+	m.mu.Unlock()
+
+	// Observers are called without the lock, so that they may query the manager.
+	for _, observe := range observers {
+		observe(e)
+	}
+}
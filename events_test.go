@@ -0,0 +1,188 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// collect returns every event received until the channel is closed.
+func collect(events <-chan flex.Event) []flex.Event {
+	var all []flex.Event
+	for e := range events {
+		all = append(all, e)
+	}
+	return all
+}
+
+// await returns the next event of kind, skipping the others.
+func await(events <-chan flex.Event, kind flex.EventKind) flex.Event {
+	for e := range events {
+		if e.Kind == kind {
+			return e
+		}
+	}
+	return flex.Event{}
+}
+
+// kinds returns the kinds of events.
+func kinds(events []flex.Event) []flex.EventKind {
+	var kinds []flex.EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+func TestManagerSubscribe(t *testing.T) {
+	t.Run("the lifecycle of a worker must be emitted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(worker, flex.WithName("foo"))
+		events := m.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		if e := <-events; e.Kind != flex.EventWorkerStarting || e.Worker != worker || e.WorkerName != "foo" || e.Restarts != 0 {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		if e := <-events; e.Kind != flex.EventWorkerStarted || e.Worker != worker || e.WorkerName != "foo" || e.Time.IsZero() {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		cancel()
+
+		// The worker may return from Run before or after Halt does.
+		all := collect(events)
+		if len(all) != 4 || all[0].Kind != flex.EventShutdownBegan || all[3].Kind != flex.EventShutdownFinished {
+			t.Fatalf("unexpected events: %v", kinds(all))
+		}
+		if !errors.Is(all[0].Err, context.Canceled) {
+			t.Errorf("expected the cancellation to be the cause but got: %v", all[0].Err)
+		}
+		stopped, halted := all[1], all[2]
+		if stopped.Kind == flex.EventWorkerHalted {
+			stopped, halted = halted, stopped
+		}
+		if stopped.Kind != flex.EventWorkerStopped || stopped.Worker != worker {
+			t.Errorf("unexpected event: %+v", stopped)
+		}
+		if halted.Kind != flex.EventWorkerHalted || halted.Worker != worker || halted.Err != nil || halted.Duration < 0 {
+			t.Errorf("unexpected event: %+v", halted)
+		}
+		if err := <-errC; err != nil || all[3].Err != nil {
+			t.Errorf("expected no error but got: %v and %v", err, all[3].Err)
+		}
+	})
+	t.Run("a failing worker must be emitted", func(t *testing.T) {
+		t.Parallel()
+
+		worker := &failingMockWorker{mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(worker)
+		events := m.Subscribe()
+
+		err := m.Start(context.Background())
+
+		all := collect(events)
+		if len(all) != 6 || all[0].Kind != flex.EventWorkerStarting || all[1].Kind != flex.EventWorkerStarted {
+			t.Fatalf("unexpected events: %v", kinds(all))
+		}
+		all = all[2:]
+		if all[0].Kind != flex.EventWorkerFailed || all[0].Worker != worker || all[0].Err == nil {
+			t.Errorf("unexpected event: %+v", all[0])
+		}
+		if all[1].Kind != flex.EventShutdownBegan || all[1].Err != all[0].Err {
+			t.Errorf("unexpected event: %+v", all[1])
+		}
+		if all[2].Kind != flex.EventWorkerHalted || all[2].Worker != worker {
+			t.Errorf("unexpected event: %+v", all[2])
+		}
+		if all[3].Kind != flex.EventShutdownFinished || all[3].Err == nil || all[3].Err.Error() != err.Error() {
+			t.Errorf("unexpected event: %+v", all[3])
+		}
+	})
+	t.Run("a reload must be emitted with its error", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&reloadingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, fail: true})
+		events := m.Subscribe()
+
+		err := m.Reload(context.Background())
+		if e := <-events; e.Kind != flex.EventReloaded || e.Err == nil || e.Err.Error() != err.Error() {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	})
+	t.Run("every subscriber must receive the events", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&mockWorker{t: t, name: "foo"})
+		first, second := m.Subscribe(), m.Subscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_ = m.Start(ctx)
+
+		if a, b := collect(first), collect(second); len(a) == 0 || len(a) != len(b) {
+			t.Errorf("expected both subscribers to receive the same events, but got %v and %v", kinds(a), kinds(b))
+		}
+	})
+}
+
+func TestManagerObserve(t *testing.T) {
+	t.Run("observers must receive every event", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&reloadingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		var reloads int
+		m.Observe(func(e flex.Event) {
+			if e.Kind == flex.EventReloaded {
+				reloads++
+			}
+		})
+
+		for range 2 * flex.EventBuffer {
+			if err := m.Reload(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if reloads != 2*flex.EventBuffer {
+			t.Errorf("expected %d reloads but got: %d", 2*flex.EventBuffer, reloads)
+		}
+	})
+	t.Run("observers must be kept over the runs of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&mockWorker{t: t, name: "foo"})
+
+		var shutdowns int
+		m.Observe(func(e flex.Event) {
+			if e.Kind == flex.EventShutdownFinished {
+				shutdowns++
+			}
+		})
+
+		for range 2 {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_ = m.Start(ctx)
+		}
+		if shutdowns != 2 {
+			t.Errorf("expected 2 shutdowns but got: %d", shutdowns)
+		}
+	})
+}
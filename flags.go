@@ -0,0 +1,77 @@
+package flex
+
+import (
+	"flag"
+	"time"
+)
+
+// Names of the flags registered by RegisterFlags.
+const (
+	// ShutdownTimeoutFlag sets the halt timeout, see WithHaltTimeout.
+	ShutdownTimeoutFlag = "shutdown-timeout"
+	// DisableWorkerFlag disables workers by name or group, see
+	// WithDisabledWorkers. It may be repeated, or hold a comma separated list.
+	DisableWorkerFlag = "disable-worker"
+	// HealthAddrFlag sets the address to serve the health of the service on,
+	// see Flags.HealthAddr.
+	HealthAddrFlag = "health-addr"
+)
+
+// Flags holds the values of the flags registered by RegisterFlags, once they
+// are parsed.
+type Flags struct {
+	// ShutdownTimeout is the value of ShutdownTimeoutFlag.
+	ShutdownTimeout time.Duration
+	// DisabledWorkers is the value of DisableWorkerFlag.
+	DisabledWorkers []string
+	// HealthAddr is the value of HealthAddrFlag, which the manager does not
+	// use itself: it is the address to serve health endpoints on, such as
+	// with flexhealth.New, empty when they should not be served.
+	HealthAddr string
+
+	fs *flag.FlagSet
+}
+
+// RegisterFlags registers the flags tuning the lifecycle of a service on fs,
+// such as flag.CommandLine, with healthAddr as the default of HealthAddrFlag,
+// so that operators can tune it without rebuilding it:
+//
+//	flags := flex.RegisterFlags(flag.CommandLine, ":8081")
+//	flag.Parse()
+//
+//	m := flex.New(flags.Options()...)
+//	m.Add(api, flex.WithName("api"))
+//	if flags.HealthAddr != "" {
+//		m.Add(flexhealth.New(flags.HealthAddr), flex.WithName("health"))
+//	}
+//
+// Binaries built with cobra register them on a flag.FlagSet added to their
+// command with AddGoFlagSet.
+func RegisterFlags(fs *flag.FlagSet, healthAddr string) *Flags {
+	f := &Flags{HealthAddr: healthAddr, fs: fs}
+	fs.DurationVar(&f.ShutdownTimeout, ShutdownTimeoutFlag, 0,
+		"how long each worker is given to halt, such as 30s (default no timeout)")
+	fs.Func(DisableWorkerFlag, "name or group of a worker not to run, may be repeated or comma separated", func(v string) error {
+		f.DisabledWorkers = append(f.DisabledWorkers, parseNames(v)...)
+		return nil
+	})
+	fs.StringVar(&f.HealthAddr, HealthAddrFlag, healthAddr,
+		"address to serve the health endpoints on, none if empty")
+	return f
+}
+
+// Options returns the options set by the flags given on the command line,
+// which take precedence over the environment variables, see
+// StartTimeoutEnv, and are overridden by options following them.
+func (f *Flags) Options() []Option {
+	var opts []Option
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case ShutdownTimeoutFlag:
+			opts = append(opts, WithHaltTimeout(f.ShutdownTimeout))
+		case DisableWorkerFlag:
+			opts = append(opts, WithDisabledWorkers(f.DisabledWorkers...))
+		}
+	})
+	return opts
+}
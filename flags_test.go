@@ -0,0 +1,55 @@
+package flex_test
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	t.Run("flags must map to options", func(t *testing.T) {
+		t.Parallel()
+
+		fs := flag.NewFlagSet("service", flag.ContinueOnError)
+		flags := flex.RegisterFlags(fs, ":8081")
+		if err := fs.Parse([]string{"-shutdown-timeout=30s", "-disable-worker=pprof,cron", "-disable-worker", "debug", "-health-addr=:9090"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if flags.ShutdownTimeout != 30*time.Second || flags.HealthAddr != ":9090" {
+			t.Errorf("unexpected flags: %+v", flags)
+		}
+
+		m := flex.New(flags.Options()...)
+		for _, name := range []string{"api", "pprof", "cron", "debug"} {
+			m.Add(&mockWorker{t: t, name: name}, flex.WithName(name))
+		}
+		if manifest := m.Manifest(); manifest.HaltTimeout != "30s" {
+			t.Errorf("expected the halt timeout to be %s but got: %s", "30s", manifest.HaltTimeout)
+		}
+		if want := []string{"pprof", "cron", "debug"}; !slices.Equal(m.DisabledWorkers(), want) {
+			t.Errorf("expected %v but got: %v", want, m.DisabledWorkers())
+		}
+	})
+	t.Run("flags which are not given must not override options", func(t *testing.T) {
+		t.Parallel()
+
+		fs := flag.NewFlagSet("service", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		flags := flex.RegisterFlags(fs, ":8081")
+		if err := fs.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(flags.Options()) != 0 {
+			t.Errorf("expected no option but got: %d", len(flags.Options()))
+		}
+		if flags.HealthAddr != ":8081" {
+			t.Errorf("expected the default health address but got: %q", flags.HealthAddr)
+		}
+	})
+}
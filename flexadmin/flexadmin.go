@@ -0,0 +1,241 @@
+// Package flexadmin provides a flex worker serving the status of the other
+// workers of its manager, for operators debugging an instance which is up but
+// misbehaving:
+//
+//	flex.MustStart(ctx, api, consumer, flexadmin.New("localhost:9090"))
+//
+// The status is served under:
+//
+//   - /, as an HTML page meant to be read in a browser.
+//   - /status, as JSON in the wire format of flexapi.
+//
+// Each worker is reported with its name, state, uptime, restart count, last
+// error and health:
+//
+//	{"schema_version":1,"workers":[
+//		{"name":"api","state":"running","started_at":"2024-01-02T03:04:05Z","uptime_seconds":3600,"health":"healthy"},
+//		{"name":"consumer","state":"running","started_at":"2024-01-02T03:14:05Z","restarts":2,
+//			"last_error":"broker unreachable","uptime_seconds":3000,"health":"degraded","health_error":"lagging"}
+//	]}
+//
+// As the status reveals the internals of the service, the server is best
+// bound to a private address.
+package flexadmin
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexapi"
+	"github.com/go-flexible/flex/flexhttp"
+)
+
+// DefaultHealthTimeout is how long the workers are given to report their
+// health when no health timeout is configured.
+const DefaultHealthTimeout = 5 * time.Second
+
+// Option configures the admin server.
+type Option func(*options)
+
+type options struct {
+	healthTimeout time.Duration
+	http          []flexhttp.Option
+}
+
+// WithHealthTimeout sets how long the workers are given to report their
+// health, after which those which have not are reported as unhealthy.
+func WithHealthTimeout(d time.Duration) Option {
+	return func(o *options) { o.healthTimeout = d }
+}
+
+// WithServerOptions sets options of the underlying flexhttp.Server, such as
+// its drain timeout.
+func WithServerOptions(opts ...flexhttp.Option) Option {
+	return func(o *options) { o.http = append(o.http, opts...) }
+}
+
+// Server is a flex worker serving the status of the workers of its manager.
+type Server struct {
+	srv *flexhttp.Server
+
+	mu      sync.Mutex
+	manager *flex.Manager
+}
+
+// New returns a worker serving the status endpoints on addr.
+func New(addr string, opts ...Option) *Server {
+	s := &Server{}
+	h := &handler{manager: s.getManager, opts: newOptions(opts)}
+	s.srv = flexhttp.New(&http.Server{Addr: addr, Handler: h.mux()}, h.opts.http...)
+	return s
+}
+
+// Handler returns a handler serving the status endpoints of m, to serve them
+// alongside other endpoints instead of on an address of their own.
+func Handler(m *flex.Manager, opts ...Option) http.Handler {
+	h := &handler{manager: func() *flex.Manager { return m }, opts: newOptions(opts)}
+	return h.mux()
+}
+
+func newOptions(opts []Option) options {
+	o := options{healthTimeout: DefaultHealthTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server) Addr() net.Addr { return s.srv.Addr() }
+
+// Run serves the status of the workers of the manager running the server
+// until it is halted. The worker reports itself ready once it is listening.
+func (s *Server) Run(ctx context.Context) error {
+	m, ok := flex.ManagerFromContext(ctx)
+	if !ok {
+		return errors.New("flexadmin: server must be run by a flex manager")
+	}
+
+	s.mu.Lock()
+	s.manager = m
+	s.mu.Unlock()
+
+	return s.srv.Run(ctx)
+}
+
+// Halt gracefully shuts the server down.
+func (s *Server) Halt(ctx context.Context) error { return s.srv.Halt(ctx) }
+
+// getManager returns the manager running the server.
+func (s *Server) getManager() *flex.Manager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.manager
+}
+
+// handler serves the status endpoints.
+type handler struct {
+	// manager returns the manager whose status is served.
+	manager func() *flex.Manager
+	opts    options
+}
+
+func (h *handler) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.serveHTML)
+	mux.HandleFunc("GET /status", h.serveJSON)
+	return mux
+}
+
+// status returns the status of the workers of the manager, or false when it
+// is not running yet.
+func (h *handler) status(ctx context.Context) (flexapi.Status, bool) {
+	m := h.manager()
+	if m == nil {
+		return flexapi.Status{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.opts.healthTimeout)
+	defer cancel()
+
+	// Both are reported in the order the workers were added.
+	health := m.Health(ctx).Workers
+	statuses := m.Status()
+
+	status := flexapi.Status{SchemaVersion: flexapi.SchemaVersion, Workers: make([]flexapi.WorkerStatus, len(statuses))}
+	for i, ws := range statuses {
+		worker := flexapi.WorkerStatus{
+			Name:          ws.Name,
+			State:         flexapi.State(ws.State.String()),
+			Restarts:      ws.Restarts,
+			UptimeSeconds: ws.Uptime.Seconds(),
+		}
+		if ws.State == flex.StateIdle {
+			worker.State = flexapi.StateUnknown
+		}
+		if !ws.StartedAt.IsZero() {
+			worker.StartedAt = &ws.StartedAt
+		}
+		if ws.LastErr != nil {
+			worker.LastError = ws.LastErr.Error()
+		}
+		if i < len(health) {
+			worker.Health = flexapi.Health(health[i].Status().String())
+			if health[i].Err != nil {
+				worker.HealthError = health[i].Err.Error()
+			}
+		}
+		status.Workers[i] = worker
+	}
+	return status, true
+}
+
+// serveJSON responds with the status of the workers in the wire format of
+// flexapi.
+func (h *handler) serveJSON(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.status(r.Context())
+	if !ok {
+		http.Error(w, "flexadmin: not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = flexapi.Encode(w, status)
+}
+
+// serveHTML responds with the status of the workers as an HTML page.
+func (h *handler) serveHTML(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.status(r.Context())
+	if !ok {
+		http.Error(w, "flexadmin: not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = page.Execute(w, status)
+}
+
+// page renders the status of the workers.
+var page = template.Must(template.New("status").Funcs(template.FuncMap{
+	"uptime": func(seconds float64) time.Duration {
+		return time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>flex status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.healthy { color: #080; }
+.degraded { color: #b60; }
+.unhealthy { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Workers</h1>
+<table>
+<tr><th>Name</th><th>State</th><th>Uptime</th><th>Restarts</th><th>Last error</th><th>Health</th></tr>
+{{range .Workers}}<tr>
+<td>{{.Name}}</td>
+<td>{{.State}}</td>
+<td>{{uptime .UptimeSeconds}}</td>
+<td>{{.Restarts}}</td>
+<td>{{.LastError}}</td>
+<td class="{{.Health}}">{{.Health}}{{with .HealthError}}: {{.}}{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,122 @@
+package flexadmin_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexadmin"
+	"github.com/go-flexible/flex/flexapi"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// degradedWorker runs until its context is done, reporting itself degraded.
+type degradedWorker struct{}
+
+func (w *degradedWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (w *degradedWorker) Halt(context.Context) error { return nil }
+
+func (w *degradedWorker) Health(context.Context) error {
+	return flex.Degraded(errors.New("replica <lagging>"))
+}
+
+// get returns the status code and body of a GET of url.
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServer(t *testing.T) {
+	t.Run("the status of the workers must be served as JSON and HTML", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(&degradedWorker{}, flex.WithName("consumer"))
+		m.Add(flexadmin.New(addr), flex.WithName("admin"))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+		<-m.Started()
+
+		code, body := get(t, "http://"+addr+"/status")
+		if code != http.StatusOK {
+			t.Fatalf("expected %d but got: %d", http.StatusOK, code)
+		}
+		status, err := flexapi.Decode(strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(status.Workers) != 2 {
+			t.Fatalf("unexpected workers: %+v", status.Workers)
+		}
+		consumer := status.Workers[0]
+		if consumer.Name != "consumer" || consumer.State != flexapi.StateRunning || consumer.StartedAt == nil {
+			t.Errorf("unexpected status: %+v", consumer)
+		}
+		if consumer.Health != flexapi.HealthDegraded || consumer.HealthError != "replica <lagging>" {
+			t.Errorf("expected the worker to be degraded but got: %+v", consumer)
+		}
+
+		code, body = get(t, "http://"+addr+"/")
+		if code != http.StatusOK {
+			t.Fatalf("expected %d but got: %d", http.StatusOK, code)
+		}
+		for _, want := range []string{"<td>consumer</td>", "<td>running</td>", "degraded: replica &lt;lagging&gt;"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected the page to contain %q but got:\n%s", want, body)
+			}
+		}
+	})
+	t.Run("a server not run by a manager must fail", func(t *testing.T) {
+		t.Parallel()
+
+		err := flexadmin.New(flextest.Addr(t)).Run(context.Background())
+		if err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("the status of an idle manager must be served", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&degradedWorker{}, flex.WithName("consumer"))
+
+		rec := httptest.NewRecorder()
+		flexadmin.Handler(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d but got: %d", http.StatusOK, rec.Code)
+		}
+		status, err := flexapi.Decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(status.Workers) != 1 || status.Workers[0].State != flexapi.StateUnknown || status.Workers[0].Health != flexapi.HealthUnhealthy {
+			t.Errorf("unexpected workers: %+v", status.Workers)
+		}
+	})
+}
@@ -0,0 +1,351 @@
+// Package flexamqp provides a flex worker consuming a RabbitMQ queue.
+//
+// The worker does not depend on an AMQP library, instead it drives a Channel,
+// which is typically a thin shim over the connection and channel of
+// rabbitmq/amqp091-go, whose Delivery satisfies Delivery:
+//
+//	type channel struct {
+//		conn *amqp.Connection
+//		ch   *amqp.Channel
+//	}
+//
+//	func (c channel) Consume(queue string, prefetch int) (<-chan amqp.Delivery, error) {
+//		if err := c.ch.Qos(prefetch, 0, false); err != nil {
+//			return nil, err
+//		}
+//		return c.ch.Consume(queue, "billing", false, false, false, false, nil)
+//	}
+//
+//	func (c channel) Cancel() error { return c.ch.Cancel("billing", false) }
+//	func (c channel) Close() error  { return c.conn.Close() }
+//
+//	dial := func(context.Context) (flexamqp.Channel[amqp.Delivery], error) {
+//		conn, err := amqp.Dial(url)
+//		if err != nil {
+//			return nil, err
+//		}
+//		ch, err := conn.Channel()
+//		if err != nil {
+//			conn.Close()
+//			return nil, err
+//		}
+//		return channel{conn, ch}, nil
+//	}
+//
+//	flex.MustStart(ctx, flexamqp.New(dial, "orders", handleOrder, flexamqp.WithPrefetch(20)))
+//
+// Whenever the broker drops the connection, the worker dials again with
+// exponential backoff.
+package flexamqp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultPrefetch is how many unacknowledged deliveries the broker sends
+	// at once when no prefetch count is configured.
+	DefaultPrefetch = 10
+	// DefaultMinBackoff is the default delay before the first dial attempt
+	// after the connection was lost.
+	DefaultMinBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff is the default maximum delay between dial attempts.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultDrainTimeout is how long in-flight deliveries are given to be
+	// handled during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+var logger = log.New(os.Stderr, "flexamqp: ", 0)
+
+// Delivery is a delivery which must be acknowledged, such as amqp.Delivery.
+type Delivery interface {
+	Ack(multiple bool) error
+	Nack(multiple, requeue bool) error
+}
+
+// Channel is an AMQP channel, along with the connection it belongs to.
+type Channel[D Delivery] interface {
+	// Consume sets the prefetch count of the channel, and starts consuming
+	// queue without auto-acknowledgement. The returned channel must be closed
+	// once the consumer is cancelled, or the connection is lost.
+	Consume(queue string, prefetch int) (<-chan D, error)
+	// Cancel stops the deliveries, leaving the channel open so that in-flight
+	// deliveries can still be acknowledged.
+	Cancel() error
+	// Close closes the channel and its connection.
+	Close() error
+}
+
+// Dial opens a new Channel.
+type Dial[D Delivery] func(ctx context.Context) (Channel[D], error)
+
+// Handler handles a single delivery.
+// The delivery is acknowledged when the handler returns nil, and negatively
+// acknowledged without being requeued when it returns an error or panics.
+type Handler[D Delivery] func(ctx context.Context, d D) error
+
+// Option configures a Consumer.
+type Option func(*options)
+
+type options struct {
+	prefetch     int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	drainTimeout time.Duration
+	nackOnHalt   bool
+}
+
+// WithPrefetch sets how many unacknowledged deliveries the broker sends at
+// once, which is how many deliveries are handled concurrently.
+func WithPrefetch(n int) Option {
+	return func(o *options) { o.prefetch = n }
+}
+
+// WithReconnectBackoff sets the bounds of the exponential backoff between dial
+// attempts.
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithDrainTimeout sets how long in-flight deliveries are given to be handled
+// once the consumer is halted, after which they are negatively acknowledged
+// and requeued, and their handlers' contexts are cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithNackOnHalt makes the consumer requeue in-flight deliveries as soon as
+// it is halted, cancelling their handlers' contexts, instead of waiting for
+// them to be handled. This suits handlers which are idempotent and long
+// running.
+func WithNackOnHalt() Option {
+	return func(o *options) { o.nackOnHalt = true }
+}
+
+// Consumer is a flex worker which consumes a queue and dispatches deliveries
+// to a handler.
+type Consumer[D Delivery] struct {
+	dial    Dial[D]
+	queue   string
+	handler Handler[D]
+	opts    options
+
+	mu       sync.Mutex
+	stop     context.CancelFunc
+	abort    context.CancelFunc
+	done     chan struct{}
+	inFlight map[*delivery[D]]struct{}
+}
+
+// delivery is an in-flight delivery, acknowledged at most once.
+type delivery[D Delivery] struct {
+	d    D
+	once sync.Once
+}
+
+// New returns a Consumer dispatching deliveries of queue, consumed from
+// channels opened by dial, to handler.
+func New[D Delivery](dial Dial[D], queue string, handler Handler[D], opts ...Option) *Consumer[D] {
+	c := &Consumer[D]{
+		dial:    dial,
+		queue:   queue,
+		handler: handler,
+		opts: options{
+			prefetch:     DefaultPrefetch,
+			minBackoff:   DefaultMinBackoff,
+			maxBackoff:   DefaultMaxBackoff,
+			drainTimeout: DefaultDrainTimeout,
+		},
+		inFlight: make(map[*delivery[D]]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Run dials and consumes the queue, dialing again with backoff whenever the
+// connection is lost, until the context is done or Halt is called.
+// The worker reports itself ready once it first consumes the queue.
+//
+// Handlers are given a context which is not cancelled when consuming stops,
+// so that in-flight deliveries can be handled and acknowledged during Halt.
+func (c *Consumer[D]) Run(ctx context.Context) error {
+	consumeCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	c.mu.Lock()
+	c.stop, c.abort, c.done = stop, abort, done
+	c.mu.Unlock()
+
+	backoff := c.opts.minBackoff
+	for {
+		err := c.consume(ctx, consumeCtx, handlerCtx)
+		if consumeCtx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			backoff = c.opts.minBackoff
+			logger.Printf("connection lost, dialing again in %s", backoff)
+		} else {
+			logger.Printf("dialing again in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-consumeCtx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.opts.maxBackoff)
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it first
+// consumes the queue, see flex.ReadyReporter.
+func (c *Consumer[D]) ReportsReady() bool { return true }
+
+// consume dials and consumes the queue until the connection is lost or the
+// context is done, in which case the consumer is cancelled and in-flight
+// deliveries are waited for before closing the channel.
+func (c *Consumer[D]) consume(ctx, consumeCtx, handlerCtx context.Context) error {
+	ch, err := c.dial(consumeCtx)
+	if err != nil {
+		return fmt.Errorf("flexamqp: dial: %w", err)
+	}
+	defer ch.Close()
+
+	deliveries, err := ch.Consume(c.queue, c.opts.prefetch)
+	if err != nil {
+		return fmt.Errorf("flexamqp: consume %q: %w", c.queue, err)
+	}
+
+	flex.Ready(ctx)
+
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	for {
+		select {
+		case <-consumeCtx.Done():
+			if err := ch.Cancel(); err != nil {
+				logger.Printf("cancel consumer: %v", err)
+			}
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return nil
+			}
+
+			tracked := c.track(d)
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				defer c.untrack(tracked)
+				c.handle(handlerCtx, tracked)
+			}()
+		}
+	}
+}
+
+// Halt stops consuming and waits for in-flight deliveries to be handled and
+// acknowledged, for at most the drain timeout, or until the deadline of ctx if
+// it is earlier, after which they are requeued. With WithNackOnHalt, they are
+// requeued right away.
+func (c *Consumer[D]) Halt(ctx context.Context) error {
+	c.mu.Lock()
+	stop, abort, done := c.stop, c.abort, c.done
+	c.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	if c.opts.nackOnHalt {
+		c.requeueInFlight()
+		abort()
+		<-done
+		return nil
+	}
+
+	timeout := c.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n := c.requeueInFlight()
+		abort()
+		<-done
+		return fmt.Errorf("flexamqp: %d in-flight deliveries were not handled within %s, requeued them", n, timeout)
+	}
+}
+
+// handle dispatches d to the handler and acknowledges it accordingly, unless
+// it was requeued meanwhile.
+func (c *Consumer[D]) handle(ctx context.Context, d *delivery[D]) {
+	ok := false
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+		d.once.Do(func() {
+			if ok {
+				_ = d.d.Ack(false)
+				return
+			}
+			_ = d.d.Nack(false, false)
+		})
+	}()
+
+	ok = c.handler(ctx, d.d) == nil
+}
+
+// requeueInFlight negatively acknowledges and requeues the in-flight
+// deliveries, and returns how many there were.
+func (c *Consumer[D]) requeueInFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for d := range c.inFlight {
+		d.once.Do(func() {
+			_ = d.d.Nack(false, true)
+			n++
+		})
+	}
+	return n
+}
+
+// track records d as in flight.
+func (c *Consumer[D]) track(d D) *delivery[D] {
+	tracked := &delivery[D]{d: d}
+	c.mu.Lock()
+	c.inFlight[tracked] = struct{}{}
+	c.mu.Unlock()
+	return tracked
+}
+
+// untrack records d as settled.
+func (c *Consumer[D]) untrack(d *delivery[D]) {
+	c.mu.Lock()
+	delete(c.inFlight, d)
+	c.mu.Unlock()
+}
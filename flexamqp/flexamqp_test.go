@@ -0,0 +1,271 @@
+package flexamqp_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexamqp"
+)
+
+// mockDelivery records how it was acknowledged.
+type mockDelivery struct {
+	id int
+
+	mu      sync.Mutex
+	settled string
+}
+
+func (d *mockDelivery) Ack(bool) error { return d.settle("ack") }
+
+func (d *mockDelivery) Nack(_, requeue bool) error {
+	if requeue {
+		return d.settle("requeue")
+	}
+	return d.settle("nack")
+}
+
+func (d *mockDelivery) settle(how string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.settled != "" {
+		return errors.New("delivery already settled")
+	}
+	d.settled = how
+	return nil
+}
+
+func (d *mockDelivery) settlement() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.settled
+}
+
+// mockBroker hands out channels delivering from a shared queue, and can drop
+// their connections.
+type mockBroker struct {
+	queue chan *mockDelivery
+
+	mu       sync.Mutex
+	dials    int
+	prefetch int
+	channels []*mockChannel
+}
+
+func newMockBroker() *mockBroker {
+	return &mockBroker{queue: make(chan *mockDelivery)}
+}
+
+func (b *mockBroker) dial(context.Context) (flexamqp.Channel[*mockDelivery], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dials++
+	ch := &mockChannel{broker: b, cancelled: make(chan struct{}), closed: make(chan struct{})}
+	b.channels = append(b.channels, ch)
+	return ch, nil
+}
+
+// drop drops the connection of the last channel.
+func (b *mockBroker) drop() {
+	b.mu.Lock()
+	ch := b.channels[len(b.channels)-1]
+	b.mu.Unlock()
+	ch.cancelOnce.Do(func() { close(ch.cancelled) })
+}
+
+func (b *mockBroker) dialCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dials
+}
+
+type mockChannel struct {
+	broker *mockBroker
+
+	cancelOnce sync.Once
+	cancelled  chan struct{}
+	closeOnce  sync.Once
+	closed     chan struct{}
+}
+
+func (c *mockChannel) Consume(_ string, prefetch int) (<-chan *mockDelivery, error) {
+	c.broker.mu.Lock()
+	c.broker.prefetch = prefetch
+	c.broker.mu.Unlock()
+
+	deliveries := make(chan *mockDelivery)
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case <-c.cancelled:
+				return
+			case d := <-c.broker.queue:
+				select {
+				case deliveries <- d:
+				case <-c.cancelled:
+					d.settle("requeue")
+					return
+				}
+			}
+		}
+	}()
+	return deliveries, nil
+}
+
+func (c *mockChannel) Cancel() error {
+	c.cancelOnce.Do(func() { close(c.cancelled) })
+	return nil
+}
+
+func (c *mockChannel) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestConsumer(t *testing.T) {
+	t.Run("handled deliveries must be acked or nacked", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newMockBroker()
+		handled := make(chan struct{}, 3)
+		c := flexamqp.New(broker.dial, "orders", func(_ context.Context, d *mockDelivery) error {
+			defer func() { handled <- struct{}{} }()
+			switch d.id {
+			case 1:
+				return errors.New("failed")
+			case 2:
+				panic("boom")
+			}
+			return nil
+		}, flexamqp.WithPrefetch(3))
+
+		errC := make(chan error, 1)
+		go func() { errC <- c.Run(context.Background()) }()
+
+		deliveries := []*mockDelivery{{id: 0}, {id: 1}, {id: 2}}
+		for _, d := range deliveries {
+			broker.queue <- d
+		}
+		for range deliveries {
+			<-handled
+		}
+
+		if err := c.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		for i, want := range []string{"ack", "nack", "nack"} {
+			if got := deliveries[i].settlement(); got != want {
+				t.Errorf("expected delivery %d to be settled with %q but got: %q", i, want, got)
+			}
+		}
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		if broker.prefetch != 3 {
+			t.Errorf("expected a prefetch of %d but got: %d", 3, broker.prefetch)
+		}
+	})
+	t.Run("in-flight deliveries must be handled when halted", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newMockBroker()
+		handling := make(chan struct{})
+		c := flexamqp.New(broker.dial, "orders", func(context.Context, *mockDelivery) error {
+			close(handling)
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+
+		go c.Run(context.Background())
+
+		d := &mockDelivery{}
+		broker.queue <- d
+		<-handling
+
+		if err := c.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if got := d.settlement(); got != "ack" {
+			t.Errorf("expected %q but got: %q", "ack", got)
+		}
+	})
+	t.Run("in-flight deliveries outliving the drain timeout must be requeued", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newMockBroker()
+		handling := make(chan struct{})
+		c := flexamqp.New(broker.dial, "orders", func(ctx context.Context, _ *mockDelivery) error {
+			close(handling)
+			<-ctx.Done()
+			return nil
+		}, flexamqp.WithDrainTimeout(10*time.Millisecond))
+
+		go c.Run(context.Background())
+
+		d := &mockDelivery{}
+		broker.queue <- d
+		<-handling
+
+		if err := c.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if got := d.settlement(); got != "requeue" {
+			t.Errorf("expected %q but got: %q", "requeue", got)
+		}
+	})
+	t.Run("in-flight deliveries must be requeued right away when configured", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newMockBroker()
+		handling := make(chan struct{})
+		c := flexamqp.New(broker.dial, "orders", func(ctx context.Context, _ *mockDelivery) error {
+			close(handling)
+			<-ctx.Done()
+			return nil
+		}, flexamqp.WithNackOnHalt())
+
+		go c.Run(context.Background())
+
+		d := &mockDelivery{}
+		broker.queue <- d
+		<-handling
+
+		if err := c.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if got := d.settlement(); got != "requeue" {
+			t.Errorf("expected %q but got: %q", "requeue", got)
+		}
+	})
+	t.Run("a dropped connection must be dialed again", func(t *testing.T) {
+		t.Parallel()
+
+		broker := newMockBroker()
+		handled := make(chan int, 2)
+		c := flexamqp.New(broker.dial, "orders", func(_ context.Context, d *mockDelivery) error {
+			handled <- d.id
+			return nil
+		}, flexamqp.WithReconnectBackoff(time.Millisecond, time.Millisecond))
+		defer c.Halt(context.Background())
+
+		go c.Run(context.Background())
+
+		broker.queue <- &mockDelivery{id: 1}
+		<-handled
+		broker.drop()
+
+		broker.queue <- &mockDelivery{id: 2}
+		if id := <-handled; id != 2 {
+			t.Errorf("expected %d but got: %d", 2, id)
+		}
+		if n := broker.dialCount(); n != 2 {
+			t.Errorf("expected %d dials but got: %d", 2, n)
+		}
+	})
+}
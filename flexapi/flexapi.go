@@ -0,0 +1,123 @@
+// Package flexapi defines the wire format spoken by flex control APIs, so that
+// fleet tooling can manage services running different versions of flex.
+//
+// The format evolves under the following rules, which keep it backward and
+// forward compatible:
+//
+//   - Every document carries the SchemaVersion it was written with.
+//   - Fields are only ever added, never renamed, removed or given a new meaning.
+//     Removed fields are reserved, both here and in flexapi.proto.
+//   - Readers ignore fields they do not know about.
+//   - Enumerations are encoded as strings, and values a reader does not know
+//     about decode as their unknown value rather than failing.
+//   - SchemaVersion is only incremented on changes readers must be aware of,
+//     and readers accept every version up to and including theirs.
+package flexapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SchemaVersion is the version of the wire format written by this package.
+const SchemaVersion = 1
+
+// ErrUnsupportedVersion is returned when decoding a document written with a
+// schema version this package does not support.
+var ErrUnsupportedVersion = errors.New("flexapi: unsupported schema version")
+
+// State is the lifecycle state of a worker.
+type State string
+
+// Worker states.
+const (
+	StateUnknown  State = "unknown"
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateStopping State = "stopping"
+	StateStopped  State = "stopped"
+	StateFailed   State = "failed"
+)
+
+// UnmarshalText decodes a state, mapping states it does not know about to
+// StateUnknown so that newer writers do not break older readers.
+func (s *State) UnmarshalText(text []byte) error {
+	switch state := State(text); state {
+	case StateStarting, StateRunning, StateStopping, StateStopped, StateFailed:
+		*s = state
+	default:
+		*s = StateUnknown
+	}
+	return nil
+}
+
+// Health is the health status of a worker.
+type Health string
+
+// Health statuses.
+const (
+	HealthUnknown   Health = "unknown"
+	HealthHealthy   Health = "healthy"
+	HealthDegraded  Health = "degraded"
+	HealthUnhealthy Health = "unhealthy"
+)
+
+// UnmarshalText decodes a health status, mapping those it does not know about
+// to HealthUnknown so that newer writers do not break older readers.
+func (h *Health) UnmarshalText(text []byte) error {
+	switch health := Health(text); health {
+	case HealthHealthy, HealthDegraded, HealthUnhealthy:
+		*h = health
+	default:
+		*h = HealthUnknown
+	}
+	return nil
+}
+
+// Status is the status of a service and its workers.
+type Status struct {
+	// SchemaVersion is the version of the format the document was written with.
+	SchemaVersion int `json:"schema_version"`
+	// FlexVersion is the version of flex the service runs, when known.
+	FlexVersion string `json:"flex_version,omitempty"`
+	// Workers holds the status of each worker.
+	Workers []WorkerStatus `json:"workers"`
+}
+
+// WorkerStatus is the status of a single worker.
+type WorkerStatus struct {
+	Name      string     `json:"name"`
+	State     State      `json:"state"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Restarts  int        `json:"restarts,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	// UptimeSeconds is how long the worker has been, or was, running.
+	UptimeSeconds float64 `json:"uptime_seconds,omitempty"`
+	// Health is the health status of the worker, and HealthError why it is
+	// not healthy, when known.
+	Health      Health `json:"health,omitempty"`
+	HealthError string `json:"health_error,omitempty"`
+}
+
+// Encode writes status to w, stamped with the current SchemaVersion.
+func Encode(w io.Writer, status Status) error {
+	status.SchemaVersion = SchemaVersion
+	return json.NewEncoder(w).Encode(status)
+}
+
+// Decode reads a status from r, ignoring fields it does not know about.
+// Documents written with a newer schema version are rejected, as they may
+// carry changes which cannot be safely ignored.
+func Decode(r io.Reader) (Status, error) {
+	var status Status
+	if err := json.NewDecoder(r).Decode(&status); err != nil {
+		return Status{}, fmt.Errorf("flexapi: decode status: %w", err)
+	}
+	if status.SchemaVersion < 1 || status.SchemaVersion > SchemaVersion {
+		return Status{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, status.SchemaVersion)
+	}
+	return status, nil
+}
@@ -0,0 +1,65 @@
+package flexapi_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexapi"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	t.Run("a status must survive a round trip", func(t *testing.T) {
+		t.Parallel()
+
+		startedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		status := flexapi.Status{
+			Workers: []flexapi.WorkerStatus{
+				{Name: "api", State: flexapi.StateRunning, StartedAt: &startedAt, Restarts: 2},
+			},
+		}
+
+		var buf bytes.Buffer
+		if err := flexapi.Encode(&buf, status); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := flexapi.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.SchemaVersion != flexapi.SchemaVersion {
+			t.Errorf("expected schema version %d but got %d", flexapi.SchemaVersion, decoded.SchemaVersion)
+		}
+		worker := decoded.Workers[0]
+		if worker.Name != "api" || worker.State != flexapi.StateRunning || !worker.StartedAt.Equal(startedAt) || worker.Restarts != 2 {
+			t.Errorf("unexpected worker status: %+v", worker)
+		}
+	})
+	t.Run("unknown fields and states must be tolerated", func(t *testing.T) {
+		t.Parallel()
+
+		doc := `{"schema_version":1,"new_field":true,"workers":[{"name":"api","state":"hibernating","health":"elated","also_new":1}]}`
+
+		status, err := flexapi.Decode(strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := status.Workers[0].State; state != flexapi.StateUnknown {
+			t.Errorf("expected state %q but got %q", flexapi.StateUnknown, state)
+		}
+		if health := status.Workers[0].Health; health != flexapi.HealthUnknown {
+			t.Errorf("expected health %q but got %q", flexapi.HealthUnknown, health)
+		}
+	})
+	t.Run("newer schema versions must be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := flexapi.Decode(strings.NewReader(`{"schema_version":99,"workers":[]}`))
+		if !errors.Is(err, flexapi.ErrUnsupportedVersion) {
+			t.Errorf("expected %v but got: %v", flexapi.ErrUnsupportedVersion, err)
+		}
+	})
+}
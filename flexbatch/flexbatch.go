@@ -0,0 +1,294 @@
+// Package flexbatch provides a flex worker accumulating items into batches,
+// which are flushed once they are full or old enough:
+//
+//	batcher := flexbatch.New(func(ctx context.Context, events []Event) error {
+//		return warehouse.Insert(ctx, events)
+//	}, flexbatch.WithMaxSize(500), flexbatch.WithMaxAge(2*time.Second))
+//
+//	flex.MustStart(ctx, api, batcher)
+//
+//	// In a handler:
+//	err := batcher.Add(ctx, event)
+//
+// Once halted, or once the context given to Run is done, the batcher stops
+// accepting items and flushes those it holds, until the drain timeout, or the
+// deadline of the context given to Halt, expires.
+package flexbatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultMaxSize is the most items in a batch when no maximum size is
+	// configured.
+	DefaultMaxSize = 100
+	// DefaultMaxAge is how long the first item of a batch waits for it to be
+	// flushed when no maximum age is configured.
+	DefaultMaxAge = time.Second
+	// DefaultDrainTimeout is how long the final flush is given to complete
+	// during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by Add once the batcher is stopping.
+var ErrClosed = errors.New("flexbatch: batcher is closed")
+
+var logger = log.New(os.Stderr, "flexbatch: ", 0)
+
+// Flush flushes a batch of items. When it returns an error the batch is
+// reported to the error handler and dropped, unless the error is marked with
+// flex.Fatal, in which case the worker stops and returns it.
+type Flush[T any] func(ctx context.Context, batch []T) error
+
+// Option configures a Batcher.
+type Option func(*options)
+
+type options struct {
+	maxSize      int
+	maxAge       time.Duration
+	drainTimeout time.Duration
+	onError      func(error)
+}
+
+// WithMaxSize sets the most items in a batch. A full batch is flushed right
+// away, and Add blocks while it is.
+func WithMaxSize(n int) Option {
+	return func(o *options) { o.maxSize = n }
+}
+
+// WithMaxAge sets how long the first item of a batch waits for it to be
+// flushed.
+func WithMaxAge(d time.Duration) Option {
+	return func(o *options) { o.maxAge = d }
+}
+
+// WithDrainTimeout sets how long the final flush is given to complete once
+// the batcher is halted, unless the context given to Halt expires first.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the errors of failed and
+// panicking flushes, which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Batcher is a flex worker flushing items of type T in batches.
+type Batcher[T any] struct {
+	flush Flush[T]
+	opts  options
+	items chan T
+
+	stopOnce sync.Once
+	stopping chan struct{}
+	adding   sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	abort  context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Batcher flushing its batches with flush.
+func New[T any](flush Flush[T], opts ...Option) *Batcher[T] {
+	b := &Batcher[T]{
+		flush: flush,
+		opts: options{
+			maxSize:      DefaultMaxSize,
+			maxAge:       DefaultMaxAge,
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+		stopping: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	b.items = make(chan T, b.opts.maxSize)
+	return b
+}
+
+// Add adds an item to the current batch, blocking while a full batch is
+// being flushed. It fails with ErrClosed once the batcher is stopping, that
+// is halted or its context is done.
+func (b *Batcher[T]) Add(ctx context.Context, item T) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.adding.Add(1)
+	b.mu.Unlock()
+	defer b.adding.Done()
+
+	select {
+	case <-b.stopping:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	case b.items <- item:
+		return nil
+	}
+}
+
+// Run batches the added items and flushes the batches until the context is
+// done or Halt is called, then stops accepting items and flushes the remaining
+// ones. It returns the error of a flush marked with flex.Fatal. The worker
+// reports itself ready right away.
+//
+// Batches are flushed with a context which is not cancelled by the shutdown,
+// but once the final flush outlives the drain timeout.
+func (b *Batcher[T]) Run(ctx context.Context) error {
+	flushCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	b.mu.Lock()
+	b.abort, b.done = abort, done
+	b.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	timer := time.NewTimer(b.opts.maxAge)
+	timer.Stop()
+	defer timer.Stop()
+
+	var batch []T
+	for stopped := false; !stopped; {
+		due := false
+		select {
+		case <-ctx.Done():
+			b.stop()
+			stopped = true
+		case <-b.stopping:
+			stopped = true
+		case item := <-b.items:
+			batch = append(batch, item)
+			if len(batch) == 1 {
+				timer.Reset(b.opts.maxAge)
+			}
+			if due = len(batch) >= b.opts.maxSize; due {
+				timer.Stop()
+			}
+		case <-timer.C:
+			due = true
+		}
+
+		if due {
+			if err := b.flushBatch(flushCtx, batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+
+	// Items added while stopping are flushed as well, once every Add in
+	// progress has returned, as none can succeed afterwards.
+	b.adding.Wait()
+	for {
+		select {
+		case item := <-b.items:
+			batch = append(batch, item)
+			continue
+		default:
+		}
+		break
+	}
+
+	for len(batch) > 0 {
+		if flushCtx.Err() != nil {
+			return fmt.Errorf("flexbatch: %d items were not flushed", len(batch))
+		}
+		n := min(len(batch), b.opts.maxSize)
+		if err := b.flushBatch(flushCtx, batch[:n]); err != nil {
+			return err
+		}
+		batch = batch[n:]
+	}
+	return nil
+}
+
+// flushBatch flushes batch, recovering flush if it panics. It returns the
+// error of the flush only if it is marked with flex.Fatal.
+func (b *Batcher[T]) flushBatch(ctx context.Context, batch []T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.opts.onError(fmt.Errorf("flexbatch: flush of %d items panicked: %v\n%s", len(batch), r, debug.Stack()))
+		}
+	}()
+
+	if err := b.flush(ctx, batch); err != nil {
+		if flex.IsFatal(err) {
+			return err
+		}
+		b.opts.onError(fmt.Errorf("flexbatch: flush %d items: %w", len(batch), err))
+	}
+	return nil
+}
+
+// Halt stops accepting items, and waits for the remaining ones to be flushed
+// for at most the drain timeout, or until the deadline of ctx if it is
+// earlier, after which the context of the flush is cancelled. A flush which
+// ignores its context is abandoned once ctx is done.
+func (b *Batcher[T]) Halt(ctx context.Context) error {
+	// A context already done when halting, as given by a manager without a
+	// halt timeout, does not bound the wait for the flush.
+	abandon := ctx.Done()
+	if ctx.Err() != nil {
+		abandon = nil
+	}
+
+	b.stop()
+
+	b.mu.Lock()
+	abort, done := b.abort, b.done
+	b.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	timeout := b.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		abort()
+	}
+
+	select {
+	case <-done:
+		return fmt.Errorf("flexbatch: final flush did not complete within %s", timeout)
+	case <-abandon:
+		return fmt.Errorf("flexbatch: final flush did not complete within %s, abandoned it: %w", timeout, ctx.Err())
+	}
+}
+
+// stop closes the batcher to new items.
+func (b *Batcher[T]) stop() {
+	b.stopOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+
+		close(b.stopping)
+	})
+}
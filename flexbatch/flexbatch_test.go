@@ -0,0 +1,237 @@
+package flexbatch_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexbatch"
+)
+
+func TestBatcher(t *testing.T) {
+	t.Run("full batches must be flushed right away", func(t *testing.T) {
+		t.Parallel()
+
+		batches := make(chan []int, 2)
+		b := flexbatch.New(func(_ context.Context, batch []int) error {
+			batches <- batch
+			return nil
+		}, flexbatch.WithMaxSize(2), flexbatch.WithMaxAge(time.Hour))
+		defer b.Halt(context.Background())
+
+		go b.Run(context.Background())
+
+		for i := range 4 {
+			if err := b.Add(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, want := range [][]int{{0, 1}, {2, 3}} {
+			if batch := <-batches; !slices.Equal(batch, want) {
+				t.Errorf("expected %v but got: %v", want, batch)
+			}
+		}
+	})
+	t.Run("batches must be flushed once old enough", func(t *testing.T) {
+		t.Parallel()
+
+		batches := make(chan []int, 1)
+		b := flexbatch.New(func(_ context.Context, batch []int) error {
+			batches <- batch
+			return nil
+		}, flexbatch.WithMaxAge(10*time.Millisecond))
+		defer b.Halt(context.Background())
+
+		go b.Run(context.Background())
+
+		if err := b.Add(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case batch := <-batches:
+			if !slices.Equal(batch, []int{1}) {
+				t.Errorf("expected %v but got: %v", []int{1}, batch)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the batch to be flushed")
+		}
+	})
+	t.Run("the remaining items must be flushed when halted", func(t *testing.T) {
+		t.Parallel()
+
+		batches := make(chan []int, 3)
+		b := flexbatch.New(func(_ context.Context, batch []int) error {
+			batches <- batch
+			return nil
+		}, flexbatch.WithMaxSize(2), flexbatch.WithMaxAge(time.Hour))
+
+		for i := range 2 {
+			if err := b.Add(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- b.Run(context.Background()) }()
+
+		if batch := <-batches; !slices.Equal(batch, []int{0, 1}) {
+			t.Errorf("expected %v but got: %v", []int{0, 1}, batch)
+		}
+		if err := b.Add(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if batch := <-batches; !slices.Equal(batch, []int{2}) {
+			t.Errorf("expected %v but got: %v", []int{2}, batch)
+		}
+		if err := b.Add(context.Background(), 3); !errors.Is(err, flexbatch.ErrClosed) {
+			t.Errorf("expected %v but got: %v", flexbatch.ErrClosed, err)
+		}
+	})
+	t.Run("items must be refused once the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		batches := make(chan []int, 1)
+		b := flexbatch.New(func(_ context.Context, batch []int) error {
+			batches <- batch
+			return nil
+		}, flexbatch.WithMaxAge(time.Hour))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- b.Run(ctx) }()
+
+		if err := b.Add(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if batch := <-batches; !slices.Equal(batch, []int{1}) {
+			t.Errorf("expected %v but got: %v", []int{1}, batch)
+		}
+		if err := b.Add(context.Background(), 2); !errors.Is(err, flexbatch.ErrClosed) {
+			t.Errorf("expected %v but got: %v", flexbatch.ErrClosed, err)
+		}
+		if err := b.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a flush outliving the drain timeout must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		flushing := make(chan struct{})
+		b := flexbatch.New(func(ctx context.Context, _ []int) error {
+			close(flushing)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexbatch.WithMaxSize(1), flexbatch.WithDrainTimeout(10*time.Millisecond),
+			flexbatch.WithErrorHandler(func(error) {}))
+
+		errC := make(chan error, 1)
+		go func() { errC <- b.Run(context.Background()) }()
+
+		if err := b.Add(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		<-flushing
+
+		if err := b.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a flush ignoring its context must be abandoned at the halt deadline", func(t *testing.T) {
+		t.Parallel()
+
+		flushing, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		b := flexbatch.New(func(context.Context, []int) error {
+			close(flushing)
+			<-release
+			return nil
+		}, flexbatch.WithMaxSize(1))
+
+		go b.Run(context.Background())
+
+		if err := b.Add(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		<-flushing
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		errC := make(chan error, 1)
+		go func() { errC <- b.Halt(ctx) }()
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected Halt to give up at its deadline")
+		}
+	})
+	t.Run("the remaining items must be flushed once the parent deadline expires", func(t *testing.T) {
+		t.Parallel()
+
+		batches := make(chan []int, 1)
+		b := flexbatch.New(func(_ context.Context, batch []int) error {
+			time.Sleep(50 * time.Millisecond)
+			batches <- batch
+			return nil
+		}, flexbatch.WithMaxAge(time.Hour))
+
+		for i := range 5 {
+			if err := b.Add(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(b)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		select {
+		case batch := <-batches:
+			if want := []int{0, 1, 2, 3, 4}; !slices.Equal(batch, want) {
+				t.Errorf("expected %v but got: %v", want, batch)
+			}
+		default:
+			t.Error("expected the remaining items to be flushed")
+		}
+	})
+	t.Run("fatal flush errors must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		flushErr := flex.Fatal(errors.New("warehouse is gone"))
+		b := flexbatch.New(func(context.Context, []int) error { return flushErr },
+			flexbatch.WithMaxSize(1))
+
+		errC := make(chan error, 1)
+		go func() { errC <- b.Run(context.Background()) }()
+
+		if err := b.Add(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errC; !errors.Is(err, flushErr) {
+			t.Errorf("expected %v but got: %v", flushErr, err)
+		}
+	})
+}
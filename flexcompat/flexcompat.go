@@ -0,0 +1,30 @@
+// Package flexcompat converts between flex workers and the services of other
+// lifecycle libraries, so that a process can be migrated to or from flex one
+// service at a time.
+//
+// Actors of github.com/oklog/run are converted with FromActor and ToActor:
+//
+//	var g run.Group
+//	g.Add(flexcompat.ToActor(ctx, worker))
+//
+//	m.Add(flexcompat.FromActor(execute, interrupt))
+//
+// Services of github.com/thejerf/suture/v4 are converted with FromService and
+// ToService:
+//
+//	sup.Add(flexcompat.ToService(worker))
+//
+//	m.Add(flexcompat.FromService(service))
+package flexcompat
+
+import (
+	"errors"
+	"log"
+	"os"
+)
+
+// ErrHalted is the error actors are interrupted with when their worker is
+// halted.
+var ErrHalted = errors.New("flexcompat: worker halted")
+
+var logger = log.New(os.Stderr, "flexcompat: ", 0)
@@ -0,0 +1,78 @@
+package flexcompat
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-flexible/flex"
+)
+
+// FromActor returns a worker running the actor of a run.Group made of execute
+// and interrupt. The worker reports itself ready right away, and Run returns
+// what execute returns. The actor is interrupted with ErrHalted when the
+// worker is halted, or with the cause of its context once it is done.
+func FromActor(execute func() error, interrupt func(error)) flex.Worker {
+	return &actorWorker{execute: execute, interrupt: interrupt}
+}
+
+// actorWorker is the worker returned by FromActor.
+type actorWorker struct {
+	execute   func() error
+	interrupt func(error)
+
+	mu   sync.Mutex
+	stop func(error)
+}
+
+func (w *actorWorker) Run(ctx context.Context) error {
+	var once sync.Once
+	stop := func(err error) { once.Do(func() { w.interrupt(err) }) }
+	done := make(chan struct{})
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop = stop
+	w.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop(context.Cause(ctx))
+		case <-done:
+		}
+	}()
+
+	flex.Ready(ctx)
+	return w.execute()
+}
+
+// Halt interrupts the actor with ErrHalted.
+func (w *actorWorker) Halt(context.Context) error {
+	w.mu.Lock()
+	stop := w.stop
+	w.mu.Unlock()
+
+	if stop != nil {
+		stop(ErrHalted)
+	}
+	return nil
+}
+
+// ToActor returns the execute and interrupt functions of an actor running
+// worker, to be added to a run.Group. The worker runs with a context derived
+// from ctx, which is cancelled when the actor is interrupted, after which the
+// worker is halted. Since interrupt cannot return an error, the errors of
+// Halt are logged.
+func ToActor(ctx context.Context, worker flex.Worker) (execute func() error, interrupt func(error)) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	execute = func() error {
+		return worker.Run(runCtx)
+	}
+	interrupt = func(err error) {
+		cancel(err)
+		if err := worker.Halt(context.WithoutCancel(ctx)); err != nil {
+			logger.Print(err)
+		}
+	}
+	return execute, interrupt
+}
@@ -0,0 +1,124 @@
+package flexcompat_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexcompat"
+)
+
+// blockingWorker runs until its context is done, and records being halted.
+type blockingWorker struct {
+	halted  chan struct{}
+	haltErr error
+}
+
+func newBlockingWorker() *blockingWorker {
+	return &blockingWorker{halted: make(chan struct{})}
+}
+
+func (w *blockingWorker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
+
+func (w *blockingWorker) Halt(context.Context) error {
+	close(w.halted)
+	return w.haltErr
+}
+
+func TestFromActor(t *testing.T) {
+	t.Run("the actor must be interrupted on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		interrupted := make(chan error, 2)
+		stop := make(chan struct{})
+		w := flexcompat.FromActor(func() error {
+			<-stop
+			return nil
+		}, func(err error) {
+			interrupted <- err
+			close(stop)
+		})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(w)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-m.Started()
+		cancel()
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		// The actor is interrupted by whichever of the cancellation and Halt
+		// comes first, but only once.
+		if err := <-interrupted; err == nil {
+			t.Error("expected the actor to be interrupted with an error")
+		}
+		if len(interrupted) != 0 {
+			t.Error("expected the actor to be interrupted once")
+		}
+	})
+	t.Run("halting must interrupt the actor with ErrHalted", func(t *testing.T) {
+		t.Parallel()
+
+		interrupted := make(chan error, 1)
+		w := flexcompat.FromActor(func() error {
+			return <-interrupted
+		}, func(err error) { interrupted <- err })
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		// Halt until Run has started and the interruption is received.
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			if err := w.Halt(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case err := <-errC:
+				if !errors.Is(err, flexcompat.ErrHalted) {
+					t.Errorf("expected %v but got: %v", flexcompat.ErrHalted, err)
+				}
+				return
+			default:
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected Run to return once halted")
+			}
+		}
+	})
+}
+
+func TestToActor(t *testing.T) {
+	t.Run("interrupting the actor must cancel and halt the worker", func(t *testing.T) {
+		t.Parallel()
+
+		w := newBlockingWorker()
+		execute, interrupt := flexcompat.ToActor(context.Background(), w)
+
+		errC := make(chan error, 1)
+		go func() { errC <- execute() }()
+
+		cause := errors.New("other actor failed")
+		interrupt(cause)
+
+		if err := <-errC; !errors.Is(err, cause) {
+			t.Errorf("expected %v but got: %v", cause, err)
+		}
+		select {
+		case <-w.halted:
+		default:
+			t.Error("expected the worker to be halted")
+		}
+	})
+}
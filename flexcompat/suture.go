@@ -0,0 +1,107 @@
+package flexcompat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-flexible/flex"
+)
+
+// Service is the interface of a suture service, which is satisfied by the
+// services of github.com/thejerf/suture/v4.
+type Service interface {
+	// Serve should run until ctx is done.
+	Serve(ctx context.Context) error
+}
+
+// FromService returns a worker serving service. The worker reports itself
+// ready right away, and is halted by cancelling the context of Serve and
+// waiting for it to return, until the deadline of the context given to Halt
+// if it has one.
+// Serve returning the error of its cancelled context is not reported as an
+// error.
+func FromService(service Service) flex.Worker {
+	return &serviceWorker{service: service}
+}
+
+// serviceWorker is the worker returned by FromService.
+type serviceWorker struct {
+	service Service
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (w *serviceWorker) Run(ctx context.Context) error {
+	serveCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	defer cancel()
+	defer close(done)
+
+	w.mu.Lock()
+	w.cancel, w.done = cancel, done
+	w.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	err := w.service.Serve(serveCtx)
+	if serveCtx.Err() != nil && errors.Is(err, serveCtx.Err()) {
+		return nil
+	}
+	return err
+}
+
+// Halt cancels the context of Serve, and waits for it to return.
+func (w *serviceWorker) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+
+	// The context of Run, which is given to Halt unless the manager has a
+	// halt timeout, is already done: only a deadline bounds the wait.
+	if _, ok := ctx.Deadline(); !ok {
+		<-done
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flexcompat: service did not stop: %w", ctx.Err())
+	}
+}
+
+// ToService returns a suture service running worker. The worker is halted
+// once the context of Serve is done, and Serve returns the errors of both
+// Run and Halt.
+func ToService(worker flex.Worker) Service {
+	return workerService{worker: worker}
+}
+
+// workerService is the Service returned by ToService.
+type workerService struct {
+	worker flex.Worker
+}
+
+func (s workerService) Serve(ctx context.Context) error {
+	runC := make(chan error, 1)
+	go func() { runC <- s.worker.Run(ctx) }()
+
+	select {
+	case err := <-runC:
+		return err
+	case <-ctx.Done():
+	}
+
+	haltErr := s.worker.Halt(context.WithoutCancel(ctx))
+	return errors.Join(<-runC, haltErr)
+}
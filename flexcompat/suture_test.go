@@ -0,0 +1,101 @@
+package flexcompat_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexcompat"
+)
+
+// serviceFunc adapts a function into a Service.
+type serviceFunc func(ctx context.Context) error
+
+func (f serviceFunc) Serve(ctx context.Context) error { return f(ctx) }
+
+func TestFromService(t *testing.T) {
+	t.Run("the service must be served until shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		stopped := make(chan struct{})
+		w := flexcompat.FromService(serviceFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			close(stopped)
+			return ctx.Err()
+		}))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(w)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-m.Started()
+		cancel()
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		select {
+		case <-stopped:
+		default:
+			t.Error("expected the service to have stopped")
+		}
+	})
+	t.Run("halting must be bounded by the deadline of its context", func(t *testing.T) {
+		t.Parallel()
+
+		serving := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		w := flexcompat.FromService(serviceFunc(func(context.Context) error {
+			close(serving)
+			<-release
+			return nil
+		}))
+		go w.Run(context.Background())
+		<-serving
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := w.Halt(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+		}
+	})
+	t.Run("errors of the service must be returned", func(t *testing.T) {
+		t.Parallel()
+
+		serveErr := errors.New("serve failed")
+		w := flexcompat.FromService(serviceFunc(func(context.Context) error { return serveErr }))
+
+		if err := w.Run(context.Background()); !errors.Is(err, serveErr) {
+			t.Errorf("expected %v but got: %v", serveErr, err)
+		}
+	})
+}
+
+func TestToService(t *testing.T) {
+	t.Run("the worker must be halted once the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		w := newBlockingWorker()
+		w.haltErr = errors.New("halt failed")
+		s := flexcompat.ToService(w)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- s.Serve(ctx) }()
+		cancel()
+
+		if err := <-errC; !errors.Is(err, w.haltErr) {
+			t.Errorf("expected %v but got: %v", w.haltErr, err)
+		}
+	})
+}
@@ -0,0 +1,330 @@
+// Package flexconfig provides a flex worker loading typed configuration from
+// files and the environment, and reloading it while the service runs.
+//
+// A Config loads its sources in order, each overriding the values of the ones
+// before it, validates the result and publishes it. Configuration satisfying
+// Validator, through a value or a pointer receiver, is validated before being
+// published, and configuration failing validation is never published:
+//
+//	type Config struct {
+//		Addr    string        `json:"addr" env:"ADDR"`
+//		Timeout time.Duration `json:"timeout" env:"TIMEOUT"`
+//	}
+//
+//	func (c Config) Validate() error {
+//		if c.Addr == "" {
+//			return errors.New("addr is required")
+//		}
+//		return nil
+//	}
+//
+//	cfg := flexconfig.New[Config]([]flexconfig.Source{
+//		flexconfig.File("/etc/myapp/config.json", json.Unmarshal),
+//		flexconfig.Env("MYAPP_"),
+//	}, flexconfig.WithWatchInterval(5*time.Second))
+//	if err := cfg.Load(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	flex.MustStart(ctx, cfg, api)
+//
+// The Config is a flex.Reloader, so the manager reloads it on SIGHUP, see
+// flex.WithReloadSignals. When one of its files changes, and has then stopped
+// changing for one watch interval, it loads the configuration and, once it is
+// published, reloads the manager, which reloads every other flex.Reloader in
+// the order they were added, so that the workers can read the new
+// configuration from Current in their own Reload. Workers may instead receive
+// every new configuration through Subscribe.
+package flexconfig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// Validator is implemented by configuration validating itself.
+type Validator interface {
+	Validate() error
+}
+
+// Option configures a Config.
+type Option func(*options)
+
+type options struct {
+	watchInterval time.Duration
+	onError       func(error)
+}
+
+// WithWatchInterval sets how often the files read by the sources are checked
+// for changes while the worker runs, reloading the manager once they do. Files
+// are not watched by default, and are best watched through flexwatch where
+// fsnotify is available.
+func WithWatchInterval(d time.Duration) Option {
+	return func(o *options) { o.watchInterval = d }
+}
+
+// WithErrorHandler sets the function called with the errors of the reloads
+// triggered by changed files, which are logged to the logger of the manager
+// by default, see flex.LoggerFrom.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Config is a flex worker holding the configuration of type T, typically a
+// struct.
+type Config[T any] struct {
+	sources []Source
+	opts    options
+
+	mu          sync.RWMutex
+	current     T
+	loaded      bool
+	subscribers []chan T
+	closed      bool
+	stamps      map[string]stamp
+
+	reload sync.Mutex
+	// reloadingAll is set while reloadAll reloads the manager, so that the
+	// configuration it just loaded is not loaded again.
+	reloadingAll atomic.Bool
+
+	halt chan struct{}
+	once sync.Once
+}
+
+// stamp identifies a version of a file.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New returns a Config loading from sources, in order.
+func New[T any](sources []Source, opts ...Option) *Config[T] {
+	c := &Config[T]{
+		sources: sources,
+		halt:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Current returns the configuration last published, or the zero value of T
+// before the configuration is loaded.
+func (c *Config[T]) Current() T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+// Subscribe returns a channel receiving every configuration published from
+// then on. The channel holds a single configuration, replaced by newer ones
+// when it is not received in time, and is closed once the worker halts.
+func (c *Config[T]) Subscribe() <-chan T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan T, 1)
+	if c.closed {
+		close(ch)
+		return ch
+	}
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// Load loads, validates and publishes the configuration. It is typically
+// called once before starting the workers, so that they are configured from
+// the start, and is otherwise called by Run.
+func (c *Config[T]) Load(ctx context.Context) error {
+	c.reload.Lock()
+	defer c.reload.Unlock()
+
+	// The files are stamped even when they fail to load, so that they are
+	// only loaded again once they change.
+	stamps := c.stat()
+	c.mu.Lock()
+	c.stamps = stamps
+	c.mu.Unlock()
+
+	var cfg T
+	for _, source := range c.sources {
+		if err := source.Load(ctx, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := validate(&cfg); err != nil {
+		return fmt.Errorf("flexconfig: invalid configuration: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current, c.loaded = cfg, true
+	for _, ch := range c.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- cfg
+	}
+	return nil
+}
+
+// Reload loads the configuration again, keeping the current one when it
+// fails to load or validate.
+func (c *Config[T]) Reload(ctx context.Context) error {
+	if c.reloadingAll.Load() {
+		return nil
+	}
+	return c.Load(ctx)
+}
+
+// Run loads the configuration, unless it is already loaded, and then watches
+// the files read by the sources until the context is done or Halt is called,
+// see WithWatchInterval. Changed files are only loaded once they have stopped
+// changing for one interval, so that a file which is still being written is
+// not loaded half way. The worker reports itself ready once the
+// configuration is loaded.
+func (c *Config[T]) Run(ctx context.Context) error {
+	defer c.close()
+
+	c.mu.RLock()
+	loaded := c.loaded
+	c.mu.RUnlock()
+	if !loaded {
+		if err := c.Load(ctx); err != nil {
+			return flex.Fatal(err)
+		}
+	}
+
+	flex.Ready(ctx)
+
+	if c.opts.watchInterval <= 0 {
+		select {
+		case <-ctx.Done():
+		case <-c.halt:
+		}
+		return nil
+	}
+
+	onError := c.opts.onError
+	if onError == nil {
+		logger := flex.LoggerFrom(ctx)
+		onError = func(err error) { logger.Log(ctx, slog.LevelError, "config reload failed", "error", err) }
+	}
+
+	ticker := time.NewTicker(c.opts.watchInterval)
+	defer ticker.Stop()
+
+	var last map[string]stamp
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.halt:
+			return nil
+		case <-ticker.C:
+			current := c.stat()
+			if !c.changed(current) {
+				last = nil
+				continue
+			}
+			if !maps.Equal(current, last) {
+				last = current
+				continue
+			}
+			last = nil
+			if err := c.reloadAll(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once the
+// configuration is loaded, see flex.ReadyReporter.
+func (c *Config[T]) ReportsReady() bool { return true }
+
+// Halt stops watching the files.
+func (c *Config[T]) Halt(context.Context) error {
+	c.once.Do(func() { close(c.halt) })
+	return nil
+}
+
+// reloadAll loads the configuration and, once it is published, reloads the
+// manager running the worker, if any, so that the other workers are not
+// reloaded with a configuration failing to load or validate.
+func (c *Config[T]) reloadAll(ctx context.Context) error {
+	if err := c.Load(ctx); err != nil {
+		return err
+	}
+
+	m, ok := flex.ManagerFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	c.reloadingAll.Store(true)
+	defer c.reloadingAll.Store(false)
+	return m.Reload(ctx)
+}
+
+// close closes the channels of the subscribers.
+func (c *Config[T]) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.subscribers {
+		close(ch)
+	}
+	c.subscribers, c.closed = nil, true
+}
+
+// stat returns the versions of the files read by the sources.
+func (c *Config[T]) stat() map[string]stamp {
+	stamps := make(map[string]stamp)
+	for _, source := range c.sources {
+		file, ok := source.(*FileSource)
+		if !ok {
+			continue
+		}
+		if info, err := os.Stat(file.path); err == nil {
+			stamps[file.path] = stamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[file.path] = stamp{}
+		}
+	}
+	return stamps
+}
+
+// changed reports whether stamps differ from the versions of the files the
+// configuration was last loaded from.
+func (c *Config[T]) changed(stamps map[string]stamp) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for path, s := range stamps {
+		if c.stamps[path] != s {
+			return true
+		}
+	}
+	return false
+}
+
+// validate validates cfg when it, or the value it points to, satisfies
+// Validator.
+func validate[T any](cfg *T) error {
+	if v, ok := any(cfg).(Validator); ok {
+		return v.Validate()
+	}
+	if v, ok := any(*cfg).(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package flexconfig_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexconfig"
+	"github.com/go-flexible/flex/flextest"
+)
+
+type config struct {
+	Addr  string `json:"addr"`
+	Level string `json:"level"`
+}
+
+func (c config) Validate() error {
+	if c.Addr == "" {
+		return errors.New("addr is required")
+	}
+	return nil
+}
+
+// writeConfig replaces the file at path atomically, so that it is never read
+// half written.
+func writeConfig(t *testing.T, path, body string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reloadingWorker records the configuration it reads when reloaded.
+type reloadingWorker struct {
+	cfg     *flexconfig.Config[config]
+	reloads chan config
+}
+
+func (w *reloadingWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (w *reloadingWorker) Halt(context.Context) error { return nil }
+
+func (w *reloadingWorker) Reload(context.Context) error {
+	w.reloads <- w.cfg.Current()
+	return nil
+}
+
+// msgLogger sends the messages of the entries it is given.
+type msgLogger chan string
+
+func (l msgLogger) Log(_ context.Context, _ slog.Level, msg string, _ ...any) {
+	select {
+	case l <- msg:
+	default:
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Run("later sources must override earlier ones", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, `{"addr": ":8080", "level": "info"}`)
+
+		cfg := flexconfig.New[config]([]flexconfig.Source{
+			flexconfig.File(path, json.Unmarshal),
+			flexconfig.SourceFunc(func(_ context.Context, v any) error {
+				v.(*config).Level = "debug"
+				return nil
+			}),
+		})
+		if err := cfg.Load(context.Background()); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if got, want := cfg.Current(), (config{Addr: ":8080", Level: "debug"}); got != want {
+			t.Errorf("expected %+v but got: %+v", want, got)
+		}
+	})
+	t.Run("an invalid configuration must not be published", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, `{"addr": ":8080"}`)
+
+		cfg := flexconfig.New[config]([]flexconfig.Source{flexconfig.File(path, json.Unmarshal)})
+		if err := cfg.Load(context.Background()); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		updates := cfg.Subscribe()
+
+		writeConfig(t, path, `{"addr": ""}`)
+		if err := cfg.Reload(context.Background()); err == nil {
+			t.Error("expected the invalid configuration to fail")
+		}
+		if got := cfg.Current(); got.Addr != ":8080" {
+			t.Errorf("expected the current configuration to be kept but got: %+v", got)
+		}
+
+		writeConfig(t, path, `{"addr": ":9090"}`)
+		if err := cfg.Reload(context.Background()); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if got := <-updates; got.Addr != ":9090" {
+			t.Errorf("expected subscribers to receive %q but got: %+v", ":9090", got)
+		}
+	})
+	t.Run("subscribers must receive the latest configuration", func(t *testing.T) {
+		t.Parallel()
+
+		var n int
+		cfg := flexconfig.New[config]([]flexconfig.Source{
+			flexconfig.SourceFunc(func(_ context.Context, v any) error {
+				n++
+				v.(*config).Addr = string(rune('0' + n))
+				return nil
+			}),
+		})
+		updates := cfg.Subscribe()
+		for range 3 {
+			if err := cfg.Load(context.Background()); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+		}
+		if got := <-updates; got.Addr != "3" {
+			t.Errorf("expected %q but got: %q", "3", got.Addr)
+		}
+	})
+	t.Run("a changed file must reload the manager", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, `{"addr": ":8080"}`)
+
+		cfg := flexconfig.New[config]([]flexconfig.Source{flexconfig.File(path, json.Unmarshal)},
+			flexconfig.WithWatchInterval(10*time.Millisecond))
+		worker := &reloadingWorker{cfg: cfg, reloads: make(chan config, 1)}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(cfg, flex.WithName("config"))
+		m.Add(worker, flex.WithName("worker"))
+		h := flextest.Start(t, m)
+		<-m.Started()
+
+		if got := cfg.Current(); got.Addr != ":8080" {
+			t.Errorf("expected the configuration to be loaded by Run but got: %+v", got)
+		}
+
+		// Make sure the modification time changes on coarse file systems.
+		time.Sleep(20 * time.Millisecond)
+		writeConfig(t, path, `{"addr": ":9090", "level": "debug"}`)
+
+		select {
+		case got := <-worker.reloads:
+			if got.Addr != ":9090" {
+				t.Errorf("expected the worker to read the new configuration but got: %+v", got)
+			}
+		case <-time.After(flextest.DefaultTimeout):
+			t.Fatal("expected the worker to be reloaded")
+		}
+
+		if err := h.Stop(); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("an invalid file must not reload the manager", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, `{"addr": ":8080"}`)
+
+		errs := make(chan error, 1)
+		cfg := flexconfig.New[config]([]flexconfig.Source{flexconfig.File(path, json.Unmarshal)},
+			flexconfig.WithWatchInterval(10*time.Millisecond),
+			flexconfig.WithErrorHandler(func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}))
+		worker := &reloadingWorker{cfg: cfg, reloads: make(chan config, 1)}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(cfg, flex.WithName("config"))
+		m.Add(worker, flex.WithName("worker"))
+		h := flextest.Start(t, m)
+		<-m.Started()
+
+		time.Sleep(20 * time.Millisecond)
+		writeConfig(t, path, `{"level": "debug"}`)
+
+		select {
+		case <-errs:
+		case <-time.After(flextest.DefaultTimeout):
+			t.Fatal("expected the invalid configuration to be reported")
+		}
+		select {
+		case got := <-worker.reloads:
+			t.Errorf("expected the worker not to be reloaded but it read: %+v", got)
+		default:
+		}
+		if got := cfg.Current(); got.Addr != ":8080" {
+			t.Errorf("expected the configuration to be kept but got: %+v", got)
+		}
+
+		if err := h.Stop(); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("reload errors must be logged to the logger of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, `{"addr": ":8080"}`)
+
+		cfg := flexconfig.New[config]([]flexconfig.Source{flexconfig.File(path, json.Unmarshal)},
+			flexconfig.WithWatchInterval(10*time.Millisecond))
+
+		logger := make(msgLogger, 100)
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger))
+		m.Add(cfg, flex.WithName("config"))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		time.Sleep(20 * time.Millisecond)
+		writeConfig(t, path, `{"level": "debug"}`)
+
+		for timeout := time.After(flextest.DefaultTimeout); ; {
+			select {
+			case msg := <-logger:
+				if msg != "config reload failed" {
+					continue
+				}
+			case <-timeout:
+				t.Fatal("expected the invalid configuration to be logged")
+			}
+			break
+		}
+	})
+	t.Run("a configuration failing to load must stop the manager", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := flexconfig.New[config](nil)
+
+		m := flex.New(flex.WithSignals())
+		m.Add(cfg, flex.WithName("config"))
+		if err := m.Start(context.Background()); err == nil {
+			t.Error("expected an error for the invalid configuration")
+		}
+	})
+}
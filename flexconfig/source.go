@@ -0,0 +1,173 @@
+package flexconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source loads configuration into cfg, a pointer to the configuration,
+// overriding the values loaded by the sources before it.
+type Source interface {
+	Load(ctx context.Context, cfg any) error
+}
+
+// SourceFunc is a function satisfying Source.
+type SourceFunc func(ctx context.Context, cfg any) error
+
+// Load calls f.
+func (f SourceFunc) Load(ctx context.Context, cfg any) error { return f(ctx, cfg) }
+
+// FileSource is a Source reading a file, see File.
+type FileSource struct {
+	path      string
+	unmarshal func([]byte, any) error
+	optional  bool
+}
+
+// File returns a Source reading the file at path, decoded with unmarshal,
+// such as json.Unmarshal or yaml.Unmarshal, whose changes are watched, see
+// WithWatchInterval.
+func File(path string, unmarshal func([]byte, any) error) *FileSource {
+	return &FileSource{path: path, unmarshal: unmarshal}
+}
+
+// Optional makes the source load nothing, rather than fail, when the file
+// does not exist.
+func (s *FileSource) Optional() *FileSource {
+	s.optional = true
+	return s
+}
+
+// Path returns the path of the file.
+func (s *FileSource) Path() string { return s.path }
+
+// Load decodes the file into cfg.
+func (s *FileSource) Load(_ context.Context, cfg any) error {
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) && s.optional {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("flexconfig: read %s: %w", s.path, err)
+	}
+	if err := s.unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("flexconfig: decode %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Env returns a Source setting the fields of the configuration tagged with
+// `env:"NAME"` from the environment variable prefix+NAME, when it is set.
+// Nested structs are walked, and strings, booleans, integers, floats,
+// durations and comma separated lists of strings are supported:
+//
+//	type Config struct {
+//		Addr    string        `env:"ADDR"`
+//		Timeout time.Duration `env:"TIMEOUT"`
+//		Brokers []string      `env:"BROKERS"`
+//	}
+//
+//	flexconfig.Env("MYAPP_") // reads MYAPP_ADDR, MYAPP_TIMEOUT and MYAPP_BROKERS
+func Env(prefix string) Source {
+	return SourceFunc(func(_ context.Context, cfg any) error {
+		v := reflect.ValueOf(cfg)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("flexconfig: env: %T is not a pointer to a struct", cfg)
+		}
+		return setEnv(v.Elem(), prefix)
+	})
+}
+
+// durationType is the type of time.Duration, parsed as a duration rather
+// than as an integer.
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setEnv sets the tagged fields of the struct v from the environment.
+func setEnv(v reflect.Value, prefix string) error {
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged := field.Tag.Lookup("env")
+		if !tagged {
+			if value.Kind() == reflect.Struct {
+				if err := setEnv(value, prefix); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		env := prefix + name
+		s, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setValue(value, s); err != nil {
+			return fmt.Errorf("flexconfig: %s=%q: %w", env, s, err)
+		}
+	}
+	return nil
+}
+
+// setValue parses s into v.
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
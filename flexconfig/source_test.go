@@ -0,0 +1,96 @@
+package flexconfig_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexconfig"
+)
+
+type envConfig struct {
+	Addr    string        `env:"ADDR"`
+	Debug   bool          `env:"DEBUG"`
+	Workers int           `env:"WORKERS"`
+	Ratio   float64       `env:"RATIO"`
+	Timeout time.Duration `env:"TIMEOUT"`
+	Brokers []string      `env:"BROKERS"`
+	Unset   string        `env:"UNSET"`
+	DB      struct {
+		URL string `env:"DB_URL"`
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Run("tagged fields must be set from the environment", func(t *testing.T) {
+		t.Setenv("TEST_ADDR", ":8080")
+		t.Setenv("TEST_DEBUG", "true")
+		t.Setenv("TEST_WORKERS", "4")
+		t.Setenv("TEST_RATIO", "0.5")
+		t.Setenv("TEST_TIMEOUT", "3s")
+		t.Setenv("TEST_BROKERS", "a:9092, b:9092")
+		t.Setenv("TEST_DB_URL", "postgres://db")
+
+		cfg := envConfig{Unset: "kept"}
+		if err := flexconfig.Env("TEST_").Load(context.Background(), &cfg); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if cfg.Addr != ":8080" || !cfg.Debug || cfg.Workers != 4 || cfg.Ratio != 0.5 || cfg.Timeout != 3*time.Second {
+			t.Errorf("unexpected configuration: %+v", cfg)
+		}
+		if !slices.Equal(cfg.Brokers, []string{"a:9092", "b:9092"}) {
+			t.Errorf("expected %v but got: %v", []string{"a:9092", "b:9092"}, cfg.Brokers)
+		}
+		if cfg.DB.URL != "postgres://db" {
+			t.Errorf("expected nested fields to be set but got: %q", cfg.DB.URL)
+		}
+		if cfg.Unset != "kept" {
+			t.Errorf("expected unset variables to keep the value but got: %q", cfg.Unset)
+		}
+	})
+	t.Run("invalid values must fail", func(t *testing.T) {
+		t.Setenv("TEST_WORKERS", "many")
+
+		var cfg envConfig
+		if err := flexconfig.Env("TEST_").Load(context.Background(), &cfg); err == nil {
+			t.Error("expected an error for an invalid integer")
+		}
+	})
+}
+
+func TestFile(t *testing.T) {
+	t.Run("the file must be decoded", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(`{"Addr": ":9090"}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		var cfg envConfig
+		if err := flexconfig.File(path, json.Unmarshal).Load(context.Background(), &cfg); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if cfg.Addr != ":9090" {
+			t.Errorf("expected %q but got: %q", ":9090", cfg.Addr)
+		}
+	})
+	t.Run("a missing file must fail unless optional", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "missing.json")
+
+		var cfg envConfig
+		if err := flexconfig.File(path, json.Unmarshal).Load(context.Background(), &cfg); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %v but got: %v", os.ErrNotExist, err)
+		}
+		if err := flexconfig.File(path, json.Unmarshal).Optional().Load(context.Background(), &cfg); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+// Package flexcron provides a flex worker running a job on a schedule, either
+// a cron expression or a fixed interval:
+//
+//	cleanup := flexcron.New(flexcron.MustParse("*/15 * * * *"), purgeExpired,
+//		flexcron.WithTimeout(5*time.Minute),
+//	)
+//	heartbeat := flexcron.New(flexcron.Every(30*time.Second), sendHeartbeat)
+//
+//	flex.MustStart(ctx, cleanup, heartbeat)
+//
+// After and At return workers running a job once instead, at a future time:
+//
+//	warmup := flexcron.After(time.Minute, warmCaches)
+//
+// Runs never overlap: a run still in progress when the next one is due causes
+// the runs missed meanwhile to be skipped. A job panicking is recovered and
+// reported like a job failing, and neither stops the worker, unless the job
+// returns an error marked with flex.Fatal.
+//
+// Once halted, a run in progress is given the drain timeout to complete
+// before its context is cancelled.
+package flexcron
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long a run in progress is given to complete
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+var logger = log.New(os.Stderr, "flexcron: ", 0)
+
+// Job is the function run on a schedule.
+type Job func(ctx context.Context) error
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	timeout      time.Duration
+	drainTimeout time.Duration
+	onError      func(error)
+}
+
+// WithTimeout sets how long a run may take before its context is cancelled.
+// Runs are not limited by default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithDrainTimeout sets how long a run in progress is given to complete once
+// the worker is halted, after which its context is cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the errors of failed and
+// panicking runs, which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker running a job on a schedule.
+type Worker struct {
+	schedule Schedule
+	job      Job
+	opts     options
+	oneShot  bool
+
+	mu      sync.Mutex
+	halted  chan struct{}
+	cancel  context.CancelFunc
+	running chan struct{}
+}
+
+// New returns a Worker running job on schedule.
+func New(schedule Schedule, job Job, opts ...Option) *Worker {
+	w := &Worker{
+		schedule: schedule,
+		job:      job,
+		opts: options{
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+		halted: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// After returns a Worker running job once, delay after the worker starts.
+// See At for how the run is reported.
+func After(delay time.Duration, job Job, opts ...Option) *Worker {
+	w := New(Every(delay), job, opts...)
+	w.oneShot = true
+	return w
+}
+
+// At returns a Worker running job once at t, or right away if t has passed
+// when the worker starts. Run returns once the job did, with its error, so
+// that a failed run is reported, and possibly restarted, like the failure of
+// any worker. When the worker is halted or its context is done before t, the
+// job does not run and Run returns nil.
+func At(t time.Time, job Job, opts ...Option) *Worker {
+	w := New(at(t), job, opts...)
+	w.oneShot = true
+	return w
+}
+
+// at is the schedule of At.
+type at time.Time
+
+func (a at) Next(time.Time) time.Time { return time.Time(a) }
+
+// Run runs the job whenever it is due, until the worker is halted, its
+// context is done or the schedule has no more runs. It returns the error of a
+// run marked with flex.Fatal. The worker reports itself ready right away, and
+// may be run again once halted, as when it is restarted.
+func (w *Worker) Run(ctx context.Context) error {
+	w.mu.Lock()
+	halted := w.halted
+	w.mu.Unlock()
+
+	// Once halted, the worker is ready to be halted again by its next Run.
+	defer func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		select {
+		case <-halted:
+			w.halted = make(chan struct{})
+		default:
+		}
+	}()
+
+	flex.Ready(ctx)
+
+	next := w.schedule.Next(time.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-halted:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		err := w.run(ctx, halted)
+		if w.oneShot {
+			return err
+		}
+		if err != nil {
+			if flex.IsFatal(err) {
+				return err
+			}
+			w.opts.onError(err)
+		}
+
+		now := time.Now()
+		due := w.schedule.Next(next)
+		next = w.schedule.Next(now)
+		if !due.IsZero() && !next.IsZero() && due.Before(now) {
+			logger.Printf("run overran its schedule, skipping the runs due from %s to %s",
+				due.Format(time.RFC3339), next.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// run runs the job once, recovering it if it panics. Its context is not
+// cancelled by the shutdown, but by the timeout or Halt.
+func (w *Worker) run(ctx context.Context, halted <-chan struct{}) (err error) {
+	var cancel context.CancelFunc
+	if w.opts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), w.opts.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+
+	running := make(chan struct{})
+	w.mu.Lock()
+	select {
+	case <-halted:
+		w.mu.Unlock()
+		cancel()
+		return nil
+	default:
+	}
+	w.cancel, w.running = cancel, running
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.cancel, w.running = nil, nil
+		w.mu.Unlock()
+
+		cancel()
+		close(running)
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("flexcron: job panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	if err := w.job(ctx); err != nil {
+		return fmt.Errorf("flexcron: job failed: %w", err)
+	}
+	return nil
+}
+
+// Halt stops scheduling runs and gives a run in progress the drain timeout
+// to complete, or until the deadline of ctx if it is earlier. Once it expires
+// the run's context is cancelled, and an error reporting it is returned once
+// the run returned, or once ctx is done if the run ignores its context.
+func (w *Worker) Halt(ctx context.Context) error {
+	// A context already done when halting, as given by a manager without a
+	// halt timeout, does not bound the wait for the run.
+	abandon := ctx.Done()
+	if ctx.Err() != nil {
+		abandon = nil
+	}
+
+	w.mu.Lock()
+	select {
+	case <-w.halted:
+	default:
+		close(w.halted)
+	}
+	cancel, running := w.cancel, w.running
+	w.mu.Unlock()
+
+	if running == nil {
+		return nil
+	}
+
+	timeout := w.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-running:
+		return nil
+	case <-timer.C:
+		cancel()
+	}
+
+	select {
+	case <-running:
+		return fmt.Errorf("flexcron: run did not complete within %s, cancelled it", timeout)
+	case <-abandon:
+		return fmt.Errorf("flexcron: run did not complete within %s, abandoned it: %w", timeout, ctx.Err())
+	}
+}
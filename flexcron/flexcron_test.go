@@ -0,0 +1,288 @@
+package flexcron_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexcron"
+)
+
+func TestWorker(t *testing.T) {
+	t.Run("the job must run on schedule until halted", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		w := flexcron.New(flexcron.Every(5*time.Millisecond), func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		time.Sleep(50 * time.Millisecond)
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if n := runs.Load(); n < 2 {
+			t.Errorf("expected the job to run repeatedly, but it ran %d times", n)
+		}
+	})
+	t.Run("runs must not overlap", func(t *testing.T) {
+		t.Parallel()
+
+		var active, overlaps atomic.Int32
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(context.Context) error {
+			if active.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			return nil
+		})
+
+		go w.Run(context.Background())
+		time.Sleep(30 * time.Millisecond)
+		_ = w.Halt(context.Background())
+
+		if n := overlaps.Load(); n != 0 {
+			t.Errorf("expected no overlapping runs but got %d", n)
+		}
+	})
+	t.Run("failing and panicking jobs must be reported without stopping the worker", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu   sync.Mutex
+			errs []error
+			runs atomic.Int32
+		)
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(context.Context) error {
+			if runs.Add(1)%2 == 0 {
+				panic("boom")
+			}
+			return errors.New("job failed")
+		}, flexcron.WithErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}))
+
+		go w.Run(context.Background())
+		time.Sleep(20 * time.Millisecond)
+		_ = w.Halt(context.Background())
+
+		mu.Lock()
+		defer mu.Unlock()
+		var failed, panicked bool
+		for _, err := range errs {
+			failed = failed || strings.Contains(err.Error(), "job failed")
+			panicked = panicked || strings.Contains(err.Error(), "job panicked: boom")
+		}
+		if !failed || !panicked {
+			t.Errorf("expected both a failure and a panic to be reported, but got: %v", errs)
+		}
+	})
+	t.Run("fatal errors must stop the worker", func(t *testing.T) {
+		t.Parallel()
+
+		fatal := flex.Fatal(errors.New("misconfigured"))
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(context.Context) error { return fatal })
+
+		if err := w.Run(context.Background()); !errors.Is(err, fatal) {
+			t.Errorf("expected %v but got: %v", fatal, err)
+		}
+	})
+	t.Run("runs must be cancelled once the timeout expires", func(t *testing.T) {
+		t.Parallel()
+
+		errC := make(chan error, 1)
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(ctx context.Context) error {
+			<-ctx.Done()
+			return flex.Fatal(ctx.Err())
+		}, flexcron.WithTimeout(5*time.Millisecond))
+
+		go func() { errC <- w.Run(context.Background()) }()
+
+		if err := <-errC; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+		}
+	})
+	t.Run("a run in progress must complete when halted", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		var completed atomic.Bool
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(ctx context.Context) error {
+			close(started)
+			select {
+			case <-time.After(20 * time.Millisecond):
+				completed.Store(true)
+			case <-ctx.Done():
+			}
+			return nil
+		}, flexcron.WithTimeout(time.Hour))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go w.Run(ctx)
+
+		<-started
+		cancel()
+		if err := w.Halt(ctx); err != nil {
+			t.Error(err)
+		}
+		if !completed.Load() {
+			t.Error("expected the run to complete")
+		}
+	})
+	t.Run("a run outliving the drain timeout must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return nil
+		}, flexcron.WithDrainTimeout(10*time.Millisecond))
+
+		go w.Run(context.Background())
+
+		<-started
+		if err := w.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+	t.Run("a run ignoring its context must be abandoned at the halt deadline", func(t *testing.T) {
+		t.Parallel()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+
+		go w.Run(context.Background())
+
+		<-started
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		errC := make(chan error, 1)
+		go func() { errC <- w.Halt(ctx) }()
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected Halt to give up at its deadline")
+		}
+	})
+	t.Run("the worker must run again once halted", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		w := flexcron.New(flexcron.Every(time.Millisecond), func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		for range 2 {
+			runs.Store(0)
+			errC := make(chan error, 1)
+			go func() { errC <- w.Run(context.Background()) }()
+
+			for deadline := time.Now().Add(time.Second); runs.Load() == 0; time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("expected the job to run")
+				}
+			}
+			if err := w.Halt(context.Background()); err != nil {
+				t.Error(err)
+			}
+			if err := <-errC; err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func TestOneShot(t *testing.T) {
+	t.Run("After must run the job once after the delay", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		start := time.Now()
+		w := flexcron.After(20*time.Millisecond, func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		if err := w.Run(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("expected the job to run after the delay but it ran after: %s", elapsed)
+		}
+		if n := runs.Load(); n != 1 {
+			t.Errorf("expected the job to run %d time but it ran %d times", 1, n)
+		}
+	})
+	t.Run("At must run the job right away when its time has passed", func(t *testing.T) {
+		t.Parallel()
+
+		jobErr := errors.New("job failed")
+		w := flexcron.At(time.Now().Add(-time.Hour), func(context.Context) error { return jobErr })
+
+		if err := w.Run(context.Background()); !errors.Is(err, jobErr) {
+			t.Errorf("expected %v but got: %v", jobErr, err)
+		}
+	})
+	t.Run("the job must not run when halted first", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		w := flexcron.At(time.Now().Add(time.Hour), func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if n := runs.Load(); n != 0 {
+			t.Errorf("expected the job not to run but it ran %d times", n)
+		}
+	})
+	t.Run("a failed run must be reported to the manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		jobErr := errors.New("job failed")
+		m := flex.New(flex.WithSignals())
+		m.Add(flexcron.After(time.Millisecond, func(context.Context) error { return jobErr }))
+
+		if err := m.Start(ctx); !errors.Is(err, jobErr) {
+			t.Errorf("expected %v but got: %v", jobErr, err)
+		}
+	})
+}
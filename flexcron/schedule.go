@@ -0,0 +1,199 @@
+package flexcron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a job runs.
+type Schedule interface {
+	// Next returns the first time after t the job runs at, or the zero time
+	// if it does not run anymore.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a schedule running a job every d, counting from the end of
+// the previous run, so that a run overrunning the interval delays the next
+// one instead of piling up.
+func Every(d time.Duration) Schedule { return every(d) }
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+// descriptors are the shorthands accepted by Parse in place of the five fields.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression made of the five fields minute (0-59), hour
+// (0-23), day of month (1-31), month (1-12) and day of week (0-6, with Sunday
+// as 0 or 7). Each field is either *, a value, a range such as 1-5, or a list
+// of them such as 1,15,30-35, optionally stepped such as */15 or 8-18/2.
+//
+// The shorthands @yearly, @annually, @monthly, @weekly, @daily, @midnight
+// and @hourly are accepted, as is @every followed by a duration, such as
+// "@every 1m30s", which is Every with that duration.
+//
+// Times are computed in the location of the time given to Next.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("flexcron: parse %q: %w", expr, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("flexcron: parse %q: duration must be positive", expr)
+		}
+		return Every(d), nil
+	}
+	if fields, ok := descriptors[expr]; ok {
+		expr = fields
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("flexcron: parse %q: expected 5 fields but got %d", expr, len(fields))
+	}
+
+	var c cron
+	for i, bounds := range [...]struct {
+		field    *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	} {
+		bits, err := parseField(fields[i], bounds.min, bounds.max)
+		if err != nil {
+			return nil, fmt.Errorf("flexcron: parse %q: %w", expr, err)
+		}
+		*bounds.field = bits
+	}
+
+	// Sunday may be written as 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.anyDOM = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	c.anyDOW = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+
+	return &c, nil
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed.
+func MustParse(expr string) Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField parses a field of a cron expression into a bit set of the
+// values it matches.
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+
+			var err error
+			if lo, err = strconv.Atoi(loText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loText)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiText); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiText)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// cron is a schedule parsed from a cron expression, each field holding the
+// bit set of the values it matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDOM, anyDOW                bool
+}
+
+// maxYears bounds the search of Next, for expressions such as "0 0 30 2 *"
+// which never match.
+const maxYears = 5
+
+// Next returns the first minute after t matching the expression, or the zero
+// time if none does within the next years.
+func (c *cron) Next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	limit := t.AddDate(maxYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the expression. As in cron,
+// a day matches either of the day of month and day of week fields when both
+// are restricted.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
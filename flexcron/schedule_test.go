@@ -0,0 +1,61 @@
+package flexcron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexcron"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2026, time.January, 14, 10, 7, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.January, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.January, 14, 10, 15, 0, 0, time.UTC)},
+		{"5,10 * * * *", time.Date(2026, time.January, 14, 10, 10, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, time.January, 14, 13, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2026, time.January, 15, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, time.January, 14, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	} {
+		t.Run(tc.expr+" must be parsed", func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := flexcron.Parse(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next := schedule.Next(from); !next.Equal(tc.want) {
+				t.Errorf("expected %v but got: %v", tc.want, next)
+			}
+		})
+	}
+
+	t.Run("expressions never matching must have no next run", func(t *testing.T) {
+		t.Parallel()
+
+		if next := flexcron.MustParse("0 0 30 2 *").Next(from); !next.IsZero() {
+			t.Errorf("expected no next run but got: %v", next)
+		}
+	})
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every -1s"} {
+		t.Run(expr+" must be rejected", func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := flexcron.Parse(expr); err == nil {
+				t.Error("expected an error but did not get one")
+			}
+		})
+	}
+}
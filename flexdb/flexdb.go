@@ -0,0 +1,202 @@
+// Package flexdb provides a flex worker owning the lifecycle of a *sql.DB.
+//
+// The worker holds readiness until the database is reachable, then verifies
+// connectivity periodically, and closes the pool once halted. It should be
+// halted after the workers using the database, by giving it a higher shutdown
+// priority:
+//
+//	db, err := sql.Open("pgx", dsn)
+//	if err != nil {
+//		return err
+//	}
+//
+//	m := flex.New()
+//	m.Add(flexdb.New(db), flex.WithName("db"), flex.WithPriority(1))
+//	m.Add(api)
+//
+// The worker implements flex.HealthReporter by pinging the database, so that
+// the health of the manager, see flex.Manager.Health, reflects outages. The
+// outcome of the last verification is reported by Check.
+package flexdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultCheckInterval is how often connectivity is verified when no
+	// check interval is configured.
+	DefaultCheckInterval = 10 * time.Second
+	// DefaultPingTimeout is how long a ping may take when no ping timeout is
+	// configured.
+	DefaultPingTimeout = 5 * time.Second
+	// DefaultMinBackoff is the default delay before pinging again after the
+	// first ping failed.
+	DefaultMinBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff is the default maximum delay between pings until the
+	// database is reachable.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// ErrNotConnected is returned by Check until the database was first reached.
+var ErrNotConnected = errors.New("flexdb: not connected")
+
+var logger = log.New(os.Stderr, "flexdb: ", 0)
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	checkInterval time.Duration
+	pingTimeout   time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+}
+
+// WithCheckInterval sets how often connectivity is verified once the database
+// was reached.
+func WithCheckInterval(d time.Duration) Option {
+	return func(o *options) { o.checkInterval = d }
+}
+
+// WithPingTimeout sets how long a ping may take.
+func WithPingTimeout(d time.Duration) Option {
+	return func(o *options) { o.pingTimeout = d }
+}
+
+// WithPingBackoff sets the bounds of the exponential backoff between pings
+// until the database is reachable.
+func WithPingBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// Worker is a flex worker which owns a database pool.
+type Worker struct {
+	db   *sql.DB
+	opts options
+
+	mu  sync.Mutex
+	err error
+}
+
+// New returns a Worker owning db.
+func New(db *sql.DB, opts ...Option) *Worker {
+	w := &Worker{
+		db: db,
+		opts: options{
+			checkInterval: DefaultCheckInterval,
+			pingTimeout:   DefaultPingTimeout,
+			minBackoff:    DefaultMinBackoff,
+			maxBackoff:    DefaultMaxBackoff,
+		},
+		err: ErrNotConnected,
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// DB returns the database pool owned by the worker.
+func (w *Worker) DB() *sql.DB {
+	return w.db
+}
+
+// Run pings the database with backoff until it is reachable, then reports the
+// worker ready and verifies connectivity periodically until the context is
+// done.
+func (w *Worker) Run(ctx context.Context) error {
+	backoff := w.opts.minBackoff
+	for {
+		err := w.ping(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Printf("pinging again in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.opts.maxBackoff)
+	}
+
+	flex.Ready(ctx)
+
+	ticker := time.NewTicker(w.opts.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.ping(ctx); err != nil && ctx.Err() == nil {
+				logger.Print(err)
+			}
+		}
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once the database
+// is reachable, see flex.ReadyReporter.
+func (w *Worker) ReportsReady() bool { return true }
+
+// ping pings the database and records the outcome, unless the context is
+// done.
+func (w *Worker) ping(ctx context.Context) error {
+	pingCtx, cancel := context.WithTimeout(ctx, w.opts.pingTimeout)
+	defer cancel()
+
+	err := w.db.PingContext(pingCtx)
+	if err != nil {
+		err = fmt.Errorf("flexdb: ping: %w", err)
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	// Until the database was first reached, it is reported as not connected.
+	if err == nil || !errors.Is(w.err, ErrNotConnected) {
+		w.err = err
+	}
+	w.mu.Unlock()
+	return err
+}
+
+// Check returns the error of the last verification of connectivity, or
+// ErrNotConnected if the database was not reached yet.
+func (w *Worker) Check(context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Health pings the database, for at most the ping timeout or until ctx is
+// done, and returns the error of the ping, see flex.HealthReporter.
+func (w *Worker) Health(ctx context.Context) error {
+	return w.ping(ctx)
+}
+
+// Halt closes the database pool, waiting for the queries in progress to
+// complete.
+func (w *Worker) Halt(context.Context) error {
+	if err := w.db.Close(); err != nil {
+		return fmt.Errorf("flexdb: close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package flexdb_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexdb"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// mockConnector connects to a database which is reachable once up is set.
+type mockConnector struct {
+	mu    sync.Mutex
+	up    bool
+	pings int
+}
+
+func (c *mockConnector) setUp(up bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.up = up
+}
+
+func (c *mockConnector) pingCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pings
+}
+
+func (c *mockConnector) Connect(context.Context) (driver.Conn, error) {
+	return &mockConn{c}, nil
+}
+
+func (c *mockConnector) Driver() driver.Driver { return nil }
+
+type mockConn struct{ c *mockConnector }
+
+func (c *mockConn) Ping(context.Context) error {
+	c.c.mu.Lock()
+	defer c.c.mu.Unlock()
+	c.c.pings++
+	if !c.c.up {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *mockConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (c *mockConn) Close() error                        { return nil }
+func (c *mockConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+// waitCheck waits for Check to report want.
+func waitCheck(t *testing.T, w *flexdb.Worker, want func(error) bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !want(w.Check(context.Background())); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected check result: %v", w.Check(context.Background()))
+		}
+	}
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("readiness must be held until the database is reachable", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{}
+		w := flexdb.New(sql.OpenDB(connector), flexdb.WithPingBackoff(time.Millisecond, time.Millisecond))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(w)
+		h := flextest.Start(t, m)
+
+		for connector.pingCount() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		select {
+		case <-m.Started():
+			t.Fatal("expected the worker not to be ready")
+		default:
+		}
+		if err := w.Check(context.Background()); !errors.Is(err, flexdb.ErrNotConnected) {
+			t.Errorf("expected %v but got: %v", flexdb.ErrNotConnected, err)
+		}
+
+		connector.setUp(true)
+		select {
+		case <-m.Started():
+		case <-time.After(time.Second):
+			t.Fatal("expected the worker to be ready")
+		}
+		if err := w.Check(context.Background()); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.DB().Ping(); err == nil {
+			t.Error("expected the pool to be closed")
+		}
+	})
+	t.Run("connectivity must be verified periodically", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{up: true}
+		w := flexdb.New(sql.OpenDB(connector), flexdb.WithCheckInterval(time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go w.Run(ctx)
+
+		waitCheck(t, w, func(err error) bool { return err == nil })
+		connector.setUp(false)
+		waitCheck(t, w, func(err error) bool { return err != nil && !errors.Is(err, flexdb.ErrNotConnected) })
+		connector.setUp(true)
+		waitCheck(t, w, func(err error) bool { return err == nil })
+	})
+	t.Run("outages must be reported to the health of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{up: true}
+		w := flexdb.New(sql.OpenDB(connector), flexdb.WithCheckInterval(time.Hour))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(w)
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		if health := m.Health(context.Background()); health.Status() != flex.StatusHealthy {
+			t.Errorf("expected the manager to be healthy but got: %+v", health)
+		}
+
+		connector.setUp(false)
+		health := m.Health(context.Background())
+		if health.Ready || !errors.Is(health.Workers[0].Err, driver.ErrBadConn) {
+			t.Errorf("expected the outage to be reported but got: %+v", health)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package flexdebug
+
+import (
+	"expvar"
+	"fmt"
+
+	"github.com/go-flexible/flex"
+)
+
+// workerVar is the expvar representation of the status of a worker.
+type workerVar struct {
+	State         string  `json:"state"`
+	Restarts      int     `json:"restarts"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	LastError     string  `json:"last_error,omitempty"`
+}
+
+// Publish publishes the lifecycle state of the workers of m under name with
+// expvar, so that it is served under /debug/vars along with the other
+// variables, see WithExpvar. Each worker is keyed by its name, see
+// flex.WithName, suffixed with "#2", "#3" and so on for the workers sharing
+// the name of a previous one, such as unnamed workers of the same type, and
+// reported with its state, restart count, uptime and last error:
+//
+//	"flex": {"workers": {"api": {"state":"running","restarts":0,"uptime_seconds":3600}}}
+//
+// The state is read whenever the variables are. Like expvar.Publish, Publish
+// panics if name is already in use.
+func Publish(name string, m *flex.Manager) {
+	expvar.Publish(name, expvar.Func(func() any {
+		workers := make(map[string]workerVar)
+		for _, status := range m.Status() {
+			v := workerVar{
+				State:         status.State.String(),
+				Restarts:      status.Restarts,
+				UptimeSeconds: status.Uptime.Seconds(),
+			}
+			if status.LastErr != nil {
+				v.LastError = status.LastErr.Error()
+			}
+			key := status.Name
+			for n := 2; ; n++ {
+				if _, ok := workers[key]; !ok {
+					break
+				}
+				key = fmt.Sprintf("%s#%d", status.Name, n)
+			}
+			workers[key] = v
+		}
+		return map[string]any{"workers": workers}
+	}))
+}
@@ -0,0 +1,89 @@
+package flexdebug_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexdebug"
+	"github.com/go-flexible/flex/flextest"
+)
+
+func TestPublish(t *testing.T) {
+	t.Run("the state of the workers must be published", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(flexdebug.New(addr, flexdebug.WithExpvar()), flex.WithName("debug"))
+		// expvar names cannot be reused, even by repeated runs of the test.
+		name := "flex_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		flexdebug.Publish(name, m)
+
+		var before struct {
+			Workers map[string]struct {
+				State string `json:"state"`
+			} `json:"workers"`
+		}
+		if err := json.Unmarshal([]byte(expvar.Get(name).String()), &before); err != nil {
+			t.Fatal(err)
+		}
+		if state := before.Workers["debug"].State; state != flex.StateIdle.String() {
+			t.Errorf("expected %q but got: %q", flex.StateIdle, state)
+		}
+
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+		<-m.Started()
+
+		resp, err := http.Get("http://" + addr + "/debug/vars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var vars map[string]json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+			t.Fatal(err)
+		}
+		var published struct {
+			Workers map[string]struct {
+				State         string  `json:"state"`
+				Restarts      int     `json:"restarts"`
+				UptimeSeconds float64 `json:"uptime_seconds"`
+			} `json:"workers"`
+		}
+		if err := json.Unmarshal(vars[name], &published); err != nil {
+			t.Fatal(err)
+		}
+		if debug, ok := published.Workers["debug"]; !ok || debug.State != flex.StateRunning.String() || debug.Restarts != 0 || debug.UptimeSeconds < 0 {
+			t.Errorf("unexpected workers: %+v", published.Workers)
+		}
+	})
+	t.Run("workers sharing a name must all be published", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(flexdebug.New(flextest.Addr(t)))
+		m.Add(flexdebug.New(flextest.Addr(t)))
+		name := "flex_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		flexdebug.Publish(name, m)
+
+		var published struct {
+			Workers map[string]struct{} `json:"workers"`
+		}
+		if err := json.Unmarshal([]byte(expvar.Get(name).String()), &published); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"*flexhttp.Server", "*flexhttp.Server#2"} {
+			if _, ok := published.Workers[key]; !ok {
+				t.Errorf("expected %q to be published but got: %v", key, published.Workers)
+			}
+		}
+	})
+}
@@ -0,0 +1,58 @@
+// Package flexdebug provides a flex worker serving the profiling endpoints of
+// net/http/pprof, and optionally the variables of expvar, on a separate
+// address, so that they are never exposed alongside the service's own
+// endpoints:
+//
+//	flex.MustStart(ctx, api, flexdebug.New("localhost:6060", flexdebug.WithExpvar()))
+//
+// The profiles are then served under /debug/pprof/, and the variables under
+// /debug/vars. Publish adds the lifecycle state of the workers of a manager to
+// the variables.
+package flexdebug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/go-flexible/flex/flexhttp"
+)
+
+// Option configures the debug server.
+type Option func(*options)
+
+type options struct {
+	expvar bool
+	http   []flexhttp.Option
+}
+
+// WithExpvar serves the variables published with expvar under /debug/vars.
+func WithExpvar() Option {
+	return func(o *options) { o.expvar = true }
+}
+
+// WithServerOptions sets options of the underlying flexhttp.Server, such as
+// its drain timeout.
+func WithServerOptions(opts ...flexhttp.Option) Option {
+	return func(o *options) { o.http = append(o.http, opts...) }
+}
+
+// New returns a worker serving the profiling endpoints on addr.
+func New(addr string, opts ...Option) *flexhttp.Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	if o.expvar {
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+
+	return flexhttp.New(&http.Server{Addr: addr, Handler: mux}, o.http...)
+}
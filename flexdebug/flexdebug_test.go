@@ -0,0 +1,58 @@
+package flexdebug_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexdebug"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// status returns the status code of a GET of url.
+func status(t *testing.T, url string) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNew(t *testing.T) {
+	t.Run("profiling endpoints must be served", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(flexdebug.New(addr))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/goroutine"} {
+			if code := status(t, "http://"+addr+path); code != http.StatusOK {
+				t.Errorf("expected %s to be served but got: %d", path, code)
+			}
+		}
+		if code := status(t, "http://"+addr+"/debug/vars"); code != http.StatusNotFound {
+			t.Errorf("expected expvar not to be served by default but got: %d", code)
+		}
+	})
+	t.Run("expvar must be served when enabled", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(flexdebug.New(addr, flexdebug.WithExpvar()))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+
+		if code := status(t, "http://"+addr+"/debug/vars"); code != http.StatusOK {
+			t.Errorf("expected expvar to be served but got: %d", code)
+		}
+	})
+}
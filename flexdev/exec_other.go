@@ -0,0 +1,30 @@
+//go:build !unix
+
+package flexdev
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// reexec runs exe as a child process and exits with its exit code, since the
+// current process cannot be replaced in place on this platform.
+func reexec(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package flexdev
+
+import (
+	"os"
+	"syscall"
+)
+
+// reexec replaces the current process with exe.
+func reexec(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,126 @@
+// Package flexdev provides helpers for running flex services during local
+// development, such as restarting the process whenever it is rebuilt.
+package flexdev
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+var logger = log.New(os.Stderr, "flexdev: ", 0)
+
+// DefaultInterval is the polling interval used by a Watcher when none is set.
+const DefaultInterval = 500 * time.Millisecond
+
+// ErrChanged is returned by a Watcher when one of its watched files changed.
+var ErrChanged = errors.New("flexdev: watched file changed")
+
+// Watcher is a worker which polls a set of files and stops the service as
+// soon as one of them changes.
+type Watcher struct {
+	// Paths are the files being watched.
+	Paths []string
+	// Interval is how often the files are checked for changes.
+	Interval time.Duration
+}
+
+// NewWatcher returns a Watcher for the given paths.
+// When no paths are given, the running executable is watched.
+func NewWatcher(paths ...string) (*Watcher, error) {
+	if len(paths) == 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{exe}
+	}
+	return &Watcher{Paths: paths, Interval: DefaultInterval}, nil
+}
+
+// Run blocks until the context is done, or returns ErrChanged once any of the
+// watched files has changed and has stopped changing for one interval, so that
+// a binary which is still being written is not picked up half way.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	last := w.snapshot()
+	changed := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			current := w.snapshot()
+			if current != last {
+				last, changed = current, true
+				continue
+			}
+			if changed {
+				return ErrChanged
+			}
+		}
+	}
+}
+
+// Halt is a no-op, the watcher stops when its context is done.
+func (w *Watcher) Halt(context.Context) error { return nil }
+
+// snapshot returns a value which changes whenever any watched file does.
+func (w *Watcher) snapshot() (sum int64) {
+	for _, path := range w.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		sum += info.ModTime().UnixNano() + info.Size()
+	}
+	return sum
+}
+
+// MustStart is like Start, but logs the error and exits with status 1 if
+// there is an error.
+func MustStart(ctx context.Context, workers ...flex.Worker) {
+	if err := Start(ctx, workers...); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// Start runs the workers alongside a Watcher for the running executable.
+// When the executable changes, the workers are halted gracefully and the
+// process replaces itself with the new binary, using the same arguments and
+// environment. Since all workers have halted before the new binary starts,
+// listeners are released and can be bound again without being inherited.
+func Start(ctx context.Context, workers ...flex.Worker) error {
+	watcher, err := NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = flex.Start(ctx, append(workers, watcher)...)
+	if !isChanged(err) {
+		return err
+	}
+
+	logger.Println("executable changed, restarting")
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return reexec(exe)
+}
+
+// isChanged reports whether err is, or contains, ErrChanged.
+func isChanged(err error) bool { return errors.Is(err, ErrChanged) }
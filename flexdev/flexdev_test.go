@@ -0,0 +1,77 @@
+package flexdev_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexdev"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Run("a changed file must stop the watcher", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "binary")
+		if err := os.WriteFile(path, []byte("v1"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		w, err := flexdev.NewWatcher(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Interval = 10 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		time.Sleep(50 * time.Millisecond)
+		if err := os.WriteFile(path, []byte("v2, but longer"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-errC; !errors.Is(err, flexdev.ErrChanged) {
+			t.Errorf("expected %v but got: %v", flexdev.ErrChanged, err)
+		}
+	})
+	t.Run("a cancelled context must stop the watcher without error", func(t *testing.T) {
+		t.Parallel()
+
+		w, err := flexdev.NewWatcher(filepath.Join(t.TempDir(), "missing"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Interval = 10 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := w.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("no paths must watch the executable", func(t *testing.T) {
+		t.Parallel()
+
+		w, err := flexdev.NewWatcher()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(w.Paths) != 1 || w.Paths[0] != exe {
+			t.Errorf("expected paths to be [%s], but got %v", exe, w.Paths)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+// Package flexfasthttp provides a flex worker serving HTTP with a
+// *fasthttp.Server.
+//
+//	srv := flexfasthttp.New(":8080", &fasthttp.Server{Handler: handler},
+//		flexfasthttp.WithDrainTimeout(20*time.Second),
+//	)
+//
+//	flex.MustStart(ctx, srv)
+//
+// Once halted, the server is shut down with ShutdownWithContext, and in-flight
+// requests are given the drain timeout to complete, after which the remaining
+// connections are closed.
+package flexfasthttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long in-flight requests are given to complete
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+var logger = log.New(os.Stderr, "flexfasthttp: ", 0)
+
+// HTTPServer is the part of *fasthttp.Server used by the worker.
+type HTTPServer interface {
+	Serve(lis net.Listener) error
+	ShutdownWithContext(ctx context.Context) error
+}
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	drainTimeout time.Duration
+}
+
+// WithDrainTimeout sets how long in-flight requests are given to complete
+// once the server is halted.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// Server is a flex worker serving HTTP with a fasthttp server.
+type Server struct {
+	addr string
+	srv  HTTPServer
+	opts options
+
+	mu      sync.Mutex
+	lis     net.Listener
+	conns   map[*conn]struct{}
+	halting bool
+}
+
+// New returns a Server serving srv on addr.
+func New(addr string, srv HTTPServer, opts ...Option) *Server {
+	s := &Server{
+		addr:  addr,
+		srv:   srv,
+		opts:  options{drainTimeout: DefaultDrainTimeout},
+		conns: make(map[*conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	return s
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// OpenConns returns the number of open connections.
+func (s *Server) OpenConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Run listens on the server's address and serves until the server is halted.
+// The worker reports itself ready once it is listening. Errors of the
+// listener, such as when accepting connections fails, are returned.
+func (s *Server) Run(ctx context.Context) error {
+	addr := s.addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("flexfasthttp: listen: %w", err)
+	}
+
+	s.mu.Lock()
+	s.lis = lis
+	s.halting = false
+	s.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	err = s.srv.Serve(&listener{Listener: lis, s: s})
+	if err != nil && !(s.closing() && errors.Is(err, net.ErrClosed)) {
+		return fmt.Errorf("flexfasthttp: serve: %w", err)
+	}
+	return nil
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is
+// listening, see flex.ReadyReporter.
+func (s *Server) ReportsReady() bool { return true }
+
+// Halt gracefully shuts the server down, giving in-flight requests the drain
+// timeout to complete, or until the deadline of ctx if it is earlier. Once it
+// expires the remaining connections are closed, and an error reporting them
+// is returned.
+func (s *Server) Halt(ctx context.Context) error {
+	s.mu.Lock()
+	s.halting = true
+	s.mu.Unlock()
+
+	if open := s.OpenConns(); open > 0 {
+		logger.Printf("draining %d connections", open)
+	}
+
+	timeout := s.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	err := s.srv.ShutdownWithContext(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		closed := s.closeConns()
+		logger.Printf("drain did not complete within %s, closed %d connections", timeout, closed)
+		return fmt.Errorf("flexfasthttp: drain did not complete within %s, closed %d connections",
+			timeout, closed)
+	case err != nil:
+		return fmt.Errorf("flexfasthttp: shutdown: %w", err)
+	}
+	return nil
+}
+
+// closing reports whether the worker is being halted.
+func (s *Server) closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.halting
+}
+
+// closeConns closes the open connections, and returns how many there were.
+func (s *Server) closeConns() int {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+	return len(conns)
+}
+
+// listener tracks the connections it accepts, so that those remaining once
+// the drain timeout expires can be closed.
+type listener struct {
+	net.Listener
+	s *Server
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	nc, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{Conn: nc, s: l.s}
+	l.s.mu.Lock()
+	l.s.conns[c] = struct{}{}
+	l.s.mu.Unlock()
+	return c, nil
+}
+
+// conn is a tracked connection.
+type conn struct {
+	net.Conn
+	s    *Server
+	once sync.Once
+}
+
+func (c *conn) Close() error {
+	c.once.Do(func() {
+		c.s.mu.Lock()
+		delete(c.s.conns, c)
+		c.s.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
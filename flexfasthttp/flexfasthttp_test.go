@@ -0,0 +1,137 @@
+package flexfasthttp_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexfasthttp"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// stdServer adapts an *http.Server to the HTTPServer interface, behaving as a
+// fasthttp server does once shut down.
+type stdServer struct{ *http.Server }
+
+func (s stdServer) Serve(lis net.Listener) error {
+	if err := s.Server.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s stdServer) ShutdownWithContext(ctx context.Context) error { return s.Shutdown(ctx) }
+
+// failingServer fails to serve.
+type failingServer struct{ err error }
+
+func (s failingServer) Serve(net.Listener) error                  { return s.err }
+func (s failingServer) ShutdownWithContext(context.Context) error { return nil }
+
+func TestServer(t *testing.T) {
+	t.Run("in-flight requests must complete when halted", func(t *testing.T) {
+		t.Parallel()
+
+		handling := make(chan struct{})
+		srv := flexfasthttp.New(flextest.Addr(t), stdServer{&http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(handling)
+			time.Sleep(50 * time.Millisecond)
+			io.WriteString(w, "ok")
+		})}})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		<-m.Started()
+
+		respC := make(chan string, 1)
+		go func() {
+			resp, err := http.Get("http://" + srv.Addr().String())
+			if err != nil {
+				respC <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			respC <- string(body)
+		}()
+		<-handling
+
+		if n := srv.OpenConns(); n != 1 {
+			t.Errorf("expected %d open connection but got: %d", 1, n)
+		}
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if body := <-respC; body != "ok" {
+			t.Errorf("expected the request to complete but got: %q", body)
+		}
+	})
+	t.Run("connections outliving the drain timeout must be closed", func(t *testing.T) {
+		t.Parallel()
+
+		handling := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		addr := flextest.Addr(t)
+		srv := flexfasthttp.New(addr, stdServer{&http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			close(handling)
+			<-release
+		})}}, flexfasthttp.WithDrainTimeout(20*time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- srv.Run(context.Background()) }()
+		flextest.WaitListening(t, addr)
+
+		reqErr := make(chan error, 1)
+		go func() {
+			resp, err := http.Get("http://" + addr)
+			if err == nil {
+				resp.Body.Close()
+			}
+			reqErr <- err
+		}()
+		<-handling
+
+		if err := srv.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if err := <-reqErr; err == nil {
+			t.Error("expected the request to fail once its connection is closed")
+		}
+		if n := srv.OpenConns(); n != 0 {
+			t.Errorf("expected %d open connections but got: %d", 0, n)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("listener errors must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		srv := flexfasthttp.New(lis.Addr().String(), stdServer{&http.Server{}})
+		if err := srv.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+	t.Run("serve errors must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		serveErr := errors.New("accept failed")
+		srv := flexfasthttp.New("127.0.0.1:0", failingServer{serveErr})
+		if err := srv.Run(context.Background()); !errors.Is(err, serveErr) {
+			t.Errorf("expected %v but got: %v", serveErr, err)
+		}
+	})
+}
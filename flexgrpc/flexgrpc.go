@@ -0,0 +1,307 @@
+// Package flexgrpc provides a flex worker for serving gRPC.
+//
+// The worker does not depend on grpc-go, instead it drives any type
+// satisfying GRPCServer, which *grpc.Server does:
+//
+//	var streams flexgrpc.StreamCounter
+//	srv := grpc.NewServer(grpc.ChainStreamInterceptor(
+//		func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+//			defer streams.Track()()
+//			return handler(srv, ss)
+//		},
+//	))
+//	pb.RegisterGreeterServer(srv, &greeter{})
+//
+//	hs := health.NewServer()
+//	flex.MustStart(ctx, flexgrpc.New(":9090", srv,
+//		flexgrpc.WithStreamCounter(&streams),
+//		flexgrpc.WithHealth(hs, func() { healthgrpc.RegisterHealthServer(srv, hs) }),
+//		flexgrpc.WithReflection(func() { reflection.Register(srv) }),
+//	))
+//
+// Errors of the listener, such as an address already in use, and of Serve
+// are returned by Run, and so reach the manager like any worker error.
+//
+// A Gateway serves a Server along with a grpc-gateway reverse proxy to it, as
+// a single worker.
+//
+// A HealthSync publishes the health of the workers of its manager through the
+// standard gRPC health service, for gRPC load balancers and probes.
+package flexgrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long in-progress RPCs are given to complete
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+// GRPCServer is the subset of *grpc.Server used by Server.
+type GRPCServer interface {
+	Serve(net.Listener) error
+	GracefulStop()
+	Stop()
+}
+
+// HealthServer is the subset of *health.Server, the standard gRPC health
+// service, used by Server.
+type HealthServer interface {
+	Resume()
+	Shutdown()
+}
+
+// StreamCounter counts in-flight streams, it is meant to be updated from a
+// stream interceptor so that Server can report streams it had to terminate.
+// The zero value is ready to use.
+type StreamCounter struct{ active atomic.Int64 }
+
+// Track records a stream as in flight until the returned function is called.
+func (c *StreamCounter) Track() (done func()) {
+	c.active.Add(1)
+	return func() { c.active.Add(-1) }
+}
+
+// Active returns the number of streams in flight.
+func (c *StreamCounter) Active() int { return int(c.active.Load()) }
+
+// DrainReport describes how the last drain of a Server went.
+type DrainReport struct {
+	// Duration is how long the drain took.
+	Duration time.Duration
+	// Forced is set when the drain timeout expired and the server was stopped
+	// without waiting for in-progress RPCs.
+	Forced bool
+	// TerminatedStreams is the number of streams still in flight when the
+	// server was stopped, it is only known when a StreamCounter is configured.
+	TerminatedStreams int
+	// TerminatedConns is the number of connections still open when the server
+	// was stopped.
+	TerminatedConns int
+}
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	drainTimeout time.Duration
+	streams      *StreamCounter
+	health       HealthServer
+	register     []func()
+}
+
+// WithDrainTimeout sets how long in-progress RPCs, including long-lived
+// streams, are given to complete once GOAWAY has been sent, after which the
+// server is stopped forcibly.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithStreamCounter sets the counter used to report streams terminated by a
+// forced stop.
+func WithStreamCounter(c *StreamCounter) Option {
+	return func(o *options) { o.streams = c }
+}
+
+// WithHealth registers hs, the standard gRPC health service, by calling
+// register, which may be nil if it is already registered. The server is
+// reported as serving once it listens, and as not serving as soon as it is
+// halted so that clients stop sending it RPCs before it drains.
+func WithHealth(hs HealthServer, register func()) Option {
+	return func(o *options) {
+		o.health = hs
+		if register != nil {
+			o.register = append(o.register, register)
+		}
+	}
+}
+
+// WithReflection registers the gRPC reflection service by calling register,
+// typically func() { reflection.Register(srv) }.
+func WithReflection(register func()) Option {
+	return func(o *options) { o.register = append(o.register, register) }
+}
+
+// Server is a flex worker serving gRPC on a TCP address.
+type Server struct {
+	srv  GRPCServer
+	addr string
+	opts options
+
+	conns atomic.Int64
+
+	mu     sync.Mutex
+	lis    net.Listener
+	report DrainReport
+}
+
+// New returns a Server serving srv on addr. The services of WithHealth and
+// WithReflection are registered with srv right away, as gRPC requires
+// services to be registered before serving.
+func New(addr string, srv GRPCServer, opts ...Option) *Server {
+	s := &Server{
+		srv:  srv,
+		addr: addr,
+		opts: options{drainTimeout: DefaultDrainTimeout},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	for _, register := range s.opts.register {
+		register()
+	}
+	return s
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Run listens on the server's address and serves until the server is halted.
+// The worker reports itself ready once it is listening.
+func (s *Server) Run(ctx context.Context) error {
+	lis, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	s.resume()
+	flex.Ready(ctx)
+
+	return s.serve(lis)
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is
+// listening, see flex.ReadyReporter.
+func (s *Server) ReportsReady() bool { return true }
+
+// listen listens on the server's address.
+func (s *Server) listen() (net.Listener, error) {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("flexgrpc: listen: %w", err)
+	}
+	lis = &countingListener{Listener: lis, conns: &s.conns}
+
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+	return lis, nil
+}
+
+// resume reports the server as serving, if it has a health service.
+func (s *Server) resume() {
+	if s.opts.health != nil {
+		s.opts.health.Resume()
+	}
+}
+
+// shutdown reports the server as not serving, if it has a health service.
+func (s *Server) shutdown() {
+	if s.opts.health != nil {
+		s.opts.health.Shutdown()
+	}
+}
+
+// serve serves on lis until the server is stopped.
+func (s *Server) serve(lis net.Listener) error {
+	if err := s.srv.Serve(lis); err != nil {
+		return fmt.Errorf("flexgrpc: serve: %w", err)
+	}
+	return nil
+}
+
+// Halt gracefully stops the server: GOAWAY is sent to clients, new RPCs are
+// refused, and in-progress RPCs are given the drain timeout to complete, or
+// until the deadline of ctx if it is earlier. Once it expires the server is
+// stopped forcibly, and an error reporting the terminated streams and
+// connections is returned.
+func (s *Server) Halt(ctx context.Context) error {
+	start := time.Now()
+	s.shutdown()
+
+	timeout := s.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+
+	report := DrainReport{}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		report.Forced = true
+		report.TerminatedConns = int(s.conns.Load())
+		if s.opts.streams != nil {
+			report.TerminatedStreams = s.opts.streams.Active()
+		}
+		s.srv.Stop()
+		<-stopped
+	}
+	report.Duration = time.Since(start)
+
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	if report.Forced {
+		return fmt.Errorf("flexgrpc: drain did not complete within %s, terminated %d streams and %d connections",
+			timeout, report.TerminatedStreams, report.TerminatedConns)
+	}
+	return nil
+}
+
+// DrainReport returns the report of the last drain.
+func (s *Server) DrainReport() DrainReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
+
+// countingListener counts the connections it accepted which are still open.
+type countingListener struct {
+	net.Listener
+	conns *atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.conns.Add(1)
+	return &countingConn{Conn: conn, conns: l.conns}, nil
+}
+
+// countingConn decrements its listener's count once closed.
+type countingConn struct {
+	net.Conn
+	conns *atomic.Int64
+	once  sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() { c.conns.Add(-1) })
+	return c.Conn.Close()
+}
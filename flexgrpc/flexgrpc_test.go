@@ -0,0 +1,213 @@
+package flexgrpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexgrpc"
+)
+
+// mockGRPCServer mimics the stop semantics of *grpc.Server: every accepted
+// connection holds a stream open until the client closes it, GracefulStop
+// waits for all of them, and Stop closes them.
+type mockGRPCServer struct {
+	streams *flexgrpc.StreamCounter
+
+	mu    sync.Mutex
+	lis   net.Listener
+	conns []net.Conn
+	wg    sync.WaitGroup
+}
+
+func (s *mockGRPCServer) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return nil
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			defer s.streams.Track()()
+			_, _ = conn.Read(make([]byte, 1))
+		}()
+	}
+}
+
+func (s *mockGRPCServer) GracefulStop() {
+	s.mu.Lock()
+	_ = s.lis.Close()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *mockGRPCServer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.lis.Close()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func start(t *testing.T, opts ...flexgrpc.Option) (*flexgrpc.Server, net.Addr) {
+	t.Helper()
+
+	streams := &flexgrpc.StreamCounter{}
+	s := flexgrpc.New("127.0.0.1:0", &mockGRPCServer{streams: streams},
+		append([]flexgrpc.Option{flexgrpc.WithStreamCounter(streams)}, opts...)...)
+
+	go func() { _ = s.Run(context.Background()) }()
+
+	deadline := time.Now().Add(time.Second)
+	for s.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return s, s.Addr()
+}
+
+// mockHealthServer records the serving status reported by a Server.
+type mockHealthServer struct {
+	mu      sync.Mutex
+	serving bool
+	changes int
+}
+
+func (h *mockHealthServer) Resume()   { h.set(true) }
+func (h *mockHealthServer) Shutdown() { h.set(false) }
+
+func (h *mockHealthServer) set(serving bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.serving = serving
+	h.changes++
+}
+
+func (h *mockHealthServer) status() (serving bool, changes int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.serving, h.changes
+}
+
+func TestServer(t *testing.T) {
+	t.Run("streams completing within the drain timeout must drain gracefully", func(t *testing.T) {
+		t.Parallel()
+
+		s, addr := start(t)
+
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.AfterFunc(20*time.Millisecond, func() { _ = conn.Close() })
+
+		if err := s.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if report := s.DrainReport(); report.Forced {
+			t.Errorf("expected a graceful drain, but got %+v", report)
+		}
+	})
+	t.Run("streams outliving the drain timeout must be terminated and reported", func(t *testing.T) {
+		t.Parallel()
+
+		s, addr := start(t, flexgrpc.WithDrainTimeout(20*time.Millisecond))
+
+		for range 2 {
+			conn, err := net.Dial("tcp", addr.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if err := s.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+
+		report := s.DrainReport()
+		if !report.Forced || report.TerminatedStreams != 2 || report.TerminatedConns != 2 {
+			t.Errorf("expected 2 terminated streams and connections, but got %+v", report)
+		}
+	})
+	t.Run("streams outliving the deadline of the halt must be terminated", func(t *testing.T) {
+		t.Parallel()
+
+		s, addr := start(t)
+
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if err := s.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if report := s.DrainReport(); !report.Forced || report.TerminatedStreams != 1 {
+			t.Errorf("expected 1 terminated stream, but got %+v", report)
+		}
+	})
+	t.Run("services must be registered once and health must follow the lifecycle", func(t *testing.T) {
+		t.Parallel()
+
+		var registered []string
+		hs := &mockHealthServer{}
+		s, _ := start(t,
+			flexgrpc.WithHealth(hs, func() { registered = append(registered, "health") }),
+			flexgrpc.WithReflection(func() { registered = append(registered, "reflection") }),
+		)
+
+		if len(registered) != 2 || registered[0] != "health" || registered[1] != "reflection" {
+			t.Errorf("expected the health and reflection services to be registered, but got: %v", registered)
+		}
+		if serving, _ := hs.status(); !serving {
+			t.Error("expected the server to be reported as serving once listening")
+		}
+
+		if err := s.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if serving, changes := hs.status(); serving || changes != 2 {
+			t.Errorf("expected the server to be reported as not serving once halted, but got serving=%v after %d changes", serving, changes)
+		}
+	})
+	t.Run("listener errors must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		s := flexgrpc.New(lis.Addr().String(), &mockGRPCServer{streams: &flexgrpc.StreamCounter{}})
+
+		var opErr *net.OpError
+		if err := s.Run(context.Background()); !errors.As(err, &opErr) {
+			t.Errorf("expected a %T but got: %v", opErr, err)
+		}
+	})
+}
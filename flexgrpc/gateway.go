@@ -0,0 +1,141 @@
+package flexgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// Gateway is a flex worker serving a Server along with an HTTP reverse proxy
+// to it, such as the ServeMux of grpc-gateway:
+//
+//	mux := runtime.NewServeMux()
+//	pb.RegisterGreeterHandlerFromEndpoint(ctx, mux, "localhost:9090", dialOpts)
+//
+//	flex.MustStart(ctx, flexgrpc.NewGateway(
+//		flexgrpc.New(":9090", srv, flexgrpc.WithHealth(hs, nil)),
+//		&http.Server{Addr: ":8080", Handler: mux},
+//	))
+//
+// Both servers share a single lifecycle: the worker is ready once both
+// listen, the health service reports serving only then, and either server
+// failing stops the other. Once halted, the gateway drains before the gRPC
+// server stops, so that the requests it proxies can complete.
+type Gateway struct {
+	grpc    *Server
+	gateway *http.Server
+
+	mu      sync.Mutex
+	lis     net.Listener
+	halting bool
+}
+
+// NewGateway returns a Gateway serving grpc along with gateway, which proxies
+// HTTP requests to it. The gateway is given the drain timeout of grpc.
+func NewGateway(grpc *Server, gateway *http.Server) *Gateway {
+	return &Gateway{grpc: grpc, gateway: gateway}
+}
+
+// Addr returns the address the gateway is listening on, or nil if it is not
+// listening yet. The address of the gRPC server is returned by its own Addr.
+func (g *Gateway) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.lis == nil {
+		return nil
+	}
+	return g.lis.Addr()
+}
+
+// Run listens on the addresses of both servers and serves until the worker is
+// halted, or either server fails. The worker reports itself ready once both
+// servers are listening.
+func (g *Gateway) Run(ctx context.Context) error {
+	grpcLis, err := g.grpc.listen()
+	if err != nil {
+		return err
+	}
+
+	addr := g.gateway.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		_ = grpcLis.Close()
+		return fmt.Errorf("flexgrpc: listen gateway: %w", err)
+	}
+
+	g.mu.Lock()
+	g.lis = lis
+	g.mu.Unlock()
+
+	g.grpc.resume()
+	flex.Ready(ctx)
+
+	errC := make(chan error, 2)
+	go func() { errC <- g.grpc.serve(grpcLis) }()
+	go func() {
+		err := g.gateway.Serve(lis)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			err = fmt.Errorf("flexgrpc: serve gateway: %w", err)
+		} else {
+			err = nil
+		}
+		errC <- err
+	}()
+
+	first := <-errC
+	if !g.closing() {
+		// One of the servers failed, the other must not be left serving.
+		g.grpc.shutdown()
+		_ = g.gateway.Close()
+		g.grpc.srv.Stop()
+	}
+	return errors.Join(first, <-errC)
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once both servers
+// are listening, see flex.ReadyReporter.
+func (g *Gateway) ReportsReady() bool { return true }
+
+// Halt reports the gRPC server as not serving, then drains the gateway, and
+// once it is drained halts the gRPC server, each being given the drain
+// timeout, or until the deadline of ctx if it is earlier.
+func (g *Gateway) Halt(ctx context.Context) error {
+	g.mu.Lock()
+	g.halting = true
+	g.mu.Unlock()
+
+	g.grpc.shutdown()
+
+	timeout := g.grpc.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	err := g.gateway.Shutdown(drainCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		_ = g.gateway.Close()
+		err = fmt.Errorf("flexgrpc: gateway drain did not complete within %s", timeout)
+	} else if err != nil {
+		err = fmt.Errorf("flexgrpc: shutdown gateway: %w", err)
+	}
+
+	return errors.Join(err, g.grpc.Halt(ctx))
+}
+
+// closing reports whether the worker is being halted.
+func (g *Gateway) closing() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.halting
+}
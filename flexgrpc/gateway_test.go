@@ -0,0 +1,124 @@
+package flexgrpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexgrpc"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// recorder records the order of events.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// recordingGRPCServer records when it is stopped.
+type recordingGRPCServer struct {
+	*mockGRPCServer
+	recorder *recorder
+}
+
+func (s recordingGRPCServer) GracefulStop() {
+	s.recorder.record("grpc stopped")
+	s.mockGRPCServer.GracefulStop()
+}
+
+func TestGateway(t *testing.T) {
+	t.Run("the gateway must drain before the gRPC server stops", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recorder{}
+		hs := &mockHealthServer{}
+		grpcSrv := flexgrpc.New("127.0.0.1:0",
+			recordingGRPCServer{&mockGRPCServer{streams: &flexgrpc.StreamCounter{}}, rec},
+			flexgrpc.WithHealth(hs, nil))
+
+		proxying := make(chan struct{})
+		addr := flextest.Addr(t)
+		g := flexgrpc.NewGateway(grpcSrv, &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(proxying)
+			time.Sleep(50 * time.Millisecond)
+			rec.record("request proxied")
+			io.WriteString(w, "ok")
+		})})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(g)
+		h := flextest.Start(t, m)
+		<-m.Started()
+
+		if serving, _ := hs.status(); !serving {
+			t.Error("expected the health service to report serving")
+		}
+		if g.Addr() == nil || grpcSrv.Addr() == nil {
+			t.Fatal("expected both servers to listen once started")
+		}
+
+		respC := make(chan string, 1)
+		go func() {
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				respC <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			respC <- string(body)
+		}()
+		<-proxying
+
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if body := <-respC; body != "ok" {
+			t.Errorf("expected the request to complete but got: %q", body)
+		}
+		if want := []string{"request proxied", "grpc stopped"}; !slices.Equal(rec.recorded(), want) {
+			t.Errorf("expected %v but got: %v", want, rec.recorded())
+		}
+		if serving, _ := hs.status(); serving {
+			t.Error("expected the health service to report not serving")
+		}
+	})
+	t.Run("listener errors of the gateway must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		grpcSrv := flexgrpc.New("127.0.0.1:0", &mockGRPCServer{streams: &flexgrpc.StreamCounter{}})
+		g := flexgrpc.NewGateway(grpcSrv, &http.Server{Addr: lis.Addr().String()})
+		if err := g.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+
+		// The gRPC listener must have been closed.
+		if _, err := net.Dial("tcp", grpcSrv.Addr().String()); err == nil {
+			t.Error("expected the gRPC listener to be closed")
+		}
+	})
+}
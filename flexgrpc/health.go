@@ -0,0 +1,167 @@
+package flexgrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultHealthInterval is how often the health of the workers is published
+// when no interval is configured.
+const DefaultHealthInterval = time.Second
+
+// StatusSetter is the subset of *health.Server, the standard gRPC health
+// service, used by HealthSync. S is the serving status type of the service,
+// healthpb.HealthCheckResponse_ServingStatus.
+type StatusSetter[S any] interface {
+	SetServingStatus(service string, status S)
+}
+
+// HealthOption configures a HealthSync.
+type HealthOption func(*healthOptions)
+
+type healthOptions struct {
+	interval        time.Duration
+	livenessService string
+}
+
+// WithHealthInterval sets how often the health of the workers is published,
+// which is also how long the workers are given to report it.
+func WithHealthInterval(d time.Duration) HealthOption {
+	return func(o *healthOptions) { o.interval = d }
+}
+
+// WithLivenessService publishes the liveness of the manager as the status of
+// the service named name, for gRPC liveness probes.
+func WithLivenessService(name string) HealthOption {
+	return func(o *healthOptions) { o.livenessService = name }
+}
+
+// HealthSync is a flex worker publishing the health of the workers of the
+// manager running it through the standard gRPC health service, so that gRPC
+// load balancers and probes follow it:
+//
+//	hs := health.NewServer()
+//	flex.MustStart(ctx,
+//		flexgrpc.New(":9090", srv, flexgrpc.WithHealth(hs, func() { healthgrpc.RegisterHealthServer(srv, hs) })),
+//		flexgrpc.NewHealthSync(hs, healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING),
+//	)
+//
+// The readiness of the manager is published as the status of the server, the
+// empty service name, and the readiness of every worker as the status of the
+// service named after it. To serve the health service on a listener of its
+// own, register hs with a gRPC server of its own run by another Server.
+type HealthSync[S any] struct {
+	hs         StatusSetter[S]
+	serving    S
+	notServing S
+	opts       healthOptions
+
+	haltOnce sync.Once
+	halted   chan struct{}
+
+	mu       sync.Mutex
+	services []string
+	stopped  bool
+}
+
+// NewHealthSync returns a HealthSync publishing health to hs, with serving
+// and notServing as the statuses of healthy and unhealthy services.
+func NewHealthSync[S any](hs StatusSetter[S], serving, notServing S, opts ...HealthOption) *HealthSync[S] {
+	h := &HealthSync[S]{
+		hs:         hs,
+		serving:    serving,
+		notServing: notServing,
+		opts:       healthOptions{interval: DefaultHealthInterval},
+		halted:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&h.opts)
+	}
+	return h
+}
+
+// Run publishes the health of the workers every interval until the context
+// is done or Halt is called, after which every service is published as not
+// serving. It must be run by a flex manager. The worker reports itself ready
+// right away.
+func (h *HealthSync[S]) Run(ctx context.Context) error {
+	m, ok := flex.ManagerFromContext(ctx)
+	if !ok {
+		return errors.New("flexgrpc: health sync must be run by a flex manager")
+	}
+	defer h.stop()
+
+	flex.Ready(ctx)
+
+	ticker := time.NewTicker(h.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		h.publish(ctx, m)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.halted:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Halt publishes every service as not serving, so that clients stop sending
+// RPCs before the servers drain, and stops Run.
+func (h *HealthSync[S]) Halt(context.Context) error {
+	h.haltOnce.Do(func() { close(h.halted) })
+	h.stop()
+	return nil
+}
+
+// publish publishes the health of the workers of m.
+func (h *HealthSync[S]) publish(ctx context.Context, m *flex.Manager) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.interval)
+	defer cancel()
+
+	health := m.Health(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+
+	h.services = h.services[:0]
+	h.set("", health.Ready)
+	for _, w := range health.Workers {
+		h.set(w.Name, w.Ready)
+	}
+	if h.opts.livenessService != "" {
+		h.set(h.opts.livenessService, health.Live)
+	}
+}
+
+// set publishes the status of service. It must be called with mu held.
+func (h *HealthSync[S]) set(service string, ok bool) {
+	h.services = append(h.services, service)
+	if ok {
+		h.hs.SetServingStatus(service, h.serving)
+	} else {
+		h.hs.SetServingStatus(service, h.notServing)
+	}
+}
+
+// stop publishes every service as not serving, and stops publishing.
+func (h *HealthSync[S]) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	h.stopped = true
+	for _, service := range h.services {
+		h.hs.SetServingStatus(service, h.notServing)
+	}
+}
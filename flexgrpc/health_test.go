@@ -0,0 +1,123 @@
+package flexgrpc_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexgrpc"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// servingStatus mimics healthpb.HealthCheckResponse_ServingStatus.
+type servingStatus int
+
+const (
+	serving servingStatus = iota + 1
+	notServing
+)
+
+// mockStatusSetter records the statuses it is given, like the health service.
+type mockStatusSetter struct {
+	mu       sync.Mutex
+	statuses map[string]servingStatus
+}
+
+func (s *mockStatusSetter) SetServingStatus(service string, status servingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses == nil {
+		s.statuses = map[string]servingStatus{}
+	}
+	s.statuses[service] = status
+}
+
+func (s *mockStatusSetter) status(service string) servingStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[service]
+}
+
+// waitStatus waits for service to have status.
+func (s *mockStatusSetter) waitStatus(t *testing.T, service string, status servingStatus) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); s.status(service) != status; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q to have status %d but got: %d", service, status, s.status(service))
+		}
+	}
+}
+
+// reportingWorker runs until its context is done, reporting the health it is
+// given.
+type reportingWorker struct {
+	err atomic.Pointer[error]
+}
+
+func (w *reportingWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (w *reportingWorker) Halt(context.Context) error { return nil }
+
+func (w *reportingWorker) Health(context.Context) error {
+	if err := w.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func TestHealthSync(t *testing.T) {
+	t.Run("the health of the workers must be published", func(t *testing.T) {
+		t.Parallel()
+
+		hs := &mockStatusSetter{}
+		consumer := &reportingWorker{}
+		m := flex.New(flex.WithSignals())
+		m.Add(consumer, flex.WithName("consumer"))
+		m.Add(flexgrpc.NewHealthSync(hs, serving, notServing,
+			flexgrpc.WithHealthInterval(5*time.Millisecond),
+			flexgrpc.WithLivenessService("liveness"),
+		), flex.WithName("health"))
+		h := flextest.Start(t, m)
+		<-m.Started()
+
+		hs.waitStatus(t, "", serving)
+		hs.waitStatus(t, "consumer", serving)
+		hs.waitStatus(t, "liveness", serving)
+
+		err := errors.New("broker unreachable")
+		consumer.err.Store(&err)
+
+		hs.waitStatus(t, "", notServing)
+		hs.waitStatus(t, "consumer", notServing)
+		if s := hs.status("liveness"); s != serving {
+			t.Errorf("expected the manager to remain alive but got: %d", s)
+		}
+
+		consumer.err.Store(nil)
+		hs.waitStatus(t, "", serving)
+
+		h.Stop()
+		for _, service := range []string{"", "consumer", "health", "liveness"} {
+			if s := hs.status(service); s != notServing {
+				t.Errorf("expected %q not to be serving once halted but got: %d", service, s)
+			}
+		}
+	})
+	t.Run("the health sync must be run by a manager", func(t *testing.T) {
+		t.Parallel()
+
+		h := flexgrpc.NewHealthSync(&mockStatusSetter{}, serving, notServing)
+		if err := h.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
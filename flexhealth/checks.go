@@ -0,0 +1,209 @@
+package flexhealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDependencyTimeout is how long a check is given when no timeout
+	// is configured for it.
+	DefaultDependencyTimeout = 2 * time.Second
+	// DefaultCacheDuration is how long the result of a check run on demand
+	// is reused for when no cache duration is configured for it.
+	DefaultCacheDuration = time.Second
+)
+
+// errNotChecked is the error of periodic checks which have not run yet.
+var errNotChecked = errors.New("not checked yet")
+
+// Check checks a dependency of the service, such as a database, a broker or a
+// downstream service, returning why it is unavailable, or nil if it is not.
+// Errors marked with flex.Degraded leave the service ready, but degraded.
+type Check func(ctx context.Context) error
+
+// CheckOption configures a check.
+type CheckOption func(*check)
+
+// Every runs the check every d in the background, from when the server
+// starts, rather than on demand. The latest result is then served.
+func Every(d time.Duration) CheckOption {
+	return func(c *check) { c.interval = d }
+}
+
+// Timeout sets how long a run of the check is given, after which it fails.
+func Timeout(d time.Duration) CheckOption {
+	return func(c *check) { c.timeout = d }
+}
+
+// CacheFor sets how long the result of a check run on demand is reused for,
+// so that frequent probes do not overload the dependency.
+func CacheFor(d time.Duration) CheckOption {
+	return func(c *check) { c.cacheFor = d }
+}
+
+// CheckResult is the result of the latest run of a check.
+type CheckResult struct {
+	Name string
+	// Err is why the dependency is unavailable, or nil if it is not.
+	Err error
+	// CheckedAt is when the check ran, or the zero time if it has not yet.
+	CheckedAt time.Time
+	// Duration is how long the check took.
+	Duration time.Duration
+}
+
+// check is a registered check.
+type check struct {
+	name     string
+	fn       Check
+	interval time.Duration
+	timeout  time.Duration
+	cacheFor time.Duration
+
+	// running serializes the runs of the check, so that concurrent probes
+	// share a result rather than each running the check.
+	running sync.Mutex
+
+	mu     sync.Mutex
+	result CheckResult
+}
+
+// Register registers a check of a dependency named name, whose failure makes
+// the server, and thus the service, not ready. Checks must be registered
+// before the server runs.
+func (s *Server) Register(name string, fn Check, opts ...CheckOption) {
+	c := &check{
+		name:     name,
+		fn:       fn,
+		timeout:  DefaultDependencyTimeout,
+		cacheFor: DefaultCacheDuration,
+		result:   CheckResult{Name: name, Err: errNotChecked},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, c)
+}
+
+// Checks returns the results of the latest runs of the registered checks, in
+// the order they were registered.
+func (s *Server) Checks() []CheckResult {
+	checks := s.getChecks()
+	results := make([]CheckResult, len(checks))
+	for i, c := range checks {
+		results[i] = c.latest()
+	}
+	return results
+}
+
+// Health runs the checks which are run on demand and whose result is not
+// cached, and returns the errors of the failing checks, so that the server
+// is not ready while a dependency is unavailable.
+func (s *Server) Health(ctx context.Context) error {
+	checks := s.getChecks()
+	errs := make([]error, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.interval <= 0 {
+				c.runCached(ctx)
+			}
+			if err := c.latest().Err; err != nil {
+				errs[i] = fmt.Errorf("%s: %w", c.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// runPeriodic runs the checks which are run periodically until ctx is done.
+func (s *Server) runPeriodic(ctx context.Context) {
+	for _, c := range s.getChecks() {
+		if c.interval <= 0 {
+			continue
+		}
+		go func() {
+			ticker := time.NewTicker(c.interval)
+			defer ticker.Stop()
+
+			for {
+				c.run(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func (s *Server) getChecks() []*check {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checks
+}
+
+// latest returns the result of the latest run of the check.
+func (c *check) latest() CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result
+}
+
+// runCached runs the check unless its latest result is still cached.
+func (c *check) runCached(ctx context.Context) {
+	c.running.Lock()
+	defer c.running.Unlock()
+
+	if at := c.latest().CheckedAt; !at.IsZero() && time.Since(at) < c.cacheFor {
+		return
+	}
+	c.runLocked(ctx)
+}
+
+// run runs the check.
+func (c *check) run(ctx context.Context) {
+	c.running.Lock()
+	defer c.running.Unlock()
+	c.runLocked(ctx)
+}
+
+// runLocked runs the check, giving up on it once its timeout expires even if
+// it does not return, and records its result.
+func (c *check) runLocked(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errC := make(chan error, 1)
+	go func() { errC <- c.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-errC:
+	case <-ctx.Done():
+		err = fmt.Errorf("check did not complete: %w", ctx.Err())
+	}
+
+	// A probe giving up does not make the dependency unavailable.
+	if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result = CheckResult{Name: c.name, Err: err, CheckedAt: start, Duration: time.Since(start)}
+}
@@ -0,0 +1,145 @@
+package flexhealth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexhealth"
+	"github.com/go-flexible/flex/flextest"
+)
+
+func TestChecks(t *testing.T) {
+	t.Run("failing checks must fail the readiness probe only", func(t *testing.T) {
+		t.Parallel()
+
+		var dbErr atomic.Pointer[error]
+		addr := flextest.Addr(t)
+		health := flexhealth.New(addr)
+		health.Register("postgres", func(context.Context) error {
+			if err := dbErr.Load(); err != nil {
+				return *err
+			}
+			return nil
+		}, flexhealth.CacheFor(0))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(health, flex.WithName("health"))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		code, rep := get(t, "http://"+addr+"/readyz")
+		if code != http.StatusOK || len(rep.Checks) != 1 || rep.Checks[0].Name != "postgres" || !rep.Checks[0].Healthy {
+			t.Errorf("expected /readyz to succeed but got: %d %+v", code, rep)
+		}
+
+		err := errors.New("connection refused")
+		dbErr.Store(&err)
+
+		code, rep = get(t, "http://"+addr+"/readyz")
+		if code != http.StatusServiceUnavailable || rep.Checks[0].Healthy || rep.Checks[0].Error != err.Error() {
+			t.Errorf("expected /readyz to fail but got: %d %+v", code, rep)
+		}
+		if code, _ := get(t, "http://"+addr+"/livez"); code != http.StatusOK {
+			t.Errorf("expected /livez to succeed but got: %d", code)
+		}
+	})
+	t.Run("degraded checks must leave the service ready", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		health := flexhealth.New(addr)
+		health.Register("replica", func(context.Context) error {
+			return flex.Degraded(errors.New("lagging by 30s"))
+		})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(health, flex.WithName("health"))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		code, rep := get(t, "http://"+addr+"/readyz")
+		if code != http.StatusOK || rep.Status != flexhealth.StatusDegraded || !rep.Degraded || !rep.Ready {
+			t.Errorf("expected /readyz to succeed degraded but got: %d %+v", code, rep)
+		}
+		if c := rep.Checks[0]; c.Healthy || !c.Degraded {
+			t.Errorf("expected the check to be degraded but got: %+v", c)
+		}
+		if w := rep.Workers[0]; !w.Degraded || w.Error == "" {
+			t.Errorf("expected the server to be degraded but got: %+v", w)
+		}
+	})
+	t.Run("results must be cached", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		health := flexhealth.New(flextest.Addr(t))
+		health.Register("broker", func(context.Context) error {
+			runs.Add(1)
+			return nil
+		}, flexhealth.CacheFor(time.Hour))
+
+		for range 3 {
+			if err := health.Health(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+		if n := runs.Load(); n != 1 {
+			t.Errorf("expected %d runs but got: %d", 1, n)
+		}
+	})
+	t.Run("checks outliving their timeout must fail", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		defer close(release)
+		health := flexhealth.New(flextest.Addr(t))
+		health.Register("billing", func(context.Context) error {
+			<-release
+			return nil
+		}, flexhealth.Timeout(10*time.Millisecond))
+
+		err := health.Health(context.Background())
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+		}
+		if res := health.Checks()[0]; res.Name != "billing" || res.Err == nil || res.CheckedAt.IsZero() {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+	t.Run("periodic checks must run in the background", func(t *testing.T) {
+		t.Parallel()
+
+		var runs atomic.Int32
+		health := flexhealth.New(flextest.Addr(t))
+		health.Register("cache", func(context.Context) error {
+			runs.Add(1)
+			return nil
+		}, flexhealth.Every(5*time.Millisecond))
+
+		if err := health.Health(context.Background()); err == nil {
+			t.Error("expected periodic checks not to have run before the server")
+		}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(health)
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		for deadline := time.Now().Add(time.Second); runs.Load() < 3; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the check to run periodically but got %d runs", runs.Load())
+			}
+		}
+		if err := health.Health(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package flexhealth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// HealthCheckArg is the argument making CheckMain check the health of the
+	// service rather than return.
+	HealthCheckArg = "healthcheck"
+	// DefaultProbeTimeout is how long CheckMain waits for the health of the
+	// service.
+	DefaultProbeTimeout = 3 * time.Second
+)
+
+// Probe gets url, a health endpoint, and returns an error unless it responds
+// with 200 OK.
+func Probe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("flexhealth: probe: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("flexhealth: probe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("flexhealth: probe %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// CheckMain lets the binary of a service serving its health on addr serve as
+// its own health check, such as the HEALTHCHECK of a Docker image, which has
+// no HTTP client:
+//
+//	func main() {
+//		flexhealth.CheckMain(":8081")
+//		// Run the service.
+//	}
+//
+//	HEALTHCHECK CMD ["/app", "healthcheck"]
+//
+// When the program is run with HealthCheckArg as its first argument, CheckMain
+// probes /healthz, or the path given as the second argument, such as /livez,
+// on the local host when addr does not name a host, and exits with 0 if it succeeds and 1 otherwise.
+// Otherwise it returns right away.
+func CheckMain(addr string) {
+	if len(os.Args) < 2 || os.Args[1] != HealthCheckArg {
+		return
+	}
+
+	path := "/healthz"
+	if len(os.Args) > 2 {
+		path = "/" + strings.TrimPrefix(os.Args[2], "/")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "flexhealth: invalid address:", err)
+		os.Exit(1)
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultProbeTimeout)
+	err = Probe(ctx, "http://"+net.JoinHostPort(host, port)+path)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
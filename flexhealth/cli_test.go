@@ -0,0 +1,80 @@
+package flexhealth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/go-flexible/flex/flexhealth"
+)
+
+func TestProbe(t *testing.T) {
+	t.Run("only 200 OK must succeed", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer srv.Close()
+
+		if err := flexhealth.Probe(context.Background(), srv.URL+"/healthz"); err != nil {
+			t.Error(err)
+		}
+		if err := flexhealth.Probe(context.Background(), srv.URL+"/livez"); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
+
+func TestCheckMain(t *testing.T) {
+	if addr := os.Getenv("FLEXHEALTH_CHECK_ADDR"); addr != "" {
+		os.Args = append([]string{os.Args[0], flexhealth.HealthCheckArg}, strings.Fields(os.Getenv("FLEXHEALTH_CHECK_ARGS"))...)
+		flexhealth.CheckMain(addr)
+		t.Fatal("expected CheckMain to exit")
+	}
+
+	t.Run("the health must be checked when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer srv.Close()
+		addr := strings.TrimPrefix(srv.URL, "http://")
+
+		for _, tt := range []struct {
+			args string
+			code int
+		}{
+			{"", 0},
+			{"livez", 1},
+		} {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCheckMain$")
+			cmd.Env = append(os.Environ(), "FLEXHEALTH_CHECK_ADDR="+addr, "FLEXHEALTH_CHECK_ARGS="+tt.args)
+			err := cmd.Run()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("expected exit code %d given %q but got: %d", tt.code, tt.args, code)
+			}
+		}
+	})
+	t.Run("CheckMain must return unless asked to check", func(t *testing.T) {
+		flexhealth.CheckMain(":0")
+	})
+}
@@ -0,0 +1,282 @@
+// Package flexhealth provides a flex worker serving the health of the other
+// workers of its manager, for the probes of Kubernetes and load balancers:
+//
+//	flex.MustStart(ctx, api, consumer, flexhealth.New(":8081"))
+//
+// The health is served under:
+//
+//   - /livez, which succeeds when every worker is alive, so that the
+//     process is restarted when a worker is deadlocked.
+//   - /readyz, which succeeds when every worker is ready, so that the process
+//     is not sent traffic while it starts or drains.
+//   - /healthz, which is /readyz under its conventional name.
+//   - /startupz, which succeeds once every worker has started, so that the
+//     liveness probe only begins then.
+//
+// See flex.Manager.Health for when workers have started, and are alive and
+// ready. Services whose startup cannot be covered by a startup probe, such as
+// those probed by tools without one, set a startup grace period during which
+// /livez succeeds while the service starts.
+//
+// Each endpoint responds with 200 OK, or 503 Service Unavailable, along with
+// the health of every worker as JSON, whose status is "degraded" rather than
+// "ok" when any worker is degraded, see flex.Degraded:
+//
+//	{"status":"unavailable","started":true,"live":true,"ready":false,"workers":[
+//		{"name":"api","state":"running","started":true,"live":true,"ready":true},
+//		{"name":"consumer","state":"running","started":true,"live":true,"ready":false,"error":"broker unreachable"}
+//	]}
+//
+// Workers report their health beyond their lifecycle state by implementing
+// flex.HealthReporter and flex.LivenessReporter. The dependencies of the
+// service, such as its database, are checked by registering checks with the
+// server, whose failure makes the service not ready:
+//
+//	health := flexhealth.New(":8081")
+//	health.Register("postgres", db.PingContext, flexhealth.Every(10*time.Second))
+//	health.Register("billing", pingBilling, flexhealth.Timeout(time.Second))
+//
+// The results of the checks are served along with the health of the workers,
+// under "checks".
+//
+// CheckMain lets the binary of the service probe its own health, to serve as
+// the HEALTHCHECK of its Docker image.
+package flexhealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexhttp"
+)
+
+// DefaultCheckTimeout is how long the workers are given to report their
+// health when no check timeout is configured.
+const DefaultCheckTimeout = 5 * time.Second
+
+// Statuses of the health report.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// Option configures the health server.
+type Option func(*options)
+
+type options struct {
+	checkTimeout time.Duration
+	startupGrace time.Duration
+	http         []flexhttp.Option
+}
+
+// WithCheckTimeout sets how long the workers are given to report their
+// health, after which those which have not are reported as not alive.
+func WithCheckTimeout(d time.Duration) Option {
+	return func(o *options) { o.checkTimeout = d }
+}
+
+// WithStartupGracePeriod sets how long after the server starts, or the
+// handler is created, /livez succeeds for as long as the service has not
+// started, so that a service which is slow to start is not restarted by its
+// liveness probe.
+func WithStartupGracePeriod(d time.Duration) Option {
+	return func(o *options) { o.startupGrace = d }
+}
+
+// WithServerOptions sets options of the underlying flexhttp.Server, such as
+// its drain timeout.
+func WithServerOptions(opts ...flexhttp.Option) Option {
+	return func(o *options) { o.http = append(o.http, opts...) }
+}
+
+// Server is a flex worker serving the health of the workers of its manager.
+type Server struct {
+	srv *flexhttp.Server
+
+	mu        sync.Mutex
+	manager   *flex.Manager
+	startedAt time.Time
+	checks    []*check
+}
+
+// New returns a worker serving the health endpoints on addr.
+func New(addr string, opts ...Option) *Server {
+	o := newOptions(opts)
+	s := &Server{}
+	h := &handler{manager: s.getManager, checks: s.Checks, opts: o}
+	s.srv = flexhttp.New(&http.Server{Addr: addr, Handler: h.mux()}, o.http...)
+	return s
+}
+
+// Handler returns a handler serving the health endpoints of m, to serve them
+// alongside other endpoints instead of on an address of their own. Checks of
+// dependencies are only served by a Server.
+func Handler(m *flex.Manager, opts ...Option) http.Handler {
+	created := time.Now()
+	h := &handler{manager: func() (*flex.Manager, time.Time) { return m, created }, opts: newOptions(opts)}
+	return h.mux()
+}
+
+func newOptions(opts []Option) options {
+	o := options{checkTimeout: DefaultCheckTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server) Addr() net.Addr { return s.srv.Addr() }
+
+// Run serves the health of the workers of the manager running the server
+// until it is halted, running the periodic checks meanwhile. The worker
+// reports itself ready once it is listening.
+func (s *Server) Run(ctx context.Context) error {
+	m, ok := flex.ManagerFromContext(ctx)
+	if !ok {
+		return errors.New("flexhealth: server must be run by a flex manager")
+	}
+
+	s.mu.Lock()
+	s.manager, s.startedAt = m, time.Now()
+	s.mu.Unlock()
+
+	checkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.runPeriodic(checkCtx)
+
+	return s.srv.Run(ctx)
+}
+
+// Halt gracefully shuts the server down.
+func (s *Server) Halt(ctx context.Context) error { return s.srv.Halt(ctx) }
+
+// getManager returns the manager running the server and when it started.
+func (s *Server) getManager() (*flex.Manager, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.manager, s.startedAt
+}
+
+// report is the JSON representation of the health of a manager.
+type report struct {
+	Status   string         `json:"status"`
+	Started  bool           `json:"started"`
+	Live     bool           `json:"live"`
+	Ready    bool           `json:"ready"`
+	Degraded bool           `json:"degraded"`
+	Stopping bool           `json:"stopping,omitempty"`
+	Workers  []workerReport `json:"workers"`
+	Checks   []checkReport  `json:"checks,omitempty"`
+}
+
+// workerReport is the JSON representation of the health of a worker.
+type workerReport struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Started  bool   `json:"started"`
+	Live     bool   `json:"live"`
+	Ready    bool   `json:"ready"`
+	Degraded bool   `json:"degraded,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// checkReport is the JSON representation of the result of a check.
+type checkReport struct {
+	Name      string     `json:"name"`
+	Healthy   bool       `json:"healthy"`
+	Degraded  bool       `json:"degraded,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+}
+
+// handler serves the health endpoints.
+type handler struct {
+	// manager returns the manager whose health is served, and when the
+	// startup grace period began.
+	manager func() (*flex.Manager, time.Time)
+	// checks returns the results of the checks, if any.
+	checks func() []CheckResult
+	opts   options
+}
+
+func (h *handler) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	started := func(health flex.Health, _ time.Time) bool { return health.Started }
+	ready := func(health flex.Health, _ time.Time) bool { return health.Ready }
+	live := func(health flex.Health, startedAt time.Time) bool {
+		starting := !health.Started && !startedAt.IsZero() && time.Since(startedAt) < h.opts.startupGrace
+		return health.Live || starting
+	}
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, r *http.Request) { h.serve(w, r, live) })
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) { h.serve(w, r, ready) })
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) { h.serve(w, r, ready) })
+	mux.HandleFunc("GET /startupz", func(w http.ResponseWriter, r *http.Request) { h.serve(w, r, started) })
+	return mux
+}
+
+// serve responds with the health of the workers, failing unless ok reports
+// that it is good enough.
+func (h *handler) serve(w http.ResponseWriter, r *http.Request, ok func(flex.Health, time.Time) bool) {
+	m, startedAt := h.manager()
+	if m == nil {
+		http.Error(w, "flexhealth: not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.opts.checkTimeout)
+	defer cancel()
+	health := m.Health(ctx)
+
+	rep := report{
+		Status:   StatusOK,
+		Started:  health.Started,
+		Live:     health.Live,
+		Ready:    health.Ready,
+		Degraded: health.Degraded,
+		Stopping: health.Stopping,
+		Workers:  make([]workerReport, 0, len(health.Workers)),
+	}
+	for _, wh := range health.Workers {
+		wr := workerReport{Name: wh.Name, State: wh.State.String(), Started: wh.Started, Live: wh.Live, Ready: wh.Ready,
+			Degraded: wh.Degraded}
+		if wh.Err != nil {
+			wr.Error = wh.Err.Error()
+		}
+		rep.Workers = append(rep.Workers, wr)
+	}
+	if h.checks != nil {
+		for _, res := range h.checks() {
+			cr := checkReport{Name: res.Name, Healthy: res.Err == nil, Degraded: flex.IsDegraded(res.Err)}
+			if res.Err != nil {
+				cr.Error = res.Err.Error()
+			}
+			if !res.CheckedAt.IsZero() {
+				cr.CheckedAt, cr.Duration = &res.CheckedAt, res.Duration.String()
+			}
+			rep.Checks = append(rep.Checks, cr)
+		}
+	}
+
+	code := http.StatusOK
+	switch {
+	case !ok(health, startedAt):
+		rep.Status, code = StatusUnavailable, http.StatusServiceUnavailable
+	case health.Degraded:
+		rep.Status = StatusDegraded
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,238 @@
+package flexhealth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexhealth"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// reportingWorker runs until its context is done, reporting the health it is
+// given.
+type reportingWorker struct {
+	err atomic.Pointer[error]
+}
+
+func (w *reportingWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (w *reportingWorker) Halt(context.Context) error { return nil }
+
+func (w *reportingWorker) Health(context.Context) error {
+	if err := w.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// stuckWorker never returns from reporting its health.
+type stuckWorker struct {
+	reportingWorker
+	release chan struct{}
+}
+
+func (w *stuckWorker) Health(context.Context) error {
+	<-w.release
+	return nil
+}
+
+// slowWorker only reports itself ready once told to.
+type slowWorker struct {
+	ready chan struct{}
+}
+
+func (w *slowWorker) Run(ctx context.Context) error {
+	select {
+	case <-w.ready:
+		flex.Ready(ctx)
+	case <-ctx.Done():
+		return nil
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (w *slowWorker) Halt(context.Context) error { return nil }
+
+// dyingWorker reports that it cannot recover.
+type dyingWorker struct{ reportingWorker }
+
+func (w *dyingWorker) Live(context.Context) error { return errors.New("deadlocked") }
+
+// report is the health report served.
+type report struct {
+	Status   string `json:"status"`
+	Started  bool   `json:"started"`
+	Live     bool   `json:"live"`
+	Ready    bool   `json:"ready"`
+	Degraded bool   `json:"degraded"`
+	Workers  []struct {
+		Name     string `json:"name"`
+		State    string `json:"state"`
+		Live     bool   `json:"live"`
+		Ready    bool   `json:"ready"`
+		Degraded bool   `json:"degraded"`
+		Error    string `json:"error"`
+	} `json:"workers"`
+	Checks []struct {
+		Name     string `json:"name"`
+		Healthy  bool   `json:"healthy"`
+		Degraded bool   `json:"degraded"`
+		Error    string `json:"error"`
+	} `json:"checks"`
+}
+
+// get returns the status code and report of a GET of url.
+func get(t *testing.T, url string) (int, report) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var rep report
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, rep
+}
+
+func TestServer(t *testing.T) {
+	t.Run("the health of the workers must be served", func(t *testing.T) {
+		t.Parallel()
+
+		consumer := &reportingWorker{}
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(consumer, flex.WithName("consumer"))
+		m.Add(flexhealth.New(addr), flex.WithName("health"))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		for _, path := range []string{"/livez", "/readyz", "/healthz"} {
+			if code, rep := get(t, "http://"+addr+path); code != http.StatusOK || rep.Status != flexhealth.StatusOK {
+				t.Errorf("expected %s to succeed but got: %d %+v", path, code, rep)
+			}
+		}
+
+		err := errors.New("broker unreachable")
+		consumer.err.Store(&err)
+
+		for _, path := range []string{"/readyz", "/healthz"} {
+			code, rep := get(t, "http://"+addr+path)
+			if code != http.StatusServiceUnavailable || rep.Status != flexhealth.StatusUnavailable || !rep.Live || rep.Ready {
+				t.Errorf("expected %s to fail but got: %d %+v", path, code, rep)
+			}
+			if len(rep.Workers) != 2 {
+				t.Fatalf("expected %d workers but got: %+v", 2, rep.Workers)
+			}
+			if w := rep.Workers[0]; w.Name != "consumer" || w.State != "running" || !w.Live || w.Ready || w.Error != err.Error() {
+				t.Errorf("unexpected health of the consumer: %+v", w)
+			}
+			if w := rep.Workers[1]; w.Name != "health" || !w.Live || !w.Ready {
+				t.Errorf("unexpected health of the server: %+v", w)
+			}
+		}
+		if code, _ := get(t, "http://"+addr+"/livez"); code != http.StatusOK {
+			t.Errorf("expected /livez to succeed but got: %d", code)
+		}
+	})
+	t.Run("deadlocked workers must fail the liveness probe", func(t *testing.T) {
+		t.Parallel()
+
+		stuck := &stuckWorker{release: make(chan struct{})}
+		defer close(stuck.release)
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(stuck, flex.WithName("stuck"))
+		m.Add(flexhealth.New(addr, flexhealth.WithCheckTimeout(10*time.Millisecond)))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		code, rep := get(t, "http://"+addr+"/livez")
+		if code != http.StatusServiceUnavailable || rep.Live {
+			t.Errorf("expected /livez to fail but got: %d %+v", code, rep)
+		}
+		if w := rep.Workers[0]; w.Live || w.Error == "" {
+			t.Errorf("expected the stuck worker not to be alive but got: %+v", w)
+		}
+	})
+	t.Run("liveness must not fail while starting within the grace period", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			grace time.Duration
+			code  int
+		}{
+			{0, http.StatusServiceUnavailable},
+			{time.Hour, http.StatusOK},
+		} {
+			slow := &slowWorker{ready: make(chan struct{})}
+			addr := flextest.Addr(t)
+			m := flex.New(flex.WithSignals())
+			m.Add(slow, flex.WithStartedOnReady())
+			m.Add(&dyingWorker{})
+			m.Add(flexhealth.New(addr, flexhealth.WithStartupGracePeriod(tt.grace)))
+			h := flextest.Start(t, m)
+			flextest.WaitListening(t, addr)
+
+			if code, rep := get(t, "http://"+addr+"/startupz"); code != http.StatusServiceUnavailable || rep.Started {
+				t.Errorf("expected /startupz to fail while starting but got: %d %+v", code, rep)
+			}
+			if code, _ := get(t, "http://"+addr+"/livez"); code != tt.code {
+				t.Errorf("expected /livez to respond with %d given a grace period of %s but got: %d", tt.code, tt.grace, code)
+			}
+
+			close(slow.ready)
+			<-m.Started()
+			if code, rep := get(t, "http://"+addr+"/startupz"); code != http.StatusOK || !rep.Started {
+				t.Errorf("expected /startupz to succeed once started but got: %d %+v", code, rep)
+			}
+			if code, _ := get(t, "http://"+addr+"/livez"); code != http.StatusServiceUnavailable {
+				t.Errorf("expected /livez to fail once started but got: %d", code)
+			}
+			h.Stop()
+		}
+	})
+	t.Run("the server must be run by a manager", func(t *testing.T) {
+		t.Parallel()
+
+		if err := flexhealth.New(flextest.Addr(t)).Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("the health endpoints must be served by the handler", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&reportingWorker{})
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		srv := httptest.NewServer(flexhealth.Handler(m))
+		defer srv.Close()
+
+		if code, rep := get(t, srv.URL+"/readyz"); code != http.StatusOK || len(rep.Workers) != 1 {
+			t.Errorf("expected /readyz to succeed but got: %d %+v", code, rep)
+		}
+	})
+}
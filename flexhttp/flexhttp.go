@@ -0,0 +1,221 @@
+// Package flexhttp provides a flex worker serving HTTP with an *http.Server.
+//
+//	srv := flexhttp.New(&http.Server{Addr: ":8443", Handler: router},
+//		flexhttp.WithTLS("cert.pem", "key.pem"),
+//		flexhttp.WithDrainTimeout(20*time.Second),
+//	)
+//
+//	flex.MustStart(ctx, srv)
+//
+// Once halted, the server stops accepting connections and closes idle ones,
+// and in-flight requests are given the drain timeout to complete, after which
+// the remaining connections are closed.
+package flexhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long in-flight requests are given to complete
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+var logger = log.New(os.Stderr, "flexhttp: ", 0)
+
+// DrainReport describes how the last drain of a Server went.
+type DrainReport struct {
+	// Duration is how long the drain took.
+	Duration time.Duration
+	// Forced is set when the drain timeout expired and the remaining
+	// connections were closed without waiting for their requests.
+	Forced bool
+	// DrainedConns is the number of connections open when the drain began.
+	DrainedConns int
+	// TerminatedConns is the number of connections still open when the
+	// drain timeout expired.
+	TerminatedConns int
+}
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	drainTimeout      time.Duration
+	certFile, keyFile string
+	tls               bool
+}
+
+// WithDrainTimeout sets how long in-flight requests are given to complete
+// once the server is halted.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithTLS makes the server serve HTTPS, with the certificate and key of the
+// given files. They may be empty when the TLSConfig of the *http.Server holds
+// the certificates.
+func WithTLS(certFile, keyFile string) Option {
+	return func(o *options) { o.certFile, o.keyFile, o.tls = certFile, keyFile, true }
+}
+
+// Server is a flex worker serving HTTP.
+type Server struct {
+	srv  *http.Server
+	opts options
+
+	mu     sync.Mutex
+	lis    net.Listener
+	conns  map[net.Conn]http.ConnState
+	report DrainReport
+}
+
+// New returns a Server serving srv on its address. The ConnState hook of
+// srv, if any, keeps being called.
+func New(srv *http.Server, opts ...Option) *Server {
+	s := &Server{
+		srv:   srv,
+		opts:  options{drainTimeout: DefaultDrainTimeout},
+		conns: make(map[net.Conn]http.ConnState),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	hook := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		s.track(conn, state)
+		if hook != nil {
+			hook(conn, state)
+		}
+	}
+
+	return s
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Run listens on the server's address and serves until the server is halted.
+// The worker reports itself ready once it is listening.
+func (s *Server) Run(ctx context.Context) error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+		if s.opts.tls {
+			addr = ":https"
+		}
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("flexhttp: listen: %w", err)
+	}
+
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	if s.opts.tls {
+		err = s.srv.ServeTLS(lis, s.opts.certFile, s.opts.keyFile)
+	} else {
+		err = s.srv.Serve(lis)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("flexhttp: serve: %w", err)
+	}
+	return nil
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is
+// listening, see flex.ReadyReporter.
+func (s *Server) ReportsReady() bool { return true }
+
+// Halt gracefully shuts the server down, giving in-flight requests the drain
+// timeout to complete, or until the deadline of ctx if it is earlier. Once it
+// expires the remaining connections are closed, and an error reporting them
+// is returned.
+func (s *Server) Halt(ctx context.Context) error {
+	start := time.Now()
+	report := DrainReport{DrainedConns: s.openConns()}
+	if report.DrainedConns > 0 {
+		logger.Printf("draining %d connections", report.DrainedConns)
+	}
+
+	timeout := s.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		report.Forced = true
+		report.TerminatedConns = s.openConns()
+		err = s.srv.Close()
+	}
+	report.Duration = time.Since(start)
+
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	switch {
+	case report.Forced:
+		logger.Printf("drain did not complete within %s, closed %d connections", timeout, report.TerminatedConns)
+		return fmt.Errorf("flexhttp: drain did not complete within %s, closed %d connections",
+			timeout, report.TerminatedConns)
+	case err != nil:
+		return fmt.Errorf("flexhttp: shutdown: %w", err)
+	case report.DrainedConns > 0:
+		logger.Printf("drained %d connections in %s", report.DrainedConns, report.Duration.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// DrainReport returns the report of the last drain.
+func (s *Server) DrainReport() DrainReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
+
+// track records the state of conn.
+func (s *Server) track(conn net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(s.conns, conn)
+	default:
+		s.conns[conn] = state
+	}
+}
+
+// openConns returns the number of open connections.
+func (s *Server) openConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
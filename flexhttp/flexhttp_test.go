@@ -0,0 +1,198 @@
+package flexhttp_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexhttp"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// slowHandler responds after delay, signalling once the request is in flight.
+func slowHandler(delay time.Duration, inFlight chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight <- struct{}{}
+		select {
+		case <-time.After(delay):
+			io.WriteString(w, "done")
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Run("in-flight requests must complete when halted", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		inFlight := make(chan struct{}, 1)
+		srv := flexhttp.New(&http.Server{Addr: addr, Handler: slowHandler(50*time.Millisecond, inFlight)})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		respC := make(chan error, 1)
+		go func() { respC <- flextest.Get(http.DefaultClient, "http://"+addr)(context.Background()) }()
+
+		<-inFlight
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-respC; err != nil {
+			t.Errorf("expected the in-flight request to complete, but got: %v", err)
+		}
+
+		report := srv.DrainReport()
+		if report.Forced || report.DrainedConns != 1 {
+			t.Errorf("unexpected drain report: %+v", report)
+		}
+	})
+	t.Run("connections must be closed once the drain timeout expires", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		inFlight := make(chan struct{}, 1)
+		srv := flexhttp.New(&http.Server{Addr: addr, Handler: slowHandler(time.Hour, inFlight)},
+			flexhttp.WithDrainTimeout(20*time.Millisecond))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		go flextest.Get(http.DefaultClient, "http://"+addr)(context.Background())
+
+		<-inFlight
+		if err := h.Stop(); err == nil {
+			t.Error("expected the forced drain to be reported")
+		}
+
+		report := srv.DrainReport()
+		if !report.Forced || report.TerminatedConns != 1 {
+			t.Errorf("unexpected drain report: %+v", report)
+		}
+	})
+	t.Run("connections must be closed once the halt deadline expires", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		inFlight := make(chan struct{}, 1)
+		srv := flexhttp.New(&http.Server{Addr: addr, Handler: slowHandler(time.Hour, inFlight)})
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(20*time.Millisecond))
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		go flextest.Get(http.DefaultClient, "http://"+addr)(context.Background())
+
+		<-inFlight
+		h.Stop()
+
+		for deadline := time.Now().Add(time.Second); !srv.DrainReport().Forced; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the connections to be closed but got: %+v", srv.DrainReport())
+			}
+		}
+	})
+	t.Run("the server must serve TLS", func(t *testing.T) {
+		t.Parallel()
+
+		certFile, keyFile := certificate(t)
+
+		addr := flextest.Addr(t)
+		inFlight := make(chan struct{}, 1)
+		srv := flexhttp.New(&http.Server{Addr: addr, Handler: slowHandler(0, inFlight)},
+			flexhttp.WithTLS(certFile, keyFile))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		if err := flextest.Get(client, "https://"+addr)(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("the connection state hook must keep being called", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		states := make(chan http.ConnState, 8)
+		inFlight := make(chan struct{}, 1)
+		srv := flexhttp.New(&http.Server{
+			Addr:      addr,
+			Handler:   slowHandler(0, inFlight),
+			ConnState: func(_ net.Conn, state http.ConnState) { states <- state },
+		})
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		flextest.WaitListening(t, addr)
+
+		if err := flextest.Get(http.DefaultClient, "http://"+addr)(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if state := <-states; state != http.StateNew {
+			t.Errorf("expected %v but got: %v", http.StateNew, state)
+		}
+	})
+}
+
+// certificate writes a self-signed certificate for 127.0.0.1 and its key,
+// and returns their files.
+func certificate(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "flexhttp"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
@@ -0,0 +1,332 @@
+// Package flexkafka provides a flex worker consuming Kafka topics as a member
+// of a consumer group.
+//
+// The worker does not depend on a Kafka client library, instead it works with
+// any type satisfying Reader, which the *kafka.Reader of segmentio/kafka-go
+// does. A reader is connected whenever the worker runs, so that a restarted
+// worker rejoins the group and resumes from the committed offsets:
+//
+//	connect := func(context.Context) (flexkafka.Reader[kafka.Message], error) {
+//		return kafka.NewReader(kafka.ReaderConfig{
+//			Brokers: brokers,
+//			GroupID: "billing",
+//			Topic:   "orders",
+//		}), nil
+//	}
+//
+//	consumer := flexkafka.New(connect, func(ctx context.Context, msg kafka.Message) error {
+//		return process(ctx, msg.Value)
+//	}, flexkafka.WithRebalanceErrors(kafka.RebalanceInProgress))
+//
+//	m := flex.New(flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 5, Backoff: time.Second}))
+//	m.Add(consumer)
+//
+// Messages are handled one at a time, in the order they are fetched, and the
+// offsets of handled messages are committed periodically and on shutdown.
+//
+// Errors of the reader, and of the handler, are returned by Run marked with
+// flex.Recoverable, so that the worker is restarted according to the
+// manager's restart policy, and messages which were not committed are
+// delivered again. Errors marked with flex.Fatal are returned as they are.
+package flexkafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultCommitInterval is how often the offsets of handled messages are
+	// committed when no commit interval is configured.
+	DefaultCommitInterval = time.Second
+	// DefaultDrainTimeout is how long the message being handled is given to
+	// be handled, and offsets to be committed, during Halt when no drain
+	// timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// Reader is a member of a consumer group.
+type Reader[M any] interface {
+	// FetchMessage blocks until the next message of the partitions assigned
+	// to the member is available, or the context is done.
+	FetchMessage(ctx context.Context) (M, error)
+	// CommitMessages commits the offsets of msgs.
+	CommitMessages(ctx context.Context, msgs ...M) error
+	// Close leaves the group.
+	Close() error
+}
+
+// Connect returns a new Reader joining the consumer group.
+type Connect[M any] func(ctx context.Context) (Reader[M], error)
+
+// Handler handles a single message.
+// The message is committed when the handler returns nil. When it returns an
+// error or panics, the consumer stops and returns the error.
+type Handler[M any] func(ctx context.Context, msg M) error
+
+// Option configures a Consumer.
+type Option func(*options)
+
+type options struct {
+	commitInterval  time.Duration
+	drainTimeout    time.Duration
+	rebalanceErrors []error
+}
+
+// WithCommitInterval sets how often the offsets of handled messages are
+// committed. Zero commits every message as soon as it is handled.
+func WithCommitInterval(d time.Duration) Option {
+	return func(o *options) { o.commitInterval = d }
+}
+
+// WithDrainTimeout sets how long the message being handled is given to be
+// handled, and offsets to be committed, once the consumer is halted, after
+// which the handler's context is cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithRebalanceErrors sets the errors returned by the reader while the group
+// is rebalancing, such as kafka.RebalanceInProgress. They do not stop the
+// consumer: fetching is retried, and the offsets which could not be committed
+// are dropped, as the partitions may now be assigned to another member which
+// delivers their messages again.
+func WithRebalanceErrors(errs ...error) Option {
+	return func(o *options) { o.rebalanceErrors = errs }
+}
+
+// Consumer is a flex worker which fetches messages from a consumer group and
+// dispatches them to a handler.
+type Consumer[M any] struct {
+	connect Connect[M]
+	handler Handler[M]
+	opts    options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+
+	pendingMu sync.Mutex
+	pending   []M
+}
+
+// New returns a Consumer dispatching messages fetched by readers returned by
+// connect to handler.
+func New[M any](connect Connect[M], handler Handler[M], opts ...Option) *Consumer[M] {
+	c := &Consumer[M]{
+		connect: connect,
+		handler: handler,
+		opts: options{
+			commitInterval: DefaultCommitInterval,
+			drainTimeout:   DefaultDrainTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+// Run connects a reader and consumes messages until the context is done or
+// Halt is called, then commits the offsets of the handled messages and closes
+// the reader. The worker reports itself ready once the reader is connected.
+//
+// The handler is given a context which is not cancelled when fetching stops,
+// so that the message being handled can be handled to completion during Halt.
+func (c *Consumer[M]) Run(ctx context.Context) error {
+	reader, err := c.connect(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return recoverable(fmt.Errorf("flexkafka: connect: %w", err))
+	}
+
+	// Offsets left over by a previous reader may not be committed by this
+	// one, their messages are delivered again instead.
+	c.pendingMu.Lock()
+	c.pending = nil
+	c.pendingMu.Unlock()
+
+	fetchCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	c.mu.Lock()
+	c.stop, c.abort, c.done = stop, abort, done
+	c.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	var committer sync.WaitGroup
+	if c.opts.commitInterval > 0 {
+		committer.Add(1)
+		go func() {
+			defer committer.Done()
+			c.commitEvery(fetchCtx, reader)
+		}()
+	}
+
+	err = c.consume(fetchCtx, handlerCtx, reader)
+	stop()
+	committer.Wait()
+
+	// The final commit is abandoned along with the handler once the drain of
+	// Halt expires.
+	commitCtx, cancel := context.WithTimeout(handlerCtx, c.opts.drainTimeout)
+	defer cancel()
+	if commitErr := c.commit(commitCtx, reader); commitErr != nil {
+		err = errors.Join(err, commitErr)
+	}
+	if closeErr := reader.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("flexkafka: close reader: %w", closeErr))
+	}
+	return recoverable(err)
+}
+
+// Halt stops fetching messages and waits for the message being handled to be
+// handled, and for the offsets to be committed, for at most the drain timeout,
+// or until the deadline of ctx if it is earlier.
+func (c *Consumer[M]) Halt(ctx context.Context) error {
+	c.mu.Lock()
+	stop, abort, done := c.stop, c.abort, c.done
+	c.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := c.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexkafka: in-flight message was not handled within %s", timeout)
+	}
+}
+
+// consume fetches and handles messages until the context is done, the reader
+// fails or the handler does.
+func (c *Consumer[M]) consume(ctx, handlerCtx context.Context, reader Reader[M]) error {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case c.rebalancing(err):
+			continue
+		case err != nil:
+			return fmt.Errorf("flexkafka: fetch message: %w", err)
+		}
+
+		if err := c.handle(handlerCtx, msg); err != nil {
+			return err
+		}
+
+		c.pendingMu.Lock()
+		c.pending = append(c.pending, msg)
+		c.pendingMu.Unlock()
+
+		if c.opts.commitInterval <= 0 {
+			if err := c.commit(ctx, reader); err != nil && ctx.Err() == nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle dispatches msg to the handler, converting panics into errors.
+func (c *Consumer[M]) handle(ctx context.Context, msg M) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("flexkafka: handler panicked: %v", r)
+		}
+	}()
+
+	if err := c.handler(ctx, msg); err != nil {
+		return fmt.Errorf("flexkafka: handle message: %w", err)
+	}
+	return nil
+}
+
+// commitEvery commits the offsets of handled messages at every commit
+// interval until the context is done. Failed commits are retried at the next
+// interval, and on shutdown.
+func (c *Consumer[M]) commitEvery(ctx context.Context, reader Reader[M]) {
+	ticker := time.NewTicker(c.opts.commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.commit(ctx, reader)
+		}
+	}
+}
+
+// commit commits the offsets of the handled messages, if any. Messages whose
+// offsets fail to be committed are kept for the next commit, unless the group
+// is rebalancing.
+func (c *Consumer[M]) commit(ctx context.Context, reader Reader[M]) error {
+	c.pendingMu.Lock()
+	msgs := c.pending
+	c.pending = nil
+	c.pendingMu.Unlock()
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	err := reader.CommitMessages(ctx, msgs...)
+	switch {
+	case err == nil, c.rebalancing(err):
+		return nil
+	default:
+		c.pendingMu.Lock()
+		c.pending = append(msgs, c.pending...)
+		c.pendingMu.Unlock()
+		return fmt.Errorf("flexkafka: commit offsets: %w", err)
+	}
+}
+
+// rebalancing reports whether err is one of the rebalance errors.
+func (c *Consumer[M]) rebalancing(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, target := range c.opts.rebalanceErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverable marks err, if not nil, with flex.Recoverable unless it was
+// marked with flex.Fatal.
+func recoverable(err error) error {
+	if err == nil || flex.IsFatal(err) {
+		return err
+	}
+	return flex.Recoverable(err)
+}
@@ -0,0 +1,248 @@
+package flexkafka_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexkafka"
+	"github.com/go-flexible/flex/flextest"
+)
+
+var errRebalance = errors.New("rebalance in progress")
+
+// mockBroker is a single partition topic read by a single member group,
+// whose readers resume from the committed offset.
+type mockBroker struct {
+	mu          sync.Mutex
+	log         []int
+	committed   int
+	connections int
+	commitErrs  []error
+	// hang makes commits block until their context is done.
+	hang bool
+}
+
+func (b *mockBroker) connect(context.Context) (flexkafka.Reader[int], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connections++
+	return &mockReader{broker: b, offset: b.committed}, nil
+}
+
+func (b *mockBroker) state() (committed, connections int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed, b.connections
+}
+
+type mockReader struct {
+	broker *mockBroker
+	offset int
+}
+
+func (r *mockReader) FetchMessage(ctx context.Context) (int, error) {
+	for {
+		r.broker.mu.Lock()
+		if r.offset < len(r.broker.log) {
+			msg := r.broker.log[r.offset]
+			r.offset++
+			r.broker.mu.Unlock()
+			return msg, nil
+		}
+		r.broker.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// CommitMessages commits the offset following the last message, messages
+// being their own offset.
+func (r *mockReader) CommitMessages(ctx context.Context, msgs ...int) error {
+	if r.broker.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+
+	if len(r.broker.commitErrs) > 0 {
+		err := r.broker.commitErrs[0]
+		r.broker.commitErrs = r.broker.commitErrs[1:]
+		return err
+	}
+	r.broker.committed = max(r.broker.committed, slices.Max(msgs)+1)
+	return nil
+}
+
+func (r *mockReader) Close() error { return nil }
+
+func TestConsumer(t *testing.T) {
+	t.Run("handled messages must be committed in order on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		broker := &mockBroker{log: []int{0, 1, 2}}
+		handled := make(chan int, 3)
+		c := flexkafka.New(broker.connect, func(_ context.Context, msg int) error {
+			handled <- msg
+			return nil
+		}, flexkafka.WithCommitInterval(time.Hour))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(c)
+		h := flextest.Start(t, m)
+
+		for want := range 3 {
+			if got := <-handled; got != want {
+				t.Errorf("expected %v but got: %v", want, got)
+			}
+		}
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if committed, _ := broker.state(); committed != 3 {
+			t.Errorf("expected offset %d to be committed but got: %d", 3, committed)
+		}
+	})
+	t.Run("failed messages must be delivered again once restarted", func(t *testing.T) {
+		t.Parallel()
+
+		broker := &mockBroker{log: []int{0, 1, 2}}
+		var (
+			mu       sync.Mutex
+			attempts = map[int]int{}
+		)
+		done := make(chan struct{})
+		c := flexkafka.New(broker.connect, func(_ context.Context, msg int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[msg]++
+			if msg == 1 && attempts[msg] == 1 {
+				return errors.New("transient")
+			}
+			if msg == 2 {
+				close(done)
+			}
+			return nil
+		}, flexkafka.WithCommitInterval(0))
+
+		m := flex.New(flex.WithSignals(), flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}))
+		m.Add(c)
+		h := flextest.Start(t, m)
+
+		<-done
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts[0] != 1 || attempts[1] != 2 || attempts[2] != 1 {
+			t.Errorf("expected only the failed message to be delivered again, but got: %v", attempts)
+		}
+		if _, connections := broker.state(); connections != 2 {
+			t.Errorf("expected the reader to be connected %d times but got: %d", 2, connections)
+		}
+	})
+	t.Run("errors must be recoverable unless marked as fatal", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range []struct {
+			err         error
+			recoverable bool
+		}{
+			{errors.New("transient"), true},
+			{flex.Fatal(errors.New("poison")), false},
+		} {
+			broker := &mockBroker{log: []int{0}}
+			c := flexkafka.New(broker.connect, func(context.Context, int) error { return tc.err })
+
+			err := c.Run(context.Background())
+			if !errors.Is(err, tc.err) || flex.IsRecoverable(err) != tc.recoverable {
+				t.Errorf("expected %v to be returned with recoverable=%v, but got: %v", tc.err, tc.recoverable, err)
+			}
+		}
+	})
+	t.Run("commits failing during a rebalance must not stop the consumer", func(t *testing.T) {
+		t.Parallel()
+
+		broker := &mockBroker{log: []int{0, 1}, commitErrs: []error{errRebalance}}
+		handled := make(chan int, 2)
+		c := flexkafka.New(broker.connect, func(_ context.Context, msg int) error {
+			handled <- msg
+			return nil
+		}, flexkafka.WithCommitInterval(0), flexkafka.WithRebalanceErrors(errRebalance))
+
+		errC := make(chan error, 1)
+		go func() { errC <- c.Run(context.Background()) }()
+
+		<-handled
+		<-handled
+		if err := c.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if committed, _ := broker.state(); committed != 2 {
+			t.Errorf("expected offset %d to be committed but got: %d", 2, committed)
+		}
+	})
+	t.Run("a message outliving the drain timeout must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		broker := &mockBroker{log: []int{0}}
+		handling := make(chan struct{})
+		c := flexkafka.New(broker.connect, func(ctx context.Context, _ int) error {
+			close(handling)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexkafka.WithDrainTimeout(10*time.Millisecond))
+
+		go c.Run(context.Background())
+
+		<-handling
+		if err := c.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if committed, _ := broker.state(); committed != 0 {
+			t.Errorf("expected no offset to be committed but got: %d", committed)
+		}
+	})
+	t.Run("the final commit must be bounded by the deadline of the halt", func(t *testing.T) {
+		t.Parallel()
+
+		broker := &mockBroker{log: []int{0}, hang: true}
+		handled := make(chan struct{})
+		c := flexkafka.New(broker.connect, func(context.Context, int) error {
+			close(handled)
+			return nil
+		}, flexkafka.WithCommitInterval(0))
+
+		errC := make(chan error, 1)
+		go func() { errC <- c.Run(context.Background()) }()
+		<-handled
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := c.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the commit to stop at the deadline, but halted in %s", elapsed)
+		}
+		if err := <-errC; err == nil {
+			t.Error("expected the failed commit to be returned")
+		}
+	})
+}
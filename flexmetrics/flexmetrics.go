@@ -0,0 +1,192 @@
+// Package flexmetrics provides a flex worker exposing metrics in the
+// Prometheus text format, along with the lifecycle metrics of a manager.
+//
+// The package does not depend on the Prometheus client library, instead it
+// comes with a lightweight Registry of counters and gauges:
+//
+//	registry := flexmetrics.NewRegistry()
+//	orders := registry.Counter("orders_total", "Orders received.", "status")
+//
+//	m := flex.New()
+//	m.Add(api)
+//	m.Add(flexmetrics.NewPrometheus(":9100", registry, flexmetrics.WithManager(m)))
+//
+//	orders.Inc("accepted")
+//
+// The metrics are served under /metrics, by a server with its own graceful
+// shutdown.
+//
+// Applications which already expose a registry of their own, such as a
+// prometheus.Registerer, rather record the lifecycle metrics into it with
+// Instrument, through an adapter implementing Registerer.
+package flexmetrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexhttp"
+)
+
+// healthTimeout is how long the workers are given to report their health
+// when the metrics are collected.
+const healthTimeout = 5 * time.Second
+
+// Option configures the metrics server.
+type Option func(*options)
+
+type options struct {
+	manager *flex.Manager
+	http    []flexhttp.Option
+}
+
+// WithManager registers the lifecycle metrics of m, recorded over its next
+// run, see Instrument.
+func WithManager(m *flex.Manager) Option {
+	return func(o *options) { o.manager = m }
+}
+
+// WithServerOptions sets options of the underlying flexhttp.Server, such as
+// its drain timeout.
+func WithServerOptions(opts ...flexhttp.Option) Option {
+	return func(o *options) { o.http = append(o.http, opts...) }
+}
+
+// NewPrometheus returns a worker serving the metrics of registry under
+// /metrics on addr.
+func NewPrometheus(addr string, registry *Registry, opts ...Option) *flexhttp.Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.manager != nil {
+		Instrument(o.manager, registry)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(registry))
+	return flexhttp.New(&http.Server{Addr: addr, Handler: mux}, o.http...)
+}
+
+// Handler returns a handler serving the metrics of registry.
+func Handler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = registry.WriteTo(w)
+	})
+}
+
+// Registerer registers the lifecycle metrics of a manager, see Instrument.
+// It is implemented by *Registry, and can be implemented over the registry of
+// a metrics library, so that the metrics join those of the application.
+type Registerer interface {
+	// RegisterCounter registers the counter named name with the given labels.
+	RegisterCounter(name, help string, labels ...string) CounterVec
+	// RegisterGauge registers the gauge named name with the given labels.
+	RegisterGauge(name, help string, labels ...string) GaugeVec
+	// RegisterHistogram registers the histogram named name with the given
+	// buckets, DefaultBuckets if nil, and labels.
+	RegisterHistogram(name, help string, buckets []float64, labels ...string) HistogramVec
+	// OnCollect registers fn to be called whenever the metrics are collected,
+	// before they are.
+	OnCollect(fn func())
+}
+
+// CounterVec is a counter registered by a Registerer, such as a *Counter.
+type CounterVec interface {
+	Add(v float64, values ...string)
+}
+
+// GaugeVec is a gauge registered by a Registerer, such as a *Gauge.
+type GaugeVec interface {
+	Set(v float64, values ...string)
+}
+
+// HistogramVec is a histogram registered by a Registerer, such as a
+// *Histogram.
+type HistogramVec interface {
+	Observe(v float64, values ...string)
+}
+
+// Instrument registers the lifecycle metrics of m into r, and records them
+// over every run of m. They are counted from every event of m, see
+// flex.Manager.Observe, so that none is missed however busy the manager is:
+//
+//   - flex_worker_starts_total, by worker, counts the workers which became ready;
+//   - flex_worker_failures_total, by worker, counts the errors returned by Run;
+//   - flex_worker_start_duration_seconds, by worker, is a histogram of how
+//     long the workers took to become ready;
+//   - flex_worker_halt_duration_seconds, by worker, is a histogram of how
+//     long the workers took to return from Halt;
+//   - flex_signals_received_total, by signal, counts the handled signals;
+//   - flex_reloads_total, by result, counts the reloads which succeeded or
+//     failed;
+//   - flex_shutdowns_total counts the shutdowns;
+//   - flex_shutdown_duration_seconds is how long the last shutdown lasted;
+//   - flex_worker_health, by worker and status, is 1 for the current health
+//     status of the worker, healthy, degraded or unhealthy, and 0 for the
+//     others, collected when the metrics are.
+func Instrument(m *flex.Manager, r Registerer) {
+	starts := r.RegisterCounter("flex_worker_starts_total", "Workers which became ready.", "worker")
+	failures := r.RegisterCounter("flex_worker_failures_total", "Errors returned by workers.", "worker")
+	startDuration := r.RegisterHistogram("flex_worker_start_duration_seconds", "How long workers took to become ready.", nil, "worker")
+	haltDuration := r.RegisterHistogram("flex_worker_halt_duration_seconds", "How long workers took to halt.", nil, "worker")
+	signals := r.RegisterCounter("flex_signals_received_total", "Signals handled by the manager.", "signal")
+	reloads := r.RegisterCounter("flex_reloads_total", "Reloads of the manager.", "result")
+	shutdowns := r.RegisterCounter("flex_shutdowns_total", "Shutdowns of the manager.")
+	duration := r.RegisterGauge("flex_shutdown_duration_seconds", "How long the last shutdown of the manager lasted.")
+	health := r.RegisterGauge("flex_worker_health", "Health status of workers.", "worker", "status")
+
+	r.OnCollect(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+		defer cancel()
+
+		for _, wh := range m.Health(ctx).Workers {
+			for _, status := range []flex.HealthStatus{flex.StatusHealthy, flex.StatusDegraded, flex.StatusUnhealthy} {
+				v := 0.0
+				if wh.Status() == status {
+					v = 1
+				}
+				health.Set(v, wh.Name, status.String())
+			}
+		}
+	})
+
+	var (
+		mu    sync.Mutex
+		began time.Time
+	)
+	m.Observe(func(e flex.Event) {
+		switch e.Kind {
+		case flex.EventWorkerStarted:
+			starts.Add(1, e.WorkerName)
+			startDuration.Observe(e.Duration.Seconds(), e.WorkerName)
+		case flex.EventWorkerHalted:
+			haltDuration.Observe(e.Duration.Seconds(), e.WorkerName)
+		case flex.EventWorkerFailed:
+			failures.Add(1, e.WorkerName)
+		case flex.EventSignalReceived:
+			signals.Add(1, e.Signal.String())
+		case flex.EventReloaded:
+			if e.Err != nil {
+				reloads.Add(1, "failure")
+			} else {
+				reloads.Add(1, "success")
+			}
+		case flex.EventShutdownBegan:
+			mu.Lock()
+			began = e.Time
+			mu.Unlock()
+		case flex.EventShutdownFinished:
+			mu.Lock()
+			d := e.Time.Sub(began)
+			mu.Unlock()
+			shutdowns.Add(1)
+			duration.Set(d.Seconds())
+		}
+	})
+}
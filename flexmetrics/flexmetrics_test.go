@@ -0,0 +1,180 @@
+package flexmetrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexmetrics"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// readyWorker reports itself ready and blocks until halted.
+type readyWorker struct{ halted chan struct{} }
+
+func (w *readyWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-w.halted
+	return nil
+}
+
+func (w *readyWorker) Halt(context.Context) error {
+	close(w.halted)
+	return nil
+}
+
+func TestNewPrometheus(t *testing.T) {
+	t.Run("metrics and lifecycle metrics must be served", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		registry.Counter("orders_total", "Orders.").Inc()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(&readyWorker{halted: make(chan struct{})}, flex.WithName("api"))
+		m.Add(flexmetrics.NewPrometheus(addr, registry, flexmetrics.WithManager(m)))
+
+		h := flextest.Start(t, m)
+		<-m.Started()
+		flextest.WaitListening(t, addr)
+
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"orders_total 1\n",
+			`flex_worker_starts_total{worker="api"} 1`,
+			`flex_worker_start_duration_seconds_count{worker="api"} 1`,
+			`flex_worker_health{worker="api",status="healthy"} 1`,
+			`flex_worker_health{worker="api",status="degraded"} 0`,
+		} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("expected the metrics to contain %q but got:\n%s", want, body)
+			}
+		}
+
+		if err := m.Reload(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		contains(t, registry, `flex_reloads_total{result="success"} 1`)
+
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		contains(t, registry, "flex_shutdowns_total 1\n")
+		contains(t, registry, `flex_worker_halt_duration_seconds_count{worker="api"} 1`)
+	})
+}
+
+// recordingRegisterer is a Registerer of another metrics library, recording
+// the values of its metrics by name and label values.
+type recordingRegisterer struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// metric is a metric registered by recordingRegisterer.
+type metric struct {
+	r    *recordingRegisterer
+	name string
+}
+
+func (m metric) Add(v float64, values ...string) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+	m.r.values[m.name+strings.Join(values, ",")] += v
+}
+
+func (m metric) Set(v float64, values ...string) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+	m.r.values[m.name+strings.Join(values, ",")] = v
+}
+
+func (m metric) Observe(v float64, values ...string) { m.Add(1, values...) }
+
+func (r *recordingRegisterer) RegisterCounter(name, _ string, _ ...string) flexmetrics.CounterVec {
+	return metric{r: r, name: name}
+}
+
+func (r *recordingRegisterer) RegisterGauge(name, _ string, _ ...string) flexmetrics.GaugeVec {
+	return metric{r: r, name: name}
+}
+
+func (r *recordingRegisterer) RegisterHistogram(name, _ string, _ []float64, _ ...string) flexmetrics.HistogramVec {
+	return metric{r: r, name: name}
+}
+
+func (r *recordingRegisterer) OnCollect(func()) {}
+
+func (r *recordingRegisterer) value(key string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.values[key]
+}
+
+func TestInstrument(t *testing.T) {
+	t.Run("lifecycle metrics must be recorded into other registerers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), flextest.DefaultTimeout)
+		defer cancel()
+
+		r := &recordingRegisterer{values: make(map[string]float64)}
+		m := flex.New(flex.WithSignals())
+		m.Add(flex.NewWorker(func(ctx context.Context) error {
+			flex.Ready(ctx)
+			return errors.New("boom")
+		}, nil), flex.WithName("api"))
+		flexmetrics.Instrument(m, r)
+
+		_ = m.Start(ctx)
+
+		for key, want := range map[string]float64{
+			"flex_worker_starts_totalapi":   1,
+			"flex_worker_failures_totalapi": 1,
+			"flex_shutdowns_total":          1,
+		} {
+			if got := r.value(key); got != want {
+				t.Errorf("expected %s to be %v but got: %v", key, want, got)
+			}
+		}
+	})
+	t.Run("lifecycle metrics must not be missed however busy the manager is", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		m := flex.New(flex.WithSignals())
+		flexmetrics.Instrument(m, registry)
+
+		for range 2 * flex.EventBuffer {
+			if err := m.Reload(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		contains(t, registry, fmt.Sprintf(`flex_reloads_total{result="success"} %d`, 2*flex.EventBuffer))
+	})
+}
+
+// contains checks that the metrics of registry contain want.
+func contains(t *testing.T, registry *flexmetrics.Registry, want string) {
+	t.Helper()
+
+	if got := exposition(t, registry); !strings.Contains(got, want) {
+		t.Errorf("expected the metrics to contain %q but got:\n%s", want, got)
+	}
+}
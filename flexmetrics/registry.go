@@ -0,0 +1,292 @@
+package flexmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics, exposed in the Prometheus text format.
+// The zero value is not ready to use, see NewRegistry.
+type Registry struct {
+	mu         sync.Mutex
+	families   map[string]*family
+	order      []string
+	collectors []func()
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// DefaultBuckets are the upper bounds of the buckets of histograms, in
+// seconds, when none are given, as used by the Prometheus client libraries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// family is a metric and its series, keyed by their label values.
+type family struct {
+	name, help, kind string
+	labels           []string
+	// buckets are the upper bounds of the buckets of a histogram.
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for a set of label values. The value of a
+// histogram is the sum of its observations, counted by bucket.
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+// Counter is a metric which only goes up.
+type Counter struct{ f *family }
+
+// Gauge is a metric which goes up and down.
+type Gauge struct{ f *family }
+
+// Histogram is a metric counting observations, such as durations, in
+// buckets.
+type Histogram struct{ f *family }
+
+// Counter returns the counter named name with the given labels, registering
+// it if needed. It panics if a metric of another type or labels is registered
+// under name.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels)}
+}
+
+// Gauge returns the gauge named name with the given labels, registering it
+// if needed. It panics if a metric of another type or labels is registered
+// under name.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labels)}
+}
+
+// Histogram returns the histogram named name with the given buckets, their
+// sorted upper bounds, or DefaultBuckets if nil, and labels, registering it if
+// needed. It panics if a metric of another type, buckets or labels is
+// registered under name.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{r.register(name, help, "histogram", labels, buckets...)}
+}
+
+// RegisterCounter returns the counter named name, see Counter, so that a
+// Registry is a Registerer.
+func (r *Registry) RegisterCounter(name, help string, labels ...string) CounterVec {
+	return r.Counter(name, help, labels...)
+}
+
+// RegisterGauge returns the gauge named name, see Gauge.
+func (r *Registry) RegisterGauge(name, help string, labels ...string) GaugeVec {
+	return r.Gauge(name, help, labels...)
+}
+
+// RegisterHistogram returns the histogram named name, see Histogram.
+func (r *Registry) RegisterHistogram(name, help string, buckets []float64, labels ...string) HistogramVec {
+	return r.Histogram(name, help, buckets, labels...)
+}
+
+// register returns the family named name, registering it if needed.
+func (r *Registry) register(name, help, kind string, labels []string, buckets ...float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || !slices.Equal(f.labels, labels) || !slices.Equal(f.buckets, buckets) {
+			panic(fmt.Sprintf("flexmetrics: %s registered as a %s with labels %v", name, f.kind, f.labels))
+		}
+		return f
+	}
+
+	f := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.families[name] = f
+	r.order = append(r.order, name)
+	return f
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *Counter) Inc(values ...string) { c.Add(1, values...) }
+
+// Add increments the counter for the given label values by v, which must not
+// be negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("flexmetrics: counters cannot decrease")
+	}
+	c.f.update(values, func(s *series) { s.value += v })
+}
+
+// Set sets the gauge for the given label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.update(values, func(s *series) { s.value = v })
+}
+
+// Add adds v, which may be negative, to the gauge for the given label values.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.update(values, func(s *series) { s.value += v })
+}
+
+// Observe records v, such as a duration in seconds, for the given label
+// values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.update(values, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.f.buckets))
+		}
+		for i, bound := range h.f.buckets {
+			if v <= bound {
+				s.counts[i]++
+			}
+		}
+		s.count++
+		s.value += v
+	})
+}
+
+// update applies fn to the series of the given label values, creating it if
+// needed.
+func (f *family) update(values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("flexmetrics: %s expects %d label values but got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// OnCollect registers fn to be called whenever the metrics are written, before
+// they are, to update the metrics which are computed on demand.
+func (r *Registry) OnCollect(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// WriteTo writes the metrics in the Prometheus text format to w.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+	for _, collect := range collectors {
+		collect()
+	}
+
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.order))
+	for _, name := range r.order {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, f := range families {
+		f.mu.Lock()
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escape(f.help, false))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != "histogram" {
+				writeSample(bw, f.name, f.labels, s.values, s.value)
+				continue
+			}
+
+			labels := append(slices.Clone(f.labels), "le")
+			for i, bound := range f.buckets {
+				writeSample(bw, f.name+"_bucket", labels, append(slices.Clone(s.values), formatValue(bound)), float64(s.counts[i]))
+			}
+			writeSample(bw, f.name+"_bucket", labels, append(slices.Clone(s.values), "+Inf"), float64(s.count))
+			writeSample(bw, f.name+"_sum", f.labels, s.values, s.value)
+			writeSample(bw, f.name+"_count", f.labels, s.values, float64(s.count))
+		}
+		f.mu.Unlock()
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// writeSample writes a sample of the metric name with the given labels and
+// their values.
+func writeSample(bw *bufio.Writer, name string, labels, values []string, v float64) {
+	bw.WriteString(name)
+	if len(labels) > 0 {
+		bw.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			fmt.Fprintf(bw, "%s=\"%s\"", label, escape(values[i], true))
+		}
+		bw.WriteByte('}')
+	}
+	fmt.Fprintf(bw, " %s\n", formatValue(v))
+}
+
+// escape escapes s for the text format, in which label values additionally
+// escape double quotes.
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+// formatValue formats v for the text format.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+package flexmetrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-flexible/flex/flexmetrics"
+)
+
+// exposition returns the metrics of registry in the text format.
+func exposition(t *testing.T, registry *flexmetrics.Registry) string {
+	t.Helper()
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("metrics must be written in the text format", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		requests := registry.Counter("requests_total", "Requests served.", "code")
+		inFlight := registry.Gauge("in_flight", "Requests in flight.")
+
+		requests.Inc("500")
+		requests.Add(2, "200")
+		inFlight.Set(3)
+		inFlight.Add(-1)
+
+		want := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{code="200"} 2
+requests_total{code="500"} 1
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 2
+`
+		if got := exposition(t, registry); got != want {
+			t.Errorf("expected:\n%s\nbut got:\n%s", want, got)
+		}
+	})
+	t.Run("histograms must be written with their buckets", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		latency := registry.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+
+		latency.Observe(0.05, "/")
+		latency.Observe(0.5, "/")
+		latency.Observe(2, "/")
+
+		want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 1
+latency_seconds_bucket{route="/",le="1"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 3
+latency_seconds_sum{route="/"} 2.55
+latency_seconds_count{route="/"} 3
+`
+		if got := exposition(t, registry); got != want {
+			t.Errorf("expected:\n%s\nbut got:\n%s", want, got)
+		}
+	})
+	t.Run("label values must be escaped", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		registry.Counter("errors_total", "Errors.", "err").Inc("bad \"input\"\n\\")
+
+		want := `errors_total{err="bad \"input\"\n\\"} 1`
+		if got := exposition(t, registry); !strings.Contains(got, want) {
+			t.Errorf("expected %q to contain %q", got, want)
+		}
+	})
+	t.Run("registering a metric again must return it", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		registry.Counter("jobs_total", "Jobs.").Inc()
+		registry.Counter("jobs_total", "Jobs.").Inc()
+
+		if got := exposition(t, registry); !strings.Contains(got, "jobs_total 2\n") {
+			t.Errorf("expected the counter to be shared but got:\n%s", got)
+		}
+	})
+	t.Run("metrics computed on demand must be collected before being written", func(t *testing.T) {
+		t.Parallel()
+
+		registry := flexmetrics.NewRegistry()
+		queued := registry.Gauge("jobs_queued", "Jobs queued.")
+		n := 0
+		registry.OnCollect(func() {
+			n++
+			queued.Set(float64(n))
+		})
+
+		for _, want := range []string{"jobs_queued 1\n", "jobs_queued 2\n"} {
+			if got := exposition(t, registry); !strings.Contains(got, want) {
+				t.Errorf("expected the metrics to contain %q but got:\n%s", want, got)
+			}
+		}
+	})
+	t.Run("registering a metric with another type must panic", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		registry := flexmetrics.NewRegistry()
+		registry.Counter("jobs", "Jobs.")
+		registry.Gauge("jobs", "Jobs.")
+	})
+}
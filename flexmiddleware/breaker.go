@@ -0,0 +1,200 @@
+package flexmiddleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultBreakerFailures is how many failures within the window open the
+	// breaker when no number of failures is configured.
+	DefaultBreakerFailures = 5
+	// DefaultBreakerWindow is the window failures are counted in when no
+	// window is configured.
+	DefaultBreakerWindow = time.Minute
+	// DefaultBreakerCooldown is how long an open breaker keeps the worker
+	// stopped when no cool-down is configured.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed is the state of a breaker restarting its worker as soon
+	// as it fails.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen is the state of a breaker keeping its worker stopped for
+	// the cool-down.
+	BreakerOpen
+	// BreakerHalfOpen is the state of a breaker probing whether its worker
+	// recovered, by running it again after the cool-down.
+	BreakerHalfOpen
+)
+
+// String returns a string representation of the BreakerState.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker configures a circuit breaker. Its zero value uses the defaults.
+type Breaker struct {
+	// Failures is how many failures within the window open the breaker.
+	Failures int
+	// Window is the window failures are counted in, and how long a probe must
+	// run without failing for the breaker to close again.
+	Window time.Duration
+	// Cooldown is how long an open breaker keeps the worker stopped.
+	Cooldown time.Duration
+	// OnStateChange, if set, is called whenever the breaker changes state.
+	OnStateChange func(from, to BreakerState)
+}
+
+// CircuitBreaker returns a middleware restarting the worker whenever Run
+// fails, until it fails too often. The breaker then opens, keeping the worker
+// stopped for the cool-down, and half-opens to probe it by running it again:
+// the breaker closes once the probe has run for the window without failing,
+// and opens again if it fails before then. This protects shared dependencies
+// from a worker failing instantly in a tight restart loop.
+//
+// Run returns once the worker returns nil or an error marked with flex.Fatal,
+// its context is done or it is halted.
+func CircuitBreaker(config Breaker) Middleware {
+	if config.Failures <= 0 {
+		config.Failures = DefaultBreakerFailures
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultBreakerWindow
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultBreakerCooldown
+	}
+
+	return func(w flex.Worker) flex.Worker {
+		return &breaker{Worker: w, config: config, halted: make(chan struct{})}
+	}
+}
+
+// breaker is a worker restarting another through a circuit breaker.
+type breaker struct {
+	flex.Worker
+	config Breaker
+
+	haltOnce sync.Once
+	halted   chan struct{}
+
+	mu    sync.Mutex
+	state BreakerState
+}
+
+// Run runs the worker through the circuit breaker.
+func (b *breaker) Run(ctx context.Context) error {
+	var failures []time.Time
+
+	for {
+		probe := b.probe()
+		err := b.Worker.Run(ctx)
+		probe.Stop()
+
+		if err == nil || flex.IsFatal(err) || b.stopping(ctx) {
+			return err
+		}
+
+		now := time.Now()
+		failures = append(failures, now)
+		for len(failures) > 0 && now.Sub(failures[0]) > b.config.Window {
+			failures = failures[1:]
+		}
+
+		if b.currentState() == BreakerClosed && len(failures) < b.config.Failures {
+			logger.Printf("%T failed to run, restarting: %v", b.Worker, err)
+			continue
+		}
+
+		logger.Printf("%T failed to run, opening the circuit for %s: %v", b.Worker, b.config.Cooldown, err)
+		b.transition(BreakerOpen)
+		failures = nil
+
+		timer := time.NewTimer(b.config.Cooldown)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-b.halted:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		b.transition(BreakerHalfOpen)
+	}
+}
+
+// probe returns a timer closing the breaker once a half-open breaker's worker
+// has run for the window.
+func (b *breaker) probe() *time.Timer {
+	return time.AfterFunc(b.config.Window, func() {
+		b.mu.Lock()
+		halfOpen := b.state == BreakerHalfOpen
+		b.mu.Unlock()
+
+		if halfOpen {
+			b.transition(BreakerClosed)
+		}
+	})
+}
+
+// stopping reports whether the worker is being shut down.
+func (b *breaker) stopping(ctx context.Context) bool {
+	select {
+	case <-b.halted:
+		return true
+	default:
+		return ctx.Err() != nil
+	}
+}
+
+// currentState returns the state of the breaker.
+func (b *breaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// transition moves the breaker to state.
+func (b *breaker) transition(state BreakerState) {
+	b.mu.Lock()
+	from := b.state
+	b.state = state
+	b.mu.Unlock()
+
+	if from != state && b.config.OnStateChange != nil {
+		b.config.OnStateChange(from, state)
+	}
+}
+
+// Halt stops restarting and halts the worker.
+func (b *breaker) Halt(ctx context.Context) error {
+	b.haltOnce.Do(func() { close(b.halted) })
+	return b.Worker.Halt(ctx)
+}
+
+// Reload reloads the worker, if it implements flex.Reloader.
+func (b *breaker) Reload(ctx context.Context) error {
+	if reloader, ok := b.Worker.(flex.Reloader); ok {
+		return reloader.Reload(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package flexmiddleware_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexmiddleware"
+)
+
+// failingWorker fails as long as failing is set, and otherwise blocks until
+// its context is done.
+type failingWorker struct {
+	failing atomic.Bool
+	runs    atomic.Int32
+}
+
+func (f *failingWorker) Run(ctx context.Context) error {
+	f.runs.Add(1)
+	if f.failing.Load() {
+		return errors.New("boom")
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (f *failingWorker) Halt(context.Context) error { return nil }
+
+// transitions records the state changes of a breaker.
+type transitions struct {
+	mu     sync.Mutex
+	states []flexmiddleware.BreakerState
+}
+
+func (tr *transitions) record(_, to flexmiddleware.BreakerState) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.states = append(tr.states, to)
+}
+
+func (tr *transitions) get() []flexmiddleware.BreakerState {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return slices.Clone(tr.states)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Run("a flapping worker must be kept stopped, then probed", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			worker = &failingWorker{}
+			states transitions
+		)
+		worker.failing.Store(true)
+
+		w := flexmiddleware.CircuitBreaker(flexmiddleware.Breaker{
+			Failures:      3,
+			Window:        50 * time.Millisecond,
+			Cooldown:      50 * time.Millisecond,
+			OnStateChange: states.record,
+		})(worker)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		time.Sleep(25 * time.Millisecond)
+		if runs := worker.runs.Load(); runs != 3 {
+			t.Errorf("expected the worker to be stopped after %d runs, but got: %d", 3, runs)
+		}
+
+		// The probe after the cool-down fails, opening the breaker again.
+		time.Sleep(50 * time.Millisecond)
+		if runs := worker.runs.Load(); runs != 4 {
+			t.Errorf("expected a single probe run, but got: %d runs", runs)
+		}
+
+		// The next probe recovers, and closes the breaker after the window.
+		worker.failing.Store(false)
+		time.Sleep(150 * time.Millisecond)
+
+		expected := []flexmiddleware.BreakerState{
+			flexmiddleware.BreakerOpen,
+			flexmiddleware.BreakerHalfOpen,
+			flexmiddleware.BreakerOpen,
+			flexmiddleware.BreakerHalfOpen,
+			flexmiddleware.BreakerClosed,
+		}
+		if got := states.get(); !slices.Equal(got, expected) {
+			t.Errorf("expected %v but got: %v", expected, got)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("halting must stop an open breaker", func(t *testing.T) {
+		t.Parallel()
+
+		worker := &failingWorker{}
+		worker.failing.Store(true)
+
+		w := flexmiddleware.CircuitBreaker(flexmiddleware.Breaker{Failures: 1, Cooldown: time.Hour})(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		for worker.runs.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := w.Halt(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-errC:
+			if err == nil {
+				t.Error("expected the error of the last run")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the run to return once halted")
+		}
+	})
+}
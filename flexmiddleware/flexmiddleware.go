@@ -0,0 +1,144 @@
+// Package flexmiddleware provides middleware decorating flex workers with
+// common behaviour, such as retrying runs which fail because of transient
+// errors or backing off from workers which keep failing, without each worker
+// reimplementing it.
+//
+//	m.Add(flexmiddleware.Retry(flexmiddleware.Policy{
+//		MaxAttempts: 5,
+//		Retryable:   func(err error) bool { return errors.Is(err, amqp.ErrClosed) },
+//	})(consumer))
+package flexmiddleware
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultBackoff is how long to wait before the first retry when no
+	// backoff is configured.
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff is the longest wait between retries when no maximum
+	// backoff is configured.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultMultiplier is what the backoff is multiplied by after every
+	// retry when no multiplier is configured.
+	DefaultMultiplier = 2
+)
+
+var logger = log.New(os.Stderr, "flexmiddleware: ", 0)
+
+// Middleware decorates a worker.
+type Middleware func(flex.Worker) flex.Worker
+
+// Policy configures how runs are retried. Its zero value retries every error
+// forever, with the default backoff.
+type Policy struct {
+	// MaxAttempts is how many times Run is called before its error is
+	// returned, zero meaning no limit.
+	MaxAttempts int
+	// Backoff is how long to wait before the first retry.
+	Backoff time.Duration
+	// MaxBackoff is the longest wait between retries.
+	MaxBackoff time.Duration
+	// Multiplier is what the backoff is multiplied by after every retry.
+	Multiplier float64
+	// Retryable reports whether a run failing with err is retried, every
+	// error is when it is nil. Errors marked with flex.Fatal never are.
+	Retryable func(err error) bool
+}
+
+// Retry returns a middleware calling Run again when it fails, according to
+// policy, until it returns nil, its context is done or the worker is halted.
+// The error of the last attempt is returned once the policy gives up.
+func Retry(policy Policy) Middleware {
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultMaxBackoff
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = DefaultMultiplier
+	}
+
+	return func(w flex.Worker) flex.Worker {
+		return &retrier{Worker: w, policy: policy, halted: make(chan struct{})}
+	}
+}
+
+// retrier is a worker retrying the runs of another.
+type retrier struct {
+	flex.Worker
+	policy Policy
+
+	haltOnce sync.Once
+	halted   chan struct{}
+}
+
+// Run runs the worker, retrying it as long as the policy allows.
+func (r *retrier) Run(ctx context.Context) error {
+	backoff := r.policy.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := r.Worker.Run(ctx)
+		if err == nil || !r.retry(ctx, attempt, err) {
+			return err
+		}
+
+		logger.Printf("%T failed to run, retrying in %s (attempt %d): %v", r.Worker, backoff, attempt, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-r.halted:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = min(time.Duration(float64(backoff)*r.policy.Multiplier), r.policy.MaxBackoff)
+	}
+}
+
+// retry reports whether the run which failed with err after attempt
+// attempts is to be retried.
+func (r *retrier) retry(ctx context.Context, attempt int, err error) bool {
+	select {
+	case <-r.halted:
+		return false
+	default:
+	}
+
+	switch {
+	case ctx.Err() != nil, flex.IsFatal(err):
+		return false
+	case r.policy.MaxAttempts > 0 && attempt >= r.policy.MaxAttempts:
+		return false
+	case r.policy.Retryable != nil && !r.policy.Retryable(err):
+		return false
+	default:
+		return true
+	}
+}
+
+// Halt stops retrying and halts the worker.
+func (r *retrier) Halt(ctx context.Context) error {
+	r.haltOnce.Do(func() { close(r.halted) })
+	return r.Worker.Halt(ctx)
+}
+
+// Reload reloads the worker, if it implements flex.Reloader.
+func (r *retrier) Reload(ctx context.Context) error {
+	if reloader, ok := r.Worker.(flex.Reloader); ok {
+		return reloader.Reload(ctx)
+	}
+	return nil
+}
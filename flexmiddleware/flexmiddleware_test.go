@@ -0,0 +1,123 @@
+package flexmiddleware_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexmiddleware"
+)
+
+// flakyWorker fails with err the first failures times it is run.
+type flakyWorker struct {
+	err      error
+	failures int32
+	runs     atomic.Int32
+}
+
+func (f *flakyWorker) Run(context.Context) error {
+	if f.runs.Add(1) <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyWorker) Halt(context.Context) error { return nil }
+
+func TestRetry(t *testing.T) {
+	t.Run("a failing run must be retried until it succeeds", func(t *testing.T) {
+		t.Parallel()
+
+		worker := &flakyWorker{err: errors.New("boom"), failures: 3}
+		retried := flexmiddleware.Retry(flexmiddleware.Policy{Backoff: time.Millisecond})(worker)
+
+		if err := retried.Run(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if runs := worker.runs.Load(); runs != 4 {
+			t.Errorf("expected %d runs but got: %d", 4, runs)
+		}
+	})
+	t.Run("the last error must be returned once the attempts are exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("boom")
+		worker := &flakyWorker{err: boom, failures: 5}
+		retried := flexmiddleware.Retry(flexmiddleware.Policy{MaxAttempts: 2, Backoff: time.Millisecond})(worker)
+
+		if err := retried.Run(context.Background()); !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+		if runs := worker.runs.Load(); runs != 2 {
+			t.Errorf("expected %d runs but got: %d", 2, runs)
+		}
+	})
+	t.Run("errors which are not retryable must be returned", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("boom")
+		for _, policy := range []flexmiddleware.Policy{
+			{Retryable: func(err error) bool { return !errors.Is(err, boom) }},
+			{},
+		} {
+			err := boom
+			if policy.Retryable == nil {
+				err = flex.Fatal(boom)
+			}
+
+			worker := &flakyWorker{err: err, failures: 5}
+			if err := flexmiddleware.Retry(policy)(worker).Run(context.Background()); !errors.Is(err, boom) {
+				t.Errorf("expected %v but got: %v", boom, err)
+			}
+			if runs := worker.runs.Load(); runs != 1 {
+				t.Errorf("expected %d run but got: %d", 1, runs)
+			}
+		}
+	})
+	t.Run("halting must stop retrying", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("boom")
+		worker := &flakyWorker{err: boom, failures: 5}
+		retried := flexmiddleware.Retry(flexmiddleware.Policy{Backoff: time.Hour})(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- retried.Run(context.Background()) }()
+
+		for worker.runs.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := retried.Halt(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, boom) {
+				t.Errorf("expected %v but got: %v", boom, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the run to return once halted")
+		}
+	})
+	t.Run("a retried worker must run under a manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		worker := &flakyWorker{err: errors.New("boom"), failures: 1}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(flexmiddleware.Retry(flexmiddleware.Policy{Backoff: time.Millisecond})(worker))
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		if runs := worker.runs.Load(); runs != 2 {
+			t.Errorf("expected %d runs but got: %d", 2, runs)
+		}
+	})
+}
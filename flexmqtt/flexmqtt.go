@@ -0,0 +1,332 @@
+// Package flexmqtt provides a flex worker managing an MQTT client connection
+// and its subscriptions.
+//
+// The worker does not depend on any MQTT library, instead it drives a Client,
+// which is typically a thin shim over a library such as paho.mqtt.golang,
+// with auto-reconnect disabled since the worker owns reconnection.
+//
+// Once halted, the worker waits for in-flight publishes, unsubscribes and
+// sends DISCONNECT, so that the broker does not publish the last will set
+// with WithWill, which is reserved for unexpected disconnections.
+package flexmqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+var logger = log.New(os.Stderr, "flexmqtt: ", 0)
+
+const (
+	// DefaultMinBackoff is the default delay before the first reconnection attempt.
+	DefaultMinBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff is the default maximum delay between reconnection attempts.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultDrainTimeout is the default time given to in-flight publishes to
+	// complete during Halt.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by Publish once the worker is halting.
+var ErrClosed = errors.New("flexmqtt: client is closed")
+
+// QoS is an MQTT quality of service level.
+type QoS byte
+
+// Quality of service levels.
+const (
+	AtMostOnce  QoS = 0
+	AtLeastOnce QoS = 1
+	ExactlyOnce QoS = 2
+)
+
+// Message is a received MQTT message.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      QoS
+	Retained bool
+}
+
+// Handler handles a received message.
+type Handler func(ctx context.Context, msg Message)
+
+// Client is an MQTT client connection.
+type Client interface {
+	// Connect connects to the broker. The returned channel must receive, or
+	// be closed, once the connection is lost.
+	Connect(ctx context.Context) (lost <-chan error, err error)
+	// Subscribe subscribes to topic, calling handler for every message.
+	Subscribe(ctx context.Context, topic string, qos QoS, handler func(Message)) error
+	// Publish publishes a message, returning once it has been delivered
+	// according to its quality of service.
+	Publish(ctx context.Context, topic string, qos QoS, retained bool, payload []byte) error
+	// Disconnect sends DISCONNECT to the broker and closes the connection.
+	Disconnect(ctx context.Context) error
+}
+
+// Will is the message the broker publishes on behalf of the client when its
+// connection is lost without DISCONNECT having been sent.
+type Will struct {
+	Topic    string
+	QoS      QoS
+	Retained bool
+	Payload  []byte
+}
+
+// WillSetter is implemented by clients supporting a last will, which they
+// must send with every following CONNECT.
+type WillSetter interface {
+	SetWill(will Will)
+}
+
+// Unsubscriber is implemented by clients able to unsubscribe, which the
+// worker does before disconnecting once halted.
+type Unsubscriber interface {
+	Unsubscribe(ctx context.Context, topics ...string) error
+}
+
+// Subscription is a topic filter subscribed to with a given quality of service.
+type Subscription struct {
+	Topic   string
+	QoS     QoS
+	Handler Handler
+}
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	subscriptions []Subscription
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	drainTimeout  time.Duration
+	will          *Will
+	onError       func(error)
+}
+
+// WithSubscription subscribes to topic once connected, and again after every reconnection.
+func WithSubscription(topic string, qos QoS, handler Handler) Option {
+	return func(o *options) {
+		o.subscriptions = append(o.subscriptions, Subscription{Topic: topic, QoS: qos, Handler: handler})
+	}
+}
+
+// WithReconnectBackoff sets the bounds of the exponential backoff between
+// reconnection attempts.
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithDrainTimeout sets how long in-flight publishes are given to complete
+// once the worker is halted, unless the context given to Halt expires first.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the panics of the
+// handlers, which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// WithWill sets the last will of the client, which requires the client to
+// implement WillSetter.
+func WithWill(topic string, qos QoS, retained bool, payload []byte) Option {
+	return func(o *options) {
+		o.will = &Will{Topic: topic, QoS: qos, Retained: retained, Payload: payload}
+	}
+}
+
+// Worker is a flex worker which keeps an MQTT client connected and subscribed.
+type Worker struct {
+	client Client
+	opts   options
+
+	mu         sync.RWMutex
+	closed     bool
+	connected  bool
+	publishing sync.WaitGroup
+	handlerCtx context.Context
+}
+
+// New returns a Worker managing client.
+func New(client Client, opts ...Option) *Worker {
+	w := &Worker{
+		client: client,
+		opts: options{
+			minBackoff:   DefaultMinBackoff,
+			maxBackoff:   DefaultMaxBackoff,
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+		handlerCtx: context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run connects and subscribes, reconnecting and resubscribing with backoff
+// whenever the connection is lost, until the context is done.
+// The worker reports itself ready once it is first connected and subscribed.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.opts.will != nil {
+		setter, ok := w.client.(WillSetter)
+		if !ok {
+			return errors.New("flexmqtt: client does not support a last will")
+		}
+		setter.SetWill(*w.opts.will)
+	}
+
+	w.mu.Lock()
+	w.handlerCtx = context.WithoutCancel(ctx)
+	w.mu.Unlock()
+
+	backoff := w.opts.minBackoff
+	for {
+		lost, err := w.connect(ctx)
+		if err == nil {
+			w.setConnected(true)
+			flex.Ready(ctx)
+			backoff = w.opts.minBackoff
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case err = <-lost:
+				w.setConnected(false)
+				logger.Printf("connection lost, reconnecting in %s: %v", backoff, err)
+			}
+		} else {
+			logger.Printf("reconnecting in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.opts.maxBackoff)
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is first
+// connected and subscribed, see flex.ReadyReporter.
+func (w *Worker) ReportsReady() bool { return true }
+
+// Halt stops accepting publishes, waits for in-flight publishes to complete
+// for at most the drain timeout, or until the deadline of ctx if it is
+// earlier, then unsubscribes, if the client implements Unsubscriber, and
+// disconnects cleanly within what remains of that time.
+func (w *Worker) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+
+	timeout := w.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	drained := make(chan struct{})
+	go func() {
+		w.publishing.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("flexmqtt: in-flight publishes did not complete within %s", timeout)
+	}
+
+	w.mu.RLock()
+	connected := w.connected
+	w.mu.RUnlock()
+
+	if unsubscriber, ok := w.client.(Unsubscriber); ok && connected && len(w.opts.subscriptions) > 0 {
+		topics := make([]string, len(w.opts.subscriptions))
+		for i, sub := range w.opts.subscriptions {
+			topics[i] = sub.Topic
+		}
+		if unsubscribeErr := unsubscriber.Unsubscribe(ctx, topics...); unsubscribeErr != nil {
+			err = errors.Join(err, fmt.Errorf("flexmqtt: unsubscribe: %w", unsubscribeErr))
+		}
+	}
+
+	if disconnectErr := w.client.Disconnect(ctx); disconnectErr != nil {
+		err = errors.Join(err, fmt.Errorf("flexmqtt: disconnect: %w", disconnectErr))
+	}
+	return err
+}
+
+// Publish publishes a message through the managed client.
+// Publishes in flight when the worker is halted are allowed to complete
+// before disconnecting, while new ones fail with ErrClosed.
+func (w *Worker) Publish(ctx context.Context, topic string, qos QoS, retained bool, payload []byte) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrClosed
+	}
+	w.publishing.Add(1)
+	w.mu.RUnlock()
+	defer w.publishing.Done()
+
+	return w.client.Publish(ctx, topic, qos, retained, payload)
+}
+
+// setConnected records whether the client is connected.
+func (w *Worker) setConnected(connected bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.connected = connected
+}
+
+// connect connects the client and subscribes to every subscription.
+func (w *Worker) connect(ctx context.Context) (<-chan error, error) {
+	lost, err := w.client.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("flexmqtt: connect: %w", err)
+	}
+
+	for _, sub := range w.opts.subscriptions {
+		if err := w.client.Subscribe(ctx, sub.Topic, sub.QoS, w.dispatcher(sub.Handler)); err != nil {
+			_ = w.client.Disconnect(ctx)
+			return nil, fmt.Errorf("flexmqtt: subscribe to %q: %w", sub.Topic, err)
+		}
+	}
+	return lost, nil
+}
+
+// dispatcher returns a callback which dispatches messages to handler,
+// isolating the client from panics in the handler, which are reported to the
+// error handler.
+func (w *Worker) dispatcher(handler Handler) func(Message) {
+	return func(msg Message) {
+		defer func() {
+			if r := recover(); r != nil {
+				w.opts.onError(fmt.Errorf("flexmqtt: handler of %q panicked: %v\n%s", msg.Topic, r, debug.Stack()))
+			}
+		}()
+
+		w.mu.RLock()
+		ctx := w.handlerCtx
+		w.mu.RUnlock()
+
+		handler(ctx, msg)
+	}
+}
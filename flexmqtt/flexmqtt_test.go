@@ -0,0 +1,244 @@
+package flexmqtt_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexmqtt"
+)
+
+// mockClient is an in-memory MQTT client whose connection can be dropped.
+type mockClient struct {
+	mu            sync.Mutex
+	connects      int
+	subscriptions map[string]func(flexmqtt.Message)
+	lost          chan error
+	disconnected  bool
+	publishDelay  time.Duration
+	published     int
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{subscriptions: make(map[string]func(flexmqtt.Message))}
+}
+
+func (c *mockClient) Connect(context.Context) (<-chan error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connects++
+	c.subscriptions = make(map[string]func(flexmqtt.Message))
+	c.lost = make(chan error, 1)
+	return c.lost, nil
+}
+
+func (c *mockClient) Subscribe(_ context.Context, topic string, _ flexmqtt.QoS, handler func(flexmqtt.Message)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions[topic] = handler
+	return nil
+}
+
+func (c *mockClient) Publish(context.Context, string, flexmqtt.QoS, bool, []byte) error {
+	time.Sleep(c.publishDelay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published++
+	return nil
+}
+
+func (c *mockClient) Disconnect(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disconnected = true
+	return nil
+}
+
+func (c *mockClient) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lost <- errors.New("connection reset")
+}
+
+func (c *mockClient) deliver(topic string, payload string) bool {
+	c.mu.Lock()
+	handler, ok := c.subscriptions[topic]
+	c.mu.Unlock()
+	if ok {
+		handler(flexmqtt.Message{Topic: topic, Payload: []byte(payload)})
+	}
+	return ok
+}
+
+func (c *mockClient) connectCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connects
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("subscriptions must be restored after a reconnect", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		received := make(chan string, 2)
+		errs := make(chan error, 1)
+		w := flexmqtt.New(client,
+			flexmqtt.WithSubscription("sensors/#", flexmqtt.AtLeastOnce, func(_ context.Context, msg flexmqtt.Message) {
+				received <- string(msg.Payload)
+			}),
+			flexmqtt.WithSubscription("panics", flexmqtt.AtMostOnce, func(context.Context, flexmqtt.Message) {
+				panic("boom")
+			}),
+			flexmqtt.WithReconnectBackoff(time.Millisecond, 10*time.Millisecond),
+			flexmqtt.WithErrorHandler(func(err error) { errs <- err }),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		waitFor(t, func() bool { return client.deliver("sensors/#", "before") })
+		client.drop()
+		waitFor(t, func() bool { return client.connectCount() == 2 })
+		waitFor(t, func() bool { return client.deliver("sensors/#", "after") })
+		client.deliver("panics", "must not crash")
+		if err := <-errs; !strings.Contains(err.Error(), "panicked: boom") {
+			t.Errorf("expected the panic to be reported but got: %v", err)
+		}
+
+		if got := <-received; got != "before" {
+			t.Errorf("expected %q but got %q", "before", got)
+		}
+		if got := <-received; got != "after" {
+			t.Errorf("expected %q but got %q", "after", got)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("halt must flush in-flight publishes and disconnect", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		client.publishDelay = 50 * time.Millisecond
+		w := flexmqtt.New(client)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { _ = w.Run(ctx) }()
+		waitFor(t, func() bool { return client.connectCount() == 1 })
+
+		published := make(chan error, 1)
+		go func() { published <- w.Publish(ctx, "events", flexmqtt.AtLeastOnce, false, []byte("hello")) }()
+		time.Sleep(10 * time.Millisecond)
+
+		cancel()
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-published; err != nil {
+			t.Error(err)
+		}
+		if client.published != 1 {
+			t.Error("expected the in-flight publish to complete before disconnecting")
+		}
+		if !client.disconnected {
+			t.Error("expected the client to be disconnected")
+		}
+		if err := w.Publish(ctx, "events", flexmqtt.AtLeastOnce, false, nil); !errors.Is(err, flexmqtt.ErrClosed) {
+			t.Errorf("expected %v but got: %v", flexmqtt.ErrClosed, err)
+		}
+	})
+	t.Run("halt must unsubscribe before disconnecting", func(t *testing.T) {
+		t.Parallel()
+
+		client := &unsubscribingClient{mockClient: newMockClient()}
+		w := flexmqtt.New(client,
+			flexmqtt.WithSubscription("a", flexmqtt.AtLeastOnce, func(context.Context, flexmqtt.Message) {}),
+			flexmqtt.WithSubscription("b", flexmqtt.AtMostOnce, func(context.Context, flexmqtt.Message) {}),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { _ = w.Run(ctx) }()
+		waitFor(t, func() bool { return client.connectCount() == 1 })
+
+		cancel()
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		if !slices.Equal(client.unsubscribed, []string{"a", "b"}) {
+			t.Errorf("expected both topics to be unsubscribed but got: %v", client.unsubscribed)
+		}
+		if client.disconnectedFirst {
+			t.Error("expected the client to unsubscribe before disconnecting")
+		}
+	})
+	t.Run("the last will must be set before connecting", func(t *testing.T) {
+		t.Parallel()
+
+		client := &unsubscribingClient{mockClient: newMockClient()}
+		w := flexmqtt.New(client, flexmqtt.WithWill("status", flexmqtt.AtLeastOnce, true, []byte("offline")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = w.Run(ctx) }()
+		waitFor(t, func() bool { return client.connectCount() == 1 })
+
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		if client.will.Topic != "status" || string(client.will.Payload) != "offline" || !client.will.Retained {
+			t.Errorf("unexpected will: %+v", client.will)
+		}
+	})
+	t.Run("a last will must require a client supporting it", func(t *testing.T) {
+		t.Parallel()
+
+		w := flexmqtt.New(newMockClient(), flexmqtt.WithWill("status", flexmqtt.AtLeastOnce, true, nil))
+		if err := w.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
+
+// unsubscribingClient is a mockClient which supports unsubscribing and a last
+// will.
+type unsubscribingClient struct {
+	*mockClient
+	unsubscribed      []string
+	disconnectedFirst bool
+	will              flexmqtt.Will
+}
+
+func (c *unsubscribingClient) Unsubscribe(_ context.Context, topics ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsubscribed = append(c.unsubscribed, topics...)
+	c.disconnectedFirst = c.disconnected
+	return nil
+}
+
+func (c *unsubscribingClient) SetWill(will flexmqtt.Will) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.will = will
+}
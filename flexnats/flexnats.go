@@ -0,0 +1,301 @@
+// Package flexnats provides a flex worker managing a NATS connection and its
+// subscriptions.
+//
+// The worker does not depend on nats.go, instead it drives any type
+// satisfying Conn, which *nats.Conn does. The connection is established
+// whenever the worker runs, with the callbacks of the worker registered:
+//
+//	connect := func(ctx context.Context, cb flexnats.Callbacks) (*nats.Conn, error) {
+//		return nats.Connect(url,
+//			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) { cb.Disconnected(err) }),
+//			nats.ReconnectHandler(func(*nats.Conn) { cb.Reconnected() }),
+//			nats.ClosedHandler(func(*nats.Conn) { cb.Closed() }),
+//		)
+//	}
+//	subscribe := func(nc *nats.Conn) error {
+//		_, err := nc.QueueSubscribe("orders.created", "billing", handleOrder)
+//		return err
+//	}
+//
+//	flex.MustStart(ctx, flexnats.New(connect, subscribe))
+//
+// The worker reports whether it is connected through the health of the
+// manager, see flex.Manager.Health.
+//
+// Once halted, the connection is drained: subscriptions stop receiving
+// messages, the messages already received are handled, and the connection is
+// closed.
+package flexnats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long the connection is given to drain during
+// Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+// ErrNotConnected is returned by Health while the connection is not
+// established.
+var ErrNotConnected = errors.New("flexnats: not connected")
+
+var logger = log.New(os.Stderr, "flexnats: ", 0)
+
+// Conn is the subset of *nats.Conn used by Worker.
+type Conn interface {
+	// Drain unsubscribes every subscription once its pending messages are
+	// handled, then closes the connection, asynchronously.
+	Drain() error
+	// Close closes the connection immediately.
+	Close()
+}
+
+// Callbacks are the connection callbacks of a Worker, which must be registered
+// with the connection when connecting.
+type Callbacks struct {
+	// Disconnected must be called when the connection is lost.
+	Disconnected func(err error)
+	// Reconnected must be called when the connection is re-established.
+	Reconnected func()
+	// Closed must be called once the connection is closed for good.
+	Closed func()
+}
+
+// Status is the status of the connection of a Worker.
+type Status int
+
+const (
+	// StatusConnecting is the status of a worker which is not connected yet.
+	StatusConnecting Status = iota
+	// StatusConnected is the status of an established connection.
+	StatusConnected
+	// StatusReconnecting is the status of a connection which was lost, and
+	// is being re-established.
+	StatusReconnecting
+	// StatusDraining is the status of a connection being drained.
+	StatusDraining
+	// StatusClosed is the status of a closed connection.
+	StatusClosed
+)
+
+// String returns a string representation of the Status.
+func (s Status) String() string {
+	switch s {
+	case StatusConnecting:
+		return "connecting"
+	case StatusConnected:
+		return "connected"
+	case StatusReconnecting:
+		return "reconnecting"
+	case StatusDraining:
+		return "draining"
+	case StatusClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	drainTimeout time.Duration
+	onStatus     func(Status)
+}
+
+// WithDrainTimeout sets how long the connection is given to drain once the
+// worker is halted, unless the context given to Halt expires first, after
+// which it is closed.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithStatusHandler sets a function called whenever the status of the
+// connection changes, such as when it is lost and re-established.
+func WithStatusHandler(fn func(Status)) Option {
+	return func(o *options) { o.onStatus = fn }
+}
+
+// Worker is a flex worker managing a NATS connection and its subscriptions.
+type Worker[C Conn] struct {
+	connect   func(context.Context, Callbacks) (C, error)
+	subscribe func(C) error
+	opts      options
+
+	mu      sync.Mutex
+	conn    *C
+	status  Status
+	closed  chan struct{}
+	halting bool
+}
+
+// New returns a Worker connecting with connect, then subscribing with
+// subscribe, which may be nil when the worker only publishes.
+func New[C Conn](connect func(context.Context, Callbacks) (C, error), subscribe func(C) error, opts ...Option) *Worker[C] {
+	w := &Worker[C]{
+		connect:   connect,
+		subscribe: subscribe,
+		opts:      options{drainTimeout: DefaultDrainTimeout},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run connects, subscribes, and returns once the context is done or the
+// worker is halted. The worker reports itself ready once subscribed.
+//
+// If the connection is closed otherwise, such as when the client gives up
+// reconnecting, Run returns an error marked with flex.Recoverable so that the
+// worker is restarted according to the manager's restart policy.
+func (w *Worker[C]) Run(ctx context.Context) error {
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+
+	w.mu.Lock()
+	w.closed, w.conn, w.halting = closed, nil, false
+	w.mu.Unlock()
+	w.setStatus(StatusConnecting)
+
+	conn, err := w.connect(ctx, Callbacks{
+		Disconnected: func(err error) {
+			if w.setStatus(StatusReconnecting) {
+				logger.Printf("disconnected: %v", err)
+			}
+		},
+		Reconnected: func() {
+			if w.setStatus(StatusConnected) {
+				logger.Print("reconnected")
+			}
+		},
+		Closed: func() {
+			w.setStatus(StatusClosed)
+			closeOnce.Do(func() { close(closed) })
+		},
+	})
+	if err != nil {
+		w.setStatus(StatusClosed)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return flex.Recoverable(fmt.Errorf("flexnats: connect: %w", err))
+	}
+
+	w.mu.Lock()
+	w.conn = &conn
+	w.mu.Unlock()
+	w.setStatus(StatusConnected)
+
+	if w.subscribe != nil {
+		if err := w.subscribe(conn); err != nil {
+			conn.Close()
+			return fmt.Errorf("flexnats: subscribe: %w", err)
+		}
+	}
+
+	flex.Ready(ctx)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-closed:
+	}
+
+	w.mu.Lock()
+	halting := w.halting
+	w.mu.Unlock()
+	if halting {
+		return nil
+	}
+	return flex.Recoverable(errors.New("flexnats: connection closed"))
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once subscribed,
+// see flex.ReadyReporter.
+func (w *Worker[C]) ReportsReady() bool { return true }
+
+// Halt drains the connection, giving the messages already received the drain
+// timeout to be handled, or until the deadline of ctx if it is earlier, after
+// which the connection is closed and an error reporting it is returned.
+func (w *Worker[C]) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	conn, closed := w.conn, w.closed
+	w.halting = true
+	w.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	w.setStatus(StatusDraining)
+	if err := (*conn).Drain(); err != nil {
+		(*conn).Close()
+		return fmt.Errorf("flexnats: drain: %w", err)
+	}
+
+	timeout := w.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-closed:
+		return nil
+	case <-timer.C:
+		(*conn).Close()
+		w.setStatus(StatusClosed)
+		return fmt.Errorf("flexnats: connection did not drain within %s", timeout)
+	}
+}
+
+// Status returns the status of the connection.
+func (w *Worker[C]) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Health returns ErrNotConnected, wrapped with the status of the connection,
+// unless the connection is established, so that the worker is not ready while
+// it is not, see flex.HealthReporter.
+func (w *Worker[C]) Health(context.Context) error {
+	if status := w.Status(); status != StatusConnected {
+		return fmt.Errorf("%w: %s", ErrNotConnected, status)
+	}
+	return nil
+}
+
+// setStatus sets the status of the connection and reports whether it
+// changed. A draining or closed connection is not reported as connected or
+// reconnecting anymore.
+func (w *Worker[C]) setStatus(status Status) bool {
+	w.mu.Lock()
+	from := w.status
+	switch {
+	case from == status:
+		w.mu.Unlock()
+		return false
+	case (from == StatusDraining || from == StatusClosed) && status != StatusClosed && status != StatusConnecting:
+		w.mu.Unlock()
+		return false
+	}
+	w.status = status
+	w.mu.Unlock()
+
+	if w.opts.onStatus != nil {
+		w.opts.onStatus(status)
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package flexnats_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexnats"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// mockConn mimics *nats.Conn: Drain closes the connection asynchronously
+// once the messages being handled are.
+type mockConn struct {
+	cb       flexnats.Callbacks
+	handling sync.WaitGroup
+
+	closeOnce sync.Once
+	drained   atomic.Bool
+	closed    atomic.Bool
+}
+
+func (c *mockConn) Drain() error {
+	c.drained.Store(true)
+	go func() {
+		c.handling.Wait()
+		c.Close()
+	}()
+	return nil
+}
+
+func (c *mockConn) Close() {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		c.cb.Closed()
+	})
+}
+
+// mockConnector connects mockConns, recording the last one.
+type mockConnector struct {
+	mu    sync.Mutex
+	conns []*mockConn
+}
+
+func (m *mockConnector) connect(_ context.Context, cb flexnats.Callbacks) (*mockConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conn := &mockConn{cb: cb}
+	m.conns = append(m.conns, conn)
+	return conn, nil
+}
+
+func (m *mockConnector) last() *mockConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conns[len(m.conns)-1]
+}
+
+// run runs w until it is connected, and returns the result of Run.
+func run[C flexnats.Conn](t *testing.T, w *flexnats.Worker[C]) <-chan error {
+	t.Helper()
+
+	errC := make(chan error, 1)
+	go func() { errC <- w.Run(context.Background()) }()
+
+	deadline := time.Now().Add(time.Second)
+	for w.Status() != flexnats.StatusConnected {
+		if time.Now().After(deadline) {
+			t.Fatal("worker did not connect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return errC
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("halting must drain the connection", func(t *testing.T) {
+		t.Parallel()
+
+		var subscribed atomic.Bool
+		connector := &mockConnector{}
+		w := flexnats.New(connector.connect, func(*mockConn) error {
+			subscribed.Store(true)
+			return nil
+		})
+
+		errC := run(t, w)
+		deadline := time.Now().Add(time.Second)
+		for !subscribed.Load() {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the worker to subscribe")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := w.Health(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if conn := connector.last(); !conn.drained.Load() || !conn.closed.Load() {
+			t.Error("expected the connection to be drained and closed")
+		}
+		if status := w.Status(); status != flexnats.StatusClosed {
+			t.Errorf("expected %v but got: %v", flexnats.StatusClosed, status)
+		}
+	})
+	t.Run("connections not draining in time must be closed", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{}
+		w := flexnats.New(connector.connect, nil, flexnats.WithDrainTimeout(10*time.Millisecond))
+
+		run(t, w)
+		conn := connector.last()
+		conn.handling.Add(1)
+		defer conn.handling.Done()
+
+		if err := w.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if !conn.closed.Load() {
+			t.Error("expected the connection to be closed")
+		}
+	})
+	t.Run("connections not draining by the deadline of the halt must be closed", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{}
+		w := flexnats.New(connector.connect, nil)
+
+		run(t, w)
+		conn := connector.last()
+		conn.handling.Add(1)
+		defer conn.handling.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := w.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if !conn.closed.Load() {
+			t.Error("expected the connection to be closed")
+		}
+	})
+	t.Run("reconnections must be reported through the status", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu       sync.Mutex
+			statuses []flexnats.Status
+		)
+		connector := &mockConnector{}
+		w := flexnats.New(connector.connect, nil, flexnats.WithStatusHandler(func(s flexnats.Status) {
+			mu.Lock()
+			defer mu.Unlock()
+			statuses = append(statuses, s)
+		}))
+		defer w.Halt(context.Background())
+
+		run(t, w)
+		conn := connector.last()
+
+		conn.cb.Disconnected(errors.New("connection reset"))
+		if err := w.Health(context.Background()); !errors.Is(err, flexnats.ErrNotConnected) {
+			t.Errorf("expected %v but got: %v", flexnats.ErrNotConnected, err)
+		}
+		conn.cb.Reconnected()
+		if err := w.Health(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		want := []flexnats.Status{flexnats.StatusConnected, flexnats.StatusReconnecting, flexnats.StatusConnected}
+		if len(statuses) != len(want) {
+			t.Fatalf("expected %v but got: %v", want, statuses)
+		}
+		for i := range want {
+			if statuses[i] != want[i] {
+				t.Errorf("expected %v but got: %v", want, statuses)
+			}
+		}
+	})
+	t.Run("disconnections must be reported to the health of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{}
+		m := flex.New(flex.WithSignals())
+		m.Add(flexnats.New(connector.connect, nil))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+		<-m.Started()
+
+		if health := m.Health(context.Background()); health.Status() != flex.StatusHealthy {
+			t.Errorf("expected the manager to be healthy but got: %+v", health)
+		}
+
+		connector.last().cb.Disconnected(errors.New("connection reset"))
+		health := m.Health(context.Background())
+		if health.Ready || !errors.Is(health.Workers[0].Err, flexnats.ErrNotConnected) {
+			t.Errorf("expected the disconnection to be reported but got: %+v", health)
+		}
+	})
+	t.Run("connections closed unexpectedly must be recoverable", func(t *testing.T) {
+		t.Parallel()
+
+		connector := &mockConnector{}
+		w := flexnats.New(connector.connect, nil)
+
+		errC := run(t, w)
+		connector.last().Close()
+
+		if err := <-errC; !flex.IsRecoverable(err) {
+			t.Errorf("expected a recoverable error but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// Package flexnet provides flex workers serving raw network protocols.
+//
+// A TCPServer hands every accepted connection to a handler:
+//
+//	srv := flexnet.NewTCPServer(":6379", func(ctx context.Context, conn net.Conn) {
+//		serveRESP(ctx, conn)
+//	}, flexnet.WithDrainTimeout(5*time.Second))
+//
+//	flex.MustStart(ctx, srv)
+//
+// Once halted, the server stops accepting connections and cancels the
+// context of the handlers, which should finish the exchange in progress and
+// return. Connections still open once the drain timeout expires are closed.
+//
+// A UDPServer dispatches every received packet to a pool of handlers:
+//
+//	srv := flexnet.NewUDPServer(":8125", func(ctx context.Context, pc net.PacketConn, packet []byte, addr net.Addr) error {
+//		return ingest(packet)
+//	}, flexnet.WithWorkers(8))
+//
+// Once halted, the server stops reading packets, gives the packets already
+// received the drain timeout to be handled, and closes its socket.
+//
+// A UnixServer serves connections on a unix socket, such as a local control
+// plane, like a TCPServer:
+//
+//	srv := flexnet.NewUnixServer("/run/myapp/admin.sock", serveAdmin, flexnet.WithSocketMode(0o660))
+//
+// A socket left behind by a previous process is removed when the server
+// starts, and the socket is removed once the server is halted.
+package flexnet
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"runtime"
+	"time"
+)
+
+// DefaultDrainTimeout is how long open connections are given to be closed by
+// their handlers during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+var logger = log.New(os.Stderr, "flexnet: ", 0)
+
+// Option configures a server.
+type Option func(*options)
+
+type options struct {
+	drainTimeout time.Duration
+	workers      int
+	onError      func(error)
+	socketMode   fs.FileMode
+}
+
+// WithDrainTimeout sets how long open connections are given to be closed by
+// their handlers once the server is halted, after which they are closed.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithWorkers sets how many packets are handled concurrently, it defaults to
+// the number of CPUs. It only applies to UDP servers.
+func WithWorkers(n int) Option {
+	return func(o *options) { o.workers = n }
+}
+
+// WithErrorHandler sets the function called with the errors of handlers,
+// which are logged by default. It only applies to UDP servers.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// WithSocketMode sets the file mode of the socket, which defaults to
+// DefaultSocketMode. It only applies to unix servers.
+func WithSocketMode(mode fs.FileMode) Option {
+	return func(o *options) { o.socketMode = mode }
+}
+
+// newOptions returns the options set by opts.
+func newOptions(opts []Option) options {
+	o := options{
+		drainTimeout: DefaultDrainTimeout,
+		workers:      runtime.NumCPU(),
+		onError:      func(err error) { logger.Print(err) },
+		socketMode:   DefaultSocketMode,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
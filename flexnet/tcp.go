@@ -0,0 +1,211 @@
+package flexnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// ConnHandler handles a connection, which is closed once it returns.
+// The context is cancelled once the server is halted.
+type ConnHandler func(ctx context.Context, conn net.Conn)
+
+// TCPServer is a flex worker accepting TCP connections and handing them to
+// a handler.
+type TCPServer struct {
+	addr string
+	streamServer
+}
+
+// NewTCPServer returns a TCPServer listening on addr.
+func NewTCPServer(addr string, handler ConnHandler, opts ...Option) *TCPServer {
+	return &TCPServer{addr: addr, streamServer: newStreamServer(handler, opts)}
+}
+
+// Run listens on the server's address and accepts connections until the
+// server is halted. The worker reports itself ready once it is listening.
+func (s *TCPServer) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("flexnet: listen: %w", err)
+	}
+	return s.serve(ctx, lis)
+}
+
+// streamServer accepts connections from a listener and hands them to a
+// handler.
+type streamServer struct {
+	handler ConnHandler
+	opts    options
+
+	mu       sync.Mutex
+	lis      net.Listener
+	cancel   context.CancelFunc
+	conns    map[net.Conn]struct{}
+	handlers sync.WaitGroup
+	halted   bool
+}
+
+// newStreamServer returns a streamServer handing connections to handler.
+func newStreamServer(handler ConnHandler, opts []Option) streamServer {
+	return streamServer{
+		handler: handler,
+		opts:    newOptions(opts),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *streamServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// OpenConns returns the number of open connections.
+func (s *streamServer) OpenConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// serve accepts connections from lis until the server is halted, and reports
+// the worker ready right away.
+func (s *streamServer) serve(ctx context.Context, lis net.Listener) error {
+	handlerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	s.mu.Lock()
+	if s.halted {
+		s.mu.Unlock()
+		return lis.Close()
+	}
+	s.lis, s.cancel = lis, cancel
+	s.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if s.closing() {
+				return nil
+			}
+			return fmt.Errorf("flexnet: accept: %w", err)
+		}
+
+		if !s.track(conn) {
+			_ = conn.Close()
+			return nil
+		}
+		go func() {
+			defer s.handlers.Done()
+			defer s.untrack(conn)
+			s.handler(handlerCtx, conn)
+		}()
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is
+// listening, see flex.ReadyReporter.
+func (s *streamServer) ReportsReady() bool { return true }
+
+// Halt stops accepting connections and cancels the context of the handlers,
+// then waits for them to return for at most the drain timeout, or until the
+// deadline of ctx if it is earlier. Once it expires the remaining connections
+// are closed, and an error reporting them is returned. Handlers which do not
+// return once their connection is closed are abandoned when ctx is done.
+func (s *streamServer) Halt(ctx context.Context) error {
+	// A context already done when halting, as given by a manager without a
+	// halt timeout, does not bound the wait for the handlers.
+	abandon := ctx.Done()
+	if ctx.Err() != nil {
+		abandon = nil
+	}
+
+	s.mu.Lock()
+	s.halted = true
+	lis, cancel := s.lis, s.cancel
+	s.mu.Unlock()
+
+	if lis == nil {
+		return nil
+	}
+
+	err := lis.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	cancel()
+
+	drained := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(drained)
+	}()
+
+	timeout := s.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return err
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	n := len(s.conns)
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	logger.Printf("drain did not complete within %s, closed %d connections", timeout, n)
+	select {
+	case <-drained:
+		return errors.Join(err, fmt.Errorf("flexnet: drain did not complete within %s, closed %d connections", timeout, n))
+	case <-abandon:
+		return errors.Join(err, fmt.Errorf("flexnet: drain did not complete within %s, closed %d connections and abandoned their handlers: %w", timeout, n, ctx.Err()))
+	}
+}
+
+// closing reports whether the server is being halted.
+func (s *streamServer) closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.halted
+}
+
+// track records conn as open, unless the server is being halted.
+func (s *streamServer) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.halted {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.handlers.Add(1)
+	return true
+}
+
+// untrack closes conn and records it as closed.
+func (s *streamServer) untrack(conn net.Conn) {
+	_ = conn.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
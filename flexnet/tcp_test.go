@@ -0,0 +1,178 @@
+package flexnet_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexnet"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// echo echoes lines until the connection is closed or the context is done.
+func echo(ctx context.Context, conn net.Conn) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			io.WriteString(conn, line+"\n")
+		}
+	}
+}
+
+// dial connects to addr and checks that the server echoes.
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	io.WriteString(conn, "ping\n")
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected an echo but got: %q, %v", line, err)
+	}
+	return conn
+}
+
+func TestTCPServer(t *testing.T) {
+	t.Run("connections must be handed to the handler and closed when halted", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexnet.NewTCPServer(addr, echo)
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		conn := dial(t, addr)
+		if n := srv.OpenConns(); n != 1 {
+			t.Errorf("expected %d open connection but got: %d", 1, n)
+		}
+
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("expected the connection to be closed but got: %v", err)
+		}
+		if n := srv.OpenConns(); n != 0 {
+			t.Errorf("expected no open connection but got: %d", n)
+		}
+	})
+	t.Run("connections outliving the drain timeout must be force closed", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexnet.NewTCPServer(addr, func(_ context.Context, conn net.Conn) {
+			echo(context.Background(), conn)
+		}, flexnet.WithDrainTimeout(20*time.Millisecond))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		conn := dial(t, addr)
+
+		start := time.Now()
+		if err := h.Stop(); err == nil {
+			t.Error("expected the forced close to be reported")
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("expected the drain timeout to be waited for, but halted in %s", elapsed)
+		}
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Error("expected the connection to be closed")
+		}
+	})
+	t.Run("connections must be force closed once the halt deadline expires", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexnet.NewTCPServer(addr, func(_ context.Context, conn net.Conn) {
+			echo(context.Background(), conn)
+		})
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(20*time.Millisecond))
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		conn := dial(t, addr)
+		h.Stop()
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("expected the connection to be closed but got: %v", err)
+		}
+	})
+	t.Run("handlers ignoring their closed connection must be abandoned at the halt deadline", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		addr := flextest.Addr(t)
+		srv := flexnet.NewTCPServer(addr, func(context.Context, net.Conn) { <-release })
+		go srv.Run(context.Background())
+		flextest.WaitListening(t, addr)
+
+		// The connection of WaitListening is handed to the handler as well.
+		for srv.OpenConns() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		errC := make(chan error, 1)
+		go func() { errC <- srv.Halt(ctx) }()
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected %v but got: %v", context.DeadlineExceeded, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected Halt to give up at its deadline")
+		}
+	})
+	t.Run("listener errors must be returned by Run", func(t *testing.T) {
+		t.Parallel()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		srv := flexnet.NewTCPServer(lis.Addr().String(), echo)
+		if err := srv.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
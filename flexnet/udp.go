@@ -0,0 +1,241 @@
+package flexnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// maxPacketSize is the size of the largest UDP datagram.
+const maxPacketSize = 65535
+
+// PacketHandler handles a packet received from addr, replying, if need be,
+// through pc. The packet must not be retained once it returns. The context is
+// cancelled once the drain timeout, or the deadline of the halt, expires
+// during Halt.
+//
+// Errors are passed to the error handler, see WithErrorHandler, except for
+// errors marked with flex.Fatal which stop the server and are returned by Run.
+type PacketHandler func(ctx context.Context, pc net.PacketConn, packet []byte, addr net.Addr) error
+
+// UDPServer is a flex worker reading packets from a UDP socket and
+// dispatching them to a pool of handlers.
+type UDPServer struct {
+	addr    string
+	handler PacketHandler
+	opts    options
+
+	buffers sync.Pool
+
+	mu      sync.Mutex
+	pc      net.PacketConn
+	abort   context.CancelFunc
+	stopped chan struct{}
+	halted  bool
+}
+
+// packet is a received packet queued for a handler.
+type packet struct {
+	buf  *[]byte
+	n    int
+	addr net.Addr
+}
+
+// NewUDPServer returns a UDPServer listening on addr.
+func NewUDPServer(addr string, handler PacketHandler, opts ...Option) *UDPServer {
+	return &UDPServer{
+		addr:    addr,
+		handler: handler,
+		opts:    newOptions(opts),
+		buffers: sync.Pool{New: func() any {
+			buf := make([]byte, maxPacketSize)
+			return &buf
+		}},
+	}
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *UDPServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pc == nil {
+		return nil
+	}
+	return s.pc.LocalAddr()
+}
+
+// Run listens on the server's address and dispatches packets to the handlers
+// until the server is halted, then waits for the packets already received to
+// be handled before closing the socket. The worker reports itself ready once
+// it is listening.
+func (s *UDPServer) Run(ctx context.Context) error {
+	pc, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("flexnet: listen: %w", err)
+	}
+	defer pc.Close()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	handlerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	s.mu.Lock()
+	if s.halted {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pc, s.abort, s.stopped = pc, cancel, stopped
+	s.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	var (
+		handlers sync.WaitGroup
+		packets  = make(chan packet, s.opts.workers)
+		fatalC   = make(chan error, 1)
+	)
+	for range s.opts.workers {
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			for p := range packets {
+				if err := s.handle(handlerCtx, pc, p); err != nil {
+					select {
+					case fatalC <- err:
+					default:
+					}
+					// Unblock the read loop, which stops once it sees the
+					// fatal error.
+					_ = pc.SetReadDeadline(time.Now())
+				}
+			}
+		}()
+	}
+
+	err = s.read(pc, packets, fatalC)
+	close(packets)
+
+	drained := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(drained)
+	}()
+
+	timer := time.NewTimer(s.opts.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+	case <-timer.C:
+		cancel()
+		<-drained
+		err = errors.Join(err, fmt.Errorf("flexnet: packets were not handled within %s", s.opts.drainTimeout))
+	}
+
+	select {
+	case fatal := <-fatalC:
+		err = errors.Join(fatal, err)
+	default:
+	}
+	return err
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it is
+// listening, see flex.ReadyReporter.
+func (s *UDPServer) ReportsReady() bool { return true }
+
+// read reads packets and queues them for the handlers until the server is
+// halted, a handler fails fatally or reading fails.
+func (s *UDPServer) read(pc net.PacketConn, packets chan<- packet, fatalC <-chan error) error {
+	for {
+		buf := s.buffers.Get().(*[]byte)
+		n, addr, err := pc.ReadFrom(*buf)
+		if err != nil {
+			s.buffers.Put(buf)
+			if s.closing() || len(fatalC) > 0 {
+				return nil
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			return fmt.Errorf("flexnet: read: %w", err)
+		}
+		packets <- packet{buf: buf, n: n, addr: addr}
+	}
+}
+
+// handle dispatches p to the handler, and returns its error if it is fatal.
+// Other errors, and panics, are passed to the error handler.
+func (s *UDPServer) handle(ctx context.Context, pc net.PacketConn, p packet) (fatal error) {
+	defer s.buffers.Put(p.buf)
+	defer func() {
+		if r := recover(); r != nil {
+			s.opts.onError(fmt.Errorf("flexnet: handler panicked: %v", r))
+		}
+	}()
+
+	if err := s.handler(ctx, pc, (*p.buf)[:p.n], p.addr); err != nil {
+		if flex.IsFatal(err) {
+			return err
+		}
+		s.opts.onError(fmt.Errorf("flexnet: handle packet from %s: %w", p.addr, err))
+	}
+	return nil
+}
+
+// Halt stops reading packets, and waits for Run to return once the packets
+// already received are handled, for at most the drain timeout, or until the
+// deadline of ctx if it is earlier, after which the context of the handlers
+// is cancelled.
+func (s *UDPServer) Halt(ctx context.Context) error {
+	s.mu.Lock()
+	s.halted = true
+	pc, abort, stopped := s.pc, s.abort, s.stopped
+	s.mu.Unlock()
+
+	if pc == nil {
+		return nil
+	}
+
+	// Unblock the read loop without closing the socket, so that handlers can
+	// still reply.
+	if err := pc.SetReadDeadline(time.Now()); err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("flexnet: stop reading: %w", err)
+	}
+
+	// Run cancels the handlers once the drain timeout expires, the deadline
+	// of ctx only needs enforcing here when it is earlier.
+	deadline, ok := ctx.Deadline()
+	if !ok || ctx.Err() != nil || time.Until(deadline) >= s.opts.drainTimeout {
+		<-stopped
+		return nil
+	}
+	timeout := time.Until(deadline)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-timer.C:
+		abort()
+		<-stopped
+		return fmt.Errorf("flexnet: packets were not handled within %s", timeout)
+	}
+}
+
+// closing reports whether the server is being halted.
+func (s *UDPServer) closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.halted
+}
@@ -0,0 +1,194 @@
+package flexnet_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexnet"
+)
+
+// startUDP runs srv and returns its address once it is listening, along with
+// the error returned by Run.
+func startUDP(t *testing.T, srv *flexnet.UDPServer) (net.Addr, <-chan error) {
+	t.Helper()
+
+	errC := make(chan error, 1)
+	go func() { errC <- srv.Run(context.Background()) }()
+
+	deadline := time.Now().Add(time.Second)
+	for srv.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the server to listen but it did not")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return srv.Addr(), errC
+}
+
+// send sends packet to addr and returns the connection it was sent from.
+func send(t *testing.T, addr net.Addr, packet string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, err := conn.Write([]byte(packet)); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestUDPServer(t *testing.T) {
+	t.Run("packets must be handed to the handler which can reply", func(t *testing.T) {
+		t.Parallel()
+
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(_ context.Context, pc net.PacketConn, packet []byte, addr net.Addr) error {
+			_, err := pc.WriteTo(packet, addr)
+			return err
+		}, flexnet.WithWorkers(2))
+		addr, errC := startUDP(t, srv)
+
+		conn := send(t, addr, "ping")
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 16)
+		n, err := conn.Read(buf)
+		if err != nil || string(buf[:n]) != "ping" {
+			t.Errorf("expected an echo but got: %q, %v", buf[:n], err)
+		}
+
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("received packets must be handled when halted", func(t *testing.T) {
+		t.Parallel()
+
+		handling := make(chan struct{})
+		handled := make(chan struct{})
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(context.Context, net.PacketConn, []byte, net.Addr) error {
+			close(handling)
+			time.Sleep(20 * time.Millisecond)
+			close(handled)
+			return nil
+		})
+		addr, errC := startUDP(t, srv)
+
+		send(t, addr, "ping")
+		<-handling
+
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		select {
+		case <-handled:
+		default:
+			t.Error("expected the packet to be handled once halted")
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("handlers outliving the drain timeout must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		handling := make(chan struct{})
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(ctx context.Context, _ net.PacketConn, _ []byte, _ net.Addr) error {
+			close(handling)
+			<-ctx.Done()
+			return nil
+		}, flexnet.WithDrainTimeout(10*time.Millisecond))
+		addr, errC := startUDP(t, srv)
+
+		send(t, addr, "ping")
+		<-handling
+
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+	t.Run("handlers outliving the deadline of the halt must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		handling := make(chan struct{})
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(ctx context.Context, _ net.PacketConn, _ []byte, _ net.Addr) error {
+			close(handling)
+			<-ctx.Done()
+			return nil
+		})
+		addr, errC := startUDP(t, srv)
+
+		send(t, addr, "ping")
+		<-handling
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := srv.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("handler errors must be passed to the error handler", func(t *testing.T) {
+		t.Parallel()
+
+		errs := make(chan error, 2)
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(_ context.Context, _ net.PacketConn, packet []byte, _ net.Addr) error {
+			if string(packet) == "panic" {
+				panic("boom")
+			}
+			return errors.New("bad packet")
+		}, flexnet.WithErrorHandler(func(err error) { errs <- err }))
+		addr, errC := startUDP(t, srv)
+		defer srv.Halt(context.Background())
+
+		send(t, addr, "ping")
+		send(t, addr, "panic")
+		for range 2 {
+			select {
+			case <-errs:
+			case <-time.After(time.Second):
+				t.Fatal("expected an error but did not get one")
+			}
+		}
+
+		select {
+		case err := <-errC:
+			t.Errorf("expected the server to keep running but got: %v", err)
+		default:
+		}
+	})
+	t.Run("fatal handler errors must be returned by run", func(t *testing.T) {
+		t.Parallel()
+
+		errBad := errors.New("bad packet")
+		srv := flexnet.NewUDPServer("127.0.0.1:0", func(context.Context, net.PacketConn, []byte, net.Addr) error {
+			return flex.Fatal(errBad)
+		})
+		addr, errC := startUDP(t, srv)
+
+		send(t, addr, "ping")
+		select {
+		case err := <-errC:
+			if !errors.Is(err, errBad) || !flex.IsFatal(err) {
+				t.Errorf("expected a fatal %v but got: %v", errBad, err)
+			}
+		case <-time.After(time.Second):
+			t.Error("expected run to return but it did not")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package flexnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// DefaultSocketMode is the file mode of unix sockets when none is configured,
+// restricting them to the user running the service.
+const DefaultSocketMode fs.FileMode = 0o600
+
+// UnixServer is a flex worker accepting connections on a unix socket and
+// handing them to a handler.
+type UnixServer struct {
+	path string
+	streamServer
+}
+
+// NewUnixServer returns a UnixServer listening on the socket at path.
+func NewUnixServer(path string, handler ConnHandler, opts ...Option) *UnixServer {
+	return &UnixServer{path: path, streamServer: newStreamServer(handler, opts)}
+}
+
+// Run removes the socket left behind by a previous process which did not exit
+// cleanly, if any, then listens on the socket and accepts connections until
+// the server is halted. The worker reports itself ready once it is listening.
+func (s *UnixServer) Run(ctx context.Context) error {
+	if err := removeStaleSocket(s.path); err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("flexnet: listen: %w", err)
+	}
+	if err := os.Chmod(s.path, s.opts.socketMode); err != nil {
+		_ = lis.Close()
+		return fmt.Errorf("flexnet: chmod socket: %w", err)
+	}
+	return s.serve(ctx, lis)
+}
+
+// Halt stops accepting connections and drains them like TCPServer.Halt,
+// within the drain timeout or until the deadline of ctx, and removes the
+// socket.
+func (s *UnixServer) Halt(ctx context.Context) error {
+	listening := s.Addr() != nil
+	err := s.streamServer.Halt(ctx)
+	if !listening {
+		return err
+	}
+
+	// Closing the listener removes the socket, make sure it is gone should
+	// that have failed.
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+		err = errors.Join(err, fmt.Errorf("flexnet: remove socket: %w", rmErr))
+	}
+	return err
+}
+
+// removeStaleSocket removes the socket at path, unless another process is
+// listening on it. Files other than sockets are left alone, so that a
+// misconfigured path does not delete them.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("flexnet: stat socket: %w", err)
+	case info.Mode()&fs.ModeSocket == 0:
+		return fmt.Errorf("flexnet: %s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("flexnet: %s is in use by another process", path)
+	}
+
+	logger.Printf("removing stale socket %s", path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("flexnet: remove stale socket: %w", err)
+	}
+	return nil
+}
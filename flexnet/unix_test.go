@@ -0,0 +1,180 @@
+//go:build !plan9
+
+package flexnet_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexnet"
+)
+
+// socketPath returns the path of a socket in a temporary directory, short
+// enough for the length limit of socket paths.
+func socketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "flexnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "s.sock")
+}
+
+// startUnix runs srv and waits for it to listen, returning the error returned
+// by Run.
+func startUnix(t *testing.T, srv *flexnet.UnixServer) <-chan error {
+	t.Helper()
+
+	errC := make(chan error, 1)
+	go func() { errC <- srv.Run(context.Background()) }()
+
+	for deadline := time.Now().Add(time.Second); srv.Addr() == nil; time.Sleep(time.Millisecond) {
+		select {
+		case err := <-errC:
+			t.Fatalf("expected the server to listen but got: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the server to listen but it did not")
+		}
+	}
+	return errC
+}
+
+func TestUnixServer(t *testing.T) {
+	t.Run("connections must be handed to the handler and the socket removed when halted", func(t *testing.T) {
+		t.Parallel()
+
+		path := socketPath(t)
+		srv := flexnet.NewUnixServer(path, echo, flexnet.WithSocketMode(0o660))
+		errC := startUnix(t, srv)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0o660 {
+			t.Errorf("expected mode %v but got: %v", fs.FileMode(0o660), mode)
+		}
+
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "ping\n")
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "ping\n" {
+			t.Errorf("expected an echo but got: %q, %v", line, err)
+		}
+
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected the socket to be removed but got: %v", err)
+		}
+	})
+	t.Run("the drain must be bounded by the deadline of the halt", func(t *testing.T) {
+		t.Parallel()
+
+		path := socketPath(t)
+		srv := flexnet.NewUnixServer(path, func(_ context.Context, conn net.Conn) {
+			echo(context.Background(), conn)
+		})
+		errC := startUnix(t, srv)
+
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "ping\n")
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "ping\n" {
+			t.Fatalf("expected an echo but got: %q, %v", line, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := srv.Halt(ctx); err == nil {
+			t.Error("expected the forced close to be reported")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the drain to stop at the deadline, but halted in %s", elapsed)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected the socket to be removed but got: %v", err)
+		}
+	})
+	t.Run("a stale socket must be removed on start", func(t *testing.T) {
+		t.Parallel()
+
+		path := socketPath(t)
+		lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lis.SetUnlinkOnClose(false)
+		lis.Close()
+
+		srv := flexnet.NewUnixServer(path, echo)
+		startUnix(t, srv)
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a socket in use must not be removed", func(t *testing.T) {
+		t.Parallel()
+
+		path := socketPath(t)
+		lis, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		srv := flexnet.NewUnixServer(path, echo)
+		if err := srv.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected the socket to be kept but got: %v", err)
+		}
+	})
+	t.Run("files other than sockets must not be removed", func(t *testing.T) {
+		t.Parallel()
+
+		path := socketPath(t)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		srv := flexnet.NewUnixServer(path, echo)
+		if err := srv.Run(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected the file to be kept but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// Package flexotel provides a flex worker owning the lifecycle of
+// OpenTelemetry providers, so that telemetry is flushed before the process
+// exits.
+//
+// The worker does not depend on the OpenTelemetry SDK, instead it drives any
+// type satisfying Provider, which the MeterProvider, TracerProvider and
+// LoggerProvider of the SDK do:
+//
+//	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+//	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter))
+//
+//	m := flex.New()
+//	m.Add(api)
+//	m.Add(flexotel.New(flexotel.Join(mp, tp)), flex.WithPriority(100))
+//	m.MustStart(ctx)
+//
+// Once halted, the worker flushes the providers and shuts them down. Giving it
+// a higher priority than the other workers halts it last, so that the
+// telemetry they record while halting is exported as well.
+package flexotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultShutdownTimeout is how long the providers are given to flush and
+// shut down during Halt when no shutdown timeout is configured.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// Provider is the subset of the providers of the OpenTelemetry SDK used by
+// Worker.
+type Provider interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// Join returns a Provider flushing and shutting down every one of providers,
+// one after the other.
+func Join(providers ...Provider) Provider {
+	return providerList(providers)
+}
+
+// providerList is a Provider made of several ones, see Join.
+type providerList []Provider
+
+func (l providerList) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range l {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (l providerList) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, p := range l {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	flushInterval   time.Duration
+	shutdownTimeout time.Duration
+	onError         func(error)
+}
+
+// WithFlushInterval makes the worker flush the providers every d while it
+// runs, in addition to the exports of the providers themselves.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) { o.flushInterval = d }
+}
+
+// WithShutdownTimeout sets how long the providers are given to flush and shut
+// down once the worker is halted, unless the context given to Halt expires
+// first.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) { o.shutdownTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the errors of periodic
+// flushes, which are logged to the logger of the manager by default, see
+// flex.LoggerFrom.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker owning the lifecycle of a Provider.
+type Worker struct {
+	provider Provider
+	opts     options
+
+	haltOnce sync.Once
+	halted   chan struct{}
+	haltErr  error
+}
+
+// New returns a Worker flushing and shutting down provider.
+func New(provider Provider, opts ...Option) *Worker {
+	w := &Worker{
+		provider: provider,
+		opts: options{
+			shutdownTimeout: DefaultShutdownTimeout,
+		},
+		halted: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run flushes the provider every flush interval, if any, until the context
+// is done or Halt is called. The worker reports itself ready right away.
+func (w *Worker) Run(ctx context.Context) error {
+	onError := w.opts.onError
+	if onError == nil {
+		logger := flex.LoggerFrom(ctx)
+		onError = func(err error) { logger.Log(ctx, slog.LevelError, "telemetry flush failed", "error", err) }
+	}
+
+	flex.Ready(ctx)
+
+	var tick <-chan time.Time
+	if w.opts.flushInterval > 0 {
+		ticker := time.NewTicker(w.opts.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.halted:
+			return nil
+		case <-tick:
+			if err := w.provider.ForceFlush(ctx); err != nil {
+				onError(fmt.Errorf("flexotel: flush: %w", err))
+			}
+		}
+	}
+}
+
+// Halt flushes the provider and shuts it down, within the shutdown timeout,
+// or until the deadline of ctx if it is earlier. The provider is only
+// shut down once, further calls return the error of the first one.
+func (w *Worker) Halt(ctx context.Context) error {
+	w.haltOnce.Do(func() {
+		close(w.halted)
+		w.haltErr = w.shutdown(ctx)
+	})
+	return w.haltErr
+}
+
+// shutdown flushes the provider and shuts it down.
+func (w *Worker) shutdown(ctx context.Context) error {
+	timeout := w.opts.shutdownTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := w.provider.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flexotel: flush: %w", err))
+	}
+	if err := w.provider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flexotel: shutdown: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package flexotel_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexotel"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// errorLogger sends the errors of the entries it is given.
+type errorLogger chan error
+
+func (l errorLogger) Log(_ context.Context, _ slog.Level, _ string, args ...any) {
+	for _, attr := range flex.Attrs(args...) {
+		if err, ok := attr.Value.Any().(error); ok && attr.Key == "error" {
+			select {
+			case l <- err:
+			default:
+			}
+		}
+	}
+}
+
+// mockProvider records the calls it receives.
+type mockProvider struct {
+	flushErr error
+
+	mu       sync.Mutex
+	calls    []string
+	deadline bool
+}
+
+func (p *mockProvider) ForceFlush(ctx context.Context) error {
+	p.record("flush", ctx)
+	return p.flushErr
+}
+
+func (p *mockProvider) Shutdown(ctx context.Context) error {
+	p.record("shutdown", ctx)
+	return nil
+}
+
+func (p *mockProvider) record(call string, ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+	_, p.deadline = ctx.Deadline()
+}
+
+func (p *mockProvider) recorded() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("providers must be flushed and shut down when halted", func(t *testing.T) {
+		t.Parallel()
+
+		mp, tp := &mockProvider{}, &mockProvider{}
+		w := flexotel.New(flexotel.Join(mp, tp))
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := w.Halt(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		for _, p := range []*mockProvider{mp, tp} {
+			if calls := p.recorded(); len(calls) != 2 || calls[0] != "flush" || calls[1] != "shutdown" {
+				t.Errorf("expected a flush then a shutdown but got: %v", calls)
+			}
+			if !p.deadline {
+				t.Error("expected the shutdown to be bounded")
+			}
+		}
+	})
+	t.Run("providers must be flushed periodically", func(t *testing.T) {
+		t.Parallel()
+
+		flushErr := errors.New("collector unreachable")
+		p := &mockProvider{flushErr: flushErr}
+		errs := make(chan error, 10)
+		w := flexotel.New(p, flexotel.WithFlushInterval(5*time.Millisecond),
+			flexotel.WithErrorHandler(func(err error) { errs <- err }))
+		go w.Run(context.Background())
+
+		if err := <-errs; !errors.Is(err, flushErr) {
+			t.Errorf("expected %v but got: %v", flushErr, err)
+		}
+		if err := w.Halt(context.Background()); !errors.Is(err, flushErr) {
+			t.Errorf("expected %v but got: %v", flushErr, err)
+		}
+	})
+	t.Run("flush errors must be logged to the logger of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		flushErr := errors.New("collector unreachable")
+		logger := make(errorLogger, 10)
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger))
+		m.Add(flexotel.New(&mockProvider{flushErr: flushErr}, flexotel.WithFlushInterval(5*time.Millisecond)))
+		h := flextest.Start(t, m)
+		defer h.Stop()
+
+		select {
+		case err := <-logger:
+			if !errors.Is(err, flushErr) {
+				t.Errorf("expected %v but got: %v", flushErr, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the flush error to be logged")
+		}
+	})
+}
@@ -0,0 +1,232 @@
+// Package flexoutbox provides a flex worker relaying the entries of a
+// transactional outbox.
+//
+// Services following the outbox pattern write the messages they publish to
+// a table, in the same transaction as the changes they describe. The worker
+// polls that table through a Store, and dispatches the pending entries in
+// batches:
+//
+//	type store struct{ db *sql.DB }
+//
+//	func (s store) Fetch(ctx context.Context, limit int) ([]event, error) {
+//		// SELECT id, topic, payload FROM outbox WHERE dispatched_at IS NULL ORDER BY id LIMIT $1
+//	}
+//
+//	func (s store) Checkpoint(ctx context.Context, batch []event) error {
+//		// UPDATE outbox SET dispatched_at = now() WHERE id = ANY($1)
+//	}
+//
+//	flex.MustStart(ctx, flexoutbox.New(store{db}, func(ctx context.Context, batch []event) error {
+//		return producer.Publish(ctx, batch...)
+//	}))
+//
+// Entries are only checkpointed once their batch was dispatched, so that they
+// are dispatched at least once: a batch whose dispatch failed, or which was
+// interrupted, is dispatched again.
+package flexoutbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultInterval is how often the outbox is polled when no interval is
+	// configured.
+	DefaultInterval = time.Second
+	// DefaultBatchSize is the most entries dispatched at once when no batch
+	// size is configured.
+	DefaultBatchSize = 100
+	// DefaultDrainTimeout is how long the batch being dispatched is given to
+	// complete during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+var logger = log.New(os.Stderr, "flexoutbox: ", 0)
+
+// Store is the outbox holding entries of type E.
+type Store[E any] interface {
+	// Fetch returns up to limit pending entries, oldest first.
+	Fetch(ctx context.Context, limit int) ([]E, error)
+	// Checkpoint marks the entries of a dispatched batch, so that they are
+	// not fetched again.
+	Checkpoint(ctx context.Context, batch []E) error
+}
+
+// Dispatch dispatches a batch of entries, such as by publishing them to a
+// message broker. When it returns an error the batch is dispatched again at
+// the next poll, unless it is marked with flex.Fatal, in which case the
+// worker stops and returns it.
+type Dispatch[E any] func(ctx context.Context, batch []E) error
+
+// Option configures a Poller.
+type Option func(*options)
+
+type options struct {
+	interval     time.Duration
+	batchSize    int
+	drainTimeout time.Duration
+	onError      func(error)
+}
+
+// WithInterval sets how often the outbox is polled. A full batch is followed
+// by another poll right away, so that a backlog is caught up with.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) { o.interval = d }
+}
+
+// WithBatchSize sets the most entries dispatched at once.
+func WithBatchSize(n int) Option {
+	return func(o *options) { o.batchSize = n }
+}
+
+// WithDrainTimeout sets how long the batch being dispatched is given to
+// complete once the poller is halted, after which the context of the
+// dispatch is cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the errors of the dispatch,
+// which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Poller is a flex worker which polls an outbox and dispatches its entries.
+type Poller[E any] struct {
+	store    Store[E]
+	dispatch Dispatch[E]
+	opts     options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Poller dispatching the entries of store.
+func New[E any](store Store[E], dispatch Dispatch[E], opts ...Option) *Poller[E] {
+	p := &Poller[E]{
+		store:    store,
+		dispatch: dispatch,
+		opts: options{
+			interval:     DefaultInterval,
+			batchSize:    DefaultBatchSize,
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+	}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	return p
+}
+
+// Run polls the outbox until the context is done or Halt is called. The
+// worker reports itself ready right away.
+//
+// The batch being dispatched is given a context which is not cancelled when
+// polling stops, so that it can complete during Halt. Errors of the store
+// are returned marked with flex.Recoverable, so that the worker is restarted
+// according to the manager's restart policy.
+func (p *Poller[E]) Run(ctx context.Context) error {
+	pollCtx, stop := context.WithCancel(ctx)
+	batchCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	p.mu.Lock()
+	p.stop, p.abort, p.done = stop, abort, done
+	p.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-pollCtx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		full, err := p.poll(pollCtx, batchCtx)
+		if pollCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if full {
+			timer.Reset(0)
+		} else {
+			timer.Reset(p.opts.interval)
+		}
+	}
+}
+
+// poll fetches a batch, dispatches it and checkpoints it, and reports whether
+// the batch was full.
+func (p *Poller[E]) poll(pollCtx, batchCtx context.Context) (bool, error) {
+	batch, err := p.store.Fetch(pollCtx, p.opts.batchSize)
+	if err != nil {
+		return false, flex.Recoverable(fmt.Errorf("flexoutbox: fetch: %w", err))
+	}
+	if len(batch) == 0 {
+		return false, nil
+	}
+
+	if err := p.dispatch(batchCtx, batch); err != nil {
+		if flex.IsFatal(err) {
+			return false, err
+		}
+		p.opts.onError(fmt.Errorf("flexoutbox: dispatch %d entries: %w", len(batch), err))
+		return false, nil
+	}
+
+	if err := p.store.Checkpoint(batchCtx, batch); err != nil {
+		return false, flex.Recoverable(fmt.Errorf("flexoutbox: checkpoint %d entries: %w", len(batch), err))
+	}
+	return len(batch) >= p.opts.batchSize, nil
+}
+
+// Halt stops polling, and waits for the batch being dispatched to complete
+// and be checkpointed, for at most the drain timeout, or until the deadline of
+// ctx if it is earlier.
+func (p *Poller[E]) Halt(ctx context.Context) error {
+	p.mu.Lock()
+	stop, abort, done := p.stop, p.abort, p.done
+	p.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := p.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexoutbox: batch was not dispatched within %s", timeout)
+	}
+}
@@ -0,0 +1,162 @@
+package flexoutbox_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexoutbox"
+)
+
+// mockStore holds pending entries, oldest first.
+type mockStore struct {
+	mu      sync.Mutex
+	pending []int
+	err     error
+}
+
+func (s *mockStore) add(entries ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, entries...)
+}
+
+func (s *mockStore) Fetch(_ context.Context, limit int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return slices.Clone(s.pending[:min(limit, len(s.pending))]), nil
+}
+
+func (s *mockStore) Checkpoint(_ context.Context, batch []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = slices.DeleteFunc(s.pending, func(e int) bool { return slices.Contains(batch, e) })
+	return nil
+}
+
+func (s *mockStore) left() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.pending)
+}
+
+func TestPoller(t *testing.T) {
+	t.Run("entries must be dispatched in batches and checkpointed", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{}
+		store.add(1, 2, 3, 4, 5)
+		batches := make(chan []int, 3)
+		p := flexoutbox.New(store, func(_ context.Context, batch []int) error {
+			batches <- batch
+			return nil
+		}, flexoutbox.WithBatchSize(2), flexoutbox.WithInterval(time.Hour))
+
+		errC := make(chan error, 1)
+		go func() { errC <- p.Run(context.Background()) }()
+
+		for _, want := range [][]int{{1, 2}, {3, 4}, {5}} {
+			if batch := <-batches; !slices.Equal(batch, want) {
+				t.Errorf("expected %v but got: %v", want, batch)
+			}
+		}
+
+		if err := p.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if left := store.left(); len(left) != 0 {
+			t.Errorf("expected every entry to be checkpointed but got: %v", left)
+		}
+	})
+	t.Run("failed batches must be dispatched again", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{}
+		store.add(1)
+		attempts := make(chan struct{}, 2)
+		errs := make(chan error, 1)
+		p := flexoutbox.New(store, func(context.Context, []int) error {
+			attempts <- struct{}{}
+			if len(attempts) == 1 {
+				return errors.New("broker unavailable")
+			}
+			return nil
+		}, flexoutbox.WithInterval(time.Millisecond), flexoutbox.WithErrorHandler(func(err error) { errs <- err }))
+		defer p.Halt(context.Background())
+
+		go p.Run(context.Background())
+
+		if err := <-errs; err == nil {
+			t.Error("expected the dispatch error to be handled")
+		}
+		for deadline := time.Now().Add(time.Second); len(store.left()) != 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the entry to be checkpointed but got: %v", store.left())
+			}
+		}
+	})
+	t.Run("the current batch must be finished when halted", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{}
+		store.add(1)
+		dispatching := make(chan struct{})
+		p := flexoutbox.New(store, func(ctx context.Context, _ []int) error {
+			close(dispatching)
+			time.Sleep(20 * time.Millisecond)
+			return ctx.Err()
+		})
+
+		go p.Run(context.Background())
+
+		<-dispatching
+		if err := p.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if left := store.left(); len(left) != 0 {
+			t.Errorf("expected the batch to be checkpointed but got: %v", left)
+		}
+	})
+	t.Run("a batch outliving the drain timeout must not be checkpointed", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{}
+		store.add(1)
+		dispatching := make(chan struct{})
+		p := flexoutbox.New(store, func(ctx context.Context, _ []int) error {
+			close(dispatching)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexoutbox.WithDrainTimeout(10*time.Millisecond), flexoutbox.WithErrorHandler(func(error) {}))
+
+		go p.Run(context.Background())
+
+		<-dispatching
+		if err := p.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if left := store.left(); !slices.Equal(left, []int{1}) {
+			t.Errorf("expected the entry to be left pending but got: %v", left)
+		}
+	})
+	t.Run("store errors must be recoverable", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{err: errors.New("connection refused")}
+		p := flexoutbox.New(store, func(context.Context, []int) error { return nil })
+
+		if err := p.Run(context.Background()); !errors.Is(err, store.err) || !flex.IsRecoverable(err) {
+			t.Errorf("expected a recoverable %v but got: %v", store.err, err)
+		}
+	})
+}
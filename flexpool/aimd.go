@@ -0,0 +1,57 @@
+package flexpool
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultBackoff is the factor the limit of an AIMD controller is multiplied
+// by when an item fails or is handled too slowly.
+const DefaultBackoff = 0.9
+
+// AIMD is a Controller applying additive increase, multiplicative decrease:
+// the limit grows by one once as many items as the limit have been handled
+// within the target latency, and is multiplied by the backoff factor whenever
+// an item fails or exceeds it.
+type AIMD struct {
+	// Min and Max bound the limit.
+	Min, Max int
+	// Target is the latency above which the limit is decreased.
+	// Zero means only failures decrease the limit.
+	Target time.Duration
+	// Backoff is the factor the limit is multiplied by when decreasing.
+	Backoff float64
+
+	mu    sync.Mutex
+	limit float64
+}
+
+// NewAIMD returns an AIMD controller whose limit starts at minLimit.
+func NewAIMD(minLimit, maxLimit int, target time.Duration) *AIMD {
+	return &AIMD{Min: minLimit, Max: maxLimit, Target: target, Backoff: DefaultBackoff, limit: float64(minLimit)}
+}
+
+// Limit returns the current limit.
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.clamp(a.limit))
+}
+
+// Observe adjusts the limit according to the outcome of handling an item.
+func (a *AIMD) Observe(latency time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	limit := a.clamp(a.limit)
+	if err != nil || (a.Target > 0 && latency > a.Target) {
+		a.limit = a.clamp(limit * a.Backoff)
+		return
+	}
+	a.limit = a.clamp(limit + 1/limit)
+}
+
+// clamp bounds limit between Min and Max, and to at least one.
+func (a *AIMD) clamp(limit float64) float64 {
+	return min(max(limit, float64(a.Min), 1), max(float64(a.Max), 1))
+}
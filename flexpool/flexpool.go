@@ -0,0 +1,242 @@
+// Package flexpool provides a flex worker handling items from a source with a
+// pool of goroutines, whose concurrency is either fixed or adjusted by a
+// Controller from the latency and errors of the handler, so that consumers
+// self-tune to the capacity of their downstream dependencies.
+//
+//	pool := flexpool.New(
+//		func(ctx context.Context) (Job, error) { return queue.Dequeue(ctx) },
+//		func(ctx context.Context, job Job) error { return process(ctx, job) },
+//		flexpool.WithController(flexpool.NewAIMD(1, 64, 200*time.Millisecond)),
+//	)
+//	flex.MustStart(ctx, pool)
+package flexpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDrainTimeout is how long in-flight items are given to be handled
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+// Source returns the next item to handle, blocking until one is available or
+// the context is done. An error stops the pool, unless the context is done.
+type Source[T any] func(ctx context.Context) (T, error)
+
+// Handler handles a single item. Errors, and panics, are fed back to the
+// controller and counted, but do not stop the pool.
+type Handler[T any] func(ctx context.Context, item T) error
+
+// Controller decides how many items are handled concurrently.
+// It must be safe for concurrent use.
+type Controller interface {
+	// Limit returns the number of items which may be handled concurrently,
+	// it is consulted before handling every item.
+	Limit() int
+	// Observe records the outcome of handling an item.
+	Observe(latency time.Duration, err error)
+}
+
+// Fixed is a Controller with a constant limit.
+type Fixed int
+
+// Limit returns the limit.
+func (f Fixed) Limit() int { return int(f) }
+
+// Observe does nothing.
+func (Fixed) Observe(time.Duration, error) {}
+
+// Metrics is a snapshot of the state of a pool.
+type Metrics struct {
+	// Limit is the current concurrency limit.
+	Limit int
+	// InFlight is the number of items being handled.
+	InFlight int
+	// Handled is the number of items handled successfully.
+	Handled uint64
+	// Failed is the number of items whose handler failed or panicked.
+	Failed uint64
+}
+
+// Option configures a Pool.
+type Option func(*options)
+
+type options struct {
+	controller   Controller
+	drainTimeout time.Duration
+}
+
+// WithConcurrency sets a fixed number of items handled concurrently, the
+// default is 1.
+func WithConcurrency(n int) Option {
+	return func(o *options) { o.controller = Fixed(n) }
+}
+
+// WithController sets the controller deciding how many items are handled
+// concurrently, such as an AIMD controller.
+func WithController(c Controller) Option {
+	return func(o *options) { o.controller = c }
+}
+
+// WithDrainTimeout sets how long in-flight items are given to be handled once
+// the pool is halted, after which their contexts are cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// Pool is a flex worker handling items from a source concurrently.
+type Pool[T any] struct {
+	next   Source[T]
+	handle Handler[T]
+	opts   options
+
+	inFlight atomic.Int64
+	handled  atomic.Uint64
+	failed   atomic.Uint64
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Pool handling the items returned by next with handle.
+func New[T any](next Source[T], handle Handler[T], opts ...Option) *Pool[T] {
+	p := &Pool[T]{
+		next:   next,
+		handle: handle,
+		opts:   options{controller: Fixed(1), drainTimeout: DefaultDrainTimeout},
+	}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	return p
+}
+
+// Metrics returns a snapshot of the state of the pool.
+func (p *Pool[T]) Metrics() Metrics {
+	return Metrics{
+		Limit:    p.opts.controller.Limit(),
+		InFlight: int(p.inFlight.Load()),
+		Handled:  p.handled.Load(),
+		Failed:   p.failed.Load(),
+	}
+}
+
+// Run handles items until the context is done or Halt is called, then waits
+// for in-flight items to be handled.
+//
+// Handlers are given a context which is not cancelled when the pool stops
+// taking items from the source, so that in-flight items can be handled to
+// completion.
+func (p *Pool[T]) Run(ctx context.Context) error {
+	nextCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	p.mu.Lock()
+	p.stop, p.abort, p.done = stop, abort, done
+	p.mu.Unlock()
+
+	var (
+		handlers sync.WaitGroup
+		released = make(chan struct{}, 1)
+	)
+	defer handlers.Wait()
+
+	flex.Ready(ctx)
+
+	for {
+		if int(p.inFlight.Load()) >= max(p.opts.controller.Limit(), 1) {
+			select {
+			case <-nextCtx.Done():
+				return nil
+			case <-released:
+				continue
+			}
+		}
+
+		item, err := p.next(nextCtx)
+		if nextCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("flexpool: next: %w", err)
+		}
+
+		p.inFlight.Add(1)
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer func() {
+				p.inFlight.Add(-1)
+				select {
+				case released <- struct{}{}:
+				default:
+				}
+			}()
+
+			p.run(handlerCtx, item)
+		}()
+	}
+}
+
+// Halt stops taking items from the source and waits for in-flight items to
+// be handled, for at most the drain timeout, or until the deadline of ctx if
+// it is earlier.
+func (p *Pool[T]) Halt(ctx context.Context) error {
+	p.mu.Lock()
+	stop, abort, done := p.stop, p.abort, p.done
+	p.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := p.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexpool: in-flight items were not handled within %s", timeout)
+	}
+}
+
+// run handles item and reports the outcome to the controller.
+func (p *Pool[T]) run(ctx context.Context, item T) {
+	start := time.Now()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("flexpool: handler panicked: %v", r)
+			}
+		}()
+		return p.handle(ctx, item)
+	}()
+
+	p.opts.controller.Observe(time.Since(start), err)
+	if err != nil {
+		p.failed.Add(1)
+		return
+	}
+	p.handled.Add(1)
+}
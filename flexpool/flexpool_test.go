@@ -0,0 +1,188 @@
+package flexpool_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexpool"
+)
+
+// counter is a source returning increasing integers.
+func counter() flexpool.Source[int] {
+	var n atomic.Int64
+	return func(ctx context.Context) (int, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return int(n.Add(1)), nil
+	}
+}
+
+func TestPool(t *testing.T) {
+	t.Run("items must not be handled beyond the concurrency limit", func(t *testing.T) {
+		t.Parallel()
+
+		var inFlight, peak atomic.Int64
+		pool := flexpool.New(counter(), func(ctx context.Context, _ int) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		}, flexpool.WithConcurrency(4))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if err := pool.Run(ctx); err != nil {
+			t.Error(err)
+		}
+		if peak.Load() != 4 {
+			t.Errorf("expected a peak of 4 in-flight items, but got %d", peak.Load())
+		}
+		if m := pool.Metrics(); m.Handled == 0 || m.InFlight != 0 || m.Limit != 4 {
+			t.Errorf("unexpected metrics: %+v", m)
+		}
+	})
+	t.Run("failing and panicking handlers must be counted", func(t *testing.T) {
+		t.Parallel()
+
+		pool := flexpool.New(counter(), func(ctx context.Context, n int) error {
+			if n%2 == 0 {
+				panic("boom")
+			}
+			return errors.New("failed")
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if err := pool.Run(ctx); err != nil {
+			t.Error(err)
+		}
+		if m := pool.Metrics(); m.Failed == 0 || m.Handled != 0 {
+			t.Errorf("unexpected metrics: %+v", m)
+		}
+	})
+	t.Run("a failing source must stop the pool", func(t *testing.T) {
+		t.Parallel()
+
+		pool := flexpool.New(func(context.Context) (int, error) {
+			return 0, errors.New("queue closed")
+		}, func(context.Context, int) error { return nil })
+
+		if err := pool.Run(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+	t.Run("halting must wait for in-flight items", func(t *testing.T) {
+		t.Parallel()
+
+		var handled atomic.Bool
+		started := make(chan struct{}, 1)
+		pool := flexpool.New(counter(), func(ctx context.Context, _ int) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			time.Sleep(20 * time.Millisecond)
+			handled.Store(ctx.Err() == nil)
+			return nil
+		})
+
+		errC := make(chan error, 1)
+		go func() { errC <- pool.Run(context.Background()) }()
+		<-started
+
+		if err := pool.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if !handled.Load() {
+			t.Error("expected the in-flight item to be handled to completion")
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("halting must give up after the drain timeout", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{}, 1)
+		pool := flexpool.New(counter(), func(ctx context.Context, _ int) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexpool.WithDrainTimeout(10*time.Millisecond))
+
+		go pool.Run(context.Background())
+		<-started
+
+		if err := pool.Halt(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestAIMD(t *testing.T) {
+	t.Run("the limit must increase additively while handling is fast", func(t *testing.T) {
+		t.Parallel()
+
+		c := flexpool.NewAIMD(1, 3, 10*time.Millisecond)
+		for range 10 {
+			c.Observe(time.Millisecond, nil)
+		}
+		if c.Limit() != 3 {
+			t.Errorf("expected the limit to reach its maximum of 3, but got %d", c.Limit())
+		}
+	})
+	t.Run("the limit must decrease multiplicatively on failures and slowness", func(t *testing.T) {
+		t.Parallel()
+
+		c := flexpool.NewAIMD(2, 100, 10*time.Millisecond)
+		for range 200 {
+			c.Observe(time.Millisecond, nil)
+		}
+		before := c.Limit()
+
+		c.Observe(time.Millisecond, errors.New("failed"))
+		c.Observe(time.Second, nil)
+		if after := c.Limit(); after != int(float64(before)*0.9*0.9) {
+			t.Errorf("expected the limit to back off from %d, but got %d", before, after)
+		}
+
+		for range 100 {
+			c.Observe(time.Millisecond, errors.New("failed"))
+		}
+		if c.Limit() != 2 {
+			t.Errorf("expected the limit to bottom out at 2, but got %d", c.Limit())
+		}
+	})
+}
+
+func TestPoolWithAIMD(t *testing.T) {
+	t.Parallel()
+
+	c := flexpool.NewAIMD(1, 8, 10*time.Millisecond)
+	pool := flexpool.New(counter(), func(context.Context, int) error { return nil }, flexpool.WithController(c))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := pool.Run(ctx); err != nil {
+		t.Error(err)
+	}
+	if c.Limit() != 8 {
+		t.Errorf("expected the limit to grow to 8, but got %d", c.Limit())
+	}
+}
@@ -0,0 +1,323 @@
+// Package flexproc provides flex workers for supervising external processes,
+// which lets a flex binary act as the init process of a container.
+//
+//	flex.MustStart(ctx,
+//		flexproc.Command(exec.Command("nginx", "-g", "daemon off;")),
+//		flexproc.NewReaper(),
+//	)
+//
+// Once halted, a process is asked to terminate, and killed if it has not
+// exited within the grace period. Its output is written to a logger, unless
+// the command redirects it, and its exit codes can be mapped to recoverable or
+// fatal errors, for the manager's restart policy to start it again or not.
+package flexproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultGracePeriod is how long processes are given to exit once asked to
+// terminate during Halt, before being killed, when no grace period is
+// configured.
+const DefaultGracePeriod = 10 * time.Second
+
+var logger = log.New(os.Stderr, "flexproc: ", 0)
+
+// Option configures a Process.
+type Option func(*options)
+
+type options struct {
+	forward     []os.Signal
+	gracePeriod time.Duration
+	logger      *log.Logger
+	exitCodes   map[int]func(error) error
+}
+
+// WithForwardSignals sets the signals forwarded to the process while it runs,
+// replacing DefaultForwardSignals. Shutdown signals do not need forwarding:
+// they halt the process as part of the shutdown.
+func WithForwardSignals(sig ...os.Signal) Option {
+	return func(o *options) { o.forward = sig }
+}
+
+// WithGracePeriod sets how long the process is given to exit once asked to
+// terminate, after which it is killed.
+func WithGracePeriod(d time.Duration) Option {
+	return func(o *options) { o.gracePeriod = d }
+}
+
+// WithLogger sets the logger the output of the process is written to, one
+// line at a time, when the command has no Stdout or Stderr of its own.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithRecoverableExitCodes marks the errors of the process exiting with one
+// of codes with flex.Recoverable, so that the process is started again
+// according to the manager's restart policy.
+func WithRecoverableExitCodes(codes ...int) Option {
+	return func(o *options) {
+		for _, code := range codes {
+			o.exitCodes[code] = flex.Recoverable
+		}
+	}
+}
+
+// WithFatalExitCodes marks the errors of the process exiting with one of
+// codes with flex.Fatal, so that the manager shuts down without restarting
+// any worker.
+func WithFatalExitCodes(codes ...int) Option {
+	return func(o *options) {
+		for _, code := range codes {
+			o.exitCodes[code] = flex.Fatal
+		}
+	}
+}
+
+// Process is a flex worker running an external process.
+type Process struct {
+	cmd  *exec.Cmd
+	opts options
+
+	mu      sync.Mutex
+	process *os.Process
+	halting bool
+	done    chan struct{}
+}
+
+// Command returns a Process worker running cmd.
+//
+// The command is used as a template: every run starts a copy of it, so that
+// the process can be started again when the worker is restarted. Commands
+// created with exec.CommandContext lose their context when copied.
+func Command(cmd *exec.Cmd, opts ...Option) *Process {
+	p := &Process{
+		cmd: cmd,
+		opts: options{
+			forward:     DefaultForwardSignals,
+			gracePeriod: DefaultGracePeriod,
+			logger:      logger,
+			exitCodes:   make(map[int]func(error) error),
+		},
+	}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	return p
+}
+
+// Run starts the process, forwards signals to it, and waits for it to exit.
+// An exit caused by halting the process is not an error, other exits with a
+// non-zero code are, marked according to WithRecoverableExitCodes and
+// WithFatalExitCodes.
+func (p *Process) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	cmd := p.command()
+	name := filepath.Base(cmd.Path)
+	var outputs []*lineWriter
+	if cmd.Stdout == nil {
+		stdout := &lineWriter{logger: p.opts.logger, prefix: name + ": "}
+		cmd.Stdout, outputs = stdout, append(outputs, stdout)
+	}
+	if cmd.Stderr == nil {
+		stderr := &lineWriter{logger: p.opts.logger, prefix: name + " (stderr): "}
+		cmd.Stderr, outputs = stderr, append(outputs, stderr)
+	}
+
+	p.mu.Lock()
+	if p.halting {
+		p.halting = false
+		p.mu.Unlock()
+		return nil
+	}
+	starting.RLock()
+	if err := cmd.Start(); err != nil {
+		starting.RUnlock()
+		p.mu.Unlock()
+		return fmt.Errorf("flexproc: start %s: %w", cmd.Path, err)
+	}
+	pid := cmd.Process.Pid
+	track(pid)
+	starting.RUnlock()
+	p.process, p.done = cmd.Process, done
+	p.mu.Unlock()
+
+	defer untrack(pid)
+
+	flex.Ready(ctx)
+
+	if len(p.opts.forward) > 0 {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, p.opts.forward...)
+		defer signal.Stop(sigC)
+
+		exited := make(chan struct{})
+		defer close(exited)
+
+		go func() {
+			for {
+				select {
+				case <-exited:
+					return
+				case sig := <-sigC:
+					_ = cmd.Process.Signal(sig)
+				}
+			}
+		}()
+	}
+
+	err := cmd.Wait()
+	for _, output := range outputs {
+		output.flush()
+	}
+
+	// The halt, if any, only stops this run, so that the process is started
+	// again when the worker is restarted.
+	p.mu.Lock()
+	halting := p.halting
+	p.halting = false
+	p.mu.Unlock()
+
+	var exitErr *exec.ExitError
+	if halting && errors.As(err, &exitErr) && terminatedBySignal(exitErr) {
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("flexproc: %s: %w", cmd.Path, err)
+	if errors.As(err, &exitErr) {
+		if mark, ok := p.opts.exitCodes[exitErr.ExitCode()]; ok {
+			return mark(err)
+		}
+	}
+	return err
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once the process is
+// started, see flex.ReadyReporter.
+func (p *Process) ReportsReady() bool { return true }
+
+// command returns a copy of the command to start.
+func (p *Process) command() *exec.Cmd {
+	return &exec.Cmd{
+		Path:        p.cmd.Path,
+		Args:        p.cmd.Args,
+		Env:         p.cmd.Env,
+		Dir:         p.cmd.Dir,
+		Stdin:       p.cmd.Stdin,
+		Stdout:      p.cmd.Stdout,
+		Stderr:      p.cmd.Stderr,
+		ExtraFiles:  p.cmd.ExtraFiles,
+		SysProcAttr: p.cmd.SysProcAttr,
+		WaitDelay:   p.cmd.WaitDelay,
+		Err:         p.cmd.Err,
+	}
+}
+
+// Halt asks the process to terminate and waits for it to exit, for at most
+// the grace period, or until the deadline of ctx if it is earlier, after
+// which it is killed and an error is returned. A process which has not been
+// started yet will not be.
+func (p *Process) Halt(ctx context.Context) error {
+	p.mu.Lock()
+	p.halting = true
+	process, done := p.process, p.done
+	p.mu.Unlock()
+
+	if process == nil {
+		return nil
+	}
+
+	if err := terminate(process); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("flexproc: terminate %s: %w", p.cmd.Path, err)
+	}
+
+	gracePeriod := p.opts.gracePeriod
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		gracePeriod = min(gracePeriod, time.Until(deadline))
+	}
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("flexproc: kill %s: %w", p.cmd.Path, err)
+	}
+	<-done
+	return fmt.Errorf("flexproc: %s did not exit within %s, killed it", p.cmd.Path, gracePeriod)
+}
+
+// maxLineLength is the length after which output is logged even though the
+// line is not complete yet.
+const maxLineLength = 64 << 10
+
+// lineWriter writes the output of a process to a logger, one line at a time.
+type lineWriter struct {
+	logger *log.Logger
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.logger.Print(w.prefix + string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineLength {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// flush logs the incomplete line, if any.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.logger.Print(w.prefix + string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
+
+var (
+	// tracked holds the pids of the processes run by Process workers, which
+	// the Reaper must leave for them to wait on.
+	tracked sync.Map
+
+	// starting is held by Process workers while starting and tracking their
+	// process, and by the Reaper while reaping, so that a process exiting
+	// right after starting is not reaped before being tracked.
+	starting sync.RWMutex
+)
+
+func track(pid int)   { tracked.Store(pid, struct{}{}) }
+func untrack(pid int) { tracked.Delete(pid) }
+
+func isTracked(pid int) bool {
+	_, ok := tracked.Load(pid)
+	return ok
+}
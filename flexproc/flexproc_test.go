@@ -0,0 +1,194 @@
+//go:build unix
+
+package flexproc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexproc"
+)
+
+func TestProcess(t *testing.T) {
+	t.Run("halting a process must not be an error", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sleep", "10"))
+
+		errC := make(chan error, 1)
+		go func() { errC <- p.Run(context.Background()) }()
+		time.Sleep(50 * time.Millisecond)
+
+		if err := p.Halt(context.Background()); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+		if err := <-errC; err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("a failing process must return an error", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sh", "-c", "exit 3"))
+		if err := p.Run(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+	t.Run("a process ignoring termination must be killed after the grace period", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sh", "-c", `trap "" TERM; echo ready; while true; do sleep 0.01; done`),
+			flexproc.WithGracePeriod(50*time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- p.Run(context.Background()) }()
+		time.Sleep(200 * time.Millisecond)
+
+		start := time.Now()
+		if err := p.Halt(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("expected the process to be killed after the grace period but took: %s", d)
+		}
+		if err := <-errC; err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("the output of the process must be logged line by line", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		p := flexproc.Command(exec.Command("sh", "-c", `echo one; printf "two\nthree"; echo oops >&2`),
+			flexproc.WithLogger(log.New(&buf, "", 0)))
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		want := []string{"sh: one", "sh: two", "sh (stderr): oops", "sh: three"}
+		if len(lines) != len(want) {
+			t.Fatalf("expected %q but got: %q", want, lines)
+		}
+		for _, line := range want {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Errorf("expected %q to be logged but got: %q", line, buf.String())
+			}
+		}
+	})
+	t.Run("exit codes must be marked as configured", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sh", "-c", "exit 3"),
+			flexproc.WithRecoverableExitCodes(3), flexproc.WithFatalExitCodes(4))
+		if err := p.Run(context.Background()); !flex.IsRecoverable(err) {
+			t.Errorf("expected a recoverable error but got: %v", err)
+		}
+
+		p = flexproc.Command(exec.Command("sh", "-c", "exit 4"),
+			flexproc.WithRecoverableExitCodes(3), flexproc.WithFatalExitCodes(4))
+		err := p.Run(context.Background())
+		var exitErr *exec.ExitError
+		if !flex.IsFatal(err) || !errors.As(err, &exitErr) || exitErr.ExitCode() != 4 {
+			t.Errorf("expected a fatal exit with code 4 but got: %v", err)
+		}
+	})
+	t.Run("a process must be started again when run again", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		p := flexproc.Command(exec.Command("echo", "hello"), flexproc.WithLogger(log.New(&buf, "", 0)))
+		for range 2 {
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := strings.Count(buf.String(), "echo: hello\n"); got != 2 {
+			t.Errorf("expected %d runs but got: %d", 2, got)
+		}
+	})
+	t.Run("a halted process must be started again when run again", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sleep", "10"))
+		for range 2 {
+			errC := make(chan error, 1)
+			go func() { errC <- p.Run(context.Background()) }()
+			time.Sleep(50 * time.Millisecond)
+
+			select {
+			case err := <-errC:
+				t.Fatalf("expected the process to run until halted but Run returned: %v", err)
+			default:
+			}
+			if err := p.Halt(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-errC; err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	t.Run("a process ignoring termination must be killed once the halt deadline expires", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("sh", "-c", `trap "" TERM; echo ready; while true; do sleep 0.01; done`))
+
+		errC := make(chan error, 1)
+		go func() { errC <- p.Run(context.Background()) }()
+		time.Sleep(200 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := p.Halt(ctx); err == nil {
+			t.Error("expected an error but got none")
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("expected the process to be killed once the deadline expired but took: %s", d)
+		}
+		<-errC
+	})
+	t.Run("a missing executable must return an error", func(t *testing.T) {
+		t.Parallel()
+
+		p := flexproc.Command(exec.Command("/does/not/exist"))
+		if err := p.Run(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestProcessForwardsSignals(t *testing.T) {
+	p := flexproc.Command(
+		exec.Command("sh", "-c", `trap "exit 0" USR1; while true; do sleep 0.01; done`),
+		flexproc.WithForwardSignals(syscall.SIGUSR1),
+	)
+
+	errC := make(chan error, 1)
+	go func() { errC <- p.Run(context.Background()) }()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected the process to exit after receiving the forwarded signal")
+		_ = p.Halt(context.Background())
+	}
+}
@@ -0,0 +1,37 @@
+package flexproc
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultReapInterval is how often the Reaper checks for zombie processes, in
+// addition to whenever a child process exits.
+const DefaultReapInterval = time.Second
+
+// Reaper is a flex worker which reaps orphaned child processes.
+//
+// When a process runs as PID 1, as it does in containers without an init
+// process, the orphaned descendants of its children are re-parented to it,
+// and it must wait on them once they exit for them not to linger as zombies.
+// Processes run by Process workers are left alone, but any other child
+// process started with os/exec will have its exit status stolen.
+//
+// Reaping is only implemented on Linux, elsewhere the Reaper does nothing.
+type Reaper struct {
+	// Interval is how often zombies are checked for.
+	Interval time.Duration
+}
+
+// NewReaper returns a Reaper.
+func NewReaper() *Reaper {
+	return &Reaper{Interval: DefaultReapInterval}
+}
+
+// Run reaps zombie processes until the context is done.
+func (r *Reaper) Run(ctx context.Context) error {
+	return r.run(ctx)
+}
+
+// Halt is a no-op, the reaper stops when its context is done.
+func (r *Reaper) Halt(context.Context) error { return nil }
@@ -0,0 +1,71 @@
+package flexproc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	pAll    = 0
+	wNoWait = 0x1000000
+)
+
+func (r *Reaper) run(ctx context.Context) error {
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGCHLD)
+	defer signal.Stop(sigC)
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reap()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sigC:
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap waits on every exited child process which is not tracked.
+// Children are first peeked at without being waited on, so that the exit
+// status of tracked processes is left for their Process worker.
+func reap() {
+	starting.Lock()
+	defer starting.Unlock()
+
+	for {
+		// siginfo_t is 128 bytes, si_pid follows three ints, padded to
+		// pointer alignment.
+		var info [128]byte
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pAll, 0,
+			uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOHANG|wNoWait, 0, 0)
+		if errno != 0 {
+			return
+		}
+
+		pid := int(*(*int32)(unsafe.Pointer(&info[pidOffset])))
+		if pid == 0 || isTracked(pid) {
+			return
+		}
+
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+			return
+		}
+	}
+}
+
+// pidOffset is the offset of si_pid in siginfo_t.
+const pidOffset = 3*4 + (unsafe.Sizeof(uintptr(0)) - 4)
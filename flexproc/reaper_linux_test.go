@@ -0,0 +1,45 @@
+package flexproc_test
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexproc"
+)
+
+func TestReaper(t *testing.T) {
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// A child process which nothing waits on, as orphans are to PID 1.
+	pid, err := syscall.ForkExec(truePath, []string{"true"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tracked process whose exit status must be left to its worker.
+	p := flexproc.Command(exec.Command("sh", "-c", "sleep 0.2"))
+	errC := make(chan error, 1)
+	go func() { errC <- p.Run(context.Background()) }()
+
+	r := flexproc.NewReaper()
+	r.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = r.Run(ctx) }()
+
+	if err := <-errC; err != nil {
+		t.Errorf("expected the tracked process to be waited on by its worker but got: %v", err)
+	}
+
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != syscall.ECHILD {
+		t.Errorf("expected the orphan to have been reaped but got: %v", err)
+	}
+}
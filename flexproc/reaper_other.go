@@ -0,0 +1,10 @@
+//go:build !linux
+
+package flexproc
+
+import "context"
+
+func (r *Reaper) run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package flexproc
+
+import (
+	"os"
+	"os/exec"
+)
+
+// DefaultForwardSignals are the signals forwarded to processes by default,
+// there are none on this platform.
+var DefaultForwardSignals []os.Signal
+
+// terminate kills p, as it cannot be asked to terminate on this platform.
+func terminate(p *os.Process) error { return p.Kill() }
+
+// terminatedBySignal reports whether the process exited because it was
+// terminated, which is any exit following terminate on this platform.
+func terminatedBySignal(*exec.ExitError) bool { return true }
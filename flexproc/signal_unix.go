@@ -0,0 +1,21 @@
+//go:build unix
+
+package flexproc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// DefaultForwardSignals are the signals forwarded to processes by default.
+var DefaultForwardSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// terminate asks p to terminate.
+func terminate(p *os.Process) error { return p.Signal(syscall.SIGTERM) }
+
+// terminatedBySignal reports whether the process exited because of a signal.
+func terminatedBySignal(err *exec.ExitError) bool {
+	status, ok := err.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled()
+}
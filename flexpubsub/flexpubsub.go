@@ -0,0 +1,152 @@
+// Package flexpubsub provides a flex worker for consuming Google Cloud Pub/Sub
+// subscriptions.
+//
+// The worker does not depend on the Pub/Sub client library, instead it works
+// with any type satisfying Subscription, which *pubsub.Subscription does:
+//
+//	sub := client.Subscription("orders")
+//	sub.ReceiveSettings.MaxExtension = 10 * time.Minute
+//
+//	flex.MustStart(ctx, flexpubsub.New(sub, func(ctx context.Context, msg *pubsub.Message) error {
+//		return process(ctx, msg.Data)
+//	}))
+package flexpubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is how long in-flight messages are given to be handled
+// during Halt when no drain timeout is configured.
+const DefaultDrainTimeout = 10 * time.Second
+
+// Message is a received message which must be acknowledged.
+type Message interface {
+	Ack()
+	Nack()
+}
+
+// Subscription is a streaming pull subscription.
+// Receive must call f concurrently for each received message, and must not
+// return until every call to f has returned.
+type Subscription[M Message] interface {
+	Receive(ctx context.Context, f func(context.Context, M)) error
+}
+
+// Handler handles a single message.
+// The message is acknowledged when the handler returns nil, and negatively
+// acknowledged when it returns an error or panics.
+type Handler[M Message] func(ctx context.Context, msg M) error
+
+// Option configures a Subscriber.
+type Option func(*options)
+
+type options struct {
+	drainTimeout time.Duration
+}
+
+// WithDrainTimeout sets how long in-flight messages are given to be handled
+// once the subscriber is halted, after which their contexts are cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// Subscriber is a flex worker which receives messages from a subscription
+// and dispatches them to a handler.
+type Subscriber[M Message] struct {
+	sub     Subscription[M]
+	handler Handler[M]
+	opts    options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Subscriber dispatching messages from sub to handler.
+func New[M Message](sub Subscription[M], handler Handler[M], opts ...Option) *Subscriber[M] {
+	s := &Subscriber[M]{
+		sub:     sub,
+		handler: handler,
+		opts:    options{drainTimeout: DefaultDrainTimeout},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	return s
+}
+
+// Run receives messages until the context is done or Halt is called.
+//
+// Handlers are given a context which is not cancelled when receiving stops, so
+// that in-flight messages can be handled to completion, and their ack
+// deadlines keep being extended by the client until they are.
+func (s *Subscriber[M]) Run(ctx context.Context) error {
+	receiveCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	s.mu.Lock()
+	s.stop, s.abort, s.done = stop, abort, done
+	s.mu.Unlock()
+
+	err := s.sub.Receive(receiveCtx, func(_ context.Context, msg M) {
+		s.handle(handlerCtx, msg)
+	})
+	if err != nil && receiveCtx.Err() == nil {
+		return fmt.Errorf("flexpubsub: receive: %w", err)
+	}
+	return nil
+}
+
+// Halt stops receiving new messages and waits for in-flight messages to be
+// handled, for at most the drain timeout, or until the deadline of ctx if it
+// is earlier.
+func (s *Subscriber[M]) Halt(ctx context.Context) error {
+	s.mu.Lock()
+	stop, abort, done := s.stop, s.abort, s.done
+	s.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := s.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexpubsub: in-flight messages were not handled within %s", timeout)
+	}
+}
+
+// handle dispatches msg to the handler and acknowledges it accordingly.
+func (s *Subscriber[M]) handle(ctx context.Context, msg M) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg.Nack()
+		}
+	}()
+
+	if err := s.handler(ctx, msg); err != nil {
+		msg.Nack()
+		return
+	}
+	msg.Ack()
+}
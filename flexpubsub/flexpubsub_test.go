@@ -0,0 +1,148 @@
+package flexpubsub_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexpubsub"
+)
+
+type mockMessage struct {
+	id     int
+	acked  atomic.Bool
+	nacked atomic.Bool
+}
+
+func (m *mockMessage) Ack()  { m.acked.Store(true) }
+func (m *mockMessage) Nack() { m.nacked.Store(true) }
+
+// mockSubscription delivers messages from a channel, mimicking the
+// semantics of the Pub/Sub client's Receive.
+type mockSubscription struct{ msgs chan *mockMessage }
+
+func (s *mockSubscription) Receive(ctx context.Context, f func(context.Context, *mockMessage)) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-s.msgs:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f(ctx, msg)
+			}()
+		}
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	t.Run("handled messages must be acked or nacked", func(t *testing.T) {
+		t.Parallel()
+
+		sub := &mockSubscription{msgs: make(chan *mockMessage)}
+		handled := make(chan struct{}, 3)
+		s := flexpubsub.New(sub, func(_ context.Context, msg *mockMessage) error {
+			defer func() { handled <- struct{}{} }()
+			switch msg.id {
+			case 1:
+				return errors.New("failed")
+			case 2:
+				panic("boom")
+			}
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- s.Run(ctx) }()
+
+		msgs := []*mockMessage{{id: 0}, {id: 1}, {id: 2}}
+		for _, msg := range msgs {
+			sub.msgs <- msg
+		}
+		for range msgs {
+			<-handled
+		}
+
+		cancel()
+		if err := s.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		if !msgs[0].acked.Load() {
+			t.Error("expected successfully handled message to be acked")
+		}
+		if !msgs[1].nacked.Load() {
+			t.Error("expected failed message to be nacked")
+		}
+		if !msgs[2].nacked.Load() {
+			t.Error("expected panicking message to be nacked")
+		}
+	})
+	t.Run("halt must wait for in-flight messages", func(t *testing.T) {
+		t.Parallel()
+
+		sub := &mockSubscription{msgs: make(chan *mockMessage)}
+		started := make(chan struct{})
+		s := flexpubsub.New(sub, func(ctx context.Context, _ *mockMessage) error {
+			close(started)
+			select {
+			case <-time.After(50 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { _ = s.Run(ctx) }()
+
+		msg := &mockMessage{}
+		sub.msgs <- msg
+		<-started
+
+		cancel()
+		if err := s.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if !msg.acked.Load() {
+			t.Error("expected in-flight message to complete and be acked")
+		}
+	})
+	t.Run("halt must give up after the drain timeout", func(t *testing.T) {
+		t.Parallel()
+
+		sub := &mockSubscription{msgs: make(chan *mockMessage)}
+		started := make(chan struct{})
+		s := flexpubsub.New(sub, func(ctx context.Context, _ *mockMessage) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexpubsub.WithDrainTimeout(20*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { _ = s.Run(ctx) }()
+
+		msg := &mockMessage{}
+		sub.msgs <- msg
+		<-started
+
+		cancel()
+		if err := s.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if !msg.nacked.Load() {
+			t.Error("expected aborted message to be nacked")
+		}
+	})
+}
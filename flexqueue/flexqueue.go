@@ -0,0 +1,377 @@
+// Package flexqueue provides a flex worker running an in-process priority
+// job queue.
+//
+// Jobs are enqueued at a priority level, 0 being the highest, and dispatched
+// to the handler by weighted round robin across the levels holding jobs: with
+// the default weights of 4, 2 and 1, for every 7 jobs dispatched while every
+// level is busy, 4 are high, 2 normal and 1 low priority jobs, so that lower
+// priorities are slowed down but never starved.
+//
+//	q := flexqueue.New(func(ctx context.Context, email Email) error {
+//		return send(ctx, email)
+//	}, flexqueue.WithConcurrency(8))
+//
+//	go flex.MustStart(ctx, q)
+//
+//	if err := q.Enqueue(flexqueue.PriorityHigh, passwordReset); err != nil {
+//		return err
+//	}
+package flexqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultCapacity is how many jobs each priority level holds when no
+	// capacity is configured.
+	DefaultCapacity = 1024
+	// DefaultDrainTimeout is how long queued and in-flight jobs are given to
+	// be handled during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// Priority levels of the default weights.
+const (
+	PriorityHigh = iota
+	PriorityNormal
+	PriorityLow
+)
+
+// DefaultWeights are the weights of the priority levels when none are
+// configured, highest priority first.
+var DefaultWeights = []int{4, 2, 1}
+
+var (
+	// ErrFull is returned when enqueuing a job at a priority level which
+	// already holds as many jobs as its capacity.
+	ErrFull = errors.New("flexqueue: queue is full")
+	// ErrClosed is returned when enqueuing a job once the queue has been halted.
+	ErrClosed = errors.New("flexqueue: queue is closed")
+	// ErrPriority is returned when enqueuing a job at a priority level which
+	// does not exist.
+	ErrPriority = errors.New("flexqueue: no such priority level")
+)
+
+// Handler handles a single job. Errors, and panics, are counted in the
+// metrics of the job's priority level.
+type Handler[T any] func(ctx context.Context, job T) error
+
+// Metrics is a snapshot of the state of a priority level.
+type Metrics struct {
+	// Priority is the priority level, 0 being the highest.
+	Priority int
+	// Weight is the share of dispatches the level gets while every level holds jobs.
+	Weight int
+	// Depth is the number of queued jobs.
+	Depth int
+	// Enqueued is the number of jobs accepted.
+	Enqueued uint64
+	// Rejected is the number of jobs refused because the level was full.
+	Rejected uint64
+	// Handled is the number of jobs handled successfully.
+	Handled uint64
+	// Failed is the number of jobs whose handler failed or panicked.
+	Failed uint64
+	// Dropped is the number of queued jobs discarded because the drain
+	// timeout expired before they were handled.
+	Dropped uint64
+}
+
+// Option configures a Queue.
+type Option func(*options)
+
+type options struct {
+	weights      []int
+	capacity     int
+	concurrency  int
+	drainTimeout time.Duration
+}
+
+// WithWeights sets the number of priority levels and their weights, highest
+// priority first, replacing DefaultWeights. Weights below 1 count as 1.
+func WithWeights(weights ...int) Option {
+	return func(o *options) { o.weights = weights }
+}
+
+// WithCapacity sets how many jobs each priority level holds.
+func WithCapacity(n int) Option {
+	return func(o *options) { o.capacity = n }
+}
+
+// WithConcurrency sets how many jobs are handled concurrently, the default is 1.
+func WithConcurrency(n int) Option {
+	return func(o *options) { o.concurrency = n }
+}
+
+// WithDrainTimeout sets how long queued and in-flight jobs are given to be
+// handled once the queue is halted, after which in-flight jobs have their
+// contexts cancelled and queued jobs are dropped.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// level is the queue of a priority level.
+type level[T any] struct {
+	jobs    []T
+	weight  int
+	current int
+	metrics Metrics
+}
+
+// Queue is a flex worker dispatching jobs to a handler by priority.
+type Queue[T any] struct {
+	handler Handler[T]
+	opts    options
+
+	// queued holds a token for every queued job.
+	queued chan struct{}
+
+	mu     sync.Mutex
+	levels []*level[T]
+	closed bool
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+
+	runMu sync.Mutex
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Queue dispatching jobs to handler.
+func New[T any](handler Handler[T], opts ...Option) *Queue[T] {
+	q := &Queue[T]{
+		handler: handler,
+		opts: options{
+			weights:      DefaultWeights,
+			capacity:     DefaultCapacity,
+			concurrency:  1,
+			drainTimeout: DefaultDrainTimeout,
+		},
+		stopped: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&q.opts)
+	}
+
+	for priority, weight := range q.opts.weights {
+		weight = max(weight, 1)
+		q.levels = append(q.levels, &level[T]{weight: weight, metrics: Metrics{Priority: priority, Weight: weight}})
+	}
+	q.queued = make(chan struct{}, len(q.levels)*q.opts.capacity)
+
+	return q
+}
+
+// Enqueue queues job at priority, 0 being the highest. It fails with ErrFull
+// when the level is full, with ErrClosed once the queue has been halted, and
+// with ErrPriority when the level does not exist.
+func (q *Queue[T]) Enqueue(priority int, job T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if priority < 0 || priority >= len(q.levels) {
+		return fmt.Errorf("%w: %d", ErrPriority, priority)
+	}
+	if q.closed {
+		return ErrClosed
+	}
+
+	l := q.levels[priority]
+	if len(l.jobs) >= q.opts.capacity {
+		l.metrics.Rejected++
+		return fmt.Errorf("%w: priority %d holds %d jobs", ErrFull, priority, q.opts.capacity)
+	}
+
+	l.jobs = append(l.jobs, job)
+	l.metrics.Enqueued++
+
+	// Tokens never outnumber the capacity of the channel, as every level is
+	// bounded, so this does not block.
+	q.queued <- struct{}{}
+	return nil
+}
+
+// Metrics returns a snapshot of the state of every priority level, highest
+// priority first.
+func (q *Queue[T]) Metrics() []Metrics {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	metrics := make([]Metrics, 0, len(q.levels))
+	for _, l := range q.levels {
+		m := l.metrics
+		m.Depth = len(l.jobs)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// Run dispatches jobs until the context is done or Halt is called, then
+// keeps dispatching until the queue is empty.
+//
+// Handlers are given a context which is not cancelled when the queue stops
+// accepting jobs, so that the queue can be drained.
+func (q *Queue[T]) Run(ctx context.Context) error {
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	q.runMu.Lock()
+	q.abort, q.done = abort, done
+	q.runMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.stop()
+		case <-done:
+		}
+	}()
+
+	var consumers sync.WaitGroup
+	for range max(q.opts.concurrency, 1) {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			q.consume(handlerCtx)
+		}()
+	}
+
+	flex.Ready(ctx)
+	consumers.Wait()
+
+	return nil
+}
+
+// Halt stops accepting jobs and waits for the queued and in-flight jobs to be
+// handled, for at most the drain timeout, or until the deadline of ctx if it
+// is earlier.
+func (q *Queue[T]) Halt(ctx context.Context) error {
+	q.stop()
+
+	q.runMu.Lock()
+	abort, done := q.abort, q.done
+	q.runMu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	timeout := q.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexqueue: queued jobs were not handled within %s", timeout)
+	}
+}
+
+// stop closes the queue to new jobs and starts draining it.
+func (q *Queue[T]) stop() {
+	q.stopOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		q.mu.Unlock()
+
+		close(q.stopped)
+	})
+}
+
+// consume dispatches jobs until the queue is stopped and empty, or ctx is
+// done, in which case the remaining jobs are dropped.
+func (q *Queue[T]) consume(ctx context.Context) {
+	for {
+		select {
+		case <-q.queued:
+			q.dispatch(ctx)
+			continue
+		case <-q.stopped:
+		}
+
+		// Drain the remaining jobs.
+		for {
+			select {
+			case <-q.queued:
+				q.dispatch(ctx)
+			default:
+				return
+			}
+		}
+	}
+}
+
+// dispatch handles the next job, or drops it if ctx is done.
+func (q *Queue[T]) dispatch(ctx context.Context) {
+	l, job := q.next()
+
+	if ctx.Err() != nil {
+		q.mu.Lock()
+		l.metrics.Dropped++
+		q.mu.Unlock()
+		return
+	}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("flexqueue: handler panicked: %v", r)
+			}
+		}()
+		return q.handler(ctx, job)
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		l.metrics.Failed++
+		return
+	}
+	l.metrics.Handled++
+}
+
+// next pops the next job by smooth weighted round robin across the levels
+// holding jobs. It must only be called after taking a token from queued.
+func (q *Queue[T]) next() (*level[T], T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var (
+		picked *level[T]
+		total  int
+	)
+	for _, l := range q.levels {
+		if len(l.jobs) == 0 {
+			continue
+		}
+		l.current += l.weight
+		total += l.weight
+		if picked == nil || l.current > picked.current {
+			picked = l
+		}
+	}
+	picked.current -= total
+
+	job := picked.jobs[0]
+	var zero T
+	picked.jobs[0] = zero
+	picked.jobs = picked.jobs[1:]
+
+	return picked, job
+}
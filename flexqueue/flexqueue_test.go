@@ -0,0 +1,148 @@
+package flexqueue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexqueue"
+)
+
+// job is a job recording the priority it was enqueued at.
+type job struct{ priority int }
+
+func TestQueue(t *testing.T) {
+	t.Run("jobs must be dispatched by weighted priority", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu         sync.Mutex
+			dispatched []int
+		)
+		q := flexqueue.New(func(_ context.Context, j job) error {
+			mu.Lock()
+			defer mu.Unlock()
+			dispatched = append(dispatched, j.priority)
+			return nil
+		})
+
+		for priority := range 3 {
+			for range 7 {
+				if err := q.Enqueue(priority, job{priority}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := q.Run(ctx); err != nil {
+			t.Error(err)
+		}
+
+		if len(dispatched) != 21 {
+			t.Fatalf("expected every job to be dispatched, but got %d", len(dispatched))
+		}
+		counts := make([]int, 3)
+		for _, priority := range dispatched[:7] {
+			counts[priority]++
+		}
+		if counts[0] != 4 || counts[1] != 2 || counts[2] != 1 {
+			t.Errorf("expected the first 7 jobs to be dispatched 4:2:1, but got %v", counts)
+		}
+	})
+	t.Run("levels must be bounded", func(t *testing.T) {
+		t.Parallel()
+
+		q := flexqueue.New(func(context.Context, job) error { return nil }, flexqueue.WithCapacity(1))
+
+		if err := q.Enqueue(flexqueue.PriorityLow, job{}); err != nil {
+			t.Error(err)
+		}
+		if err := q.Enqueue(flexqueue.PriorityLow, job{}); !errors.Is(err, flexqueue.ErrFull) {
+			t.Errorf("expected %v but got: %v", flexqueue.ErrFull, err)
+		}
+		if err := q.Enqueue(3, job{}); !errors.Is(err, flexqueue.ErrPriority) {
+			t.Errorf("expected %v but got: %v", flexqueue.ErrPriority, err)
+		}
+		if m := q.Metrics()[flexqueue.PriorityLow]; m.Depth != 1 || m.Enqueued != 1 || m.Rejected != 1 {
+			t.Errorf("unexpected metrics: %+v", m)
+		}
+	})
+	t.Run("halting must drain the queue", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		q := flexqueue.New(func(_ context.Context, j job) error {
+			<-release
+			if j.priority == flexqueue.PriorityNormal {
+				return errors.New("failed")
+			}
+			return nil
+		}, flexqueue.WithConcurrency(2))
+
+		errC := make(chan error, 1)
+		go func() { errC <- q.Run(context.Background()) }()
+
+		for _, priority := range []int{0, 0, 1, 2} {
+			if err := q.Enqueue(priority, job{priority}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		haltC := make(chan error, 1)
+		go func() { haltC <- q.Halt(context.Background()) }()
+
+		// Halting is asynchronous: wait for the queue to be closed.
+		for q.Enqueue(0, job{}) == nil {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+
+		if err := <-haltC; err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if err := q.Enqueue(0, job{}); !errors.Is(err, flexqueue.ErrClosed) {
+			t.Errorf("expected %v but got: %v", flexqueue.ErrClosed, err)
+		}
+
+		metrics := q.Metrics()
+		if metrics[0].Handled == 0 || metrics[1].Failed != 1 || metrics[2].Handled != 1 {
+			t.Errorf("unexpected metrics: %+v", metrics)
+		}
+		for _, m := range metrics {
+			if m.Depth != 0 || m.Dropped != 0 {
+				t.Errorf("expected the queue to be drained, but got: %+v", m)
+			}
+		}
+	})
+	t.Run("halting must drop queued jobs after the drain timeout", func(t *testing.T) {
+		t.Parallel()
+
+		q := flexqueue.New(func(ctx context.Context, _ job) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexqueue.WithDrainTimeout(10*time.Millisecond))
+
+		for range 3 {
+			if err := q.Enqueue(flexqueue.PriorityNormal, job{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		go q.Run(context.Background())
+		time.Sleep(10 * time.Millisecond)
+
+		if err := q.Halt(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+		if m := q.Metrics()[flexqueue.PriorityNormal]; m.Failed != 1 || m.Dropped != 2 {
+			t.Errorf("unexpected metrics: %+v", m)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+// Package flexredis provides a flex worker owning a Redis pub/sub
+// subscription.
+//
+// The worker does not depend on a Redis client, instead it drives any type
+// satisfying Client, which is typically a thin shim over the client of
+// redis/go-redis:
+//
+//	type client struct{ *redis.Client }
+//
+//	func (c client) Subscribe(ctx context.Context, channels ...string) (flexredis.Subscription[*redis.Message], error) {
+//		pubsub := c.Client.Subscribe(ctx, channels...)
+//		// Wait for the subscription to be confirmed.
+//		if _, err := pubsub.Receive(ctx); err != nil {
+//			pubsub.Close()
+//			return nil, err
+//		}
+//		return subscription{pubsub}, nil
+//	}
+//
+//	type subscription struct{ *redis.PubSub }
+//
+//	func (s subscription) Receive(ctx context.Context) (*redis.Message, error) { return s.ReceiveMessage(ctx) }
+//	func (s subscription) Unsubscribe(ctx context.Context) error                { return s.PubSub.Unsubscribe(ctx) }
+//
+//	flex.MustStart(ctx, flexredis.New(client{rdb}, []string{"invalidations"}, handleInvalidation))
+//
+// Whenever the subscription is lost, the worker subscribes again with
+// exponential backoff. Once halted, the message being handled is given the
+// drain timeout to complete, the subscription is torn down and the client is
+// closed.
+package flexredis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultMinBackoff is the default delay before the first attempt to
+	// subscribe again after the subscription was lost.
+	DefaultMinBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff is the default maximum delay between attempts to
+	// subscribe.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultDrainTimeout is how long the message being handled is given to
+	// complete during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+var logger = log.New(os.Stderr, "flexredis: ", 0)
+
+// Client is a Redis client able to subscribe to channels.
+type Client[M any] interface {
+	// Subscribe subscribes to channels, returning once the subscription is
+	// confirmed.
+	Subscribe(ctx context.Context, channels ...string) (Subscription[M], error)
+	// Close closes the client.
+	Close() error
+}
+
+// Subscription is a subscription to Redis channels.
+type Subscription[M any] interface {
+	// Receive blocks until a message is received, and returns an error once
+	// the connection is lost.
+	Receive(ctx context.Context) (M, error)
+	// Unsubscribe unsubscribes from every channel.
+	Unsubscribe(ctx context.Context) error
+	// Close closes the subscription and its connection.
+	Close() error
+}
+
+// Handler handles a single message. Messages are handled one at a time, in the
+// order they were published.
+type Handler[M any] func(ctx context.Context, msg M) error
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	drainTimeout time.Duration
+	onError      func(error)
+}
+
+// WithReconnectBackoff sets the bounds of the exponential backoff between
+// attempts to subscribe.
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithDrainTimeout sets how long the message being handled is given to
+// complete once the worker is halted, after which its handler's context is
+// cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the errors of the handler,
+// which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker which subscribes to Redis channels and dispatches
+// their messages to a handler.
+type Worker[M any] struct {
+	client   Client[M]
+	channels []string
+	handler  Handler[M]
+	opts     options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Worker dispatching the messages published to channels to
+// handler.
+func New[M any](client Client[M], channels []string, handler Handler[M], opts ...Option) *Worker[M] {
+	w := &Worker[M]{
+		client:   client,
+		channels: channels,
+		handler:  handler,
+		opts: options{
+			minBackoff:   DefaultMinBackoff,
+			maxBackoff:   DefaultMaxBackoff,
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run subscribes and dispatches messages, subscribing again with backoff
+// whenever the subscription is lost, until the context is done or Halt is
+// called. The worker reports itself ready once it first subscribes.
+//
+// The handler is given a context which is not cancelled when receiving stops,
+// so that the message being handled can complete during Halt.
+func (w *Worker[M]) Run(ctx context.Context) error {
+	receiveCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop, w.abort, w.done = stop, abort, done
+	w.mu.Unlock()
+
+	backoff := w.opts.minBackoff
+	for {
+		subscribed, err := w.subscribe(ctx, receiveCtx, handlerCtx)
+		if receiveCtx.Err() != nil {
+			return nil
+		}
+		if subscribed {
+			backoff = w.opts.minBackoff
+			logger.Printf("subscription lost, subscribing again in %s: %v", backoff, err)
+		} else {
+			logger.Printf("subscribing again in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-receiveCtx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.opts.maxBackoff)
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once it first
+// subscribes, see flex.ReadyReporter.
+func (w *Worker[M]) ReportsReady() bool { return true }
+
+// subscribe subscribes and dispatches messages until the subscription is
+// lost or the context is done, then tears the subscription down. It reports
+// whether it subscribed.
+func (w *Worker[M]) subscribe(ctx, receiveCtx, handlerCtx context.Context) (bool, error) {
+	sub, err := w.client.Subscribe(receiveCtx, w.channels...)
+	if err != nil {
+		return false, fmt.Errorf("flexredis: subscribe to %v: %w", w.channels, err)
+	}
+	defer w.teardown(handlerCtx, sub)
+
+	flex.Ready(ctx)
+
+	for {
+		msg, err := sub.Receive(receiveCtx)
+		if err != nil {
+			return true, fmt.Errorf("flexredis: receive: %w", err)
+		}
+		w.handle(handlerCtx, msg)
+	}
+}
+
+// teardown unsubscribes and closes sub, for at most the drain timeout. The
+// context is that of the handler, so that unsubscribing is abandoned along
+// with the handler once the drain of Halt expires.
+func (w *Worker[M]) teardown(ctx context.Context, sub Subscription[M]) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.drainTimeout)
+	defer cancel()
+
+	if err := sub.Unsubscribe(ctx); err != nil {
+		logger.Printf("unsubscribe: %v", err)
+	}
+	if err := sub.Close(); err != nil {
+		logger.Printf("close subscription: %v", err)
+	}
+}
+
+// handle dispatches msg to the handler, passing its error or panic to the
+// error handler.
+func (w *Worker[M]) handle(ctx context.Context, msg M) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.opts.onError(fmt.Errorf("flexredis: handler panicked: %v", r))
+		}
+	}()
+
+	if err := w.handler(ctx, msg); err != nil {
+		w.opts.onError(fmt.Errorf("flexredis: handle message: %w", err))
+	}
+}
+
+// Halt stops receiving messages and waits for the message being handled to
+// complete and the subscription to be torn down, for at most the drain
+// timeout, or until the deadline of ctx if it is earlier, then closes the
+// client.
+func (w *Worker[M]) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	stop, abort, done := w.stop, w.abort, w.done
+	w.mu.Unlock()
+
+	var err error
+	if done != nil {
+		stop()
+
+		timeout := w.opts.drainTimeout
+		if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+			timeout = min(timeout, time.Until(deadline))
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			abort()
+			<-done
+			err = fmt.Errorf("flexredis: message was not handled within %s", timeout)
+		}
+	}
+
+	if closeErr := w.client.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("flexredis: close client: %w", closeErr)
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package flexredis_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexredis"
+)
+
+// mockClient hands out subscriptions receiving from a shared channel, and can
+// drop their connections.
+type mockClient struct {
+	msgs chan string
+	// hang makes unsubscribing block until its context is done.
+	hang bool
+
+	mu            sync.Mutex
+	subscriptions []*mockSubscription
+	closed        bool
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{msgs: make(chan string)}
+}
+
+func (c *mockClient) Subscribe(context.Context, ...string) (flexredis.Subscription[string], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &mockSubscription{client: c, lost: make(chan struct{})}
+	c.subscriptions = append(c.subscriptions, sub)
+	return sub, nil
+}
+
+func (c *mockClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// drop drops the connection of the last subscription.
+func (c *mockClient) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.subscriptions[len(c.subscriptions)-1].lost)
+}
+
+func (c *mockClient) state() (subscriptions int, torndown, closed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	torndown = true
+	for _, sub := range c.subscriptions {
+		torndown = torndown && sub.unsubscribed && sub.closed
+	}
+	return len(c.subscriptions), torndown, c.closed
+}
+
+type mockSubscription struct {
+	client *mockClient
+	lost   chan struct{}
+
+	unsubscribed, closed bool
+}
+
+func (s *mockSubscription) Receive(ctx context.Context) (string, error) {
+	select {
+	case <-s.lost:
+		return "", errors.New("connection lost")
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-s.lost:
+		return "", errors.New("connection lost")
+	case msg := <-s.client.msgs:
+		return msg, nil
+	}
+}
+
+func (s *mockSubscription) Unsubscribe(ctx context.Context) error {
+	if s.client.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.unsubscribed = true
+	return nil
+}
+
+func (s *mockSubscription) Close() error {
+	s.client.mu.Lock()
+	defer s.client.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("messages must be handled and the subscription torn down when halted", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		handled := make(chan string, 1)
+		w := flexredis.New(client, []string{"news"}, func(_ context.Context, msg string) error {
+			handled <- msg
+			return nil
+		})
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		client.msgs <- "hello"
+		if msg := <-handled; msg != "hello" {
+			t.Errorf("expected %q but got: %q", "hello", msg)
+		}
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if _, torndown, closed := client.state(); !torndown || !closed {
+			t.Errorf("expected the subscription to be torn down and the client closed but got: %v, %v", torndown, closed)
+		}
+	})
+	t.Run("handler errors and panics must not stop the worker", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		errs := make(chan error, 2)
+		handled := make(chan struct{})
+		w := flexredis.New(client, []string{"news"}, func(_ context.Context, msg string) error {
+			switch msg {
+			case "fail":
+				return errors.New("failed")
+			case "panic":
+				panic("boom")
+			}
+			close(handled)
+			return nil
+		}, flexredis.WithErrorHandler(func(err error) { errs <- err }))
+		defer w.Halt(context.Background())
+
+		go w.Run(context.Background())
+
+		client.msgs <- "fail"
+		client.msgs <- "panic"
+		client.msgs <- "ok"
+		<-handled
+		if n := len(errs); n != 2 {
+			t.Errorf("expected %d errors but got: %d", 2, n)
+		}
+	})
+	t.Run("a lost subscription must be subscribed again", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		handled := make(chan string)
+		w := flexredis.New(client, []string{"news"}, func(_ context.Context, msg string) error {
+			handled <- msg
+			return nil
+		}, flexredis.WithReconnectBackoff(time.Millisecond, time.Millisecond))
+		defer w.Halt(context.Background())
+
+		go w.Run(context.Background())
+
+		client.msgs <- "one"
+		<-handled
+		client.drop()
+
+		client.msgs <- "two"
+		if msg := <-handled; msg != "two" {
+			t.Errorf("expected %q but got: %q", "two", msg)
+		}
+		if n, _, _ := client.state(); n != 2 {
+			t.Errorf("expected %d subscriptions but got: %d", 2, n)
+		}
+	})
+	t.Run("a handler outliving the drain timeout must be cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		handling := make(chan struct{})
+		w := flexredis.New(client, []string{"news"}, func(ctx context.Context, _ string) error {
+			close(handling)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexredis.WithDrainTimeout(10*time.Millisecond), flexredis.WithErrorHandler(func(error) {}))
+
+		go w.Run(context.Background())
+
+		client.msgs <- "slow"
+		<-handling
+		if err := w.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+	t.Run("the teardown must be bounded by the deadline of the halt", func(t *testing.T) {
+		t.Parallel()
+
+		client := newMockClient()
+		client.hang = true
+		w := flexredis.New(client, []string{"news"}, func(context.Context, string) error { return nil })
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+		client.msgs <- "hello"
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := w.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the teardown to stop at the deadline, but halted in %s", elapsed)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+}
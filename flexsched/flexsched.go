@@ -0,0 +1,137 @@
+// Package flexsched provides cooperative time slicing for CPU-bound workers,
+// so that heavy batch workers sharing a process with latency-sensitive ones
+// cannot monopolise it.
+//
+// A limited worker calls Yield between work items. Yield gives other
+// goroutines a chance to run and, once the worker has used up its budget for
+// the current interval, blocks until the next interval begins:
+//
+//	func (b *Batch) Run(ctx context.Context) error {
+//		for item := range b.items {
+//			if err := flexsched.Yield(ctx); err != nil {
+//				return nil
+//			}
+//			crunch(item)
+//		}
+//		return nil
+//	}
+//
+//	m.Add(flexsched.Limit(batch, 200*time.Millisecond, time.Second))
+//
+// The time a worker spends between two calls to Yield is what is counted
+// against its budget, as Go does not measure the CPU time of goroutines: work
+// items should be small compared to the budget, and must not block on I/O.
+package flexsched
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// budgetKey is the context key under which the budget of a limited worker is stored.
+type budgetKey struct{}
+
+// budget tracks the time used by a worker in the current interval.
+type budget struct {
+	limit, interval time.Duration
+
+	mu        sync.Mutex
+	window    time.Time
+	used      time.Duration
+	lastYield time.Time
+	throttled time.Duration
+}
+
+// Limited is a worker whose Run is limited to a time budget per interval.
+type Limited struct {
+	flex.Worker
+	budget *budget
+}
+
+// Limit returns a worker running w with a budget of limit per interval,
+// which w must honour by calling Yield between work items.
+func Limit(w flex.Worker, limit, interval time.Duration) *Limited {
+	return &Limited{Worker: w, budget: &budget{limit: limit, interval: interval}}
+}
+
+// Run runs the worker with its budget.
+func (l *Limited) Run(ctx context.Context) error {
+	now := time.Now()
+
+	l.budget.mu.Lock()
+	l.budget.window, l.budget.used, l.budget.lastYield = now, 0, now
+	l.budget.mu.Unlock()
+
+	return l.Worker.Run(context.WithValue(ctx, budgetKey{}, l.budget))
+}
+
+// Throttled returns how long the worker has been blocked in Yield because
+// its budget was used up.
+func (l *Limited) Throttled() time.Duration {
+	l.budget.mu.Lock()
+	defer l.budget.mu.Unlock()
+	return l.budget.throttled
+}
+
+// Yield yields the processor to other goroutines and, when ctx belongs to a
+// limited worker which has used up its budget for the current interval,
+// blocks until the next interval begins. It returns the context's error if
+// it is done.
+func Yield(ctx context.Context) error {
+	runtime.Gosched()
+
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return ctx.Err()
+	}
+
+	wait := b.account(time.Now())
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	b.resume(time.Now(), wait)
+	return nil
+}
+
+// account charges the time since the last yield to the budget, and returns
+// how long the worker must wait for its next interval.
+func (b *budget) account(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used += now.Sub(b.lastYield)
+	b.lastYield = now
+
+	end := b.window.Add(b.interval)
+	if !now.Before(end) {
+		b.window, b.used = now, 0
+		return 0
+	}
+	if b.used < b.limit {
+		return 0
+	}
+	return end.Sub(now)
+}
+
+// resume starts a new interval once the worker has waited for it.
+func (b *budget) resume(now time.Time, waited time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.window, b.used, b.lastYield = now, 0, now
+	b.throttled += waited
+}
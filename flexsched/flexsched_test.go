@@ -0,0 +1,90 @@
+package flexsched_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexsched"
+)
+
+// busyWorker busily works on items of a fixed duration, yielding between them.
+type busyWorker struct {
+	item  time.Duration
+	items atomic.Int64
+}
+
+func (b *busyWorker) Run(ctx context.Context) error {
+	for {
+		if err := flexsched.Yield(ctx); err != nil {
+			return nil
+		}
+		for start := time.Now(); time.Since(start) < b.item; {
+		}
+		b.items.Add(1)
+	}
+}
+
+func (b *busyWorker) Halt(context.Context) error { return nil }
+
+func TestLimit(t *testing.T) {
+	t.Run("a worker must be throttled to its budget", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			unlimited = &busyWorker{item: time.Millisecond}
+			limited   = &busyWorker{item: time.Millisecond}
+			worker    = flexsched.Limit(limited, 10*time.Millisecond, 50*time.Millisecond)
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		go unlimited.Run(ctx)
+		if err := worker.Run(ctx); err != nil {
+			t.Error(err)
+		}
+
+		// 4 intervals of 10ms of work, allowing for the item overrunning the budget.
+		if n := limited.items.Load(); n > 4*11+4 {
+			t.Errorf("expected at most %d items to be worked on, but got %d", 4*11+4, n)
+		}
+		if limited.items.Load() >= unlimited.items.Load() {
+			t.Errorf("expected the limited worker to work on less items than the unlimited one, but got %d and %d",
+				limited.items.Load(), unlimited.items.Load())
+		}
+		if worker.Throttled() == 0 {
+			t.Error("expected the worker to have been throttled")
+		}
+	})
+	t.Run("a worker within its budget must not be throttled", func(t *testing.T) {
+		t.Parallel()
+
+		worker := flexsched.Limit(&busyWorker{item: time.Microsecond}, time.Second, time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if err := worker.Run(ctx); err != nil {
+			t.Error(err)
+		}
+		if worker.Throttled() != 0 {
+			t.Errorf("expected no throttling but got %s", worker.Throttled())
+		}
+	})
+	t.Run("yielding must return once the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := flexsched.Yield(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v but got: %v", context.Canceled, err)
+		}
+		if err := flexsched.Yield(context.Background()); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+}
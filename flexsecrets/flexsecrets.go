@@ -0,0 +1,303 @@
+// Package flexsecrets provides a flex worker fetching credentials
+// periodically, such as database passwords leased from Vault or rotated by
+// AWS Secrets Manager, and notifying the workers using them of rotations.
+//
+// The worker does not depend on any secret store, instead it drives any type
+// satisfying Fetcher, typically a function calling its client:
+//
+//	creds := flexsecrets.New(flexsecrets.FetcherFunc[Credentials](func(ctx context.Context) (flexsecrets.Secret[Credentials], error) {
+//		s, err := vault.Logical().ReadWithContext(ctx, "database/creds/api")
+//		if err != nil {
+//			return flexsecrets.Secret[Credentials]{}, err
+//		}
+//		return flexsecrets.Secret[Credentials]{
+//			Value: Credentials{User: s.Data["username"].(string), Password: s.Data["password"].(string)},
+//			Lease: s.LeaseID,
+//			TTL:   time.Duration(s.LeaseDuration) * time.Second,
+//		}, nil
+//	}))
+//	creds.OnRotate(func(ctx context.Context, c Credentials) error {
+//		return pool.Reconnect(ctx, c)
+//	})
+//
+//	flex.MustStart(ctx, creds, api)
+//
+// Secrets with a TTL are fetched again once two thirds of it have elapsed,
+// others every interval, see WithInterval. Fetchers of leased secrets may
+// implement Revoker, so that the lease of the last secret is revoked once the
+// worker halts.
+package flexsecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultInterval is how often secrets without a TTL are fetched when no
+// interval is configured.
+const DefaultInterval = 5 * time.Minute
+
+// DefaultRetryInterval is how long the worker waits before fetching again a
+// secret which failed to be fetched when no retry interval is configured.
+const DefaultRetryInterval = 10 * time.Second
+
+// Secret is a credential fetched by a Fetcher.
+type Secret[T any] struct {
+	// Value is the credential.
+	Value T
+	// Lease identifies the lease of the secret, if any, see Revoker.
+	Lease string
+	// TTL is how long the secret is valid for, zero if unknown.
+	TTL time.Duration
+}
+
+// Fetcher fetches a secret from its store.
+type Fetcher[T any] interface {
+	Fetch(ctx context.Context) (Secret[T], error)
+}
+
+// FetcherFunc is a function satisfying Fetcher.
+type FetcherFunc[T any] func(ctx context.Context) (Secret[T], error)
+
+// Fetch calls f.
+func (f FetcherFunc[T]) Fetch(ctx context.Context) (Secret[T], error) { return f(ctx) }
+
+// Revoker is implemented by fetchers of leased secrets, whose lease is
+// revoked once the worker halts.
+type Revoker interface {
+	Revoke(ctx context.Context, lease string) error
+}
+
+// File returns a Fetcher reading the secret held by the file at path, such as
+// a mounted Kubernetes secret.
+func File(path string) Fetcher[[]byte] {
+	return FetcherFunc[[]byte](func(context.Context) (Secret[[]byte], error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Secret[[]byte]{}, fmt.Errorf("flexsecrets: %w", err)
+		}
+		return Secret[[]byte]{Value: b}, nil
+	})
+}
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	interval      time.Duration
+	retryInterval time.Duration
+	onError       func(error)
+}
+
+// WithInterval sets how often secrets without a TTL are fetched.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) { o.interval = d }
+}
+
+// WithRetryInterval sets how long the worker waits before fetching again a
+// secret which failed to be fetched.
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) { o.retryInterval = d }
+}
+
+// WithErrorHandler sets the function called with the errors of the fetches
+// following the first one and of the rotation callbacks, which are logged to
+// the logger of the manager by default, see flex.LoggerFrom.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker fetching a secret of type T periodically.
+type Worker[T any] struct {
+	fetcher Fetcher[T]
+	opts    options
+
+	mu          sync.RWMutex
+	secret      Secret[T]
+	fetched     bool
+	callbacks   []func(context.Context, T) error
+	subscribers []chan T
+	closed      bool
+	stop        context.CancelFunc
+	done        chan struct{}
+}
+
+// New returns a Worker fetching secrets with fetcher.
+func New[T any](fetcher Fetcher[T], opts ...Option) *Worker[T] {
+	w := &Worker[T]{
+		fetcher: fetcher,
+		opts: options{
+			interval:      DefaultInterval,
+			retryInterval: DefaultRetryInterval,
+		},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Current returns the secret last fetched, and whether one was.
+func (w *Worker[T]) Current() (T, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.secret.Value, w.fetched
+}
+
+// OnRotate registers fn to be called with every secret fetched which differs
+// from the previous one, in the order the functions were registered. It should
+// be called before the worker runs.
+func (w *Worker[T]) OnRotate(fn func(ctx context.Context, secret T) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Subscribe returns a channel receiving every secret fetched which differs
+// from the previous one. The channel holds a single secret, replaced by newer
+// ones when it is not received in time, and is closed once the worker halts.
+func (w *Worker[T]) Subscribe() <-chan T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch := make(chan T, 1)
+	if w.closed {
+		close(ch)
+		return ch
+	}
+	w.subscribers = append(w.subscribers, ch)
+	return ch
+}
+
+// Run fetches the secret, failing if it cannot, and then fetches it again
+// periodically until the context is done or Halt is called. The worker
+// reports itself ready once the secret is fetched.
+func (w *Worker[T]) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	defer stop()
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop, w.done = stop, done
+	w.mu.Unlock()
+
+	next, err := w.refresh(ctx)
+	if err != nil {
+		return fmt.Errorf("flexsecrets: fetch: %w", err)
+	}
+
+	flex.Ready(ctx)
+
+	timer := time.NewTimer(next)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			next, err := w.refresh(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				w.report(ctx, fmt.Errorf("flexsecrets: fetch: %w", err))
+				next = w.opts.retryInterval
+			}
+			timer.Reset(next)
+		}
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once the secret is
+// fetched, see flex.ReadyReporter.
+func (w *Worker[T]) ReportsReady() bool { return true }
+
+// Halt stops fetching the secret, waiting for the fetch in progress, if any,
+// to return, closes the channels of the subscribers and then revokes the
+// lease of the last secret if the fetcher implements Revoker.
+func (w *Worker[T]) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+
+	if done != nil {
+		stop()
+		<-done
+	}
+
+	w.mu.Lock()
+	lease := w.secret.Lease
+	w.secret.Lease = ""
+	for _, ch := range w.subscribers {
+		close(ch)
+	}
+	w.subscribers, w.closed = nil, true
+	w.mu.Unlock()
+
+	revoker, ok := w.fetcher.(Revoker)
+	if !ok || lease == "" {
+		return nil
+	}
+	if err := revoker.Revoke(ctx, lease); err != nil {
+		return fmt.Errorf("flexsecrets: revoke: %w", err)
+	}
+	return nil
+}
+
+// refresh fetches the secret, notifies the subscribers if it was rotated and
+// returns when to fetch it next.
+func (w *Worker[T]) refresh(ctx context.Context) (time.Duration, error) {
+	secret, err := w.fetcher.Fetch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	rotated := !w.fetched || !reflect.DeepEqual(w.secret.Value, secret.Value)
+	w.secret, w.fetched = secret, true
+	callbacks := w.callbacks
+	if rotated {
+		for _, ch := range w.subscribers {
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- secret.Value
+		}
+	}
+	w.mu.Unlock()
+
+	if rotated {
+		var errs []error
+		for _, fn := range callbacks {
+			errs = append(errs, fn(ctx, secret.Value))
+		}
+		if err := errors.Join(errs...); err != nil {
+			w.report(ctx, fmt.Errorf("flexsecrets: rotate: %w", err))
+		}
+	}
+
+	if secret.TTL > 0 {
+		return secret.TTL * 2 / 3, nil
+	}
+	return w.opts.interval, nil
+}
+
+// report passes err to the error handler, or logs it to the logger of the
+// manager running the worker if there is none.
+func (w *Worker[T]) report(ctx context.Context, err error) {
+	if w.opts.onError != nil {
+		w.opts.onError(err)
+		return
+	}
+	flex.LoggerFrom(ctx).Log(ctx, slog.LevelError, "secret refresh failed", "error", err)
+}
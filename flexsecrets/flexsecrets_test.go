@@ -0,0 +1,195 @@
+package flexsecrets_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexsecrets"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// errorLogger sends the errors of the entries it is given.
+type errorLogger chan error
+
+func (l errorLogger) Log(_ context.Context, _ slog.Level, _ string, args ...any) {
+	for _, attr := range flex.Attrs(args...) {
+		if err, ok := attr.Value.Any().(error); ok && attr.Key == "error" {
+			select {
+			case l <- err:
+			default:
+			}
+		}
+	}
+}
+
+// mockStore hands out numbered passwords, each with its own lease.
+type mockStore struct {
+	ttl time.Duration
+	err error
+
+	mu      sync.Mutex
+	fetches int
+	revoked []string
+}
+
+func (s *mockStore) Fetch(context.Context) (flexsecrets.Secret[string], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return flexsecrets.Secret[string]{}, s.err
+	}
+	s.fetches++
+	n := string(rune('0' + s.fetches))
+	return flexsecrets.Secret[string]{Value: "password-" + n, Lease: "lease-" + n, TTL: s.ttl}, nil
+}
+
+func (s *mockStore) Revoke(_ context.Context, lease string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = append(s.revoked, lease)
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("rotations must be notified and the last lease revoked", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{ttl: 30 * time.Millisecond}
+		w := flexsecrets.New[string](store)
+
+		rotated := make(chan string, 10)
+		w.OnRotate(func(_ context.Context, secret string) error {
+			rotated <- secret
+			return nil
+		})
+		updates := w.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		for _, expected := range []string{"password-1", "password-2"} {
+			select {
+			case got := <-rotated:
+				if got != expected {
+					t.Errorf("expected %q but got: %q", expected, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected %q to be notified", expected)
+			}
+		}
+		if got := <-updates; got != "password-2" {
+			t.Errorf("expected subscribers to receive %q but got: %q", "password-2", got)
+		}
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+		if err := <-errC; err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+		if _, ok := <-updates; ok {
+			t.Error("expected the subscription to be closed")
+		}
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if current, _ := w.Current(); len(store.revoked) != 1 || store.revoked[0] != "lease-"+current[len("password-"):] {
+			t.Errorf("expected the last lease to be revoked but got: %v", store.revoked)
+		}
+	})
+	t.Run("a secret failing to be fetched first must fail", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("boom")
+		w := flexsecrets.New[string](&mockStore{err: boom})
+		if err := w.Run(context.Background()); !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+		if _, ok := w.Current(); ok {
+			t.Error("expected no secret")
+		}
+	})
+	t.Run("later failures must be retried", func(t *testing.T) {
+		t.Parallel()
+
+		store := &mockStore{}
+		errs := make(chan error, 10)
+		w := flexsecrets.New[string](store,
+			flexsecrets.WithInterval(10*time.Millisecond),
+			flexsecrets.WithRetryInterval(10*time.Millisecond),
+			flexsecrets.WithErrorHandler(func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = w.Run(ctx) }()
+
+		updates := w.Subscribe()
+		<-updates
+
+		boom := errors.New("boom")
+		store.mu.Lock()
+		store.err = boom
+		store.mu.Unlock()
+
+		if err := <-errs; !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+
+		store.mu.Lock()
+		store.err = nil
+		store.mu.Unlock()
+
+		select {
+		case <-updates:
+		case <-time.After(time.Second):
+			t.Error("expected the secret to be fetched again")
+		}
+	})
+	t.Run("rotation failures must be logged to the logger of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("pool refused the password")
+		w := flexsecrets.New[string](&mockStore{})
+		w.OnRotate(func(context.Context, string) error { return boom })
+
+		logger := make(errorLogger, 10)
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger))
+		m.Add(w)
+		h := flextest.Start(t, m)
+		defer h.Stop()
+
+		select {
+		case err := <-logger:
+			if !errors.Is(err, boom) {
+				t.Errorf("expected %v but got: %v", boom, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the rotation failure to be logged")
+		}
+	})
+	t.Run("a file must be read", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "password")
+		if err := os.WriteFile(path, []byte("hunter2"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		secret, err := flexsecrets.File(path).Fetch(context.Background())
+		if err != nil || string(secret.Value) != "hunter2" {
+			t.Errorf("expected %q but got: %q, %v", "hunter2", secret.Value, err)
+		}
+	})
+}
@@ -0,0 +1,360 @@
+// Package flexservicebus provides a flex worker for receiving messages from
+// Azure Service Bus queues and subscriptions.
+//
+// The worker does not depend on the Azure SDK, instead it works with any type
+// satisfying Receiver or SessionReceiver. Adapting the SDK's receivers only
+// requires dropping the options arguments:
+//
+//	type receiver struct{ *azservicebus.Receiver }
+//
+//	func (r receiver) ReceiveMessages(ctx context.Context, n int) ([]*azservicebus.ReceivedMessage, error) {
+//		return r.Receiver.ReceiveMessages(ctx, n, nil)
+//	}
+//
+//	func (r receiver) CompleteMessage(ctx context.Context, msg *azservicebus.ReceivedMessage) error {
+//		return r.Receiver.CompleteMessage(ctx, msg, nil)
+//	}
+//
+//	// ... and likewise for AbandonMessage and RenewMessageLock.
+package flexservicebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxMessages is the default number of messages received at once.
+	DefaultMaxMessages = 10
+	// DefaultLockRenewalInterval is the default interval at which the locks of
+	// messages and sessions being handled are renewed.
+	DefaultLockRenewalInterval = 10 * time.Second
+	// DefaultSessionIdleTimeout is the default time after which a session
+	// without messages is released so that another one can be accepted.
+	DefaultSessionIdleTimeout = 30 * time.Second
+	// DefaultDrainTimeout is the default time given to in-flight messages to be
+	// handled during Halt.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+var logger = log.New(os.Stderr, "flexservicebus: ", 0)
+
+// Receiver receives and settles messages of type M.
+type Receiver[M any] interface {
+	ReceiveMessages(ctx context.Context, maxMessages int) ([]M, error)
+	CompleteMessage(ctx context.Context, msg M) error
+	AbandonMessage(ctx context.Context, msg M) error
+	RenewMessageLock(ctx context.Context, msg M) error
+	Close(ctx context.Context) error
+}
+
+// SessionReceiver is a Receiver locked to a single session.
+type SessionReceiver[M any] interface {
+	Receiver[M]
+	RenewSessionLock(ctx context.Context) error
+}
+
+// SessionAcceptor accepts the next available session, blocking until one is
+// available or the context is done.
+type SessionAcceptor[M any] func(ctx context.Context) (SessionReceiver[M], error)
+
+// Handler handles a single message.
+// The message is completed when the handler returns nil, and abandoned when
+// it returns an error or panics.
+type Handler[M any] func(ctx context.Context, msg M) error
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	maxMessages         int
+	maxSessions         int
+	lockRenewalInterval time.Duration
+	sessionIdleTimeout  time.Duration
+	drainTimeout        time.Duration
+	onError             func(error)
+}
+
+// WithMaxMessages sets how many messages are received, and handled
+// concurrently, at once.
+func WithMaxMessages(n int) Option {
+	return func(o *options) { o.maxMessages = n }
+}
+
+// WithMaxSessions sets how many sessions are handled concurrently.
+// It only applies to workers created with NewSession.
+func WithMaxSessions(n int) Option {
+	return func(o *options) { o.maxSessions = n }
+}
+
+// WithLockRenewalInterval sets the interval at which the locks of messages and
+// sessions being handled are renewed. It must be shorter than the lock
+// duration configured on the entity.
+func WithLockRenewalInterval(d time.Duration) Option {
+	return func(o *options) { o.lockRenewalInterval = d }
+}
+
+// WithSessionIdleTimeout sets how long a session may go without messages
+// before it is released.
+func WithSessionIdleTimeout(d time.Duration) Option {
+	return func(o *options) { o.sessionIdleTimeout = d }
+}
+
+// WithDrainTimeout sets how long in-flight messages are given to be handled
+// and settled once the worker is halted, unless the context given to Halt
+// expires first.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithErrorHandler sets the function called with the panics of the handler,
+// and the errors of settling messages and renewing locks, which are logged by
+// default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker which receives messages and dispatches them to a
+// handler, renewing their locks while they are being handled.
+type Worker[M any] struct {
+	receiver Receiver[M]
+	accept   SessionAcceptor[M]
+	handler  Handler[M]
+	opts     options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Worker receiving messages from a non-session receiver.
+func New[M any](receiver Receiver[M], handler Handler[M], opts ...Option) *Worker[M] {
+	return newWorker(receiver, nil, handler, opts)
+}
+
+// NewSession returns a Worker handling sessions accepted by accept, one after
+// the other, up to the configured maximum number of concurrent sessions.
+func NewSession[M any](accept SessionAcceptor[M], handler Handler[M], opts ...Option) *Worker[M] {
+	return newWorker(nil, accept, handler, opts)
+}
+
+func newWorker[M any](receiver Receiver[M], accept SessionAcceptor[M], handler Handler[M], opts []Option) *Worker[M] {
+	w := &Worker[M]{
+		receiver: receiver,
+		accept:   accept,
+		handler:  handler,
+		opts: options{
+			maxMessages:         DefaultMaxMessages,
+			maxSessions:         1,
+			lockRenewalInterval: DefaultLockRenewalInterval,
+			sessionIdleTimeout:  DefaultSessionIdleTimeout,
+			drainTimeout:        DefaultDrainTimeout,
+			onError:             func(err error) { logger.Print(err) },
+		},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run receives messages until the context is done or Halt is called.
+// Handlers are given a context which is not cancelled when receiving stops,
+// so that in-flight messages can be handled and settled during Halt.
+func (w *Worker[M]) Run(ctx context.Context) error {
+	receiveCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop, w.abort, w.done = stop, abort, done
+	w.mu.Unlock()
+
+	if w.accept == nil {
+		return w.receive(receiveCtx, handlerCtx, w.receiver, 0)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errC = make(chan error, w.opts.maxSessions)
+	)
+	for range w.opts.maxSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.sessions(receiveCtx, handlerCtx); err != nil {
+				errC <- err
+				stop()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errC)
+
+	return <-errC
+}
+
+// Halt stops receiving messages, waits for in-flight messages to be handled
+// and settled for at most the drain timeout, or until the deadline of ctx if
+// it is earlier, then closes the receiver within what remains of that time.
+func (w *Worker[M]) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	stop, abort, done := w.stop, w.abort, w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := w.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		abort()
+		<-done
+		err = fmt.Errorf("flexservicebus: in-flight messages were not settled within %s", timeout)
+	}
+
+	if w.receiver != nil {
+		if closeErr := w.receiver.Close(ctx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("flexservicebus: close receiver: %w", closeErr))
+		}
+	}
+	return err
+}
+
+// sessions accepts and handles sessions until the context is done.
+func (w *Worker[M]) sessions(ctx, handlerCtx context.Context) error {
+	for {
+		session, err := w.accept(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("flexservicebus: accept session: %w", err)
+		}
+
+		err = w.session(ctx, handlerCtx, session)
+		if closeErr := session.Close(handlerCtx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("flexservicebus: close session: %w", closeErr))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// session handles the messages of a single session, renewing the session
+// lock, until the session goes idle or the context is done.
+func (w *Worker[M]) session(ctx, handlerCtx context.Context, session SessionReceiver[M]) error {
+	renewCtx, stopRenewal := context.WithCancel(handlerCtx)
+	defer stopRenewal()
+	go w.renew(renewCtx, "session lock", session.RenewSessionLock)
+
+	return w.receive(ctx, handlerCtx, session, w.opts.sessionIdleTimeout)
+}
+
+// receive receives and handles batches of messages until the context is done,
+// or until no messages were received within idleTimeout, when it is non-zero.
+func (w *Worker[M]) receive(ctx, handlerCtx context.Context, r Receiver[M], idleTimeout time.Duration) error {
+	for {
+		receiveCtx, cancel := ctx, context.CancelFunc(func() {})
+		if idleTimeout > 0 {
+			receiveCtx, cancel = context.WithTimeout(ctx, idleTimeout)
+		}
+		msgs, err := r.ReceiveMessages(receiveCtx, w.opts.maxMessages)
+		cancel()
+
+		w.handleAll(handlerCtx, r, msgs, idleTimeout == 0)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case idleTimeout > 0 && len(msgs) == 0 && errors.Is(err, context.DeadlineExceeded):
+			return nil
+		case err != nil:
+			return fmt.Errorf("flexservicebus: receive messages: %w", err)
+		}
+	}
+}
+
+// handleAll handles a batch of messages concurrently, and waits for all of
+// them to be settled. Message locks are only renewed when renewLocks is set,
+// since messages received through a session are covered by the session lock.
+func (w *Worker[M]) handleAll(ctx context.Context, r Receiver[M], msgs []M, renewLocks bool) {
+	var wg sync.WaitGroup
+	for _, msg := range msgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if renewLocks {
+				renewCtx, stopRenewal := context.WithCancel(ctx)
+				defer stopRenewal()
+				go w.renew(renewCtx, "message lock", func(ctx context.Context) error {
+					return r.RenewMessageLock(ctx, msg)
+				})
+			}
+
+			if w.handle(ctx, msg) {
+				if err := r.CompleteMessage(ctx, msg); err != nil {
+					w.opts.onError(fmt.Errorf("flexservicebus: complete message: %w", err))
+				}
+				return
+			}
+			if err := r.AbandonMessage(ctx, msg); err != nil {
+				w.opts.onError(fmt.Errorf("flexservicebus: abandon message: %w", err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// handle dispatches msg to the handler and reports whether it succeeded.
+// Panics are reported to the error handler.
+func (w *Worker[M]) handle(ctx context.Context, msg M) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.opts.onError(fmt.Errorf("flexservicebus: handler panicked: %v\n%s", r, debug.Stack()))
+			ok = false
+		}
+	}()
+	return w.handler(ctx, msg) == nil
+}
+
+// renew renews the lock with fn at every lock renewal interval until the
+// context is done, reporting the failures other than those caused by the
+// context being done.
+func (w *Worker[M]) renew(ctx context.Context, lock string, fn func(context.Context) error) {
+	ticker := time.NewTicker(w.opts.lockRenewalInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				w.opts.onError(fmt.Errorf("flexservicebus: renew %s: %w", lock, err))
+			}
+		}
+	}
+}
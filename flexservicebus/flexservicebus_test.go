@@ -0,0 +1,206 @@
+package flexservicebus_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexservicebus"
+)
+
+type mockMessage struct {
+	id int
+}
+
+// mockReceiver delivers messages from a channel and records settlements.
+type mockReceiver struct {
+	msgs chan *mockMessage
+
+	mu        sync.Mutex
+	completed []int
+	abandoned []int
+	renewals  atomic.Int32
+	closed    atomic.Bool
+}
+
+func newMockReceiver() *mockReceiver {
+	return &mockReceiver{msgs: make(chan *mockMessage, 10)}
+}
+
+func (r *mockReceiver) ReceiveMessages(ctx context.Context, _ int) ([]*mockMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg := <-r.msgs:
+		return []*mockMessage{msg}, nil
+	}
+}
+
+func (r *mockReceiver) CompleteMessage(_ context.Context, msg *mockMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed = append(r.completed, msg.id)
+	return nil
+}
+
+func (r *mockReceiver) AbandonMessage(_ context.Context, msg *mockMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abandoned = append(r.abandoned, msg.id)
+	return nil
+}
+
+func (r *mockReceiver) RenewMessageLock(context.Context, *mockMessage) error {
+	r.renewals.Add(1)
+	return nil
+}
+
+func (r *mockReceiver) RenewSessionLock(context.Context) error {
+	r.renewals.Add(1)
+	return nil
+}
+
+func (r *mockReceiver) Close(context.Context) error {
+	r.closed.Store(true)
+	return nil
+}
+
+func (r *mockReceiver) settled() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.completed) + len(r.abandoned)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("messages must be completed or abandoned", func(t *testing.T) {
+		t.Parallel()
+
+		r := newMockReceiver()
+		errs := make(chan error, 1)
+		w := flexservicebus.New(r, func(_ context.Context, msg *mockMessage) error {
+			switch msg.id {
+			case 1:
+				return errors.New("failed")
+			case 2:
+				panic("boom")
+			}
+			return nil
+		}, flexservicebus.WithErrorHandler(func(err error) { errs <- err }))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		for id := range 3 {
+			r.msgs <- &mockMessage{id: id}
+		}
+		waitFor(t, func() bool { return r.settled() == 3 })
+
+		cancel()
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		if len(r.completed) != 1 || r.completed[0] != 0 {
+			t.Errorf("expected message 0 to be completed, but got %v", r.completed)
+		}
+		if len(r.abandoned) != 2 {
+			t.Errorf("expected messages 1 and 2 to be abandoned, but got %v", r.abandoned)
+		}
+		if !r.closed.Load() {
+			t.Error("expected the receiver to be closed")
+		}
+		if err := <-errs; !strings.Contains(err.Error(), "handler panicked: boom") {
+			t.Errorf("expected the panic to be reported, but got %v", err)
+		}
+	})
+	t.Run("locks must be renewed while handling and in-flight messages drained", func(t *testing.T) {
+		t.Parallel()
+
+		r := newMockReceiver()
+		started := make(chan struct{})
+		w := flexservicebus.New(r, func(context.Context, *mockMessage) error {
+			close(started)
+			time.Sleep(60 * time.Millisecond)
+			return nil
+		}, flexservicebus.WithLockRenewalInterval(10*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() { _ = w.Run(ctx) }()
+
+		r.msgs <- &mockMessage{}
+		<-started
+
+		cancel()
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if len(r.completed) != 1 {
+			t.Error("expected in-flight message to be completed during halt")
+		}
+		if r.renewals.Load() == 0 {
+			t.Error("expected the message lock to be renewed")
+		}
+	})
+	t.Run("idle sessions must be released and the next one accepted", func(t *testing.T) {
+		t.Parallel()
+
+		sessions := make(chan *mockReceiver, 2)
+		first, second := newMockReceiver(), newMockReceiver()
+		first.msgs <- &mockMessage{id: 1}
+		second.msgs <- &mockMessage{id: 2}
+		sessions <- first
+		sessions <- second
+
+		accept := func(ctx context.Context) (flexservicebus.SessionReceiver[*mockMessage], error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case s := <-sessions:
+				return s, nil
+			}
+		}
+
+		w := flexservicebus.NewSession(accept, func(context.Context, *mockMessage) error { return nil },
+			flexservicebus.WithSessionIdleTimeout(20*time.Millisecond),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		waitFor(t, func() bool { return first.closed.Load() && second.settled() == 1 })
+
+		cancel()
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if first.settled() != 1 {
+			t.Error("expected the first session's message to be settled")
+		}
+		if !second.closed.Load() {
+			t.Error("expected the second session to be closed on halt")
+		}
+	})
+}
@@ -0,0 +1,244 @@
+// Package flexslo tracks the duration of the graceful shutdowns of a flex
+// manager against a service level objective, across restarts of the process,
+// and raises an alert when the objective is not met.
+//
+//	tracker := flexslo.Track(m, flexslo.Objective{Target: 0.95, Threshold: 10 * time.Second},
+//		flexslo.WithStateFile("/var/lib/api/shutdowns.json"),
+//		flexslo.WithAlert(func(r flexslo.Report) {
+//			pager.Trigger(fmt.Sprintf("%.0f%% of shutdowns under %s", r.Compliance*100, r.Objective.Threshold))
+//		}),
+//	)
+//
+//	err := m.Start(ctx)
+//	tracker.Wait()
+//
+// A shutdown lasts from the moment it is requested, by a signal, a failing
+// worker or the cancellation of the manager's context, until every worker has
+// returned from Run and Halt.
+package flexslo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultWindow is how many of the latest shutdowns compliance is computed
+// over when no window is configured.
+const DefaultWindow = 100
+
+var logger = log.New(os.Stderr, "flexslo: ", 0)
+
+// Objective is a shutdown service level objective, such as 95% of shutdowns
+// lasting at most 10 seconds.
+type Objective struct {
+	// Target is the fraction of shutdowns, between 0 and 1, which must last
+	// at most Threshold.
+	Target float64
+	// Threshold is how long a shutdown may last to comply with the objective.
+	Threshold time.Duration
+}
+
+// Report is the compliance of the tracked shutdowns with the objective.
+type Report struct {
+	Objective Objective
+	// Duration is how long the latest shutdown lasted.
+	Duration time.Duration
+	// Shutdowns is the number of shutdowns compliance is computed over.
+	Shutdowns int
+	// Compliance is the fraction of those shutdowns which lasted at most the
+	// threshold of the objective.
+	Compliance float64
+}
+
+// Violated reports whether the compliance is below the target of the objective.
+func (r Report) Violated() bool { return r.Shutdowns > 0 && r.Compliance < r.Objective.Target }
+
+// Option configures a Tracker.
+type Option func(*options)
+
+type options struct {
+	stateFile string
+	window    int
+	alert     func(Report)
+}
+
+// WithStateFile persists the durations of the tracked shutdowns to path, so
+// that compliance is tracked across restarts of the process. Without it, only
+// the shutdowns of the running process are tracked.
+func WithStateFile(path string) Option {
+	return func(o *options) { o.stateFile = path }
+}
+
+// WithWindow sets how many of the latest shutdowns compliance is computed over.
+func WithWindow(n int) Option {
+	return func(o *options) { o.window = n }
+}
+
+// WithAlert sets the function called after every shutdown for as long as the
+// objective is violated.
+func WithAlert(fn func(Report)) Option {
+	return func(o *options) { o.alert = fn }
+}
+
+// state is the content of the state file.
+type state struct {
+	// Shutdowns holds the durations of the latest shutdowns, oldest first.
+	Shutdowns []time.Duration `json:"shutdowns"`
+}
+
+// Tracker tracks the shutdowns of a manager.
+type Tracker struct {
+	objective Objective
+	opts      options
+	done      chan struct{}
+
+	mu     sync.Mutex
+	state  state
+	report Report
+}
+
+// Track starts tracking the shutdowns of m against objective. It must be
+// called before the manager is started, and tracks a single run of it.
+func Track(m *flex.Manager, objective Objective, opts ...Option) *Tracker {
+	t := &Tracker{
+		objective: objective,
+		opts:      options{window: DefaultWindow},
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&t.opts)
+	}
+
+	if err := t.load(); err != nil {
+		logger.Printf("loading state failed, starting afresh: %v", err)
+	}
+	t.report = t.compute(0)
+
+	go t.watch(m.Subscribe())
+	return t
+}
+
+// Wait blocks until the shutdown of the manager has been recorded and
+// persisted, and the alert, if any, has returned.
+func (t *Tracker) Wait() { <-t.done }
+
+// Report returns the compliance with the objective as of the latest
+// recorded shutdown.
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.report
+}
+
+// watch records the shutdown reported by events.
+func (t *Tracker) watch(events <-chan flex.Event) {
+	defer close(t.done)
+
+	var began time.Time
+	for e := range events {
+		switch e.Kind {
+		case flex.EventShutdownBegan:
+			began = e.Time
+		case flex.EventShutdownFinished:
+			if !began.IsZero() {
+				t.record(e.Time.Sub(began))
+			}
+		}
+	}
+}
+
+// record records a shutdown which lasted d, persists it and raises the alert
+// if the objective is violated.
+func (t *Tracker) record(d time.Duration) {
+	t.mu.Lock()
+	t.state.Shutdowns = append(t.state.Shutdowns, d)
+	if excess := len(t.state.Shutdowns) - max(t.opts.window, 1); excess > 0 {
+		t.state.Shutdowns = t.state.Shutdowns[excess:]
+	}
+	t.report = t.compute(d)
+	report := t.report
+	t.mu.Unlock()
+
+	if err := t.save(); err != nil {
+		logger.Printf("saving state failed: %v", err)
+	}
+
+	if report.Violated() && t.opts.alert != nil {
+		t.opts.alert(report)
+	}
+}
+
+// compute returns the report for the recorded shutdowns, the latest of which
+// lasted d. It must be called with mu held.
+func (t *Tracker) compute(d time.Duration) Report {
+	report := Report{Objective: t.objective, Duration: d, Shutdowns: len(t.state.Shutdowns)}
+	if report.Shutdowns == 0 {
+		return report
+	}
+
+	var compliant int
+	for _, shutdown := range t.state.Shutdowns {
+		if shutdown <= t.objective.Threshold {
+			compliant++
+		}
+	}
+	report.Compliance = float64(compliant) / float64(report.Shutdowns)
+	return report
+}
+
+// load reads the state file, if any.
+func (t *Tracker) load() error {
+	if t.opts.stateFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(t.opts.stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &t.state); err != nil {
+		return fmt.Errorf("flexslo: decode %s: %w", t.opts.stateFile, err)
+	}
+	return nil
+}
+
+// save atomically replaces the state file, if any, with the current state.
+func (t *Tracker) save() error {
+	if t.opts.stateFile == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	b, err := json.Marshal(t.state)
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(t.opts.stateFile), filepath.Base(t.opts.stateFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), t.opts.stateFile)
+}
@@ -0,0 +1,104 @@
+package flexslo_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexslo"
+)
+
+// slowWorker takes delay to halt.
+type slowWorker struct{ delay time.Duration }
+
+func (s slowWorker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s slowWorker) Halt(context.Context) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+// shutdown runs a manager with a worker taking delay to halt, tracking its
+// shutdown with the given options.
+func shutdown(t *testing.T, delay time.Duration, opts ...flexslo.Option) flexslo.Report {
+	t.Helper()
+
+	m := flex.New(flex.WithSignals())
+	m.Add(slowWorker{delay: delay})
+
+	tracker := flexslo.Track(m, flexslo.Objective{Target: 0.5, Threshold: 20 * time.Millisecond}, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Wait()
+	return tracker.Report()
+}
+
+func TestTrack(t *testing.T) {
+	t.Run("shutdowns must be tracked across restarts", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			stateFile = filepath.Join(t.TempDir(), "shutdowns.json")
+			alerts    []flexslo.Report
+			opts      = []flexslo.Option{
+				flexslo.WithStateFile(stateFile),
+				flexslo.WithAlert(func(r flexslo.Report) { alerts = append(alerts, r) }),
+			}
+		)
+
+		report := shutdown(t, 0, opts...)
+		if report.Shutdowns != 1 || report.Compliance != 1 || report.Violated() {
+			t.Errorf("unexpected report after a fast shutdown: %+v", report)
+		}
+
+		report = shutdown(t, 50*time.Millisecond, opts...)
+		if report.Shutdowns != 2 || report.Compliance != 0.5 || report.Violated() {
+			t.Errorf("unexpected report after a slow shutdown: %+v", report)
+		}
+		if report.Duration < 50*time.Millisecond {
+			t.Errorf("expected the shutdown to last at least %s but got: %s", 50*time.Millisecond, report.Duration)
+		}
+		if len(alerts) != 0 {
+			t.Errorf("expected no alert while the objective is met, but got: %+v", alerts)
+		}
+
+		report = shutdown(t, 50*time.Millisecond, opts...)
+		if report.Shutdowns != 3 || !report.Violated() {
+			t.Errorf("expected the objective to be violated but got: %+v", report)
+		}
+		if len(alerts) != 1 || alerts[0] != report {
+			t.Errorf("expected a single alert for %+v but got: %+v", report, alerts)
+		}
+	})
+	t.Run("only the shutdowns within the window must be tracked", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			stateFile = filepath.Join(t.TempDir(), "shutdowns.json")
+			opts      = []flexslo.Option{flexslo.WithStateFile(stateFile), flexslo.WithWindow(1)}
+		)
+
+		shutdown(t, 50*time.Millisecond, opts...)
+		report := shutdown(t, 0, opts...)
+		if report.Shutdowns != 1 || report.Compliance != 1 {
+			t.Errorf("expected the slow shutdown to fall out of the window, but got: %+v", report)
+		}
+	})
+	t.Run("shutdowns must be tracked without a state file", func(t *testing.T) {
+		t.Parallel()
+
+		if report := shutdown(t, 0); report.Shutdowns != 1 {
+			t.Errorf("expected %d shutdown but got: %d", 1, report.Shutdowns)
+		}
+	})
+}
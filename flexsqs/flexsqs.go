@@ -0,0 +1,300 @@
+// Package flexsqs provides a flex worker long-polling an Amazon SQS queue.
+//
+// The worker does not depend on the AWS SDK, instead it works with any type
+// satisfying Queue, which is typically a thin shim over the SQS client of
+// aws-sdk-go-v2:
+//
+//	type queue struct {
+//		client *sqs.Client
+//		url    string
+//	}
+//
+//	func (q queue) ReceiveMessages(ctx context.Context, max int, wait, visibility time.Duration) ([]types.Message, error) {
+//		out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+//			QueueUrl:            &q.url,
+//			MaxNumberOfMessages: int32(max),
+//			WaitTimeSeconds:     int32(wait.Seconds()),
+//			VisibilityTimeout:   int32(visibility.Seconds()),
+//		})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return out.Messages, nil
+//	}
+//
+//	// ... and likewise for DeleteMessage and ChangeMessageVisibility.
+//
+//	flex.MustStart(ctx, flexsqs.New(queue{client, url}, handleOrder, flexsqs.WithConcurrency(32)))
+//
+// Messages are only received while the worker has capacity to handle them,
+// so that they do not sit leased in memory, and the visibility timeout of
+// messages being handled is extended until they are.
+package flexsqs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultConcurrency is how many messages are handled concurrently when
+	// no concurrency is configured.
+	DefaultConcurrency = 10
+	// DefaultWaitTime is how long a receive waits for messages when no wait
+	// time is configured, the longest SQS allows.
+	DefaultWaitTime = 20 * time.Second
+	// DefaultVisibilityTimeout is the visibility timeout of received messages,
+	// extended while they are being handled, when none is configured.
+	DefaultVisibilityTimeout = 30 * time.Second
+	// DefaultDrainTimeout is how long in-flight messages are given to be
+	// handled during Halt when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// maxMessages is the most messages SQS returns from a single receive.
+const maxMessages = 10
+
+// Queue is an SQS queue holding messages of type M.
+type Queue[M any] interface {
+	// ReceiveMessages long-polls for up to max messages for at most wait,
+	// leasing them for the visibility timeout.
+	ReceiveMessages(ctx context.Context, max int, wait, visibility time.Duration) ([]M, error)
+	// DeleteMessage deletes a handled message.
+	DeleteMessage(ctx context.Context, msg M) error
+	// ChangeMessageVisibility sets the visibility timeout of msg, counting
+	// from now.
+	ChangeMessageVisibility(ctx context.Context, msg M, visibility time.Duration) error
+}
+
+// Handler handles a single message.
+// The message is deleted when the handler returns nil. When it returns an
+// error or panics, the message becomes visible again once its visibility
+// timeout expires, to be retried or moved to a dead-letter queue.
+type Handler[M any] func(ctx context.Context, msg M) error
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	concurrency       int
+	waitTime          time.Duration
+	visibilityTimeout time.Duration
+	drainTimeout      time.Duration
+}
+
+// WithConcurrency sets how many messages are handled concurrently.
+func WithConcurrency(n int) Option {
+	return func(o *options) { o.concurrency = n }
+}
+
+// WithWaitTime sets how long a receive waits for messages.
+func WithWaitTime(d time.Duration) Option {
+	return func(o *options) { o.waitTime = d }
+}
+
+// WithVisibilityTimeout sets the visibility timeout of received messages,
+// which is extended by as much at every half of it while they are being
+// handled.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(o *options) { o.visibilityTimeout = d }
+}
+
+// WithDrainTimeout sets how long in-flight messages are given to be handled
+// once the worker is halted, after which their handlers' contexts are
+// cancelled and they are made visible again right away.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// Worker is a flex worker which receives messages from a queue and
+// dispatches them to a handler, extending their visibility timeout while
+// they are being handled.
+type Worker[M any] struct {
+	queue   Queue[M]
+	handler Handler[M]
+	opts    options
+
+	mu    sync.Mutex
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	done  chan struct{}
+}
+
+// New returns a Worker receiving messages from queue.
+func New[M any](queue Queue[M], handler Handler[M], opts ...Option) *Worker[M] {
+	w := &Worker[M]{
+		queue:   queue,
+		handler: handler,
+		opts: options{
+			concurrency:       DefaultConcurrency,
+			waitTime:          DefaultWaitTime,
+			visibilityTimeout: DefaultVisibilityTimeout,
+			drainTimeout:      DefaultDrainTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run receives messages until the context is done or Halt is called, then
+// waits for the messages already received to be handled. The worker reports
+// itself ready right away.
+//
+// Handlers are given a context which is not cancelled when receiving stops,
+// so that leased messages can be handled to completion during Halt. Errors
+// of the queue are returned marked with flex.Recoverable, so that the worker
+// is restarted according to the manager's restart policy.
+func (w *Worker[M]) Run(ctx context.Context) error {
+	receiveCtx, stop := context.WithCancel(ctx)
+	handlerCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	defer abort()
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop, w.abort, w.done = stop, abort, done
+	w.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	slots := make(chan struct{}, w.opts.concurrency)
+	for {
+		n, ok := acquire(receiveCtx, slots)
+		if !ok {
+			return nil
+		}
+
+		msgs, err := w.queue.ReceiveMessages(receiveCtx, n, w.opts.waitTime, w.opts.visibilityTimeout)
+		for range n - len(msgs) {
+			<-slots
+		}
+		if receiveCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return flex.Recoverable(fmt.Errorf("flexsqs: receive messages: %w", err))
+		}
+
+		for _, msg := range msgs {
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				defer func() { <-slots }()
+				w.handle(handlerCtx, msg)
+			}()
+		}
+	}
+}
+
+// acquire blocks until at least one slot is free, then acquires as many free
+// slots as a receive may return messages, and returns how many it acquired.
+func acquire(ctx context.Context, slots chan struct{}) (int, bool) {
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case slots <- struct{}{}:
+	}
+
+	n := 1
+	for n < maxMessages {
+		select {
+		case slots <- struct{}{}:
+			n++
+		default:
+			return n, true
+		}
+	}
+	return n, true
+}
+
+// Halt stops receiving messages, and waits for the messages already received
+// to be handled for at most the drain timeout, or until the deadline of ctx if
+// it is earlier.
+func (w *Worker[M]) Halt(ctx context.Context) error {
+	w.mu.Lock()
+	stop, abort, done := w.stop, w.abort, w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+
+	timeout := w.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return fmt.Errorf("flexsqs: in-flight messages were not handled within %s", timeout)
+	}
+}
+
+// handle dispatches msg to the handler, extending its visibility timeout
+// until it returns, and deletes it if it succeeded. Messages whose handler
+// was aborted are made visible again right away.
+func (w *Worker[M]) handle(ctx context.Context, msg M) {
+	extendCtx, stopExtending := context.WithCancel(ctx)
+	extended := make(chan struct{})
+	go func() {
+		defer close(extended)
+		w.extend(extendCtx, msg)
+	}()
+
+	ok := w.dispatch(ctx, msg)
+	stopExtending()
+	<-extended
+
+	settleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.opts.visibilityTimeout)
+	defer cancel()
+
+	switch {
+	case ok:
+		_ = w.queue.DeleteMessage(settleCtx, msg)
+	case ctx.Err() != nil:
+		_ = w.queue.ChangeMessageVisibility(settleCtx, msg, 0)
+	}
+}
+
+// dispatch dispatches msg to the handler and reports whether it succeeded.
+func (w *Worker[M]) dispatch(ctx context.Context, msg M) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	return w.handler(ctx, msg) == nil
+}
+
+// extend extends the visibility timeout of msg at every half of it until the
+// context is done.
+func (w *Worker[M]) extend(ctx context.Context, msg M) {
+	ticker := time.NewTicker(w.opts.visibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = w.queue.ChangeMessageVisibility(ctx, msg, w.opts.visibilityTimeout)
+		}
+	}
+}
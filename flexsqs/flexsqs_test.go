@@ -0,0 +1,205 @@
+package flexsqs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexsqs"
+)
+
+// mockQueue long-polls a channel of messages and records how they were settled.
+type mockQueue struct {
+	msgs chan int
+	err  error
+
+	mu         sync.Mutex
+	deleted    []int
+	visibility map[int][]time.Duration
+}
+
+func newMockQueue() *mockQueue {
+	return &mockQueue{msgs: make(chan int), visibility: make(map[int][]time.Duration)}
+}
+
+func (q *mockQueue) ReceiveMessages(ctx context.Context, _ int, wait, _ time.Duration) ([]int, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(wait):
+		return nil, nil
+	case msg := <-q.msgs:
+		return []int{msg}, nil
+	}
+}
+
+func (q *mockQueue) DeleteMessage(_ context.Context, msg int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.deleted = append(q.deleted, msg)
+	return nil
+}
+
+func (q *mockQueue) ChangeMessageVisibility(_ context.Context, msg int, visibility time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.visibility[msg] = append(q.visibility[msg], visibility)
+	return nil
+}
+
+func (q *mockQueue) settled() (deleted []int, visibility map[int][]time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.deleted, q.visibility
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("handled messages must be deleted and failed ones kept", func(t *testing.T) {
+		t.Parallel()
+
+		queue := newMockQueue()
+		handled := make(chan struct{}, 3)
+		w := flexsqs.New(queue, func(_ context.Context, msg int) error {
+			defer func() { handled <- struct{}{} }()
+			switch msg {
+			case 1:
+				return errors.New("failed")
+			case 2:
+				panic("boom")
+			}
+			return nil
+		}, flexsqs.WithWaitTime(time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		for msg := range 3 {
+			queue.msgs <- msg
+		}
+		for range 3 {
+			<-handled
+		}
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		deleted, visibility := queue.settled()
+		if len(deleted) != 1 || deleted[0] != 0 {
+			t.Errorf("expected only message 0 to be deleted but got: %v", deleted)
+		}
+		if len(visibility) != 0 {
+			t.Errorf("expected failed messages to be left to their visibility timeout but got: %v", visibility)
+		}
+	})
+	t.Run("no more messages than the concurrency must be received", func(t *testing.T) {
+		t.Parallel()
+
+		queue := newMockQueue()
+		var active, peak atomic.Int32
+		release := make(chan struct{})
+		w := flexsqs.New(queue, func(context.Context, int) error {
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			active.Add(-1)
+			return nil
+		}, flexsqs.WithConcurrency(2), flexsqs.WithWaitTime(time.Millisecond))
+
+		go w.Run(context.Background())
+
+		queue.msgs <- 0
+		queue.msgs <- 1
+		select {
+		case queue.msgs <- 2:
+			t.Error("expected no message to be received while at capacity")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(release)
+		queue.msgs <- 2
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if p := peak.Load(); p > 2 {
+			t.Errorf("expected at most %d messages to be handled concurrently but got: %d", 2, p)
+		}
+	})
+	t.Run("the visibility of messages being handled must be extended", func(t *testing.T) {
+		t.Parallel()
+
+		queue := newMockQueue()
+		handling := make(chan struct{})
+		w := flexsqs.New(queue, func(context.Context, int) error {
+			close(handling)
+			time.Sleep(30 * time.Millisecond)
+			return nil
+		}, flexsqs.WithVisibilityTimeout(20*time.Millisecond), flexsqs.WithWaitTime(time.Millisecond))
+
+		go w.Run(context.Background())
+
+		queue.msgs <- 0
+		<-handling
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+
+		deleted, visibility := queue.settled()
+		if len(deleted) != 1 {
+			t.Errorf("expected the message to be handled once halted but got: %v", deleted)
+		}
+		if len(visibility[0]) == 0 || visibility[0][0] != 20*time.Millisecond {
+			t.Errorf("expected the visibility timeout to be extended but got: %v", visibility[0])
+		}
+	})
+	t.Run("messages outliving the drain timeout must be made visible again", func(t *testing.T) {
+		t.Parallel()
+
+		queue := newMockQueue()
+		handling := make(chan struct{})
+		w := flexsqs.New(queue, func(ctx context.Context, _ int) error {
+			close(handling)
+			<-ctx.Done()
+			return ctx.Err()
+		}, flexsqs.WithDrainTimeout(10*time.Millisecond), flexsqs.WithWaitTime(time.Millisecond))
+
+		go w.Run(context.Background())
+
+		queue.msgs <- 0
+		<-handling
+		if err := w.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+
+		if _, visibility := queue.settled(); len(visibility[0]) != 1 || visibility[0][0] != 0 {
+			t.Errorf("expected the message to be made visible again but got: %v", visibility[0])
+		}
+	})
+	t.Run("queue errors must be recoverable", func(t *testing.T) {
+		t.Parallel()
+
+		queue := newMockQueue()
+		queue.err = errors.New("throttled")
+		w := flexsqs.New(queue, func(context.Context, int) error { return nil })
+
+		if err := w.Run(context.Background()); !errors.Is(err, queue.err) || !flex.IsRecoverable(err) {
+			t.Errorf("expected a recoverable %v but got: %v", queue.err, err)
+		}
+	})
+}
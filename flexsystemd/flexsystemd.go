@@ -0,0 +1,144 @@
+// Package flexsystemd integrates flex with systemd's service notification
+// protocol, so that flex binaries work as Type=notify units with WatchdogSec.
+//
+//	m := flex.New()
+//	m.Add(api)
+//	m.Add(flexsystemd.New(m))
+//	m.MustStart(ctx)
+//
+// The notifier sends READY=1 once every worker of the manager has started,
+// STOPPING=1 as the manager shuts down and, when systemd enables the watchdog,
+// WATCHDOG=1 at half the watchdog interval for as long as the health check
+// passes. When the process was not started by systemd, it does nothing.
+//
+// Workers have started once their Run is entered, unless they wait for Ready,
+// see flex.WithStartedOnReady, so that READY=1 is held until servers are
+// listening, for instance, without requiring every worker to call Ready.
+package flexsystemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+var logger = log.New(os.Stderr, "flexsystemd: ", 0)
+
+// Notify sends state, such as "READY=1", to the notification socket of
+// systemd. It returns false, and no error, when the process was not started
+// with a notification socket.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("flexsystemd: connect to %s: %w", socket, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("flexsystemd: notify %q: %w", state, err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog interval systemd expects this process
+// to ping within, and false when the watchdog is not enabled for it.
+func WatchdogInterval() (time.Duration, bool) {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Option configures a Notifier.
+type Option func(*options)
+
+type options struct {
+	healthCheck func(context.Context) error
+}
+
+// WithHealthCheck sets the check which must pass for watchdog pings to be
+// sent, so that systemd restarts a service which is running but unhealthy.
+// By default, pings are sent for as long as the manager runs.
+func WithHealthCheck(check func(context.Context) error) Option {
+	return func(o *options) { o.healthCheck = check }
+}
+
+// Notifier is a flex worker notifying systemd about the lifecycle of a manager.
+type Notifier struct {
+	m    *flex.Manager
+	opts options
+}
+
+// New returns a Notifier for m, which must be added to m.
+func New(m *flex.Manager, opts ...Option) *Notifier {
+	n := &Notifier{m: m}
+	for _, opt := range opts {
+		opt(&n.opts)
+	}
+	return n
+}
+
+// Run notifies systemd until the context is done.
+func (n *Notifier) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	select {
+	case <-n.m.Started():
+		n.notify("READY=1")
+	case <-ctx.Done():
+	}
+
+	var pings <-chan time.Time
+	if interval, ok := WatchdogInterval(); ok {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.notify("STOPPING=1")
+			return nil
+		case <-pings:
+			if n.opts.healthCheck != nil {
+				if err := n.opts.healthCheck(ctx); err != nil {
+					logger.Printf("health check failed, skipping watchdog ping: %v", err)
+					continue
+				}
+			}
+			n.notify("WATCHDOG=1")
+		}
+	}
+}
+
+// Halt is a no-op, the notifier stops when its context is done.
+func (n *Notifier) Halt(context.Context) error { return nil }
+
+// notify sends state to systemd, logging failures.
+func (n *Notifier) notify(state string) {
+	if _, err := Notify(state); err != nil {
+		logger.Print(err)
+	}
+}
@@ -0,0 +1,188 @@
+//go:build unix
+
+package flexsystemd_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexsystemd"
+)
+
+// listen binds a notification socket and points NOTIFY_SOCKET at it.
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
+
+// next returns the next state sent to conn.
+func next(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+// blockingWorker reports itself as ready and blocks until its context is done.
+type blockingWorker struct{}
+
+func (blockingWorker) Run(ctx context.Context) error {
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (blockingWorker) Halt(context.Context) error { return nil }
+
+// idleWorker blocks until its context is done, without calling Ready.
+type idleWorker struct{}
+
+func (idleWorker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (idleWorker) Halt(context.Context) error { return nil }
+
+func TestNotifier(t *testing.T) {
+	t.Run("the lifecycle of the manager must be notified", func(t *testing.T) {
+		conn := listen(t)
+		t.Setenv("WATCHDOG_USEC", "20000")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(blockingWorker{})
+		m.Add(flexsystemd.New(m))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		if state := next(t, conn); state != "READY=1" {
+			t.Errorf("expected READY=1 but got: %q", state)
+		}
+		if state := next(t, conn); state != "WATCHDOG=1" {
+			t.Errorf("expected WATCHDOG=1 but got: %q", state)
+		}
+
+		cancel()
+		for {
+			state := next(t, conn)
+			if state == "STOPPING=1" {
+				break
+			}
+			if state != "WATCHDOG=1" {
+				t.Fatalf("unexpected state: %q", state)
+			}
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("ready must be notified once workers not calling ready run", func(t *testing.T) {
+		conn := listen(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(idleWorker{})
+		m.Add(flexsystemd.New(m))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		if state := next(t, conn); state != "READY=1" {
+			t.Errorf("expected READY=1 but got: %q", state)
+		}
+
+		cancel()
+		if state := next(t, conn); state != "STOPPING=1" {
+			t.Errorf("expected STOPPING=1 but got: %q", state)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("watchdog pings must stop while the health check fails", func(t *testing.T) {
+		conn := listen(t)
+		t.Setenv("WATCHDOG_USEC", "20000")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(flexsystemd.New(m, flexsystemd.WithHealthCheck(func(context.Context) error {
+			return errors.New("unhealthy")
+		})))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		var states []string
+		for {
+			state := next(t, conn)
+			states = append(states, state)
+			if state == "STOPPING=1" {
+				break
+			}
+		}
+		if got := strings.Join(states, ","); got != "READY=1,STOPPING=1" {
+			t.Errorf("expected no watchdog pings but got: %s", got)
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("nothing must be notified without a notification socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		if ok, err := flexsystemd.Notify("READY=1"); ok || err != nil {
+			t.Errorf("expected no notification but got: %v, %v", ok, err)
+		}
+	})
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	if interval, ok := flexsystemd.WatchdogInterval(); !ok || interval != 30*time.Second {
+		t.Errorf("expected 30s but got: %v, %v", interval, ok)
+	}
+
+	t.Setenv("WATCHDOG_PID", "1")
+	if _, ok := flexsystemd.WatchdogInterval(); ok {
+		t.Error("expected the watchdog to be disabled for another process")
+	}
+}
@@ -0,0 +1,243 @@
+// Package flextest provides helpers for verifying the startup and graceful
+// shutdown of flex workers end to end, against real servers bound to
+// ephemeral ports.
+//
+//	func TestGracefulShutdown(t *testing.T) {
+//		srv := flexgrpc.New(flextest.Addr(t), grpcServer)
+//
+//		m := flex.New()
+//		m.Add(srv)
+//
+//		h := flextest.Start(t, m)
+//		flextest.WaitListening(t, srv.Addr().String())
+//
+//		report := h.Drain(8, func(ctx context.Context) error {
+//			_, err := client.SayHello(ctx, &pb.HelloRequest{})
+//			return err
+//		})
+//		flextest.AssertNoDropped(t, report)
+//	}
+package flextest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultTimeout bounds how long the helpers wait for a worker to start
+// listening, or for a manager to stop.
+const DefaultTimeout = 10 * time.Second
+
+// Addr returns a loopback address with a free ephemeral port, for workers
+// which must be given the address to listen on.
+func Addr(t testing.TB) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("flextest: find a free port: %v", err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().String()
+}
+
+// WaitListening waits until a TCP connection to addr can be established.
+func WaitListening(t testing.TB, addr string) {
+	t.Helper()
+
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("flextest: %s is not listening: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Harness runs a manager in the background for the duration of a test.
+type Harness struct {
+	t      testing.TB
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Start starts m in the background. The manager is stopped when the test
+// ends, if it has not been stopped already.
+func Start(t testing.TB, m *flex.Manager) *Harness {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Harness{t: t, cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(h.done)
+		h.err = m.Start(ctx)
+	}()
+
+	t.Cleanup(func() { _ = h.Stop() })
+	return h
+}
+
+// Stop shuts the manager down and returns the error it stopped with.
+// The test fails if the manager does not stop within DefaultTimeout.
+func (h *Harness) Stop() error {
+	h.cancel()
+	return h.Wait()
+}
+
+// Wait waits for the manager to stop by itself and returns the error it
+// stopped with. The test fails if it does not stop within DefaultTimeout.
+func (h *Harness) Wait() error {
+	select {
+	case <-h.done:
+		return h.err
+	case <-time.After(DefaultTimeout):
+		h.t.Errorf("flextest: the manager did not stop within %s", DefaultTimeout)
+		return nil
+	}
+}
+
+// Request sends a single request to a worker under test, and returns an
+// error when it was not served successfully.
+type Request func(context.Context) error
+
+// DrainReport is the outcome of the requests sent during a drain.
+type DrainReport struct {
+	// Completed is the number of requests served successfully.
+	Completed int
+	// Rejected is the number of requests which could not connect because the
+	// worker was no longer accepting connections, which a graceful shutdown
+	// allows.
+	Rejected int
+	// Dropped holds the errors of the requests which failed otherwise, such as
+	// requests whose connection was reset while they were being served.
+	Dropped []error
+	// Err is the error the manager stopped with.
+	Err error
+}
+
+// settleDelay is how long Drain lets requests reach the worker before
+// shutting the manager down.
+const settleDelay = 10 * time.Millisecond
+
+// Drain sends requests from concurrency goroutines and keeps sending them
+// until the manager has stopped. The manager is shut down once every
+// goroutine has completed a request and sent another, so that requests are
+// in flight as the drain begins; requests should take longer than 10ms to
+// be served for them to still be in flight.
+func (h *Harness) Drain(concurrency int, req Request) DrainReport {
+	h.t.Helper()
+
+	var (
+		mu      sync.Mutex
+		report  DrainReport
+		wg      sync.WaitGroup
+		resent  sync.WaitGroup
+		stopped = make(chan struct{})
+	)
+
+	resent.Add(concurrency)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sent := 0
+			for {
+				if sent++; sent == 2 {
+					resent.Done()
+				}
+				err := req(context.Background())
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					report.Completed++
+				case isDialError(err):
+					report.Rejected++
+				default:
+					report.Dropped = append(report.Dropped, err)
+				}
+				mu.Unlock()
+
+				select {
+				case <-stopped:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	resent.Wait()
+	time.Sleep(settleDelay)
+	report.Err = h.Stop()
+	close(stopped)
+	wg.Wait()
+
+	return report
+}
+
+// isDialError reports whether err happened while connecting, before the
+// request could be sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// AssertNoDropped fails the test when requests were dropped during the
+// drain, or the manager stopped with an error.
+func AssertNoDropped(t testing.TB, r DrainReport) {
+	t.Helper()
+
+	if r.Err != nil {
+		t.Errorf("flextest: the manager stopped with an error: %v", r.Err)
+	}
+	for _, err := range r.Dropped {
+		t.Errorf("flextest: request dropped: %v", err)
+	}
+	if r.Completed == 0 {
+		t.Error("flextest: no request completed")
+	}
+}
+
+// Get returns a Request sending a GET request to url with client, which
+// succeeds when it is answered with a 2xx status and its body is read in full.
+func Get(client *http.Client, url string) Request {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			return err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("flextest: GET %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
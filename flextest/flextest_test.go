@@ -0,0 +1,124 @@
+package flextest_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flextest"
+)
+
+// httpWorker serves a slow handler, and either shuts down gracefully or
+// closes every connection abruptly when halted.
+type httpWorker struct {
+	addr     string
+	graceful bool
+	srv      *http.Server
+}
+
+func newHTTPWorker(addr string, graceful bool) *httpWorker {
+	return &httpWorker{
+		addr:     addr,
+		graceful: graceful,
+		srv: &http.Server{
+			Addr: addr,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(20 * time.Millisecond)
+				w.Write([]byte("ok"))
+			}),
+		},
+	}
+}
+
+func (w *httpWorker) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", w.addr)
+	if err != nil {
+		return err
+	}
+	flex.Ready(ctx)
+
+	if err := w.srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (w *httpWorker) Halt(ctx context.Context) error {
+	if w.graceful {
+		return w.srv.Shutdown(context.WithoutCancel(ctx))
+	}
+	return w.srv.Close()
+}
+
+func TestHarness(t *testing.T) {
+	t.Run("a graceful worker must not drop requests during the drain", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(newHTTPWorker(addr, true))
+
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		report := h.Drain(4, flextest.Get(client, "http://"+addr))
+
+		flextest.AssertNoDropped(t, report)
+	})
+	t.Run("an abrupt worker must drop in-flight requests", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		m := flex.New(flex.WithSignals())
+		m.Add(newHTTPWorker(addr, false))
+
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		report := h.Drain(4, flextest.Get(client, "http://"+addr))
+
+		if len(report.Dropped) == 0 {
+			t.Errorf("expected dropped requests but got: %+v", report)
+		}
+	})
+	t.Run("a worker failing to start must be reported by Wait", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(newHTTPWorker(addr, true))
+
+		if err := flextest.Start(t, m).Wait(); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	addr := flextest.Addr(t)
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	if err := flextest.Get(http.DefaultClient, "http://"+addr)(context.Background()); err == nil {
+		t.Error("expected an error for a 404 but got none")
+	}
+}
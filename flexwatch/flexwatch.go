@@ -0,0 +1,270 @@
+// Package flexwatch provides a flex worker watching files and directories,
+// and calling a function once they change, for example to reload
+// configuration or TLS certificates.
+//
+// The worker does not depend on fsnotify, instead it drives any type
+// satisfying Watcher, which is typically a thin shim over *fsnotify.Watcher:
+//
+//	type watcher struct {
+//		*fsnotify.Watcher
+//		events chan flexwatch.Event
+//	}
+//
+//	func (w watcher) Events() <-chan flexwatch.Event { return w.events }
+//	func (w watcher) Errors() <-chan error           { return w.Watcher.Errors }
+//
+//	open := func() (flexwatch.Watcher, error) {
+//		fw, err := fsnotify.NewWatcher()
+//		if err != nil {
+//			return nil, err
+//		}
+//		w := watcher{fw, make(chan flexwatch.Event)}
+//		go func() {
+//			defer close(w.events)
+//			for e := range fw.Events {
+//				w.events <- flexwatch.Event{Path: e.Name, Op: flexwatch.Op(e.Op)}
+//			}
+//		}()
+//		return w, nil
+//	}
+//
+//	flex.MustStart(ctx, api, flexwatch.New(open, []string{"/etc/myapp"}, func(ctx context.Context, paths []string) error {
+//		return api.Reload(ctx)
+//	}))
+//
+// Files which are replaced rather than written to, such as mounted
+// Kubernetes secrets or certificates renewed by certbot, should be watched
+// through the directory holding them.
+package flexwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// DefaultDebounce is how long changes are collected before calling the
+// function when no debounce is configured.
+const DefaultDebounce = 100 * time.Millisecond
+
+var logger = log.New(os.Stderr, "flexwatch: ", 0)
+
+// Op describes a change to a path. Its values match those of fsnotify.Op.
+type Op uint32
+
+// Changes to a path.
+const (
+	Create Op = 1 << iota
+	Write
+	Remove
+	Rename
+	Chmod
+)
+
+// Event is a change to a path.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Watcher watches paths for changes, such as a shim over *fsnotify.Watcher.
+type Watcher interface {
+	// Add starts watching path, and the entries of path if it is a directory.
+	Add(path string) error
+	// Events returns the changes to the watched paths.
+	Events() <-chan Event
+	// Errors returns the errors of the watcher.
+	Errors() <-chan error
+	// Close stops watching, closing the channels of events and errors.
+	Close() error
+}
+
+// Open opens a new Watcher.
+type Open func() (Watcher, error)
+
+// Func is called with the paths which changed, sorted. When it returns an
+// error marked with flex.Fatal, the worker stops and returns it.
+type Func func(ctx context.Context, paths []string) error
+
+// Option configures a Worker.
+type Option func(*options)
+
+type options struct {
+	debounce  time.Duration
+	recursive bool
+	onError   func(error)
+}
+
+// WithDebounce sets how long changes are collected before calling the
+// function, so that a burst of changes, such as a file being written in
+// several chunks, results in a single call.
+func WithDebounce(d time.Duration) Option {
+	return func(o *options) { o.debounce = d }
+}
+
+// WithRecursive watches the directories under the watched directories,
+// including those created while the worker runs.
+func WithRecursive() Option {
+	return func(o *options) { o.recursive = true }
+}
+
+// WithErrorHandler sets the function called with the errors of the watcher and
+// the function, which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Worker is a flex worker which calls a function whenever the paths it watches
+// change.
+type Worker struct {
+	open     Open
+	paths    []string
+	onChange Func
+	opts     options
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New returns a Worker watching paths with watchers opened by open.
+func New(open Open, paths []string, onChange Func, opts ...Option) *Worker {
+	w := &Worker{
+		open:     open,
+		paths:    paths,
+		onChange: onChange,
+		opts: options{
+			debounce: DefaultDebounce,
+			onError:  func(err error) { logger.Print(err) },
+		},
+	}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+	return w
+}
+
+// Run watches the paths until the context is done or Halt is called.
+// The worker reports itself ready once every path is watched.
+func (w *Worker) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	defer stop()
+	defer close(done)
+
+	w.mu.Lock()
+	w.stop, w.done = stop, done
+	w.mu.Unlock()
+
+	watcher, err := w.open()
+	if err != nil {
+		return fmt.Errorf("flexwatch: open watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, path := range w.paths {
+		if err := w.add(watcher, path); err != nil {
+			return err
+		}
+	}
+
+	flex.Ready(ctx)
+
+	timer := time.NewTimer(w.opts.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	errs := watcher.Errors()
+	changed := make(map[string]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-watcher.Events():
+			if !ok {
+				return errors.New("flexwatch: watcher closed")
+			}
+			if w.opts.recursive && e.Op&Create != 0 {
+				if info, err := os.Stat(e.Path); err == nil && info.IsDir() {
+					if err := w.add(watcher, e.Path); err != nil {
+						w.opts.onError(err)
+					}
+				}
+			}
+			changed[e.Path] = struct{}{}
+			timer.Reset(w.opts.debounce)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.opts.onError(fmt.Errorf("flexwatch: %w", err))
+		case <-timer.C:
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			slices.Sort(paths)
+			clear(changed)
+
+			if err := w.onChange(ctx, paths); err != nil {
+				if flex.IsFatal(err) {
+					return err
+				}
+				w.opts.onError(err)
+			}
+		}
+	}
+}
+
+// ReportsReady returns true, as the worker calls flex.Ready once every path is
+// watched, see flex.ReadyReporter.
+func (w *Worker) ReportsReady() bool { return true }
+
+// add watches root, and the directories under it when watching recursively.
+func (w *Worker) add(watcher Watcher, root string) error {
+	if !w.opts.recursive {
+		if err := watcher.Add(root); err != nil {
+			return fmt.Errorf("flexwatch: watch %s: %w", root, err)
+		}
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("flexwatch: watch %s: %w", path, err)
+		}
+		if path != root && !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("flexwatch: watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// Halt stops watching, waiting for a call of the function in progress to
+// return, and closes the watcher.
+func (w *Worker) Halt(context.Context) error {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	stop()
+	<-done
+	return nil
+}
@@ -0,0 +1,152 @@
+package flexwatch_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexwatch"
+)
+
+// mockWatcher records the watched paths and delivers the events it is sent.
+type mockWatcher struct {
+	events chan flexwatch.Event
+	errs   chan error
+
+	mu      sync.Mutex
+	watched []string
+	closed  bool
+}
+
+func newMockWatcher() *mockWatcher {
+	return &mockWatcher{events: make(chan flexwatch.Event), errs: make(chan error)}
+}
+
+func (w *mockWatcher) open() (flexwatch.Watcher, error) { return w, nil }
+
+func (w *mockWatcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watched = append(w.watched, path)
+	return nil
+}
+
+func (w *mockWatcher) Events() <-chan flexwatch.Event { return w.events }
+func (w *mockWatcher) Errors() <-chan error           { return w.errs }
+
+func (w *mockWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *mockWatcher) state() (watched []string, closed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.watched), w.closed
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("changes must be debounced into a single call", func(t *testing.T) {
+		t.Parallel()
+
+		watcher := newMockWatcher()
+		calls := make(chan []string, 2)
+		w := flexwatch.New(watcher.open, []string{"/etc/app"}, func(_ context.Context, paths []string) error {
+			calls <- paths
+			return nil
+		}, flexwatch.WithDebounce(20*time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		watcher.events <- flexwatch.Event{Path: "/etc/app/b.yaml", Op: flexwatch.Write}
+		watcher.events <- flexwatch.Event{Path: "/etc/app/a.yaml", Op: flexwatch.Create}
+		watcher.events <- flexwatch.Event{Path: "/etc/app/b.yaml", Op: flexwatch.Write}
+
+		if paths := <-calls; !slices.Equal(paths, []string{"/etc/app/a.yaml", "/etc/app/b.yaml"}) {
+			t.Errorf("expected both paths but got: %v", paths)
+		}
+		select {
+		case paths := <-calls:
+			t.Errorf("expected a single call but got another with: %v", paths)
+		case <-time.After(40 * time.Millisecond):
+		}
+
+		if err := w.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if watched, closed := watcher.state(); !slices.Equal(watched, []string{"/etc/app"}) || !closed {
+			t.Errorf("expected the path to be watched and the watcher closed but got: %v, %v", watched, closed)
+		}
+	})
+	t.Run("directories must be watched recursively", func(t *testing.T) {
+		t.Parallel()
+
+		root := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "a", "config.yaml"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		watcher := newMockWatcher()
+		called := make(chan struct{})
+		w := flexwatch.New(watcher.open, []string{root}, func(context.Context, []string) error {
+			close(called)
+			return nil
+		}, flexwatch.WithRecursive(), flexwatch.WithDebounce(time.Millisecond))
+		defer w.Halt(context.Background())
+
+		go w.Run(context.Background())
+		for watched, _ := watcher.state(); len(watched) < 3; watched, _ = watcher.state() {
+			time.Sleep(time.Millisecond)
+		}
+
+		created := filepath.Join(root, "c")
+		if err := os.Mkdir(created, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		watcher.events <- flexwatch.Event{Path: created, Op: flexwatch.Create}
+		<-called
+
+		want := []string{root, filepath.Join(root, "a"), filepath.Join(root, "a", "b"), created}
+		if watched, _ := watcher.state(); !slices.Equal(watched, want) {
+			t.Errorf("expected %v to be watched but got: %v", want, watched)
+		}
+	})
+	t.Run("fatal errors must stop the worker", func(t *testing.T) {
+		t.Parallel()
+
+		errReload := errors.New("invalid config")
+		watcher := newMockWatcher()
+		errs := make(chan error, 1)
+		w := flexwatch.New(watcher.open, []string{"/etc/app"}, func(context.Context, []string) error {
+			return flex.Fatal(errReload)
+		}, flexwatch.WithDebounce(time.Millisecond), flexwatch.WithErrorHandler(func(err error) { errs <- err }))
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(context.Background()) }()
+
+		watcher.errs <- errors.New("overflow")
+		if err := <-errs; err == nil {
+			t.Error("expected the watcher error to be handled")
+		}
+
+		watcher.events <- flexwatch.Event{Path: "/etc/app/config.yaml", Op: flexwatch.Write}
+		if err := <-errC; !errors.Is(err, errReload) || !flex.IsFatal(err) {
+			t.Errorf("expected a fatal %v but got: %v", errReload, err)
+		}
+	})
+}
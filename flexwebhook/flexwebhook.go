@@ -0,0 +1,375 @@
+// Package flexwebhook provides a flex worker delivering webhooks.
+//
+// Deliveries are enqueued by the service, and posted by a pool of workers,
+// which retry them with exponential backoff:
+//
+//	webhooks := flexwebhook.New(flexwebhook.WithStore(store))
+//	flex.MustStart(ctx, api, webhooks)
+//
+//	// In a handler:
+//	err := webhooks.Enqueue(ctx, flexwebhook.Delivery{URL: sub.URL, Body: payload})
+//
+// Once halted, the dispatcher stops accepting deliveries and drains its queue
+// until the drain timeout, or the deadline of the context given to Halt,
+// expires. Deliveries which could not be delivered by then are saved to the
+// Store, if any, to be enqueued again once the service restarts.
+package flexwebhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+const (
+	// DefaultWorkers is how many deliveries are posted concurrently when no
+	// number of workers is configured.
+	DefaultWorkers = 4
+	// DefaultQueueSize is how many deliveries may wait to be posted when no
+	// queue size is configured.
+	DefaultQueueSize = 1000
+	// DefaultMaxAttempts is how many times a delivery is posted before being
+	// given up on when no maximum is configured.
+	DefaultMaxAttempts = 5
+	// DefaultMinBackoff is the default delay before the first retry.
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff is the default maximum delay between retries.
+	DefaultMaxBackoff = time.Minute
+	// DefaultDrainTimeout is how long the queue is given to drain during Halt
+	// when no drain timeout is configured.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by Enqueue once the dispatcher is halting.
+var ErrClosed = errors.New("flexwebhook: dispatcher is closed")
+
+var logger = log.New(os.Stderr, "flexwebhook: ", 0)
+
+// Delivery is a webhook to post.
+type Delivery struct {
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Attempts is how many times the delivery was posted already.
+	Attempts int
+}
+
+// Store persists the deliveries which were not delivered when the dispatcher
+// stopped.
+type Store interface {
+	Save(ctx context.Context, deliveries []Delivery) error
+}
+
+// Option configures a Dispatcher.
+type Option func(*options)
+
+type options struct {
+	client       *http.Client
+	workers      int
+	queueSize    int
+	maxAttempts  int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	drainTimeout time.Duration
+	store        Store
+	onError      func(error)
+}
+
+// WithClient sets the client posting deliveries, which defaults to a client
+// with a timeout of 30 seconds.
+func WithClient(client *http.Client) Option {
+	return func(o *options) { o.client = client }
+}
+
+// WithWorkers sets how many deliveries are posted concurrently.
+func WithWorkers(n int) Option {
+	return func(o *options) { o.workers = n }
+}
+
+// WithQueueSize sets how many deliveries may wait to be posted, after which
+// Enqueue blocks.
+func WithQueueSize(n int) Option {
+	return func(o *options) { o.queueSize = n }
+}
+
+// WithMaxAttempts sets how many times a delivery is posted before being given
+// up on.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) { o.maxAttempts = n }
+}
+
+// WithBackoff sets the bounds of the exponential backoff between retries.
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithDrainTimeout sets how long the queue is given to drain once the
+// dispatcher is halted, unless the context given to Halt expires first.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *options) { o.drainTimeout = d }
+}
+
+// WithStore sets the store saving the deliveries which were not delivered
+// when the dispatcher stopped. Without a store, they are lost.
+func WithStore(store Store) Option {
+	return func(o *options) { o.store = store }
+}
+
+// WithErrorHandler sets the function called with the errors of deliveries
+// which are given up on, which are logged by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+// Dispatcher is a flex worker which delivers webhooks.
+type Dispatcher struct {
+	opts  options
+	queue chan Delivery
+
+	stopOnce sync.Once
+	stopping chan struct{}
+	abortCtx context.Context
+	abort    context.CancelFunc
+
+	mu          sync.Mutex
+	done        chan struct{}
+	undelivered []Delivery
+}
+
+// New returns a Dispatcher.
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		opts: options{
+			client:       &http.Client{Timeout: 30 * time.Second},
+			workers:      DefaultWorkers,
+			queueSize:    DefaultQueueSize,
+			maxAttempts:  DefaultMaxAttempts,
+			minBackoff:   DefaultMinBackoff,
+			maxBackoff:   DefaultMaxBackoff,
+			drainTimeout: DefaultDrainTimeout,
+			onError:      func(err error) { logger.Print(err) },
+		},
+		stopping: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+	d.queue = make(chan Delivery, d.opts.queueSize)
+	d.abortCtx, d.abort = context.WithCancel(context.Background())
+	return d
+}
+
+// Enqueue enqueues a delivery, blocking while the queue is full. It fails
+// with ErrClosed once the dispatcher is halting.
+func (d *Dispatcher) Enqueue(ctx context.Context, delivery Delivery) error {
+	select {
+	case <-d.stopping:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case <-d.stopping:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	case d.queue <- delivery:
+		return nil
+	}
+}
+
+// Run posts the enqueued deliveries until the context is done or Halt is
+// called, then drains the queue and saves the deliveries which were not
+// delivered. The worker reports itself ready right away.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	d.mu.Lock()
+	d.done = done
+	d.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			d.stop()
+		case <-done:
+		}
+	}()
+
+	flex.Ready(ctx)
+
+	var workers sync.WaitGroup
+	for range d.opts.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			d.work()
+		}()
+	}
+	workers.Wait()
+
+	// Deliveries enqueued while the workers were stopping are saved as well.
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.keep(delivery)
+			continue
+		default:
+		}
+		break
+	}
+
+	return d.save(context.WithoutCancel(ctx))
+}
+
+// work posts deliveries until the dispatcher stops and its queue is empty,
+// or it is aborted.
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.deliver(delivery)
+		case <-d.stopping:
+			select {
+			case delivery := <-d.queue:
+				d.deliver(delivery)
+			default:
+				return
+			}
+		}
+	}
+}
+
+// deliver posts delivery, retrying it with backoff. Deliveries which are
+// aborted are kept to be saved.
+func (d *Dispatcher) deliver(delivery Delivery) {
+	backoff := d.opts.minBackoff
+	for {
+		if d.abortCtx.Err() != nil {
+			d.keep(delivery)
+			return
+		}
+
+		retry, err := d.post(delivery)
+		delivery.Attempts++
+		if err == nil {
+			return
+		}
+		if d.abortCtx.Err() != nil {
+			d.keep(delivery)
+			return
+		}
+		if !retry || delivery.Attempts >= d.opts.maxAttempts {
+			d.opts.onError(fmt.Errorf("flexwebhook: gave up on %s after %d attempts: %w", delivery.URL, delivery.Attempts, err))
+			return
+		}
+
+		select {
+		case <-d.abortCtx.Done():
+			d.keep(delivery)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, d.opts.maxBackoff)
+	}
+}
+
+// post posts delivery, and reports whether it should be retried if it failed.
+func (d *Dispatcher) post(delivery Delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.abortCtx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return false, err
+	}
+	for key, values := range delivery.Header {
+		req.Header[key] = values
+	}
+
+	resp, err := d.opts.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// keep records delivery as not delivered.
+func (d *Dispatcher) keep(delivery Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.undelivered = append(d.undelivered, delivery)
+}
+
+// save saves the deliveries which were not delivered.
+func (d *Dispatcher) save(ctx context.Context) error {
+	d.mu.Lock()
+	undelivered := d.undelivered
+	d.undelivered = nil
+	d.mu.Unlock()
+
+	if len(undelivered) == 0 {
+		return nil
+	}
+	if d.opts.store == nil {
+		return fmt.Errorf("flexwebhook: %d deliveries were not delivered", len(undelivered))
+	}
+	if err := d.opts.store.Save(ctx, undelivered); err != nil {
+		return fmt.Errorf("flexwebhook: save %d deliveries: %w", len(undelivered), err)
+	}
+	return nil
+}
+
+// stop stops accepting deliveries.
+func (d *Dispatcher) stop() {
+	d.stopOnce.Do(func() { close(d.stopping) })
+}
+
+// Halt stops accepting deliveries, and waits for the queue to drain for at
+// most the drain timeout, or until the deadline of ctx if it is earlier.
+// Deliveries in progress are then aborted, and saved along with those left
+// in the queue.
+func (d *Dispatcher) Halt(ctx context.Context) error {
+	d.stop()
+
+	d.mu.Lock()
+	done := d.done
+	d.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	timeout := d.opts.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		d.abort()
+		<-done
+		return fmt.Errorf("flexwebhook: queue was not drained within %s", timeout)
+	}
+}
@@ -0,0 +1,242 @@
+package flexwebhook_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexwebhook"
+)
+
+// mockStore records the deliveries it saves.
+type mockStore struct {
+	mu    sync.Mutex
+	saved []flexwebhook.Delivery
+}
+
+func (s *mockStore) Save(_ context.Context, deliveries []flexwebhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, deliveries...)
+	return nil
+}
+
+func (s *mockStore) deliveries() []flexwebhook.Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.saved)
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("deliveries must be posted with their headers", func(t *testing.T) {
+		t.Parallel()
+
+		received := make(chan string, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- r.Method + " " + r.Header.Get("X-Signature") + " " + string(body)
+		}))
+		defer srv.Close()
+
+		d := flexwebhook.New()
+		errC := make(chan error, 1)
+		go func() { errC <- d.Run(context.Background()) }()
+
+		err := d.Enqueue(context.Background(), flexwebhook.Delivery{
+			URL:    srv.URL,
+			Header: http.Header{"X-Signature": {"abc"}},
+			Body:   []byte("hello"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := <-received, "POST abc hello"; got != want {
+			t.Errorf("expected %q but got: %q", want, got)
+		}
+
+		if err := d.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("failed deliveries must be retried", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		delivered := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			close(delivered)
+		}))
+		defer srv.Close()
+
+		d := flexwebhook.New(flexwebhook.WithBackoff(time.Millisecond, time.Millisecond))
+		defer d.Halt(context.Background())
+		go d.Run(context.Background())
+
+		if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-delivered:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the delivery to succeed but got %d attempts", attempts.Load())
+		}
+	})
+	t.Run("client errors must not be retried", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		errs := make(chan error, 1)
+		d := flexwebhook.New(flexwebhook.WithBackoff(time.Millisecond, time.Millisecond),
+			flexwebhook.WithErrorHandler(func(err error) { errs <- err }))
+		defer d.Halt(context.Background())
+		go d.Run(context.Background())
+
+		if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL}); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err == nil {
+			t.Error("expected the delivery error to be handled")
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Errorf("expected %d attempts but got: %d", 1, n)
+		}
+	})
+	t.Run("the queue must be drained when halted", func(t *testing.T) {
+		t.Parallel()
+
+		var delivered atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			delivered.Add(1)
+		}))
+		defer srv.Close()
+
+		d := flexwebhook.New(flexwebhook.WithWorkers(1))
+		for range 5 {
+			if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- d.Run(context.Background()) }()
+
+		// Wait for Run to have started before halting.
+		for delivered.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := d.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if n := delivered.Load(); n != 5 {
+			t.Errorf("expected %d deliveries but got: %d", 5, n)
+		}
+		if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL}); !errors.Is(err, flexwebhook.ErrClosed) {
+			t.Errorf("expected %v but got: %v", flexwebhook.ErrClosed, err)
+		}
+	})
+	t.Run("undelivered deliveries must be saved once the drain timeout expires", func(t *testing.T) {
+		t.Parallel()
+
+		posting := make(chan struct{}, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The body must be read for the cancellation of the client to be noticed.
+			_, _ = io.ReadAll(r.Body)
+			select {
+			case posting <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		store := &mockStore{}
+		d := flexwebhook.New(flexwebhook.WithWorkers(1), flexwebhook.WithStore(store),
+			flexwebhook.WithDrainTimeout(20*time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- d.Run(context.Background()) }()
+
+		for _, body := range []string{"a", "b"} {
+			if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL, Body: []byte(body)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		<-posting
+
+		if err := d.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		saved := store.deliveries()
+		if len(saved) != 2 {
+			t.Fatalf("expected %d saved deliveries but got: %d", 2, len(saved))
+		}
+		if saved[0].Attempts != 1 || string(saved[0].Body) != "a" {
+			t.Errorf("expected the aborted delivery to be saved with 1 attempt but got: %+v", saved[0])
+		}
+		if saved[1].Attempts != 0 || string(saved[1].Body) != "b" {
+			t.Errorf("expected the queued delivery to be saved with 0 attempts but got: %+v", saved[1])
+		}
+	})
+	t.Run("the drain must be bounded by the deadline of the halt context", func(t *testing.T) {
+		t.Parallel()
+
+		posting := make(chan struct{}, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The body must be read for the cancellation of the client to be noticed.
+			_, _ = io.ReadAll(r.Body)
+			select {
+			case posting <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		d := flexwebhook.New(flexwebhook.WithStore(&mockStore{}), flexwebhook.WithDrainTimeout(time.Hour))
+		go d.Run(context.Background())
+
+		if err := d.Enqueue(context.Background(), flexwebhook.Delivery{URL: srv.URL}); err != nil {
+			t.Fatal(err)
+		}
+		<-posting
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := d.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected Halt to return by the deadline but it took: %s", elapsed)
+		}
+	})
+}
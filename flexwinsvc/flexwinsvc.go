@@ -0,0 +1,84 @@
+// Package flexwinsvc integrates flex with the Windows service control manager,
+// so that flex binaries can be installed and run as Windows services.
+//
+//	m := flex.New()
+//	m.Add(api)
+//
+//	if err := flexwinsvc.Run(ctx, "my-service", m); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// When the process is started by the service control manager, the service is
+// reported as running once every worker has started, see
+// flex.Manager.Started. Stop and Shutdown control requests halt the workers
+// gracefully, while Pause and Continue requests are forwarded to workers
+// implementing Pauser, and errors they return are logged through the logger
+// of the manager.
+// Otherwise, and on every other platform, Run simply starts the manager.
+//
+// Like the rest of flex, the package does not depend on any module outside
+// of the standard library: rather than golang.org/x/sys/windows/svc, it calls
+// the few functions of advapi32.dll it needs directly, which keeps flex
+// free of dependencies for the users who never build for Windows. The layouts
+// of the structures it shares with advapi32.dll, and the states it reports,
+// are tested on Windows by the continuous integration of flex.
+package flexwinsvc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-flexible/flex"
+)
+
+// ErrNotSupported is returned by Install and Uninstall on platforms other than Windows.
+var ErrNotSupported = errors.New("flexwinsvc: windows services are not supported on this platform")
+
+// Pauser is implemented by workers which can pause and resume their work
+// without being halted.
+type Pauser interface {
+	Pause(context.Context) error
+	Resume(context.Context) error
+}
+
+// Run starts the manager as the Windows service called name when the process
+// was started by the service control manager, and as a regular process otherwise.
+// It returns once the manager has stopped.
+func Run(ctx context.Context, name string, m *flex.Manager) error {
+	return run(ctx, name, m)
+}
+
+// Install registers the running executable as an automatically started
+// Windows service called name, which is run with the given arguments.
+func Install(name, displayName string, args ...string) error {
+	return install(name, displayName, args)
+}
+
+// Uninstall removes the Windows service called name.
+func Uninstall(name string) error {
+	return uninstall(name)
+}
+
+// pausers returns the workers of m implementing Pauser.
+func pausers(m *flex.Manager) []Pauser {
+	var pausers []Pauser
+	for _, worker := range m.Workers() {
+		if pauser, ok := worker.(Pauser); ok {
+			pausers = append(pausers, pauser)
+		}
+	}
+	return pausers
+}
+
+// pauseAll pauses, or resumes, every pauser and returns the joined errors.
+func pauseAll(ctx context.Context, pausers []Pauser, resume bool) error {
+	var errs []error
+	for _, pauser := range pausers {
+		if resume {
+			errs = append(errs, pauser.Resume(ctx))
+		} else {
+			errs = append(errs, pauser.Pause(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package flexwinsvc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flexwinsvc"
+)
+
+type mockWorker struct{ ran bool }
+
+func (m *mockWorker) Run(context.Context) error  { m.ran = true; return nil }
+func (m *mockWorker) Halt(context.Context) error { return nil }
+
+func TestRun(t *testing.T) {
+	t.Run("outside of windows the manager must simply start", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		worker := &mockWorker{}
+		m := flex.New()
+		m.Add(worker)
+
+		if err := flexwinsvc.Run(ctx, "test", m); err != nil {
+			t.Error(err)
+		}
+		if !worker.ran {
+			t.Error("expected the worker to run")
+		}
+	})
+	t.Run("installing must not be supported outside of windows", func(t *testing.T) {
+		t.Parallel()
+
+		if err := flexwinsvc.Install("test", "Test"); !errors.Is(err, flexwinsvc.ErrNotSupported) {
+			t.Errorf("expected %v but got: %v", flexwinsvc.ErrNotSupported, err)
+		}
+		if err := flexwinsvc.Uninstall("test"); !errors.Is(err, flexwinsvc.ErrNotSupported) {
+			t.Errorf("expected %v but got: %v", flexwinsvc.ErrNotSupported, err)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package flexwinsvc
+
+import (
+	"context"
+
+	"github.com/go-flexible/flex"
+)
+
+func run(ctx context.Context, _ string, m *flex.Manager) error {
+	return m.Start(ctx)
+}
+
+func install(string, string, []string) error { return ErrNotSupported }
+
+func uninstall(string) error { return ErrNotSupported }
@@ -0,0 +1,369 @@
+//go:build windows
+
+package flexwinsvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/go-flexible/flex"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+	procOpenSCManagerW                = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW                = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                  = advapi32.NewProc("OpenServiceW")
+	procDeleteService                 = advapi32.NewProc("DeleteService")
+	procCloseServiceHandle            = advapi32.NewProc("CloseServiceHandle")
+)
+
+const (
+	errFailedServiceControllerConnect syscall.Errno = 1063
+	errCallNotImplemented                           = 120
+
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+
+	scManagerAllAccess = 0xF003F
+	serviceAllAccess   = 0xF01FF
+	accessDelete       = 0x10000
+
+	stateStopped         = 1
+	stateStartPending    = 2
+	stateStopPending     = 3
+	stateRunning         = 4
+	stateContinuePending = 5
+	statePausePending    = 6
+	statePaused          = 7
+
+	controlStop        = 1
+	controlPause       = 2
+	controlContinue    = 3
+	controlInterrogate = 4
+	controlShutdown    = 5
+
+	acceptStop          = 1
+	acceptPauseContinue = 2
+	acceptShutdown      = 4
+
+	// pendingWaitHint is how long the service control manager is told to
+	// wait for the next checkpoint of a pending state, which is advanced
+	// every checkPointInterval.
+	pendingWaitHint    = 30 * time.Second
+	checkPointInterval = pendingWaitHint / 3
+)
+
+// serviceTableEntry mirrors SERVICE_TABLE_ENTRYW.
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// serviceStatus mirrors SERVICE_STATUS.
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// service is the service being run. The service control manager calls back
+// into the process from threads of its own, without any way to carry Go
+// values along, so it is kept in a package level variable.
+type service struct {
+	name    *uint16
+	ctx     context.Context
+	cancel  context.CancelFunc
+	m       *flex.Manager
+	pausers []Pauser
+	err     error
+
+	mu     sync.Mutex
+	handle uintptr
+	status serviceStatus
+}
+
+var (
+	current *service
+
+	callbacksOnce   sync.Once
+	serviceMainProc uintptr
+	handlerProc     uintptr
+)
+
+func run(ctx context.Context, name string, m *flex.Manager) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	callbacksOnce.Do(func() {
+		serviceMainProc = syscall.NewCallback(serviceMain)
+		handlerProc = syscall.NewCallback(handler)
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	current = &service{name: namePtr, ctx: ctx, cancel: cancel, m: m, pausers: pausers(m)}
+
+	table := []serviceTableEntry{{name: namePtr, proc: serviceMainProc}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		if errors.Is(err, errFailedServiceControllerConnect) {
+			// Not started by the service control manager.
+			return m.Start(ctx)
+		}
+		return fmt.Errorf("flexwinsvc: start service control dispatcher: %w", err)
+	}
+	return current.err
+}
+
+// serviceMain is called by the service control manager to run the service,
+// and must only return once the service has stopped.
+func serviceMain(_, _ uintptr) uintptr {
+	s := current
+
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(s.name)), handlerProc, 0)
+	if handle == 0 {
+		s.err = fmt.Errorf("flexwinsvc: register service control handler: %w", err)
+		return 0
+	}
+
+	s.mu.Lock()
+	s.handle = handle
+	s.mu.Unlock()
+
+	s.setState(stateStartPending)
+
+	done := make(chan error, 1)
+	go func() { done <- s.m.Start(s.ctx) }()
+
+	// The service is only reported as running once every worker has started,
+	// and the checkpoint of the pending states is advanced until then and
+	// while stopping, so that the service is not considered hung.
+	ticker := time.NewTicker(checkPointInterval)
+	defer ticker.Stop()
+
+	started := s.m.Started()
+	for running := true; running; {
+		select {
+		case <-started:
+			started = nil
+			s.setStateFrom(stateStartPending, stateRunning)
+		case <-ticker.C:
+			s.checkPoint()
+		case s.err = <-done:
+			running = false
+		}
+	}
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.status.win32ExitCode = 1
+	}
+	s.mu.Unlock()
+	s.setState(stateStopped)
+
+	return 0
+}
+
+// handler is called by the service control manager for every control request.
+func handler(control, _, _, _ uintptr) uintptr {
+	s := current
+
+	switch control {
+	case controlStop, controlShutdown:
+		s.setState(stateStopPending)
+		s.cancel()
+	case controlPause:
+		s.setState(statePausePending)
+		go s.pause(false, statePaused)
+	case controlContinue:
+		s.setState(stateContinuePending)
+		go s.pause(true, stateRunning)
+	case controlInterrogate:
+		s.mu.Lock()
+		s.report()
+		s.mu.Unlock()
+	default:
+		return errCallNotImplemented
+	}
+	return 0
+}
+
+// pause pauses or resumes every pauser, then enters state. Errors are logged
+// through the logger of the manager, as the service control manager has no
+// way to be told about them.
+func (s *service) pause(resume bool, state uint32) {
+	if err := pauseAll(s.ctx, s.pausers, resume); err != nil {
+		msg := "pause failed"
+		if resume {
+			msg = "resume failed"
+		}
+		s.m.Logger().Log(s.ctx, slog.LevelError, msg, "error", err)
+	}
+	s.setState(state)
+}
+
+// setState reports the service as being in state to the service control manager.
+func (s *service) setState(state uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enter(state)
+}
+
+// setStateFrom reports the service as being in state, unless it has left the
+// from state meanwhile, such as a service asked to stop while starting.
+func (s *service) setStateFrom(from, state uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.currentState == from {
+		s.enter(state)
+	}
+}
+
+// enter reports the service as being in state, the lock must be held.
+func (s *service) enter(state uint32) {
+	s.status.serviceType = serviceWin32OwnProcess
+	s.status.currentState = state
+	s.status.checkPoint = 0
+	s.status.waitHint = 0
+	s.status.controlsAccepted = 0
+
+	switch state {
+	case stateStartPending, stateStopPending, statePausePending, stateContinuePending:
+		s.status.checkPoint = 1
+		s.status.waitHint = uint32(pendingWaitHint.Milliseconds())
+	case stateRunning, statePaused:
+		s.status.controlsAccepted = acceptStop | acceptShutdown
+		if len(s.pausers) > 0 {
+			s.status.controlsAccepted |= acceptPauseContinue
+		}
+	}
+	s.report()
+}
+
+// checkPoint advances the checkpoint of a pending state and refreshes its
+// wait hint, and does nothing in other states.
+func (s *service) checkPoint() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.status.currentState {
+	case stateStartPending, stateStopPending, statePausePending, stateContinuePending:
+		s.status.checkPoint++
+		s.status.waitHint = uint32(pendingWaitHint.Milliseconds())
+		s.report()
+	}
+}
+
+// report sends the current status to the service control manager, the lock
+// must be held.
+func (s *service) report() {
+	if s.handle != 0 {
+		_, _, _ = procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&s.status)))
+	}
+}
+
+func install(name, displayName string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmdline := []string{syscall.EscapeArg(exe)}
+	for _, arg := range args {
+		cmdline = append(cmdline, syscall.EscapeArg(arg))
+	}
+
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	displayPtr, err := syscall.UTF16PtrFromString(displayName)
+	if err != nil {
+		return err
+	}
+	pathPtr, err := syscall.UTF16PtrFromString(strings.Join(cmdline, " "))
+	if err != nil {
+		return err
+	}
+
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer closeHandle(scm)
+
+	h, _, err := procCreateServiceW.Call(
+		scm,
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(displayPtr)),
+		serviceAllAccess,
+		serviceWin32OwnProcess,
+		serviceAutoStart,
+		serviceErrorNormal,
+		uintptr(unsafe.Pointer(pathPtr)),
+		0, 0, 0, 0, 0,
+	)
+	if h == 0 {
+		return fmt.Errorf("flexwinsvc: create service %q: %w", name, err)
+	}
+	closeHandle(h)
+	return nil
+}
+
+func uninstall(name string) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer closeHandle(scm)
+
+	h, _, err := procOpenServiceW.Call(scm, uintptr(unsafe.Pointer(namePtr)), accessDelete)
+	if h == 0 {
+		return fmt.Errorf("flexwinsvc: open service %q: %w", name, err)
+	}
+	defer closeHandle(h)
+
+	if r, _, err := procDeleteService.Call(h); r == 0 {
+		return fmt.Errorf("flexwinsvc: delete service %q: %w", name, err)
+	}
+	return nil
+}
+
+// openSCManager connects to the service control manager of the local machine.
+func openSCManager() (uintptr, error) {
+	h, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if h == 0 {
+		return 0, fmt.Errorf("flexwinsvc: open service control manager: %w", err)
+	}
+	return h, nil
+}
+
+// closeHandle closes a service control manager or service handle.
+func closeHandle(h uintptr) {
+	_, _, _ = procCloseServiceHandle.Call(h)
+}
@@ -0,0 +1,139 @@
+//go:build windows
+
+package flexwinsvc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-flexible/flex"
+)
+
+type mockPauser struct{ err error }
+
+func (m *mockPauser) Run(context.Context) error    { return nil }
+func (m *mockPauser) Halt(context.Context) error   { return nil }
+func (m *mockPauser) Pause(context.Context) error  { return m.err }
+func (m *mockPauser) Resume(context.Context) error { return m.err }
+
+type recordingLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (r *recordingLogger) Log(_ context.Context, _ slog.Level, msg string, _ ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func TestService(t *testing.T) {
+	waitHint := uint32(pendingWaitHint.Milliseconds())
+
+	t.Run("pending states must start at the first checkpoint", func(t *testing.T) {
+		t.Parallel()
+
+		s := &service{}
+		for _, state := range []uint32{stateStartPending, stateStopPending, statePausePending, stateContinuePending} {
+			s.setState(state)
+			if s.status.currentState != state || s.status.checkPoint != 1 || s.status.waitHint != waitHint || s.status.controlsAccepted != 0 {
+				t.Errorf("unexpected status in state %d: %+v", state, s.status)
+			}
+		}
+	})
+	t.Run("running services must accept pausing only with pausers", func(t *testing.T) {
+		t.Parallel()
+
+		s := &service{}
+		s.setState(stateRunning)
+		if s.status.checkPoint != 0 || s.status.waitHint != 0 || s.status.controlsAccepted != acceptStop|acceptShutdown {
+			t.Errorf("unexpected status: %+v", s.status)
+		}
+
+		s = &service{pausers: []Pauser{&mockPauser{}}}
+		s.setState(statePaused)
+		if s.status.controlsAccepted != acceptStop|acceptShutdown|acceptPauseContinue {
+			t.Errorf("unexpected status: %+v", s.status)
+		}
+	})
+	t.Run("the checkpoint must only advance in pending states", func(t *testing.T) {
+		t.Parallel()
+
+		s := &service{}
+		s.setState(stateStartPending)
+		s.checkPoint()
+		s.checkPoint()
+		if s.status.checkPoint != 3 || s.status.waitHint != waitHint {
+			t.Errorf("unexpected status: %+v", s.status)
+		}
+
+		s.setState(stateRunning)
+		s.checkPoint()
+		if s.status.checkPoint != 0 || s.status.waitHint != 0 {
+			t.Errorf("unexpected status: %+v", s.status)
+		}
+	})
+	t.Run("a service leaving its state must not be moved", func(t *testing.T) {
+		t.Parallel()
+
+		s := &service{}
+		s.setState(stateStopPending)
+		s.setStateFrom(stateStartPending, stateRunning)
+		if s.status.currentState != stateStopPending {
+			t.Errorf("expected state %d but got: %d", stateStopPending, s.status.currentState)
+		}
+	})
+	t.Run("pause errors must be logged through the manager", func(t *testing.T) {
+		t.Parallel()
+
+		logger := &recordingLogger{}
+		s := &service{
+			ctx:     context.Background(),
+			m:       flex.New(flex.WithLogger(logger)),
+			pausers: []Pauser{&mockPauser{err: errors.New("boom")}},
+		}
+		s.pause(false, statePaused)
+		s.pause(true, stateRunning)
+
+		if len(logger.msgs) != 2 || logger.msgs[0] != "pause failed" || logger.msgs[1] != "resume failed" {
+			t.Errorf("unexpected logs: %v", logger.msgs)
+		}
+		if s.status.currentState != stateRunning {
+			t.Errorf("expected state %d but got: %d", stateRunning, s.status.currentState)
+		}
+	})
+	t.Run("the structures must have the layouts of advapi32", func(t *testing.T) {
+		t.Parallel()
+
+		// SERVICE_STATUS is seven DWORDs, SERVICE_TABLE_ENTRYW two pointers.
+		if size := unsafe.Sizeof(serviceStatus{}); size != 28 {
+			t.Errorf("expected SERVICE_STATUS to be 28 bytes but got: %d", size)
+		}
+		if size := unsafe.Sizeof(serviceTableEntry{}); size != 2*unsafe.Sizeof(uintptr(0)) {
+			t.Errorf("expected SERVICE_TABLE_ENTRYW to be two pointers but got: %d bytes", size)
+		}
+	})
+	t.Run("outside of the service control manager the manager must simply start", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		ran := false
+		m := flex.New()
+		m.Add(flex.NewWorker(func(context.Context) error {
+			ran = true
+			return nil
+		}, nil))
+
+		if err := run(ctx, "test", m); err != nil {
+			t.Error(err)
+		}
+		if !ran {
+			t.Error("expected the worker to run")
+		}
+	})
+}
@@ -0,0 +1,267 @@
+// Package flexws provides a flex worker serving WebSocket connections, which
+// are closed with a close frame when the worker is halted.
+//
+// The worker does not depend on a WebSocket library, instead it works with any
+// type satisfying Conn, which is typically a thin shim over the connection of
+// gorilla/websocket:
+//
+//	type conn struct{ *websocket.Conn }
+//
+//	func (c conn) WriteClose(code int, reason string) error {
+//		msg := websocket.FormatCloseMessage(code, reason)
+//		return c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+//	}
+//
+//	upgrader := websocket.Upgrader{}
+//	upgrade := func(w http.ResponseWriter, r *http.Request) (conn, error) {
+//		c, err := upgrader.Upgrade(w, r, nil)
+//		return conn{c}, err
+//	}
+//
+//	flex.MustStart(ctx, flexws.New(":8080", upgrade, handleFeed, flexws.WithCloseGracePeriod(3*time.Second)))
+//
+// Once halted, the server stops accepting connections, cancels the contexts
+// of the handlers, and sends a close frame to every open connection. Clients
+// are given the grace period to acknowledge it, after which the remaining
+// connections are closed.
+package flexws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-flexible/flex/flexhttp"
+)
+
+const (
+	// DefaultCloseGracePeriod is how long clients are given to acknowledge
+	// the close frame during Halt when no grace period is configured.
+	DefaultCloseGracePeriod = 5 * time.Second
+	// DefaultCloseReason is the reason sent in close frames when none is
+	// configured.
+	DefaultCloseReason = "server shutting down"
+)
+
+// CloseGoingAway is the status code of the close frames sent during Halt, as
+// defined by RFC 6455.
+const CloseGoingAway = 1001
+
+var logger = log.New(os.Stderr, "flexws: ", 0)
+
+// Conn is a WebSocket connection.
+type Conn interface {
+	// WriteClose sends a close frame with code and reason. It must be safe to
+	// call concurrently with the handler using the connection.
+	WriteClose(code int, reason string) error
+	// Close closes the underlying connection.
+	Close() error
+}
+
+// Upgrader upgrades an HTTP request to a WebSocket connection. When it fails,
+// it must have replied to the request.
+type Upgrader[C Conn] func(w http.ResponseWriter, r *http.Request) (C, error)
+
+// Handler handles a connection until the client closes it. The context is
+// cancelled once the server is halted, after which the handler should stop
+// writing to the connection and return once the client acknowledged the
+// close frame. The connection is closed once it returns.
+type Handler[C Conn] func(ctx context.Context, conn C)
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	gracePeriod time.Duration
+	reason      string
+	http        []flexhttp.Option
+}
+
+// WithCloseGracePeriod sets how long clients are given to acknowledge the
+// close frame once the server is halted, unless the context given to Halt
+// expires first, after which their connections are closed.
+func WithCloseGracePeriod(d time.Duration) Option {
+	return func(o *options) { o.gracePeriod = d }
+}
+
+// WithCloseReason sets the reason sent in close frames.
+func WithCloseReason(reason string) Option {
+	return func(o *options) { o.reason = reason }
+}
+
+// WithServerOptions sets options of the underlying flexhttp.Server, such as
+// its drain timeout, which applies to the requests which are not upgraded.
+func WithServerOptions(opts ...flexhttp.Option) Option {
+	return func(o *options) { o.http = append(o.http, opts...) }
+}
+
+// Server is a flex worker which upgrades the requests it serves to WebSocket
+// connections and dispatches them to a handler.
+type Server[C Conn] struct {
+	upgrade Upgrader[C]
+	handler Handler[C]
+	opts    options
+	http    *flexhttp.Server
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	conns    map[*conn[C]]struct{}
+	handlers sync.WaitGroup
+	halted   bool
+}
+
+// conn is an open connection, closed at most once.
+type conn[C Conn] struct {
+	c    C
+	once sync.Once
+}
+
+// New returns a Server listening on addr.
+func New[C Conn](addr string, upgrade Upgrader[C], handler Handler[C], opts ...Option) *Server[C] {
+	s := &Server[C]{
+		upgrade: upgrade,
+		handler: handler,
+		opts: options{
+			gracePeriod: DefaultCloseGracePeriod,
+			reason:      DefaultCloseReason,
+		},
+		conns: make(map[*conn[C]]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.http = flexhttp.New(&http.Server{Addr: addr, Handler: s}, s.opts.http...)
+	return s
+}
+
+// Addr returns the address the server is listening on, or nil if it is not
+// listening yet.
+func (s *Server[C]) Addr() net.Addr {
+	return s.http.Addr()
+}
+
+// OpenConns returns the number of open WebSocket connections.
+func (s *Server[C]) OpenConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Describe implements flex.Describer, reporting the number of open WebSocket
+// connections.
+func (s *Server[C]) Describe() map[string]string {
+	return map[string]string{"conns": strconv.Itoa(s.OpenConns())}
+}
+
+// Run serves until the server is halted. The worker reports itself ready once
+// it is listening.
+func (s *Server[C]) Run(ctx context.Context) error {
+	return s.http.Run(ctx)
+}
+
+// ServeHTTP upgrades the request and hands the connection to the handler,
+// unless the server is halted.
+func (s *Server[C]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := s.upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	tracked := &conn[C]{c: c}
+	s.mu.Lock()
+	if s.halted {
+		s.mu.Unlock()
+		_ = c.WriteClose(CloseGoingAway, s.opts.reason)
+		_ = c.Close()
+		return
+	}
+	s.conns[tracked] = struct{}{}
+	s.handlers.Add(1)
+	s.mu.Unlock()
+
+	defer s.handlers.Done()
+	defer s.untrack(tracked)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Printf("handler panicked: %v", r)
+		}
+	}()
+
+	s.handler(s.ctx, c)
+}
+
+// Halt stops accepting connections and sends a close frame to the open ones,
+// then waits for their handlers to return for at most the grace period, or
+// until the deadline of ctx if it is earlier, after which the remaining
+// connections are closed and an error reporting them is returned.
+func (s *Server[C]) Halt(ctx context.Context) error {
+	err := s.http.Halt(ctx)
+
+	s.mu.Lock()
+	s.halted = true
+	conns := make([]*conn[C], 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	for _, c := range conns {
+		if err := c.c.WriteClose(CloseGoingAway, s.opts.reason); err != nil {
+			logger.Printf("send close frame: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	gracePeriod := s.opts.gracePeriod
+	if deadline, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+		gracePeriod = min(gracePeriod, time.Until(deadline))
+	}
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return err
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	n := len(s.conns)
+	for c := range s.conns {
+		c.close()
+	}
+	s.mu.Unlock()
+	<-done
+
+	logger.Printf("%d connections did not close within %s, closed them", n, gracePeriod)
+	return errors.Join(err, fmt.Errorf("flexws: %d connections did not close within %s, closed them", n, gracePeriod))
+}
+
+// untrack closes c and records it as closed.
+func (s *Server[C]) untrack(c *conn[C]) {
+	c.close()
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+// close closes the connection, once.
+func (c *conn[C]) close() {
+	c.once.Do(func() { _ = c.c.Close() })
+}
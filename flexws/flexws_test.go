@@ -0,0 +1,213 @@
+package flexws_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+	"github.com/go-flexible/flex/flextest"
+	"github.com/go-flexible/flex/flexws"
+)
+
+// mockConn is a hijacked connection speaking a line protocol, in which close
+// frames are sent as a "close" line.
+type mockConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	mu sync.Mutex
+}
+
+func (c *mockConn) WriteClose(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.Conn, "close %d %s\n", code, reason)
+	return err
+}
+
+// upgrade hijacks the connection of the request.
+func upgrade(w http.ResponseWriter, _ *http.Request) (*mockConn, error) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n\r\n")
+	return &mockConn{Conn: conn, r: rw.Reader}, nil
+}
+
+// echo echoes lines until the client closes the connection.
+func echo(_ context.Context, conn *mockConn) {
+	for {
+		line, err := conn.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		conn.mu.Lock()
+		io.WriteString(conn, line)
+		conn.mu.Unlock()
+	}
+}
+
+// dial opens a connection to addr and checks that the server echoes.
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: flex\r\n\r\nping\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "\r\n" {
+			break
+		}
+	}
+	if line, err := r.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Fatalf("expected an echo but got: %q, %v", line, err)
+	}
+	return conn, r
+}
+
+// waitConns waits for srv to have n open connections.
+func waitConns(t *testing.T, srv *flexws.Server[*mockConn], n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); srv.OpenConns() != n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d open connections but got: %d", n, srv.OpenConns())
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Run("connections must be sent a close frame when halted", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexws.New(addr, upgrade, echo, flexws.WithCloseReason("bye"))
+
+		m := flex.New(flex.WithSignals())
+		m.Add(srv)
+		h := flextest.Start(t, m)
+		flextest.WaitListening(t, addr)
+
+		conn, r := dial(t, addr)
+		waitConns(t, srv, 1)
+
+		// Acknowledge the close frame by closing the connection.
+		closeFrame := make(chan string, 1)
+		go func() {
+			line, _ := r.ReadString('\n')
+			closeFrame <- line
+			conn.Close()
+		}()
+
+		var buf strings.Builder
+		if err := m.Dump(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "conns=1") {
+			t.Errorf("expected the open connections to be dumped but got: %q", buf.String())
+		}
+
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if line := <-closeFrame; line != "close 1001 bye\n" {
+			t.Errorf("expected a close frame but got: %q", line)
+		}
+		if n := srv.OpenConns(); n != 0 {
+			t.Errorf("expected no open connections but got: %d", n)
+		}
+	})
+	t.Run("connections outliving the grace period must be closed", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexws.New(addr, upgrade, echo, flexws.WithCloseGracePeriod(10*time.Millisecond))
+
+		go srv.Run(context.Background())
+		flextest.WaitListening(t, addr)
+
+		_, r := dial(t, addr)
+		waitConns(t, srv, 1)
+
+		if err := srv.Halt(context.Background()); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "close 1001") {
+			t.Errorf("expected a close frame but got: %q", line)
+		}
+		if _, err := r.ReadString('\n'); err != io.EOF {
+			t.Errorf("expected the connection to be closed but got: %v", err)
+		}
+	})
+	t.Run("connections outliving the deadline of the halt must be closed", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		srv := flexws.New(addr, upgrade, echo)
+
+		go srv.Run(context.Background())
+		flextest.WaitListening(t, addr)
+
+		_, r := dial(t, addr)
+		waitConns(t, srv, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := srv.Halt(ctx); err == nil {
+			t.Error("expected an error but did not get one")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the connections to be closed at the deadline, but halted in %s", elapsed)
+		}
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "close 1001") {
+			t.Errorf("expected a close frame but got: %q", line)
+		}
+	})
+	t.Run("handler contexts must be cancelled when halted", func(t *testing.T) {
+		t.Parallel()
+
+		addr := flextest.Addr(t)
+		cancelled := make(chan struct{})
+		srv := flexws.New(addr, upgrade, func(ctx context.Context, conn *mockConn) {
+			go echo(ctx, conn)
+			<-ctx.Done()
+			close(cancelled)
+		})
+
+		go srv.Run(context.Background())
+		flextest.WaitListening(t, addr)
+
+		dial(t, addr)
+		waitConns(t, srv, 1)
+
+		if err := srv.Halt(context.Background()); err != nil {
+			t.Error(err)
+		}
+		select {
+		case <-cancelled:
+		default:
+			t.Error("expected the handler context to be cancelled")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+// Package flexzap adapts a *zap.Logger to a flex.Logger, so that the
+// lifecycle logs of a manager are written as structured zap entries:
+//
+//	logger, _ := zap.NewProduction()
+//	m := flex.New(flex.WithLogger(flexzap.New(logger, zap.Any)))
+//
+// The package does not depend on zap, instead it accepts any logger with the
+// leveled methods of a *zap.Logger, along with the function building its
+// fields, zap.Any.
+package flexzap
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-flexible/flex"
+)
+
+// Logger is the subset of *zap.Logger used by the adapter, whose fields are
+// of type F, zap.Field.
+type Logger[F any] interface {
+	Debug(msg string, fields ...F)
+	Info(msg string, fields ...F)
+	Warn(msg string, fields ...F)
+	Error(msg string, fields ...F)
+}
+
+// adapter is a flex.Logger writing to a zap logger.
+type adapter[F any] struct {
+	l     Logger[F]
+	field func(key string, value any) F
+}
+
+// New returns a flex.Logger writing to l, turning attributes into fields with
+// field, which is zap.Any for a *zap.Logger.
+func New[F any](l Logger[F], field func(key string, value any) F) flex.Logger {
+	return adapter[F]{l: l, field: field}
+}
+
+// Log writes the entry at the zap level matching level: debug below
+// slog.LevelInfo, info below slog.LevelWarn, warn below slog.LevelError and
+// error from there on.
+func (a adapter[F]) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	attrs := flex.Attrs(args...)
+	fields := make([]F, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = append(fields, a.field(attr.Key, attr.Value.Resolve().Any()))
+	}
+
+	switch {
+	case level < slog.LevelInfo:
+		a.l.Debug(msg, fields...)
+	case level < slog.LevelWarn:
+		a.l.Info(msg, fields...)
+	case level < slog.LevelError:
+		a.l.Warn(msg, fields...)
+	default:
+		a.l.Error(msg, fields...)
+	}
+}
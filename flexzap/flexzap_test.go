@@ -0,0 +1,73 @@
+package flexzap_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexzap"
+)
+
+// field mirrors zap.Field.
+type field struct {
+	key   string
+	value any
+}
+
+// mockLogger mirrors *zap.Logger, recording the last entry.
+type mockLogger struct {
+	level  string
+	msg    string
+	fields []field
+}
+
+func (l *mockLogger) Debug(msg string, fields ...field) { l.record("debug", msg, fields) }
+func (l *mockLogger) Info(msg string, fields ...field)  { l.record("info", msg, fields) }
+func (l *mockLogger) Warn(msg string, fields ...field)  { l.record("warn", msg, fields) }
+func (l *mockLogger) Error(msg string, fields ...field) { l.record("error", msg, fields) }
+
+func (l *mockLogger) record(level, msg string, fields []field) {
+	l.level, l.msg, l.fields = level, msg, fields
+}
+
+// anyField mirrors zap.Any.
+func anyField(key string, value any) field { return field{key: key, value: value} }
+
+func TestNew(t *testing.T) {
+	t.Run("entries must be written at the matching level with their fields", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockLogger{}
+		logger := flexzap.New(mock, anyField)
+
+		for level, want := range map[slog.Level]string{
+			slog.LevelDebug: "debug",
+			slog.LevelInfo:  "info",
+			slog.LevelWarn:  "warn",
+			slog.LevelError: "error",
+		} {
+			logger.Log(context.Background(), level, "worker started")
+			if mock.level != want || mock.msg != "worker started" {
+				t.Errorf("expected %s but got: %s %q", want, mock.level, mock.msg)
+			}
+		}
+
+		err := errors.New("boom")
+		logger.Log(context.Background(), slog.LevelError, "worker failed",
+			"worker", "api", slog.Duration("duration", time.Second), "error", err)
+		if len(mock.fields) != 3 {
+			t.Fatalf("unexpected fields: %v", mock.fields)
+		}
+		if f := mock.fields[0]; f.key != "worker" || f.value != "api" {
+			t.Errorf("unexpected field: %v", f)
+		}
+		if f := mock.fields[1]; f.key != "duration" || f.value != time.Second {
+			t.Errorf("unexpected field: %v", f)
+		}
+		if f := mock.fields[2]; f.key != "error" || f.value != err {
+			t.Errorf("unexpected field: %v", f)
+		}
+	})
+}
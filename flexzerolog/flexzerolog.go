@@ -0,0 +1,72 @@
+// Package flexzerolog adapts a zerolog.Logger to a flex.Logger, so that the
+// lifecycle logs of a manager are written as structured zerolog events:
+//
+//	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+//	m := flex.New(flex.WithLogger(flexzerolog.New(&logger)))
+//
+// The package does not depend on zerolog, instead it accepts any logger with
+// the leveled methods of a *zerolog.Logger, returning events with the methods
+// of a *zerolog.Event.
+package flexzerolog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-flexible/flex"
+)
+
+// Event is the subset of *zerolog.Event used by the adapter.
+type Event[E any] interface {
+	Str(key, val string) E
+	Interface(key string, i any) E
+	Msg(msg string)
+}
+
+// Logger is the subset of *zerolog.Logger used by the adapter, whose events
+// are of type E, *zerolog.Event.
+type Logger[E Event[E]] interface {
+	Debug() E
+	Info() E
+	Warn() E
+	Error() E
+}
+
+// adapter is a flex.Logger writing to a zerolog logger.
+type adapter[E Event[E]] struct{ l Logger[E] }
+
+// New returns a flex.Logger writing to l.
+func New[E Event[E]](l Logger[E]) flex.Logger { return adapter[E]{l: l} }
+
+// Log writes the entry at the zerolog level matching level: debug below
+// slog.LevelInfo, info below slog.LevelWarn, warn below slog.LevelError and
+// error from there on. Errors and values implementing fmt.Stringer, such as
+// durations, are written as strings.
+func (a adapter[E]) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	var e E
+	switch {
+	case level < slog.LevelInfo:
+		e = a.l.Debug()
+	case level < slog.LevelWarn:
+		e = a.l.Info()
+	case level < slog.LevelError:
+		e = a.l.Warn()
+	default:
+		e = a.l.Error()
+	}
+
+	for _, attr := range flex.Attrs(args...) {
+		switch v := attr.Value.Resolve().Any().(type) {
+		case string:
+			e = e.Str(attr.Key, v)
+		case error:
+			e = e.Str(attr.Key, v.Error())
+		case fmt.Stringer:
+			e = e.Str(attr.Key, v.String())
+		default:
+			e = e.Interface(attr.Key, v)
+		}
+	}
+	e.Msg(msg)
+}
@@ -0,0 +1,83 @@
+package flexzerolog_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex/flexzerolog"
+)
+
+// mockEvent mirrors *zerolog.Event, a nil event being disabled.
+type mockEvent struct {
+	logger *mockLogger
+	level  string
+	fields map[string]any
+}
+
+func (e *mockEvent) Str(key, val string) *mockEvent { return e.Interface(key, val) }
+
+func (e *mockEvent) Interface(key string, i any) *mockEvent {
+	if e != nil {
+		e.fields[key] = i
+	}
+	return e
+}
+
+func (e *mockEvent) Msg(msg string) {
+	if e != nil {
+		e.logger.level, e.logger.msg, e.logger.fields = e.level, msg, e.fields
+	}
+}
+
+// mockLogger mirrors *zerolog.Logger, recording the last event, with debug
+// disabled.
+type mockLogger struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+func (l *mockLogger) Debug() *mockEvent { return nil }
+func (l *mockLogger) Info() *mockEvent  { return l.event("info") }
+func (l *mockLogger) Warn() *mockEvent  { return l.event("warn") }
+func (l *mockLogger) Error() *mockEvent { return l.event("error") }
+
+func (l *mockLogger) event(level string) *mockEvent {
+	return &mockEvent{logger: l, level: level, fields: make(map[string]any)}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("entries must be written at the matching level with their fields", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockLogger{}
+		logger := flexzerolog.New(mock)
+
+		for level, want := range map[slog.Level]string{
+			slog.LevelInfo:  "info",
+			slog.LevelWarn:  "warn",
+			slog.LevelError: "error",
+		} {
+			logger.Log(context.Background(), level, "worker started")
+			if mock.level != want || mock.msg != "worker started" {
+				t.Errorf("expected %s but got: %s %q", want, mock.level, mock.msg)
+			}
+		}
+
+		logger.Log(context.Background(), slog.LevelDebug, "worker stopped")
+		if mock.msg == "worker stopped" {
+			t.Error("expected disabled levels not to be written")
+		}
+
+		logger.Log(context.Background(), slog.LevelError, "worker failed",
+			"worker", "api", "duration", time.Second, "restarts", 2, "error", errors.New("boom"))
+		for key, want := range map[string]any{"worker": "api", "duration": "1s", "restarts": int64(2), "error": "boom"} {
+			if got := mock.fields[key]; got != want {
+				t.Errorf("expected %s to be %#v but got: %#v", key, want, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HealthStatus summarizes health in three states.
+type HealthStatus int
+
+const (
+	// StatusHealthy is the status of what is alive, ready and not degraded.
+	StatusHealthy HealthStatus = iota + 1
+	// StatusDegraded is the status of what is alive and ready, but reported
+	// an error marked with Degraded.
+	StatusDegraded
+	// StatusUnhealthy is the status of what is not alive or not ready.
+	StatusUnhealthy
+)
+
+// String returns a string representation of the HealthStatus.
+func (s HealthStatus) String() string {
+	switch s {
+	case StatusHealthy:
+		return "healthy"
+	case StatusDegraded:
+		return "degraded"
+	case StatusUnhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+// healthStatus returns the status of what is live, ready and degraded.
+func healthStatus(live, ready, degraded bool) HealthStatus {
+	switch {
+	case !live || !ready:
+		return StatusUnhealthy
+	case degraded:
+		return StatusDegraded
+	default:
+		return StatusHealthy
+	}
+}
+
+// degradedError marks an error as degraded, see Degraded.
+type degradedError struct{ err error }
+
+func (e *degradedError) Error() string { return e.err.Error() }
+func (e *degradedError) Unwrap() error { return e.err }
+
+// Degraded marks err, reported by a HealthReporter or a LivenessReporter, as
+// degraded: the worker keeps working, albeit poorly, as when a replica lags
+// or a cache is cold, so it remains alive and ready, but is reported as
+// degraded. Degraded returns nil if err is nil.
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &degradedError{err: err}
+}
+
+// IsDegraded reports whether err, or any error it wraps, was marked with
+// Degraded. Errors joined with errors.Join are degraded only if all of them
+// are.
+func IsDegraded(err error) bool {
+	switch err := err.(type) {
+	case nil:
+		return false
+	case *degradedError:
+		return true
+	case interface{ Unwrap() []error }:
+		errs := err.Unwrap()
+		for _, err := range errs {
+			if !IsDegraded(err) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	default:
+		return IsDegraded(errors.Unwrap(err))
+	}
+}
+
+// Health is the health of the workers of a Manager, distinguishing liveness,
+// whether the process must be restarted, from readiness, whether it should be
+// sent traffic, and telling whether it has started.
+type Health struct {
+	// Started is set when every worker has started.
+	Started bool
+	// Live is set when every worker is alive.
+	Live bool
+	// Ready is set when every worker is ready.
+	Ready bool
+	// Degraded is set when any worker is degraded.
+	Degraded bool
+	// Stopping is set once a shutdown has been requested, which makes the
+	// manager not ready, see WithShutdownDelay.
+	Stopping bool
+	// Workers holds the health of each worker, in the order they were added.
+	Workers []WorkerHealth
+}
+
+// WorkerHealth is the health of a single worker.
+type WorkerHealth struct {
+	Name    string
+	State   WorkerState
+	Started bool
+	Live    bool
+	Ready   bool
+	// Degraded is set when the worker reported an error marked with Degraded,
+	// which leaves it alive and ready.
+	Degraded bool
+	// Err is why the worker is not live, not ready or degraded, when known:
+	// the error reported by its HealthReporter or LivenessReporter, or the
+	// last error it failed with.
+	Err error
+}
+
+// Status returns the status of the workers: unhealthy if any is not alive or
+// not ready, degraded if any is degraded, and healthy otherwise.
+func (h Health) Status() HealthStatus { return healthStatus(h.Live, h.Ready, h.Degraded) }
+
+// Status returns the status of the worker.
+func (h WorkerHealth) Status() HealthStatus { return healthStatus(h.Live, h.Ready, h.Degraded) }
+
+// Health returns the health of the workers.
+//
+// A worker has started once its Run was entered, or, when it waits for Ready,
+// see WithStartedOnReady, once it has called Ready, returned from Run or been
+// restarted.
+//
+// A worker is ready while it is running and its HealthReporter, if it
+// implements it, reports no error, and once it has returned from Run without
+// an error. A worker which is starting, halting or failed is not ready.
+//
+// A worker is alive unless its LivenessReporter, if it implements it, reports
+// an error, or its reporters have not returned by the time ctx is done, as
+// happens when the worker is deadlocked. A worker implementing Heartbeater
+// which missed a heartbeat is neither alive nor ready. Errors reported marked
+// with Degraded make the worker degraded instead of not alive or not ready.
+// The reporters of running workers are called concurrently, and Health
+// returns at the latest once ctx is done. The manager is not ready once a
+// shutdown has been requested, even while its workers still are.
+//
+// EventHealthChanged is emitted for the workers whose status differs from the
+// one last observed.
+func (m *Manager) Health(ctx context.Context) Health {
+	health := Health{Started: true, Live: true, Ready: true, Workers: make([]WorkerHealth, len(m.workers))}
+
+	type result struct {
+		i                 int
+		notReady, notLive error
+	}
+	results := make(chan result, len(m.workers))
+	pending := make(map[int]bool)
+
+	now := time.Now()
+	for i, worker := range m.workers {
+		status := worker.snapshot()
+		wh := &health.Workers[i]
+		*wh = WorkerHealth{Name: worker.name(), State: status.state, Started: worker.hasStarted(status), Live: true}
+
+		if err := worker.missedHeartbeat(status, now); err != nil {
+			wh.Live, wh.Err = false, err
+			continue
+		}
+
+		switch status.state {
+		case StateRunning:
+			wh.Ready = true
+			ready, _ := worker.Worker.(HealthReporter)
+			live, _ := worker.Worker.(LivenessReporter)
+			if ready == nil && live == nil {
+				continue
+			}
+
+			pending[i] = true
+			go func() {
+				r := result{i: i}
+				if ready != nil {
+					r.notReady = ready.Health(ctx)
+				}
+				if live != nil {
+					r.notLive = live.Live(ctx)
+				}
+				results <- r
+			}()
+		case StateStopped:
+			wh.Ready = true
+		case StateFailed:
+			wh.Err = status.lastErr
+		}
+	}
+
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.i)
+			wh := &health.Workers[r.i]
+			if r.notReady != nil && !IsDegraded(r.notReady) {
+				wh.Ready, wh.Err = false, r.notReady
+			}
+			if r.notLive != nil && !IsDegraded(r.notLive) {
+				wh.Live, wh.Err = false, r.notLive
+			}
+			for _, err := range []error{r.notReady, r.notLive} {
+				if wh.Err == nil && IsDegraded(err) {
+					wh.Degraded, wh.Err = true, err
+				}
+			}
+		case <-ctx.Done():
+			for i := range pending {
+				wh := &health.Workers[i]
+				wh.Live, wh.Ready = false, false
+				wh.Err = fmt.Errorf("health was not reported: %w", ctx.Err())
+			}
+			clear(pending)
+		}
+	}
+
+	for _, wh := range health.Workers {
+		health.Started = health.Started && wh.Started
+		health.Live = health.Live && wh.Live
+		health.Ready = health.Ready && wh.Ready
+		health.Degraded = health.Degraded || wh.Degraded
+	}
+
+	m.mu.Lock()
+	health.Stopping = m.stopping
+	m.mu.Unlock()
+	health.Ready = health.Ready && !health.Stopping
+
+	m.observeHealth(health)
+	return health
+}
+
+// observeHealth emits EventHealthChanged for the workers whose status differs
+// from the one last observed, and restarts those which have been unhealthy
+// for longer than their threshold, see WithRestartWhenUnhealthy.
+func (m *Manager) observeHealth(health Health) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	now := time.Now()
+	for i, wh := range health.Workers {
+		worker := m.workers[i]
+		if status := wh.Status(); status != worker.health {
+			m.emit(Event{Kind: EventHealthChanged, Worker: worker.Worker, WorkerName: wh.Name,
+				OldStatus: worker.health, NewStatus: status, Err: wh.Err})
+			worker.health = status
+		}
+
+		threshold := worker.opts.unhealthyRestart
+		switch {
+		case threshold <= 0 || wh.State != StateRunning || wh.Status() != StatusUnhealthy:
+			worker.unhealthySince = time.Time{}
+		case worker.unhealthySince.IsZero():
+			worker.unhealthySince = now
+		case now.Sub(worker.unhealthySince) > threshold:
+			err := fmt.Errorf("%w for %s: %v", ErrUnhealthy, now.Sub(worker.unhealthySince).Round(time.Millisecond), wh.Err)
+			worker.unhealthySince = time.Time{}
+			m.haltForRestart(context.Background(), worker, err)
+		}
+	}
+}
+
+// healthInterval returns how often the health of the workers is observed
+// while the manager runs: the interval set with WithHealthInterval, or half
+// the shortest threshold of the workers restarted when unhealthy, or zero
+// if it is not.
+func (m *Manager) healthInterval() time.Duration {
+	if m.opts.healthInterval > 0 {
+		return m.opts.healthInterval
+	}
+
+	var interval time.Duration
+	for _, worker := range m.workers {
+		if threshold := worker.opts.unhealthyRestart; threshold > 0 && (interval == 0 || threshold/2 < interval) {
+			interval = max(threshold/2, time.Millisecond)
+		}
+	}
+	return interval
+}
+
+// watchHealth observes the health of the workers every interval until ctx is
+// done.
+func (m *Manager) watchHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			healthCtx, cancel := context.WithTimeout(ctx, interval)
+			m.Health(healthCtx)
+			cancel()
+		}
+	}
+}
+
+// hasStarted reports whether the worker has started, given its status.
+func (w *managedWorker) hasStarted(status workerStatus) bool {
+	return status.state != StateIdle && (status.state != StateStarting || status.restarts > 0)
+}
@@ -0,0 +1,422 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// reportingMockWorker reports the health it is given.
+type reportingMockWorker struct {
+	blockingMockWorker
+	err error
+}
+
+func (r *reportingMockWorker) Health(context.Context) error { return r.err }
+
+// stuckReportingMockWorker never returns from reporting its health.
+type stuckReportingMockWorker struct {
+	blockingMockWorker
+	release chan struct{}
+}
+
+func (s *stuckReportingMockWorker) Health(context.Context) error {
+	<-s.release
+	return nil
+}
+
+// flappingMockWorker reports the health it is last given.
+type flappingMockWorker struct {
+	blockingMockWorker
+	err atomic.Pointer[error]
+}
+
+func (f *flappingMockWorker) Health(context.Context) error {
+	if err := f.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// dyingMockWorker reports that it cannot recover.
+type dyingMockWorker struct {
+	blockingMockWorker
+	err error
+}
+
+func (d *dyingMockWorker) Live(context.Context) error { return d.err }
+
+// readyReportingMockWorker reports calling Ready, without ever calling it.
+type readyReportingMockWorker struct {
+	blockingMockWorker
+}
+
+func (r *readyReportingMockWorker) ReportsReady() bool { return true }
+
+func TestManagerHealth(t *testing.T) {
+	t.Run("the health of every worker must be aggregated", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		brokerErr := errors.New("broker unreachable")
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, flex.WithName("api"))
+		m.Add(&reportingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, err: brokerErr},
+			flex.WithName("consumer"))
+
+		if h := m.Health(ctx); !h.Live || h.Ready || h.Workers[0].State != flex.StateIdle {
+			t.Errorf("expected idle workers to be alive but not ready, got: %+v", h)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		h := m.Health(ctx)
+		if !h.Live || h.Ready {
+			t.Errorf("expected the manager to be alive but not ready, got: %+v", h)
+		}
+		if wh := h.Workers[0]; wh.Name != "api" || !wh.Ready || wh.State != flex.StateRunning {
+			t.Errorf("expected api to be ready but got: %+v", wh)
+		}
+		if wh := h.Workers[1]; wh.Name != "consumer" || wh.Ready || !wh.Live || !errors.Is(wh.Err, brokerErr) {
+			t.Errorf("expected consumer not to be ready because of %v but got: %+v", brokerErr, wh)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("workers must be given their manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		managers := make(chan *flex.Manager, 1)
+		m.Add(flex.NewWorker(func(ctx context.Context) error {
+			got, _ := flex.ManagerFromContext(ctx)
+			managers <- got
+			return nil
+		}, nil))
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-managers; got != m {
+			t.Errorf("expected %p but got: %p", m, got)
+		}
+		if _, ok := flex.ManagerFromContext(ctx); ok {
+			t.Error("expected no manager outside of a worker")
+		}
+	})
+	t.Run("workers not reporting their health in time must not be alive", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		stuck := &stuckReportingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, release: make(chan struct{})}
+		defer close(stuck.release)
+		m := flex.New(flex.WithSignals())
+		m.Add(stuck)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		checkCtx, cancelCheck := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancelCheck()
+
+		h := m.Health(checkCtx)
+		if h.Live || h.Ready {
+			t.Errorf("expected the manager to be neither alive nor ready, got: %+v", h)
+		}
+		if wh := h.Workers[0]; !errors.Is(wh.Err, context.DeadlineExceeded) {
+			t.Errorf("expected %v but got: %v", context.DeadlineExceeded, wh.Err)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("workers reporting they cannot recover must not be alive", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		dying := &dyingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, err: errors.New("deadlocked")}
+		m := flex.New(flex.WithSignals())
+		m.Add(dying)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		if h := m.Health(ctx); h.Live || !h.Ready || !errors.Is(h.Workers[0].Err, dying.err) {
+			t.Errorf("expected the manager to be ready but not alive, got: %+v", h)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("workers reporting degraded errors must remain alive and ready", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		lagErr := flex.Degraded(errors.New("replica lagging"))
+		m := flex.New(flex.WithSignals())
+		m.Add(&reportingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, err: lagErr})
+		m.Add(&dyingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}, err: flex.Degraded(errors.New("cache cold"))})
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		h := m.Health(ctx)
+		if !h.Live || !h.Ready || !h.Degraded || h.Status() != flex.StatusDegraded {
+			t.Errorf("expected the manager to be degraded, got: %+v", h)
+		}
+		if wh := h.Workers[0]; !wh.Degraded || wh.Status() != flex.StatusDegraded || !errors.Is(wh.Err, lagErr) {
+			t.Errorf("expected the worker to be degraded because of %v but got: %+v", lagErr, wh)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("changes of health must be emitted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		flapping := &flappingMockWorker{blockingMockWorker: blockingMockWorker{mockWorker: mockWorker{t: t}, ready: true}}
+		m := flex.New(flex.WithSignals(), flex.WithHealthInterval(5*time.Millisecond))
+		m.Add(flapping, flex.WithName("replica"))
+		events := m.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		next := func() flex.Event {
+			t.Helper()
+			for e := range events {
+				if e.Kind == flex.EventHealthChanged {
+					return e
+				}
+			}
+			t.Fatal("expected a health change")
+			return flex.Event{}
+		}
+
+		// The worker may be observed while starting.
+		e := next()
+		if e.NewStatus == flex.StatusUnhealthy {
+			e = next()
+		}
+		if e.WorkerName != "replica" || e.NewStatus != flex.StatusHealthy {
+			t.Errorf("expected the worker to become healthy but got: %+v", e)
+		}
+
+		lagErr := flex.Degraded(errors.New("replica lagging"))
+		flapping.err.Store(&lagErr)
+
+		e = next()
+		if e.OldStatus != flex.StatusHealthy || e.NewStatus != flex.StatusDegraded || !errors.Is(e.Err, lagErr) {
+			t.Errorf("expected the worker to become degraded because of %v but got: %+v", lagErr, e)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("workers must have started once run, or once ready when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t}}, flex.WithName("run"))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t}}, flex.WithName("ready"), flex.WithStartedOnReady())
+		m.Add(&readyReportingMockWorker{blockingMockWorker{mockWorker: mockWorker{t: t}}}, flex.WithName("reporter"))
+
+		if h := m.Health(ctx); h.Started || h.Workers[0].Started {
+			t.Errorf("expected idle workers not to have started, got: %+v", h)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		var h flex.Health
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			if h = m.Health(ctx); h.Workers[0].State == flex.StateRunning && h.Workers[1].State == flex.StateStarting && h.Workers[2].State == flex.StateStarting {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected only the worker waited on to be starting but got: %+v", h)
+			}
+		}
+		if h.Started || !h.Workers[0].Started || h.Workers[1].Started || h.Workers[2].Started {
+			t.Errorf("expected only the worker not waited on to have started, got: %+v", h)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a worker never calling ready must be ready once run", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		select {
+		case <-m.Started():
+		case <-time.After(time.Second):
+			t.Fatal("expected the manager to report its workers as started")
+		}
+		if h := m.Health(ctx); !h.Ready || h.Workers[0].State != flex.StateRunning {
+			t.Errorf("expected the worker to be running and ready but got: %+v", h)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestIsDegraded(t *testing.T) {
+	degraded := flex.Degraded(errors.New("replica lagging"))
+	failed := errors.New("connection refused")
+
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{failed, false},
+		{degraded, true},
+		{fmt.Errorf("postgres: %w", degraded), true},
+		{errors.Join(degraded, fmt.Errorf("redis: %w", degraded)), true},
+		{errors.Join(degraded, failed), false},
+	} {
+		if got := flex.IsDegraded(tt.err); got != tt.want {
+			t.Errorf("expected %v for %v but got: %v", tt.want, tt.err, got)
+		}
+	}
+	if flex.Degraded(nil) != nil {
+		t.Error("expected no error")
+	}
+}
+
+// sickMockWorker runs until its context is done or it is halted, reporting
+// itself unhealthy over its first run.
+type sickMockWorker struct {
+	runs atomic.Int32
+
+	mu     sync.Mutex
+	halted chan struct{}
+}
+
+func (s *sickMockWorker) Run(ctx context.Context) error {
+	s.runs.Add(1)
+	halted := make(chan struct{})
+	s.mu.Lock()
+	s.halted = halted
+	s.mu.Unlock()
+
+	flex.Ready(ctx)
+	select {
+	case <-ctx.Done():
+	case <-halted:
+	}
+	return nil
+}
+
+func (s *sickMockWorker) Halt(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.halted != nil {
+		close(s.halted)
+		s.halted = nil
+	}
+	return nil
+}
+
+func (s *sickMockWorker) Health(context.Context) error {
+	if s.runs.Load() == 1 {
+		return errors.New("connection pool exhausted")
+	}
+	return nil
+}
+
+func TestRestartWhenUnhealthy(t *testing.T) {
+	t.Run("workers unhealthy for too long must be restarted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		sick := &sickMockWorker{}
+		m := flex.New(flex.WithSignals(), flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}))
+		m.Add(sick, flex.WithRestartWhenUnhealthy(20*time.Millisecond))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		for deadline := time.Now().Add(time.Second); sick.runs.Load() < 2; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the worker to be restarted")
+			}
+		}
+		if h := m.Health(ctx); !h.Ready {
+			t.Errorf("expected the restarted worker to be healthy but got: %+v", h)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("workers unhealthy without a restart policy must shut the manager down", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&sickMockWorker{}, flex.WithRestartWhenUnhealthy(20*time.Millisecond))
+
+		if err := m.Start(ctx); !errors.Is(err, flex.ErrUnhealthy) {
+			t.Errorf("expected %v but got: %v", flex.ErrUnhealthy, err)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package flex
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HeartbeatAction is what is done when a worker misses a heartbeat.
+type HeartbeatAction int
+
+const (
+	// HeartbeatReport only reports the worker as neither alive nor ready.
+	HeartbeatReport HeartbeatAction = iota
+	// HeartbeatRestart halts the worker, which then fails with a recoverable
+	// ErrHeartbeatMissed, so that it is restarted according to its restart
+	// policy, or shuts the manager down if it has none.
+	HeartbeatRestart
+	// HeartbeatShutdown fails the worker with ErrHeartbeatMissed, shutting the
+	// manager down.
+	HeartbeatShutdown
+)
+
+// Heartbeat records that the worker owning ctx is making progress, see
+// Heartbeater. Calling Heartbeat with a context which was not passed to Run
+// by flex is a no-op.
+func Heartbeat(ctx context.Context) {
+	if worker, ok := ctx.Value(workerKey{}).(*managedWorker); ok {
+		worker.mu.Lock()
+		worker.status.lastBeat = time.Now()
+		worker.mu.Unlock()
+	}
+}
+
+// missedHeartbeat returns ErrHeartbeatMissed if the worker, given its status,
+// is running and has not called Heartbeat within its interval, counting from
+// when it started.
+func (w *managedWorker) missedHeartbeat(status workerStatus, now time.Time) error {
+	hb, ok := w.Worker.(Heartbeater)
+	if !ok || status.state != StateRunning {
+		return nil
+	}
+
+	last := status.startedAt
+	if status.lastBeat.After(last) {
+		last = status.lastBeat
+	}
+	if since := now.Sub(last); since > hb.HeartbeatInterval() {
+		return fmt.Errorf("%w: none for %s", ErrHeartbeatMissed, since.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// watchHeartbeat checks that worker keeps calling Heartbeat until ctx is done,
+// acting on missed heartbeats according to the manager's options. fail fails
+// the manager with an error of the worker.
+func (m *Manager) watchHeartbeat(ctx context.Context, worker *managedWorker, fail func(error)) {
+	hb, ok := worker.Worker.(Heartbeater)
+	if !ok || m.opts.heartbeat == HeartbeatReport {
+		return
+	}
+
+	ticker := time.NewTicker(max(hb.HeartbeatInterval()/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			worker.mu.Lock()
+			err := worker.missedHeartbeat(worker.status, now)
+			restarting := worker.restartErr != nil
+			worker.mu.Unlock()
+			if err == nil || restarting {
+				continue
+			}
+
+			switch m.opts.heartbeat {
+			case HeartbeatShutdown:
+				fail(err)
+				return
+			case HeartbeatRestart:
+				m.haltForRestart(ctx, worker, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// heartbeatingMockWorker runs until its context is done or it is halted,
+// calling Heartbeat regularly unless it is stalled.
+type heartbeatingMockWorker struct {
+	stalled bool
+	runs    atomic.Int32
+
+	mu     sync.Mutex
+	halted chan struct{}
+}
+
+func (h *heartbeatingMockWorker) HeartbeatInterval() time.Duration { return 20 * time.Millisecond }
+
+func (h *heartbeatingMockWorker) Run(ctx context.Context) error {
+	h.runs.Add(1)
+	halted := make(chan struct{})
+	h.mu.Lock()
+	h.halted = halted
+	h.mu.Unlock()
+
+	flex.Ready(ctx)
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-halted:
+			return nil
+		case <-ticker.C:
+			if !h.stalled {
+				flex.Heartbeat(ctx)
+			}
+		}
+	}
+}
+
+func (h *heartbeatingMockWorker) Halt(context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.halted != nil {
+		close(h.halted)
+		h.halted = nil
+	}
+	return nil
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Run("missed heartbeats must be reported by the health", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&heartbeatingMockWorker{}, flex.WithName("beating"))
+		m.Add(&heartbeatingMockWorker{stalled: true}, flex.WithName("stalled"))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+		time.Sleep(50 * time.Millisecond)
+
+		h := m.Health(ctx)
+		if wh := h.Workers[0]; !wh.Live || !wh.Ready {
+			t.Errorf("expected the beating worker to be healthy but got: %+v", wh)
+		}
+		if wh := h.Workers[1]; wh.Live || wh.Ready || !errors.Is(wh.Err, flex.ErrHeartbeatMissed) {
+			t.Errorf("expected the stalled worker to have missed its heartbeat but got: %+v", wh)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("missed heartbeats must shut the manager down when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals(), flex.WithHeartbeatAction(flex.HeartbeatShutdown))
+		m.Add(&heartbeatingMockWorker{stalled: true})
+
+		if err := m.Start(ctx); !errors.Is(err, flex.ErrHeartbeatMissed) {
+			t.Errorf("expected %v but got: %v", flex.ErrHeartbeatMissed, err)
+		}
+		if ctx.Err() != nil {
+			t.Error("expected the manager to shut down before its context expired")
+		}
+	})
+	t.Run("missed heartbeats must restart the worker when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		w := &heartbeatingMockWorker{stalled: true}
+		m := flex.New(flex.WithSignals(), flex.WithHeartbeatAction(flex.HeartbeatRestart),
+			flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}))
+		m.Add(w)
+
+		if err := m.Start(ctx); !errors.Is(err, flex.ErrHeartbeatMissed) {
+			t.Errorf("expected %v but got: %v", flex.ErrHeartbeatMissed, err)
+		}
+		if n := w.runs.Load(); n != 2 {
+			t.Errorf("expected the worker to run %d times but it ran %d times", 2, n)
+		}
+	})
+}
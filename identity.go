@@ -0,0 +1,62 @@
+package flex
+
+import (
+	"context"
+	"os"
+)
+
+// Identity holds the service-level identifiers seeded into the context of
+// every worker with WithIdentity, so that the logs and traces of all workers
+// carry the same identifiers without each of them plumbing them through.
+type Identity struct {
+	// Service is the name of the service.
+	Service string
+	// InstanceID identifies the running instance of the service. It defaults
+	// to the host name.
+	InstanceID string
+	// Region is the region the instance runs in.
+	Region string
+	// DeploymentID identifies the deployment, or release, the instance belongs to.
+	DeploymentID string
+}
+
+// identityKey is the context key under which the identity is stored.
+type identityKey struct{}
+
+// withIdentity returns ctx carrying id, filling in the default instance ID.
+func withIdentity(ctx context.Context, id Identity) context.Context {
+	if id.InstanceID == "" {
+		id.InstanceID, _ = os.Hostname()
+	}
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity seeded into ctx, and whether there is one.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// ServiceName returns the name of the service seeded into ctx, if any.
+func ServiceName(ctx context.Context) string {
+	id, _ := IdentityFromContext(ctx)
+	return id.Service
+}
+
+// InstanceID returns the instance ID seeded into ctx, if any.
+func InstanceID(ctx context.Context) string {
+	id, _ := IdentityFromContext(ctx)
+	return id.InstanceID
+}
+
+// Region returns the region seeded into ctx, if any.
+func Region(ctx context.Context) string {
+	id, _ := IdentityFromContext(ctx)
+	return id.Region
+}
+
+// DeploymentID returns the deployment ID seeded into ctx, if any.
+func DeploymentID(ctx context.Context) string {
+	id, _ := IdentityFromContext(ctx)
+	return id.DeploymentID
+}
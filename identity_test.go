@@ -0,0 +1,102 @@
+package flex_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// identityMockWorker records the identities seeded into its contexts.
+type identityMockWorker struct {
+	mockWorker
+	run, halt flex.Identity
+}
+
+func (i *identityMockWorker) Run(ctx context.Context) error {
+	i.run, _ = flex.IdentityFromContext(ctx)
+	return nil
+}
+
+func (i *identityMockWorker) Halt(ctx context.Context) error {
+	i.halt, _ = flex.IdentityFromContext(ctx)
+	return nil
+}
+
+func TestManagerIdentity(t *testing.T) {
+	t.Run("workers must be seeded with the identity", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		id := flex.Identity{Service: "api", InstanceID: "api-0", Region: "eu-west-1", DeploymentID: "v1.2.3"}
+		worker := &identityMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals(), flex.WithIdentity(id))
+		m.Add(worker)
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if worker.run != id || worker.halt != id {
+			t.Errorf("expected %+v but got: %+v and %+v", id, worker.run, worker.halt)
+		}
+	})
+	t.Run("the instance ID must default to the host name", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			t.Skip(err)
+		}
+
+		worker := &identityMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals(), flex.WithIdentity(flex.Identity{Service: "api"}))
+		m.Add(worker)
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if worker.run.InstanceID != hostname {
+			t.Errorf("expected %q but got: %q", hostname, worker.run.InstanceID)
+		}
+	})
+	t.Run("the accessors must return the identifiers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		if flex.ServiceName(ctx) != "" || flex.InstanceID(ctx) != "" || flex.Region(ctx) != "" || flex.DeploymentID(ctx) != "" {
+			t.Error("expected no identifiers without an identity")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var got [4]string
+		m := flex.New(flex.WithSignals(), flex.WithIdentity(flex.Identity{Service: "api", InstanceID: "api-0", Region: "eu", DeploymentID: "v1"}))
+		m.Add(&accessorMockWorker{got: &got})
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := [4]string{"api", "api-0", "eu", "v1"}; got != expected {
+			t.Errorf("expected %v but got: %v", expected, got)
+		}
+	})
+}
+
+// accessorMockWorker records the identifiers returned by the accessors.
+type accessorMockWorker struct{ got *[4]string }
+
+func (a *accessorMockWorker) Run(ctx context.Context) error {
+	*a.got = [4]string{flex.ServiceName(ctx), flex.InstanceID(ctx), flex.Region(ctx), flex.DeploymentID(ctx)}
+	return nil
+}
+
+func (a *accessorMockWorker) Halt(context.Context) error { return nil }
@@ -0,0 +1,94 @@
+package flex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// InitJob is a one-off task run before any worker starts, such as creating
+// queues or toggling feature flags. Init jobs are run one after the other, in
+// the order they were configured, and a failing init job fails Start.
+type InitJob struct {
+	// Name identifies the job in errors.
+	Name string
+	// Run performs the job.
+	Run func(context.Context) error
+	// Compensate undoes the job, it is optional. When an init job fails,
+	// every job which completed before it is compensated, in reverse order.
+	Compensate func(context.Context) error
+}
+
+// Compensation is the outcome of compensating an init job.
+type Compensation struct {
+	// Job is the name of the compensated job.
+	Job string
+	// Err is the error the compensation failed with, if any.
+	Err error
+}
+
+// InitError is returned by Start when an init job fails, once the jobs which
+// completed before it have been compensated.
+type InitError struct {
+	// Job is the name of the failed job.
+	Job string
+	// Err is the error the job failed with.
+	Err error
+	// Compensations holds the outcome of compensating the completed jobs, in
+	// the order they were compensated.
+	Compensations []Compensation
+}
+
+// Error returns a string representation of the InitError.
+func (e *InitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "init job %s failed: %v", e.Job, e.Err)
+
+	for _, c := range e.Compensations {
+		if c.Err != nil {
+			fmt.Fprintf(&b, "; compensating %s failed: %v", c.Job, c.Err)
+		} else {
+			fmt.Fprintf(&b, "; compensated %s", c.Job)
+		}
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of the failed job, followed by those of the
+// failed compensations.
+func (e *InitError) Unwrap() []error {
+	errs := []error{e.Err}
+	for _, c := range e.Compensations {
+		if c.Err != nil {
+			errs = append(errs, c.Err)
+		}
+	}
+	return errs
+}
+
+// runInitJobs runs the init jobs in order, compensating the completed ones
+// when one fails.
+func (m *Manager) runInitJobs(ctx context.Context) error {
+	for i, job := range m.opts.initJobs {
+		err := job.Run(ctx)
+		if err == nil {
+			continue
+		}
+
+		initErr := &InitError{Job: job.Name, Err: err}
+
+		// Compensations must complete even though the context may be why
+		// the job failed.
+		ctx := context.WithoutCancel(ctx)
+		for j := i - 1; j >= 0; j-- {
+			if done := m.opts.initJobs[j]; done.Compensate != nil {
+				initErr.Compensations = append(initErr.Compensations, Compensation{
+					Job: done.Name,
+					Err: done.Compensate(ctx),
+				})
+			}
+		}
+		return initErr
+	}
+	return nil
+}
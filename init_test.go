@@ -0,0 +1,94 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// initJob returns an init job recording its runs and compensations in log.
+func initJob(log *[]string, name string, runErr, compensateErr error) flex.InitJob {
+	return flex.InitJob{
+		Name: name,
+		Run: func(context.Context) error {
+			*log = append(*log, "run "+name)
+			return runErr
+		},
+		Compensate: func(context.Context) error {
+			*log = append(*log, "compensate "+name)
+			return compensateErr
+		},
+	}
+}
+
+func TestInitJobs(t *testing.T) {
+	t.Run("init jobs must run in order before the workers", func(t *testing.T) {
+		t.Parallel()
+
+		var log []string
+		m := flex.New(
+			flex.WithSignals(),
+			flex.WithInitJob(initJob(&log, "a", nil, nil)),
+			flex.WithInitJob(initJob(&log, "b", nil, nil)),
+		)
+		m.Add(&mockWorker{t: t, name: "foo"})
+		events := m.Subscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		if !slices.Equal(log, []string{"run a", "run b"}) {
+			t.Errorf("unexpected init sequence: %v", log)
+		}
+		if len(collect(events)) == 0 {
+			t.Error("expected the workers to run")
+		}
+	})
+	t.Run("a failing init job must compensate the completed ones", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			log           []string
+			migrationErr  = errors.New("migration failed")
+			compensateErr = errors.New("queue in use")
+			worker        = &haltTrackingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+		)
+
+		m := flex.New(
+			flex.WithSignals(),
+			flex.WithInitJob(initJob(&log, "create-queue", nil, compensateErr)),
+			flex.WithInitJob(flex.InitJob{Name: "log", Run: func(context.Context) error { return nil }}),
+			flex.WithInitJob(initJob(&log, "enable-flag", nil, nil)),
+			flex.WithInitJob(initJob(&log, "migrate", migrationErr, nil)),
+			flex.WithInitJob(initJob(&log, "never", nil, nil)),
+		)
+		m.Add(worker)
+
+		err := m.Start(context.Background())
+
+		want := []string{"run create-queue", "run enable-flag", "run migrate", "compensate enable-flag", "compensate create-queue"}
+		if !slices.Equal(log, want) {
+			t.Errorf("expected %v but got %v", want, log)
+		}
+
+		var initErr *flex.InitError
+		if !errors.As(err, &initErr) {
+			t.Fatalf("expected an error of type %T, but got: %v", initErr, err)
+		}
+		if initErr.Job != "migrate" || len(initErr.Compensations) != 2 {
+			t.Errorf("unexpected error: %+v", initErr)
+		}
+		if !errors.Is(err, migrationErr) || !errors.Is(err, compensateErr) {
+			t.Errorf("expected the job's and the compensation's errors to be reported, but got: %v", err)
+		}
+		if worker.halted.Load() {
+			t.Error("expected the workers not to be run")
+		}
+	})
+}
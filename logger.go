@@ -0,0 +1,121 @@
+package flex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Logger receives the lifecycle logs of a Manager, see WithLogger. The
+// attributes of an entry are given as alternating keys and values, or as
+// slog.Attr, as to slog.Logger.Log, so that a *slog.Logger is a Logger.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// stdLogger is the default Logger, writing the message of each entry followed
+// by its attributes as key=value pairs.
+type stdLogger struct {
+	l *log.Logger
+	// level is the minimum level of the entries written, all of them if nil.
+	level slog.Leveler
+}
+
+// Log writes the entry, unless its level is below the minimum level.
+func (s stdLogger) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	if s.level != nil && level < s.level.Level() {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, attr := range Attrs(args...) {
+		fmt.Fprintf(&b, " %s=%s", attr.Key, dumpValue(attr.Value.String()))
+	}
+	s.l.Print(b.String())
+}
+
+// Attrs returns the attributes given to Logger.Log as alternating keys and
+// values, or as slog.Attr, the way slog.Logger reads them, for Logger
+// implementations adapting other logging libraries.
+func Attrs(args ...any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		var attr slog.Attr
+		switch key := args[0].(type) {
+		case slog.Attr:
+			attr, args = key, args[1:]
+		case string:
+			if len(args) == 1 {
+				attr, args = slog.Any("!BADKEY", key), nil
+				break
+			}
+			attr, args = slog.Any(key, args[1]), args[2:]
+		default:
+			attr, args = slog.Any("!BADKEY", key), args[1:]
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// log logs an entry through the logger of the manager.
+func (m *Manager) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	m.opts.logger.Log(ctx, level, msg, args...)
+}
+
+// logTransition logs a lifecycle transition of the manager, if enabled with
+// WithLogger.
+func (m *Manager) logTransition(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if m.opts.logTransitions {
+		m.log(ctx, level, msg, args...)
+	}
+}
+
+// logTransition logs a lifecycle transition of the worker, if enabled with
+// WithLogger.
+func (w *managedWorker) logTransition(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if w.verbose {
+		w.logger.Log(ctx, level, msg, args...)
+	}
+}
+
+// LoggerFrom returns the logger of the manager running the worker owning ctx,
+// the context given to its Run or Halt, scoped to the worker: its entries
+// have the name of the worker under the "worker" key. It is a *slog.Logger
+// when the manager was given one. Outside of a worker run by flex, it returns
+// a logger writing to stderr.
+func LoggerFrom(ctx context.Context) Logger {
+	if worker, ok := ctx.Value(workerKey{}).(*managedWorker); ok && worker.logger != nil {
+		return worker.logger
+	}
+	return stdLogger{l: logger}
+}
+
+// Logger returns the logger of the manager, see WithLogger, for code driving
+// the manager from outside of its workers.
+func (m *Manager) Logger() Logger {
+	return m.opts.logger
+}
+
+// withWorker returns l scoped to the worker named name.
+func withWorker(l Logger, name string) Logger {
+	if sl, ok := l.(*slog.Logger); ok {
+		return sl.With("worker", name)
+	}
+	return attrLogger{Logger: l, attrs: []any{"worker", name}}
+}
+
+// attrLogger is a Logger adding attributes to every entry.
+type attrLogger struct {
+	Logger
+	attrs []any
+}
+
+// Log logs the entry with the attributes of the logger first.
+func (a attrLogger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	a.Logger.Log(ctx, level, msg, append(slices.Clone(a.attrs), args...)...)
+}
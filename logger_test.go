@@ -0,0 +1,201 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// entry is a log entry recorded by recordingLogger.
+type entry struct {
+	level slog.Level
+	msg   string
+	args  []any
+}
+
+// recordingLogger records the entries it is given.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+func (r *recordingLogger) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry{level: level, msg: msg, args: args})
+}
+
+// find returns the first entry with msg.
+func (r *recordingLogger) find(msg string) (entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+func TestWithLogger(t *testing.T) {
+	policyErr := errors.New("policy engine unreachable")
+	failingPolicy := flex.PolicyFunc(func(context.Context, flex.Decision) (bool, error) {
+		return false, policyErr
+	})
+
+	t.Run("lifecycle logs must be given to the logger", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger), flex.WithPolicy(failingPolicy))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := logger.find("policy failed, proceeding with shutdown")
+		if !ok || e.level != slog.LevelWarn || len(e.args) != 2 || e.args[0] != "error" || e.args[1] != policyErr {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+	})
+	t.Run("a slog logger must be a logger", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var buf bytes.Buffer
+		m := flex.New(flex.WithSignals(), flex.WithPolicy(failingPolicy),
+			flex.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		found := false
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var record map[string]any
+			if err := json.Unmarshal(line, &record); err != nil {
+				t.Fatal(err)
+			}
+			if record["msg"] == "policy failed, proceeding with shutdown" {
+				found = true
+				if record["level"] != "WARN" || record["error"] != policyErr.Error() {
+					t.Errorf("unexpected record: %v", record)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected the policy failure to be logged but got:\n%s", buf.String())
+		}
+	})
+	t.Run("lifecycle transitions must be logged with their attributes", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		runErr := errors.New("boom")
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger))
+		m.Add(flex.NewWorker(func(ctx context.Context) error {
+			flex.Ready(ctx)
+			return runErr
+		}, nil), flex.WithName("api"))
+
+		if err := m.Start(ctx); !errors.Is(err, runErr) {
+			t.Fatalf("expected %v but got: %v", runErr, err)
+		}
+
+		for _, msg := range []string{"worker started", "worker failed", "shutdown began", "shutdown finished"} {
+			if _, ok := logger.find(msg); !ok {
+				t.Errorf("expected %q to be logged but got: %+v", msg, logger.entries)
+			}
+		}
+		e, _ := logger.find("worker failed")
+		attrs := map[any]any{}
+		for i := 0; i+1 < len(e.args); i += 2 {
+			attrs[e.args[i]] = e.args[i+1]
+		}
+		if e.level != slog.LevelError || attrs["worker"] != "api" || attrs["phase"] != "run" || attrs["error"] != runErr {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+		if _, ok := attrs["duration"].(time.Duration); !ok {
+			t.Errorf("expected a duration but got: %+v", e)
+		}
+	})
+	t.Run("the manager must return its logger", func(t *testing.T) {
+		t.Parallel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithLogger(logger))
+		if got := m.Logger(); got != logger {
+			t.Errorf("expected %v but got: %v", logger, got)
+		}
+		if flex.New().Logger() == nil {
+			t.Error("expected a default logger")
+		}
+	})
+	t.Run("workers must be given a logger scoped to them", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		var buf bytes.Buffer
+		m := flex.New(flex.WithSignals(), flex.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+		m.Add(flex.NewWorker(func(ctx context.Context) error {
+			flex.LoggerFrom(ctx).(*slog.Logger).Info("consuming", "topic", "orders")
+			return nil
+		}, nil), flex.WithName("consumer"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if want := `msg=consuming worker=consumer topic=orders`; !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the logs to contain %q but got:\n%s", want, buf.String())
+		}
+		if flex.LoggerFrom(ctx) == nil {
+			t.Error("expected a logger outside of a worker")
+		}
+	})
+	t.Run("halting workers must be given a logger scoped to them", func(t *testing.T) {
+		t.Parallel()
+
+		for _, opts := range [][]flex.Option{nil, {flex.WithHaltTimeout(time.Second)}} {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			logger := &recordingLogger{}
+			m := flex.New(append(opts, flex.WithSignals(), flex.WithLogger(logger))...)
+			m.Add(flex.NewWorker(func(ctx context.Context) error {
+				cancel()
+				<-ctx.Done()
+				return nil
+			}, func(ctx context.Context) error {
+				flex.LoggerFrom(ctx).Log(ctx, slog.LevelInfo, "flushing")
+				return nil
+			}), flex.WithName("consumer"))
+
+			if err := m.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			e, ok := logger.find("flushing")
+			if !ok || len(e.args) != 2 || e.args[0] != "worker" || e.args[1] != "consumer" {
+				t.Errorf("unexpected entry: %+v", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,724 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Manager runs a set of workers and manages their lifecycle.
+type Manager struct {
+	opts     options
+	workers  []*managedWorker
+	disabled []*managedWorker
+
+	mu          sync.Mutex
+	startedAt   time.Time
+	started     chan struct{}
+	stopping    bool
+	subscribers []chan Event
+	observers   []func(Event)
+	report      *ShutdownReport
+
+	// healthMu serializes the observations of the health of the workers.
+	healthMu sync.Mutex
+}
+
+// New returns a new Manager configured with the given options, which take
+// precedence over those set by environment variables, see StartTimeoutEnv,
+// and over the runtime detected by DetectRuntime.
+func New(opts ...Option) *Manager {
+	var detected []Option
+	if runtime, ok := DetectRuntime(); ok {
+		detected = append(detected, WithRuntime(runtime))
+	}
+	return newManager(append(append(detected, envOptions()...), opts...)...)
+}
+
+// newManager returns a new Manager configured with the given options only,
+// ignoring the environment.
+func newManager(opts ...Option) *Manager {
+	m := &Manager{opts: options{
+		signals:       DefaultSignals,
+		reloadSignals: DefaultReloadSignals,
+		dumpSignals:   DefaultDumpSignals,
+		ignoredErrors: DefaultIgnoredErrors,
+		logger:        stdLogger{l: logger},
+	}, started: make(chan struct{})}
+	for _, opt := range opts {
+		opt(&m.opts)
+	}
+	if l, ok := m.opts.logger.(stdLogger); ok && m.opts.logLevel != nil {
+		l.level = m.opts.logLevel
+		m.opts.logger = l
+	}
+	return m
+}
+
+// Add registers a worker with the manager, it will be run when the manager starts.
+// Workers disabled by name are skipped, see WithDisabledWorkers.
+func (m *Manager) Add(w Worker, opts ...WorkerOption) {
+	wrk := &managedWorker{Worker: w, emit: m.emit}
+	for _, opt := range opts {
+		opt(&wrk.opts)
+	}
+	if !m.enabled(wrk) {
+		m.disabled = append(m.disabled, wrk)
+		return
+	}
+	wrk.logger, wrk.verbose = withWorker(m.opts.logger, wrk.name()), m.opts.logTransitions
+	m.workers = append(m.workers, wrk)
+}
+
+// Workers returns the workers added to the manager, in the order they were
+// added. The workers of its namespaces and disabled workers are not included.
+func (m *Manager) Workers() []Worker {
+	workers := make([]Worker, 0, len(m.workers))
+	for _, worker := range m.workers {
+		if _, ok := worker.Worker.(*namespaceWorker); !ok {
+			workers = append(workers, worker.Worker)
+		}
+	}
+	return workers
+}
+
+// Started returns a channel which is closed once every worker of the running,
+// or next, Start has started, that is entered Run, or called Ready or
+// returned from Run if it waits for Ready, see WithStartedOnReady.
+// It is not closed when the manager shuts down before then.
+func (m *Manager) Started() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started
+}
+
+// MustStart is like Start, but logs the error to the logger of the manager
+// and exits with status 1 if there is an error, as MustStart does.
+func (m *Manager) MustStart(ctx context.Context) {
+	if err := m.Start(ctx); err != nil {
+		m.log(ctx, slog.LevelError, "manager failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// Start is a blocking operation that will start processing the workers.
+// Workers implementing Validator are validated first, and Start returns their
+// errors if any fails. Init jobs are run next, and Start returns an
+// *InitError if one fails.
+// Workers failing with a recoverable error are restarted according to their
+// RestartPolicy, see Recoverable.
+// Once the context is done, or any worker fails, every worker is halted and
+// Start returns after all of them have returned from both Run and Halt, or
+// failed with ErrHaltTimeout, see WithHaltTimeout. Halted workers which have
+// not returned from Run within the halt timeout, or DefaultReturnTimeout, are
+// not waited on any longer.
+// Errors returned once the shutdown was requested are ignored when they match
+// DefaultIgnoredErrors, see WithIgnoredErrors.
+// Every other error returned by Run or Halt is annotated as a *WorkerError and
+// collected into the returned MultiError, or joined with errors.Join when
+// WithJoinedErrors is set.
+func (m *Manager) Start(ctx context.Context) error {
+	if len(m.workers) < 1 {
+		return ErrNoWorkers
+	}
+
+	for _, worker := range m.workers {
+		if worker.Worker == nil {
+			return ErrNilWorker
+		}
+	}
+	if err := m.checkDependencies(); err != nil {
+		return err
+	}
+
+	m.writeManifestFile(ctx)
+
+	if m.opts.identity != nil {
+		ctx = withIdentity(ctx, *m.opts.identity)
+	}
+	m.logBanner(ctx)
+
+	if err := m.validateWorkers(ctx); err != nil {
+		return err
+	}
+	if err := m.runInitJobs(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.startedAt, m.stopping = time.Now(), false
+	select {
+	case <-m.started:
+		m.started = make(chan struct{})
+	default:
+	}
+	started := m.started
+	m.mu.Unlock()
+
+	shutdown := &shutdownState{runtime: m.opts.runtime}
+	ctx = context.WithValue(ctx, shutdownKey{}, shutdown)
+
+	// Workers run under runCtx, which is only cancelled once a requested
+	// shutdown, signalled by the cancellation of ctx, has been allowed to
+	// proceed. The deadline of the parent context remains a hard limit.
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRun()
+	runCtx = context.WithValue(runCtx, managerKey{}, m)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		runCtx, cancelDeadline = context.WithDeadline(runCtx, deadline)
+		defer cancelDeadline()
+	}
+
+	ctx, requestShutdown := context.WithCancelCause(ctx)
+	defer requestShutdown(nil)
+
+	var (
+		errs = collector{join: m.opts.joinErrors}
+		runs sync.WaitGroup
+	)
+
+	shutdownSignals := slices.Clone(m.opts.signals)
+	if m.opts.dumpShutdown {
+		shutdownSignals = append(shutdownSignals, m.opts.dumpSignals...)
+	}
+
+	if handlers := m.signalHandlers(); len(shutdownSignals) > 0 || len(handlers) > 0 {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, append(slices.Clone(shutdownSignals), slices.Collect(maps.Keys(handlers))...)...)
+		defer signal.Stop(sigC)
+
+		runs.Add(1)
+		go func() {
+			defer runs.Done()
+
+			for {
+				select {
+				case <-runCtx.Done():
+					return
+				case sig := <-sigC:
+					m.emit(Event{Kind: EventSignalReceived, Signal: sig})
+					if slices.Contains(shutdownSignals, sig) {
+						requestShutdown(&SignalError{Signal: sig})
+					}
+					for _, handle := range handlers[sig] {
+						if err := handle(runCtx); err != nil {
+							m.log(runCtx, slog.LevelError, "handling signal failed", "signal", sig, "error", err)
+							errs.add(err)
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	// Every worker is reset before any runs, as workers wait on the workers
+	// they depend on to have started.
+	for _, worker := range m.workers {
+		worker.started = make(chan struct{})
+		worker.returned = make(chan struct{})
+		worker.startOnce = sync.Once{}
+		worker.abandoned = false
+		worker.setState(StateStarting)
+	}
+
+	for _, worker := range m.workers {
+		go func(worker *managedWorker) {
+			defer close(worker.returned)
+			defer worker.markStarted()
+
+			if !m.awaitDependencies(runCtx, worker) {
+				return
+			}
+
+			for restarts := 0; ; restarts++ {
+				m.emit(Event{Kind: EventWorkerStarting, Worker: worker.Worker, WorkerName: worker.name(), Restarts: restarts})
+				if !worker.awaitsReady(m.opts) {
+					worker.markStarted()
+				}
+				err := m.call(context.WithValue(runCtx, workerKey{}, worker), worker, worker.Run)
+				if restartErr := worker.takeRestartErr(); restartErr != nil && runCtx.Err() == nil {
+					err = restartErr
+				}
+				if err == nil || m.ignored(ctx, err) {
+					worker.setState(StateStopped)
+					m.emit(Event{Kind: EventWorkerStopped, Worker: worker.Worker, WorkerName: worker.name()})
+					worker.logTransition(runCtx, slog.LevelInfo, "worker stopped",
+						"phase", PhaseRun.String(), "duration", worker.snapshot().uptime(time.Now()))
+					return
+				}
+
+				worker.setError(err)
+				worker.setState(StateFailed)
+				m.workerFailed(runCtx, worker, err)
+
+				if !m.restart(runCtx, worker, err, restarts) {
+					errs.add(m.annotate(worker, PhaseRun, err))
+					requestShutdown(err)
+					return
+				}
+				worker.restart()
+				worker.logTransition(runCtx, slog.LevelWarn, "worker restarted", "restarts", restarts+1)
+			}
+		}(worker)
+
+		if timeout := worker.startTimeout(m.opts); timeout > 0 {
+			runs.Add(1)
+			go func(worker *managedWorker) {
+				defer runs.Done()
+
+				timer := time.NewTimer(timeout)
+				defer timer.Stop()
+
+				select {
+				case <-worker.started:
+				case <-ctx.Done():
+				case <-timer.C:
+					err := fmt.Errorf("%w after %s", ErrStartTimeout, timeout)
+					m.workerFailed(runCtx, worker, err)
+					errs.add(m.annotate(worker, PhaseRun, err))
+					requestShutdown(err)
+				}
+			}(worker)
+		}
+
+		runs.Add(1)
+		go func(worker *managedWorker) {
+			defer runs.Done()
+			m.warnSlowStart(ctx, worker)
+		}(worker)
+
+		runs.Add(1)
+		go func(worker *managedWorker) {
+			defer runs.Done()
+
+			m.watchHeartbeat(ctx, worker, func(err error) {
+				worker.setError(err)
+				m.workerFailed(runCtx, worker, err)
+				errs.add(m.annotate(worker, PhaseRun, err))
+				requestShutdown(err)
+			})
+		}(worker)
+	}
+
+	if interval := m.healthInterval(); interval > 0 {
+		runs.Add(1)
+		go func() {
+			defer runs.Done()
+			m.watchHealth(ctx, interval)
+		}()
+	}
+
+	runs.Add(1)
+	go func() {
+		defer runs.Done()
+
+		for _, worker := range m.workers {
+			select {
+			case <-worker.started:
+			case <-ctx.Done():
+				return
+			}
+		}
+		// Workers returning from Run because of a shutdown are marked as
+		// started, but did not start.
+		if ctx.Err() == nil && runCtx.Err() == nil {
+			close(started)
+		}
+	}()
+
+	<-ctx.Done()
+	began := time.Now()
+	shutdown.begin(began)
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	m.emit(Event{Kind: EventShutdownBegan, Err: context.Cause(ctx)})
+	m.logTransition(runCtx, slog.LevelInfo, "shutdown began", "cause", context.Cause(ctx))
+
+	report := ShutdownReport{Cause: context.Cause(ctx), Began: began, Workers: make([]WorkerShutdown, len(m.workers))}
+
+	m.awaitShutdownPolicy(runCtx, context.Cause(ctx))
+	m.awaitShutdownDelay(runCtx, began)
+	cancelRun()
+
+	for _, band := range m.haltBands() {
+		var wg sync.WaitGroup
+		wg.Add(len(band))
+
+		// Workers are halted before the workers they depend on.
+		halted := make(map[*managedWorker]chan struct{}, len(band))
+		for _, worker := range band {
+			halted[worker] = make(chan struct{})
+		}
+
+		for _, worker := range band {
+			go func(worker *managedWorker) {
+				defer wg.Done()
+				defer close(halted[worker])
+				m.awaitDependents(worker, halted)
+
+				worker.setState(StateStopping)
+				start := time.Now()
+				halted := m.warnSlowHalt(runCtx, worker)
+				err := m.halt(runCtx, worker)
+				halted()
+				forced := errors.Is(err, ErrHaltTimeout)
+				if m.ignored(ctx, err) {
+					err = nil
+				}
+				duration := time.Since(start)
+				worker.setHaltDuration(duration)
+				m.emit(Event{Kind: EventWorkerHalted, Worker: worker.Worker, WorkerName: worker.name(), Err: err, Duration: duration})
+				report.Workers[slices.Index(m.workers, worker)] = WorkerShutdown{
+					Name: worker.name(), HaltDuration: duration, Err: err, Forced: forced,
+				}
+				if err != nil {
+					worker.logTransition(runCtx, slog.LevelError, "worker failed to halt",
+						"phase", PhaseHalt.String(), "duration", duration, "forced", forced, "error", err)
+				} else {
+					worker.logTransition(runCtx, slog.LevelInfo, "worker halted",
+						"phase", PhaseHalt.String(), "duration", duration)
+				}
+				worker.setError(err)
+				m.handleError(runCtx, worker, PhaseHalt, err)
+				errs.add(m.annotate(worker, PhaseHalt, err))
+			}(worker)
+		}
+
+		wg.Wait()
+	}
+
+	m.awaitReturns(runCtx)
+	runs.Wait()
+
+	err := errs.err()
+	report.Duration, report.Err = time.Since(began), err
+	m.reportShutdown(runCtx, report)
+	m.emit(Event{Kind: EventShutdownFinished, Err: err})
+
+	args := []any{"duration", report.Duration, "forced", report.Forced()}
+	if slowest, ok := report.Slowest(); ok {
+		args = append(args, "slowest", slowest.Name, "slowest_duration", slowest.HaltDuration)
+	}
+	if err != nil {
+		m.logTransition(runCtx, slog.LevelError, "shutdown finished", append(args, "error", err)...)
+	} else {
+		m.logTransition(runCtx, slog.LevelInfo, "shutdown finished", args...)
+	}
+	return err
+}
+
+// awaitReturns waits for the workers to return from Run, so that the errors
+// caused by halting them, and those racing with the shutdown, are not lost.
+// Workers which were abandoned as they did not halt in time are not waited
+// on, and the others are waited on for at most the halt timeout, or
+// DefaultReturnTimeout without one, as a worker whose Run ignores both its
+// context and Halt would never return.
+func (m *Manager) awaitReturns(ctx context.Context) {
+	timeout, ok := m.haltTimeout(ctx)
+	if !ok {
+		timeout = DefaultReturnTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	expired := false
+	for _, worker := range m.workers {
+		if worker.abandoned {
+			continue
+		}
+		if !expired {
+			select {
+			case <-worker.returned:
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+		select {
+		case <-worker.returned:
+		default:
+			worker.logTransition(ctx, slog.LevelWarn, "worker did not return from run",
+				"phase", PhaseRun.String(), "timeout", timeout)
+		}
+	}
+}
+
+// workerFailed reports that worker failed to run with err to the error
+// handler, the subscribers and the logger.
+func (m *Manager) workerFailed(ctx context.Context, worker *managedWorker, err error) {
+	m.handleError(ctx, worker, PhaseRun, err)
+	m.emit(Event{Kind: EventWorkerFailed, Worker: worker.Worker, WorkerName: worker.name(), Err: err})
+	worker.logTransition(ctx, slog.LevelError, "worker failed",
+		"phase", PhaseRun.String(), "duration", worker.snapshot().uptime(time.Now()), "error", err)
+}
+
+// call calls fn, the Run, Halt or Reload of worker, with ctx carrying the
+// pprof label worker=<name>, which the goroutines it starts inherit, so that
+// profiles can be attributed to the worker. Its panics are recovered if
+// enabled with WithPanicHandler.
+func (m *Manager) call(ctx context.Context, worker *managedWorker, fn func(context.Context) error) (err error) {
+	pprof.Do(ctx, pprof.Labels("worker", worker.name()), func(ctx context.Context) {
+		defer m.recoverPanic(worker, &err)
+		err = fn(ctx)
+	})
+	return err
+}
+
+// halt halts worker. With a halt timeout, Halt is given a context expiring
+// after the timeout instead of ctx, and the worker is abandoned, failing with
+// ErrHaltTimeout, if it has not returned by then.
+func (m *Manager) halt(ctx context.Context, worker *managedWorker) error {
+	ctx = context.WithValue(ctx, workerKey{}, worker)
+	timeout, ok := m.haltTimeout(ctx)
+	if !ok {
+		return m.call(ctx, worker, worker.Halt)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() { errC <- m.call(ctx, worker, worker.Halt) }()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		worker.abandoned = true
+		worker.setState(StateFailed)
+		return fmt.Errorf("%w after %s", ErrHaltTimeout, timeout)
+	}
+}
+
+// haltTimeout returns how long a worker halting now is given, and whether it
+// is given a limited time: the halt timeout, bounded by the time left until
+// the halt margin before the runtime kills the process, if known and earlier
+// than the deadline of ctx, so that the workers which do not halt in time are
+// abandoned before it does.
+func (m *Manager) haltTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := m.opts.haltTimeout, m.opts.haltTimeout > 0
+
+	if state, found := ctx.Value(shutdownKey{}).(*shutdownState); found {
+		deadline, hasDeadline := ctx.Deadline()
+		if at, set := state.deadline(); set && (!hasDeadline || at.Before(deadline)) {
+			margin := state.runtime.GracePeriod / 10
+			if m.opts.haltMargin != nil {
+				margin = *m.opts.haltMargin
+			}
+			if left := max(time.Until(at.Add(-margin)), 0); !ok || left < timeout {
+				timeout, ok = left, true
+			}
+		}
+	}
+	return timeout, ok
+}
+
+// awaitShutdownPolicy returns once the policy, if any, allows the shutdown
+// caused by cause to proceed, or once the shutdown deadline has passed.
+func (m *Manager) awaitShutdownPolicy(ctx context.Context, cause error) {
+	if m.opts.policy == nil {
+		return
+	}
+
+	deadline, ok := Deadline(ctx)
+	ctx = context.WithoutCancel(ctx)
+	if ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	decision := Decision{Kind: DecisionShutdown, Cause: cause}
+	for {
+		allow, err := m.opts.policy.Allow(ctx, decision)
+		if err != nil {
+			m.log(ctx, slog.LevelWarn, "policy failed, proceeding with shutdown", "error", err)
+			return
+		}
+		if allow {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			m.log(ctx, slog.LevelWarn, "policy did not allow the shutdown before its deadline, proceeding")
+			return
+		case <-time.After(policyRetryInterval):
+		}
+	}
+}
+
+// awaitShutdownDelay waits until the shutdown delay has passed since the
+// shutdown began, or until the shutdown deadline, whichever comes first.
+func (m *Manager) awaitShutdownDelay(ctx context.Context, began time.Time) {
+	wait := time.Until(began.Add(m.opts.shutdownDelay))
+	if deadline, ok := Deadline(ctx); ok {
+		wait = min(wait, time.Until(deadline))
+	}
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	<-timer.C
+}
+
+// SignalError is the cause of a shutdown requested by a signal.
+type SignalError struct{ Signal os.Signal }
+
+// Error returns a string representation of the SignalError.
+func (e *SignalError) Error() string { return "received signal " + e.Signal.String() }
+
+// Reload calls Reload on every worker implementing Reloader, one after the
+// other in the order they were added, and returns a MultiError holding the
+// errors of those which failed, annotated as *WorkerError, or those errors
+// joined with errors.Join when WithJoinedErrors is set.
+func (m *Manager) Reload(ctx context.Context) error {
+	errs := collector{join: m.opts.joinErrors}
+	for _, worker := range m.workers {
+		if reloader, ok := worker.Worker.(Reloader); ok {
+			err := m.call(ctx, worker, reloader.Reload)
+			m.handleError(ctx, worker, PhaseReload, err)
+			errs.add(m.annotate(worker, PhaseReload, err))
+		}
+	}
+
+	err := errs.err()
+	m.emit(Event{Kind: EventReloaded, Err: err})
+	return err
+}
+
+// signalHandlers returns the handlers to call for each signal received while
+// running, reloading workers on reload signals and writing diagnostic dumps
+// on dump signals first.
+func (m *Manager) signalHandlers() map[os.Signal][]func(context.Context) error {
+	handlers := make(map[os.Signal][]func(context.Context) error)
+	for _, sig := range m.opts.reloadSignals {
+		handlers[sig] = append(handlers[sig], m.Reload)
+	}
+	for _, sig := range m.opts.dumpSignals {
+		handlers[sig] = append(handlers[sig], m.logDump)
+	}
+	for _, h := range m.opts.signalHandlers {
+		handlers[h.sig] = append(handlers[h.sig], h.fn)
+	}
+	return handlers
+}
+
+// haltBands groups the workers by shutdown priority, lowest priority first.
+func (m *Manager) haltBands() [][]*managedWorker {
+	byPriority := make(map[int][]*managedWorker)
+	for _, worker := range m.workers {
+		byPriority[worker.opts.priority] = append(byPriority[worker.opts.priority], worker)
+	}
+
+	bands := make([][]*managedWorker, 0, len(byPriority))
+	for _, priority := range slices.Sorted(maps.Keys(byPriority)) {
+		bands = append(bands, byPriority[priority])
+	}
+	return bands
+}
+
+// Ready marks the worker owning ctx as started.
+// Workers should call it from Run once they are up, for example after binding
+// their listener, when they are configured to wait for it, with a start
+// timeout or WithStartedOnReady, and may call it otherwise. Calling Ready with a
+// context which was not passed to Run by flex is a no-op.
+func Ready(ctx context.Context) {
+	if worker, ok := ctx.Value(workerKey{}).(*managedWorker); ok {
+		worker.markStarted()
+	}
+}
+
+// workerKey is the context key under which a worker is stored in the
+// contexts given to its Run and Halt.
+type workerKey struct{}
+
+// ManagerFromContext returns the manager running the worker owning ctx, and
+// whether there is one, so that workers can report on the other workers of
+// their manager.
+func ManagerFromContext(ctx context.Context) (*Manager, bool) {
+	m, ok := ctx.Value(managerKey{}).(*Manager)
+	return m, ok
+}
+
+// managerKey is the context key under which the manager running the workers
+// is stored.
+type managerKey struct{}
+
+// managedWorker is a Worker registered with a Manager, along with its options and state.
+type managedWorker struct {
+	Worker
+	opts workerOptions
+
+	startOnce sync.Once
+	started   chan struct{}
+	returned  chan struct{}
+	abandoned bool
+	emit      func(Event)
+
+	mu     sync.Mutex
+	status workerStatus
+	// startDuration and haltDuration are how long the worker last took to
+	// start and to halt, see Manager.Stats.
+	startDuration time.Duration
+	haltDuration  time.Duration
+	// logger is the logger of the manager, scoped to the worker, which logs
+	// its transitions if verbose.
+	logger  Logger
+	verbose bool
+	// restartErr is the error the worker is being halted for, to be
+	// restarted, such as that of a missed heartbeat.
+	restartErr error
+	// health is the health status last observed, and unhealthySince when it
+	// was first observed unhealthy while running, guarded by the healthMu of
+	// the manager.
+	health         HealthStatus
+	unhealthySince time.Time
+}
+
+// markStarted records that the worker has started, it is safe to call more
+// than once. A restarted worker is marked as running again, but its start is
+// only waited on the first time.
+func (w *managedWorker) markStarted() {
+	if w.setState(StateRunning) && w.emit != nil {
+		status := w.snapshot()
+		duration := status.startedAt.Sub(status.runAt)
+		w.setStartDuration(duration)
+		w.emit(Event{Kind: EventWorkerStarted, Worker: w.Worker, WorkerName: w.name(), Duration: duration})
+		w.logTransition(context.Background(), slog.LevelInfo, "worker started",
+			"duration", duration, "restarts", status.restarts)
+	}
+	w.startOnce.Do(func() { close(w.started) })
+}
+
+// awaitsReady reports whether the worker only starts once it calls Ready,
+// rather than once its Run is entered: when it is configured with
+// WithStartedOnReady, has a start timeout or is a ReadyReporter reporting so.
+func (w *managedWorker) awaitsReady(opts options) bool {
+	if r, ok := w.Worker.(ReadyReporter); ok && r.ReportsReady() {
+		return true
+	}
+	return w.opts.readyStarted || w.startTimeout(opts) > 0
+}
+
+// startTimeout returns the start timeout for the worker, preferring its own
+// override over the manager-wide setting.
+func (w *managedWorker) startTimeout(opts options) time.Duration {
+	if w.opts.startTimeout != nil {
+		return *w.opts.startTimeout
+	}
+	return opts.startTimeout
+}
@@ -0,0 +1,576 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// blockingMockWorker blocks in Run until its context is done, optionally
+// reporting itself as ready first.
+type blockingMockWorker struct {
+	mockWorker
+	ready bool
+}
+
+func (b *blockingMockWorker) Run(ctx context.Context) error {
+	if b.ready {
+		flex.Ready(ctx)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestManagerStartTimeout(t *testing.T) {
+	t.Run("a worker which never starts must time out", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithStartTimeout(50 * time.Millisecond))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		err := m.Start(ctx)
+		if !errors.Is(err, flex.ErrStartTimeout) {
+			t.Errorf("expected %v but got: %v", flex.ErrStartTimeout, err)
+		}
+		if ctx.Err() != nil {
+			t.Error("expected the start timeout to trigger a shutdown before the context expired")
+		}
+	})
+	t.Run("a worker reporting ready must not time out", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithStartTimeout(50 * time.Millisecond))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a worker returning from run must not time out", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithStartTimeout(50 * time.Millisecond))
+		m.Add(&mockWorker{t: t, name: "foo"})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a worker override must take precedence", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithStartTimeout(50 * time.Millisecond))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}, flex.WithWorkerStartTimeout(0))
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// recordingMockWorker records the name of the worker into halted when halting.
+type recordingMockWorker struct {
+	mockWorker
+	mu     *sync.Mutex
+	halted *[]string
+}
+
+func (r *recordingMockWorker) Halt(context.Context) error {
+	time.Sleep(10 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.halted = append(*r.halted, r.name)
+	return nil
+}
+
+func TestManagerPriority(t *testing.T) {
+	t.Run("workers must halt in priority order", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		var (
+			mu     sync.Mutex
+			halted []string
+		)
+		newWorker := func(name string) flex.Worker {
+			return &recordingMockWorker{mockWorker: mockWorker{t: t, name: name}, mu: &mu, halted: &halted}
+		}
+
+		m := flex.New()
+		m.Add(newWorker("database"), flex.WithPriority(2))
+		m.Add(newWorker("api"))
+		m.Add(newWorker("consumer"), flex.WithPriority(1))
+		m.Add(newWorker("cache"), flex.WithPriority(1))
+
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(halted) != 4 {
+			t.Fatalf("expected 4 halted workers, but got %v", halted)
+		}
+		if halted[0] != "api" || halted[3] != "database" {
+			t.Errorf("expected api to halt first and database last, but got %v", halted)
+		}
+	})
+}
+
+// haltFailingMockWorker blocks in Run until halted, and fails both to halt
+// and, once halted, to run.
+type haltFailingMockWorker struct {
+	mockWorker
+	halted chan struct{}
+}
+
+func newHaltFailingMockWorker(t *testing.T, name string) *haltFailingMockWorker {
+	return &haltFailingMockWorker{mockWorker: mockWorker{t: t, name: name}, halted: make(chan struct{})}
+}
+
+func (h *haltFailingMockWorker) Run(context.Context) error {
+	<-h.halted
+	time.Sleep(10 * time.Millisecond)
+	return errors.New(h.name + " run failed")
+}
+
+func (h *haltFailingMockWorker) Halt(context.Context) error {
+	close(h.halted)
+	return errors.New(h.name + " halt failed")
+}
+
+func TestManagerErrors(t *testing.T) {
+	t.Run("every run and halt error must be returned", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		m := flex.New()
+		m.Add(newHaltFailingMockWorker(t, "foo"))
+		m.Add(newHaltFailingMockWorker(t, "bar"), flex.WithPriority(1))
+		m.Add(newHaltFailingMockWorker(t, "baz"), flex.WithPriority(1))
+
+		err := m.Start(ctx)
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected an error of type %T, but got: %T", flex.MultiError{}, err)
+		}
+		if len(merr.Errors) != 6 {
+			t.Errorf("expected 6 errors, but got %d: %v", len(merr.Errors), merr.Errors)
+		}
+	})
+	t.Run("a run error must be returned alongside halt errors", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New()
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "foo"}})
+		m.Add(newHaltFailingMockWorker(t, "bar"))
+
+		err := m.Start(ctx)
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected an error of type %T, but got: %T", flex.MultiError{}, err)
+		}
+		if len(merr.Errors) != 3 {
+			t.Errorf("expected 3 errors, but got %d: %v", len(merr.Errors), merr.Errors)
+		}
+	})
+	t.Run("errors must be joined when configured to", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithJoinedErrors())
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "foo"}})
+		m.Add(newHaltFailingMockWorker(t, "bar"))
+
+		err := m.Start(ctx)
+
+		var merr flex.MultiError
+		if errors.As(err, &merr) {
+			t.Fatalf("expected no %T, but got: %v", merr, err)
+		}
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok || len(joined.Unwrap()) != 3 {
+			t.Fatalf("expected 3 joined errors, but got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "run failed") {
+			t.Errorf("expected every error to be displayed, but got: %q", err.Error())
+		}
+	})
+	t.Run("no error must be returned as nil when joining", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithJoinedErrors())
+		m.Add(&mockWorker{t: t, name: "foo"})
+
+		if err := m.Start(ctx); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+}
+
+func TestManagerWithoutSignals(t *testing.T) {
+	t.Run("disabled signals must still stop on context cancellation", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// reloadingMockWorker counts its reloads and fails them when asked to.
+type reloadingMockWorker struct {
+	mockWorker
+	reloads atomic.Int32
+	fail    bool
+}
+
+func (r *reloadingMockWorker) Reload(context.Context) error {
+	r.reloads.Add(1)
+	if r.fail {
+		return errors.New("reload failed")
+	}
+	return nil
+}
+
+func TestManagerReload(t *testing.T) {
+	t.Run("reload must reach every reloader and collect errors", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			foo = &reloadingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+			bar = &reloadingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}, fail: true}
+		)
+
+		m := flex.New()
+		m.Add(foo)
+		m.Add(&mockWorker{t: t, name: "baz"})
+		m.Add(bar)
+
+		err := m.Reload(context.Background())
+		if foo.reloads.Load() != 1 || bar.reloads.Load() != 1 {
+			t.Error("expected every reloader to be reloaded once")
+		}
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) || len(merr.Errors) != 1 {
+			t.Errorf("expected a single reload error, but got: %v", err)
+		}
+	})
+}
+
+func TestManagerWorkers(t *testing.T) {
+	t.Run("workers must be returned in the order they were added", func(t *testing.T) {
+		t.Parallel()
+
+		foo, bar := &mockWorker{t: t, name: "foo"}, &mockWorker{t: t, name: "bar"}
+
+		m := flex.New()
+		m.Add(foo)
+		m.Add(bar, flex.WithPriority(1))
+
+		workers := m.Workers()
+		if len(workers) != 2 || workers[0] != foo || workers[1] != bar {
+			t.Errorf("expected [foo bar] but got %v", workers)
+		}
+	})
+}
+
+func TestManagerStarted(t *testing.T) {
+	t.Run("started must be closed once every worker has started", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true})
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}, ready: true})
+
+		started := m.Started()
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Error("expected the manager to report its workers as started")
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("started must not be closed while a worker is starting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true})
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}}, flex.WithStartedOnReady())
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+
+		select {
+		case <-m.Started():
+			t.Error("expected the manager not to report its workers as started")
+		default:
+		}
+	})
+}
+
+// stuckMockWorker blocks in Run and Halt until released, recording whether
+// Halt was passed a live context.
+type stuckMockWorker struct {
+	mockWorker
+	release chan struct{}
+	live    atomic.Bool
+}
+
+func (s *stuckMockWorker) Run(context.Context) error {
+	<-s.release
+	return nil
+}
+
+func (s *stuckMockWorker) Halt(ctx context.Context) error {
+	s.live.Store(ctx.Err() == nil)
+	<-s.release
+	return nil
+}
+
+func TestManagerHaltTimeout(t *testing.T) {
+	t.Run("a worker which does not halt in time must be abandoned", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		worker := &stuckMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, release: make(chan struct{})}
+		defer close(worker.release)
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(20*time.Millisecond))
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, flex.ErrHaltTimeout) {
+				t.Errorf("expected %v but got: %v", flex.ErrHaltTimeout, err)
+			}
+			if halt := flex.PhaseErrors(err, flex.PhaseHalt); len(halt) != 1 {
+				t.Errorf("expected the timeout to be a halt error but got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the manager to give up on the worker")
+		}
+
+		if !worker.live.Load() {
+			t.Error("expected halt to be passed a live context")
+		}
+	})
+	t.Run("a worker halting in time must not time out", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(time.Second))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a run ignoring its context must not block start without a timeout", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&ignoringMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, release: release})
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		select {
+		case err := <-errC:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(flex.DefaultReturnTimeout + time.Second):
+			t.Fatal("expected the manager to stop waiting for the worker to return from run")
+		}
+	})
+}
+
+// ignoringMockWorker blocks in Run until released, ignoring its context, and
+// returns from Halt right away.
+type ignoringMockWorker struct {
+	mockWorker
+	release chan struct{}
+}
+
+func (i *ignoringMockWorker) Run(context.Context) error {
+	<-i.release
+	return nil
+}
+
+func TestManagerShutdownDelay(t *testing.T) {
+	t.Run("workers must keep running while not ready for the delay", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		delay := 50 * time.Millisecond
+		m := flex.New(flex.WithSignals(), flex.WithShutdownDelay(delay))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: true})
+		events := m.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		if h := m.Health(context.Background()); !h.Ready || h.Stopping {
+			t.Errorf("expected the manager to be ready but got: %+v", h)
+		}
+
+		start := time.Now()
+		cancel()
+		for e := range events {
+			if e.Kind == flex.EventShutdownBegan {
+				break
+			}
+		}
+
+		h := m.Health(context.Background())
+		if h.Ready || !h.Stopping || h.Workers[0].State != flex.StateRunning {
+			t.Errorf("expected the manager not to be ready while its worker runs but got: %+v", h)
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("expected the workers to be halted after %s but they were after: %s", delay, elapsed)
+		}
+	})
+	t.Run("the delay must be cut short by the shutdown deadline", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithSignals(), flex.WithShutdownDelay(time.Hour),
+			flex.WithRuntime(flex.Runtime{Name: "test", GracePeriod: 20 * time.Millisecond}))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		select {
+		case err := <-errC:
+			// The delay leaves no time to halt before the deadline.
+			if err != nil && !errors.Is(err, flex.ErrHaltTimeout) {
+				t.Error(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the delay to end by the shutdown deadline")
+		}
+	})
+}
+
+// labelMockWorker records the pprof label "worker" its Run and Halt are
+// called with.
+type labelMockWorker struct {
+	mockWorker
+	run, halt atomic.Value
+}
+
+func (l *labelMockWorker) Run(ctx context.Context) error {
+	label, _ := pprof.Label(ctx, "worker")
+	l.run.Store(label)
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (l *labelMockWorker) Halt(ctx context.Context) error {
+	label, _ := pprof.Label(ctx, "worker")
+	l.halt.Store(label)
+	return nil
+}
+
+func TestManagerProfileLabels(t *testing.T) {
+	t.Run("workers must be run and halted under their pprof label", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &labelMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(time.Second))
+		m.Add(worker, flex.WithName("foo"))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		if run, halt := worker.run.Load(), worker.halt.Load(); run != "foo" || halt != "foo" {
+			t.Errorf("expected %q but got: %v and %v", "foo", run, halt)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+//go:build unix
+
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// readyMockWorker reports itself as ready on a channel and blocks until its
+// context is done.
+type readyMockWorker struct {
+	mockWorker
+	ready chan struct{}
+}
+
+func (r *readyMockWorker) Run(ctx context.Context) error {
+	close(r.ready)
+	<-ctx.Done()
+	return nil
+}
+
+func TestManagerSignals(t *testing.T) {
+	t.Run("a configured signal must trigger a shutdown", func(t *testing.T) {
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+
+		var cause error
+		m := flex.New(
+			flex.WithSignals(syscall.SIGUSR2),
+			flex.WithPolicy(flex.PolicyFunc(func(_ context.Context, d flex.Decision) (bool, error) {
+				cause = d.Cause
+				return true, nil
+			})),
+		)
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-errC:
+			if err != nil {
+				t.Error(err)
+			}
+			if ctx.Err() != nil {
+				t.Error("expected the signal to trigger a shutdown before the context expired")
+			}
+			var sigErr *flex.SignalError
+			if !errors.As(cause, &sigErr) || sigErr.Signal != syscall.SIGUSR2 {
+				t.Errorf("expected the signal to be the cause of the shutdown, but got: %v", cause)
+			}
+		case <-time.After(time.Second):
+			t.Error("expected the signal to trigger a shutdown")
+		}
+	})
+}
+
+// reloadableReadyMockWorker reports itself as ready and notifies reloads on a channel.
+type reloadableReadyMockWorker struct {
+	readyMockWorker
+	reloaded chan struct{}
+}
+
+func (r *reloadableReadyMockWorker) Reload(context.Context) error {
+	r.reloaded <- struct{}{}
+	return nil
+}
+
+func TestManagerReloadSignals(t *testing.T) {
+	t.Run("a reload signal must reload workers without stopping them", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		worker := &reloadableReadyMockWorker{
+			readyMockWorker: readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})},
+			reloaded:        make(chan struct{}, 1),
+		}
+
+		m := flex.New(flex.WithReloadSignals(syscall.SIGUSR1))
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-worker.reloaded:
+		case <-time.After(time.Second):
+			t.Fatal("expected the signal to reload the worker")
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the manager to keep running until the context expired")
+		}
+	})
+}
+
+func TestManagerOnSignal(t *testing.T) {
+	t.Run("a signal handler must be called and its error returned", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+		called := make(chan struct{}, 1)
+
+		m := flex.New(flex.OnSignal(syscall.SIGUSR1, func(context.Context) error {
+			called <- struct{}{}
+			return errors.New("handler failed")
+		}))
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-called:
+		case <-time.After(time.Second):
+			t.Fatal("expected the signal handler to be called")
+		}
+
+		if err := <-errC; err == nil {
+			t.Error("expected an error but did not get one")
+		}
+	})
+}
+
+func TestManagerDumpSignals(t *testing.T) {
+	t.Run("a dump signal must not stop the manager", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+
+		m := flex.New(flex.WithDumpSignals(syscall.SIGUSR1))
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the manager to keep running until the context expired")
+		}
+	})
+	t.Run("a dump signal must stop the manager when configured to", func(t *testing.T) {
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+
+		m := flex.New(flex.WithDumpSignals(syscall.SIGUSR1), flex.WithShutdownOnDump())
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if ctx.Err() != nil {
+			t.Error("expected the signal to trigger a shutdown before the context expired")
+		}
+	})
+	t.Run("a dump signal must log the fields of the workers as attributes", func(t *testing.T) {
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithLogger(logger), flex.WithDumpSignals(syscall.SIGUSR1), flex.WithShutdownOnDump())
+		m.Add(worker, flex.WithName("foo"))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errC; err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := logger.find("worker dump")
+		if !ok {
+			t.Fatalf("expected the worker to be dumped but got: %+v", logger.entries)
+		}
+		attrs := make(map[string]string)
+		for _, attr := range flex.Attrs(e.args...) {
+			attrs[attr.Key] = attr.Value.String()
+		}
+		if attrs["worker"] != "foo" || attrs["state"] != flex.StateRunning.String() {
+			t.Errorf("unexpected attributes: %v", attrs)
+		}
+	})
+}
+
+func TestManagerSignalEvents(t *testing.T) {
+	t.Run("a received signal must be emitted", func(t *testing.T) {
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &readyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, ready: make(chan struct{})}
+
+		m := flex.New(flex.WithSignals(syscall.SIGUSR2))
+		m.Add(worker)
+		events := m.Subscribe()
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		<-worker.ready
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+			t.Fatal(err)
+		}
+
+		if e := await(events, flex.EventSignalReceived); e.Signal != syscall.SIGUSR2 {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+}
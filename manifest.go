@@ -0,0 +1,414 @@
+package flex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+// ManifestVersion is the version of the manifest format written by flex.
+const ManifestVersion = 1
+
+// Manifest describes the workers of a manager and its options, so that the
+// exact set a service ran with, in production during an incident for
+// example, can be written down and booted again elsewhere, see
+// NewFromManifest. Options which are functions, such as policies and
+// handlers, cannot be described, and neither are namespaces and the errors
+// set with WithIgnoredErrors.
+//
+// Fields left empty describe no option, so that booting the manifest keeps
+// the value given to NewFromManifest, or the default. The signals are only
+// left empty when nil, as an empty list describes a manager handling none.
+// They are described by name, such as SIGTERM, as their numbers differ
+// between platforms, and by number only when they have no name known to
+// flex, see SignalsEnv.
+type Manifest struct {
+	Version          int                    `json:"version" yaml:"version"`
+	StartTimeout     string                 `json:"start_timeout,omitempty" yaml:"start_timeout,omitempty"`
+	HaltTimeout      string                 `json:"halt_timeout,omitempty" yaml:"halt_timeout,omitempty"`
+	ShutdownDelay    string                 `json:"shutdown_delay,omitempty" yaml:"shutdown_delay,omitempty"`
+	SlowStartWarning string                 `json:"slow_start_warning,omitempty" yaml:"slow_start_warning,omitempty"`
+	SlowHaltWarning  string                 `json:"slow_halt_warning,omitempty" yaml:"slow_halt_warning,omitempty"`
+	HealthInterval   string                 `json:"health_interval,omitempty" yaml:"health_interval,omitempty"`
+	Signals          []string               `json:"signals" yaml:"signals"`
+	ReloadSignals    []string               `json:"reload_signals" yaml:"reload_signals"`
+	DumpSignals      []string               `json:"dump_signals" yaml:"dump_signals"`
+	ShutdownOnDump   bool                   `json:"shutdown_on_dump,omitempty" yaml:"shutdown_on_dump,omitempty"`
+	JoinErrors       bool                   `json:"join_errors,omitempty" yaml:"join_errors,omitempty"`
+	NamespaceLimit   int                    `json:"namespace_limit,omitempty" yaml:"namespace_limit,omitempty"`
+	RestartPolicy    *ManifestRestartPolicy `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+	Workers          []ManifestWorker       `json:"workers" yaml:"workers"`
+}
+
+// ManifestWorker describes a worker and its options.
+type ManifestWorker struct {
+	// Name is the name of the worker, which binds it to its factory unless
+	// Factory is set.
+	Name string `json:"name" yaml:"name"`
+	// Factory is the name of the factory of the worker, so that several
+	// workers may be created by the same factory. It defaults to Name.
+	Factory string `json:"factory,omitempty" yaml:"factory,omitempty"`
+	// Type is the type of the worker, for information only.
+	Type          string                 `json:"type,omitempty" yaml:"type,omitempty"`
+	Group         string                 `json:"group,omitempty" yaml:"group,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Priority      int                    `json:"priority,omitempty" yaml:"priority,omitempty"`
+	StartTimeout  string                 `json:"start_timeout,omitempty" yaml:"start_timeout,omitempty"`
+	RestartPolicy *ManifestRestartPolicy `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+}
+
+// ManifestRestartPolicy describes a RestartPolicy.
+type ManifestRestartPolicy struct {
+	MaxRestarts int    `json:"max_restarts" yaml:"max_restarts"`
+	Backoff     string `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+}
+
+// Codec serializes manifests. Its functions have the signatures of those of
+// encoding/json, which is what JSON uses, and of most other encoding
+// packages, so that YAML is supported with:
+//
+//	flex.Codec{Marshal: yaml.Marshal, Unmarshal: yaml.Unmarshal}
+type Codec struct {
+	Marshal   func(v any) ([]byte, error)
+	Unmarshal func(data []byte, v any) error
+}
+
+// JSON is the Codec serializing manifests as indented JSON.
+var JSON = Codec{
+	Marshal:   func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") },
+	Unmarshal: json.Unmarshal,
+}
+
+// WorkerFactory returns a new worker to boot from a manifest.
+type WorkerFactory func() (Worker, error)
+
+// Manifest returns the manifest describing the manager.
+func (m *Manager) Manifest() Manifest {
+	manifest := Manifest{
+		Version:        ManifestVersion,
+		Signals:        signalNamesOf(m.opts.signals),
+		ReloadSignals:  signalNamesOf(m.opts.reloadSignals),
+		DumpSignals:    signalNamesOf(m.opts.dumpSignals),
+		ShutdownOnDump: m.opts.dumpShutdown,
+		JoinErrors:     m.opts.joinErrors,
+		NamespaceLimit: m.opts.namespaceLimit,
+		RestartPolicy:  manifestRestartPolicy(m.opts.restartPolicy),
+		Workers:        []ManifestWorker{},
+	}
+	if m.opts.startTimeout > 0 {
+		manifest.StartTimeout = m.opts.startTimeout.String()
+	}
+	if m.opts.haltTimeout > 0 {
+		manifest.HaltTimeout = m.opts.haltTimeout.String()
+	}
+	if m.opts.shutdownDelay > 0 {
+		manifest.ShutdownDelay = m.opts.shutdownDelay.String()
+	}
+	if m.opts.slowStart > 0 {
+		manifest.SlowStartWarning = m.opts.slowStart.String()
+	}
+	if m.opts.slowHalt > 0 {
+		manifest.SlowHaltWarning = m.opts.slowHalt.String()
+	}
+	if m.opts.healthInterval > 0 {
+		manifest.HealthInterval = m.opts.healthInterval.String()
+	}
+
+	for _, worker := range m.workers {
+		if _, ok := worker.Worker.(*namespaceWorker); ok {
+			continue
+		}
+
+		mw := ManifestWorker{
+			Name:      worker.name(),
+			Type:      fmt.Sprintf("%T", worker.Worker),
+			Group:     worker.opts.group,
+			DependsOn: slices.Clone(worker.opts.dependsOn),
+			Priority:  worker.opts.priority,
+		}
+		if worker.opts.startTimeout != nil {
+			mw.StartTimeout = worker.opts.startTimeout.String()
+		}
+		if worker.opts.restartPolicy != nil {
+			mw.RestartPolicy = manifestRestartPolicy(*worker.opts.restartPolicy)
+		}
+		manifest.Workers = append(manifest.Workers, mw)
+	}
+
+	return manifest
+}
+
+// WriteManifest writes manifest to w, serialized with codec.
+func WriteManifest(w io.Writer, manifest Manifest, codec Codec) error {
+	b, err := codec.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// ReadManifest reads a manifest serialized with codec from r. Manifests
+// written with a newer version of the format are rejected.
+func ReadManifest(r io.Reader, codec Codec) (Manifest, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := codec.Unmarshal(b, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("decode manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > ManifestVersion {
+		return Manifest{}, fmt.Errorf("%w %d", ErrManifestVersion, manifest.Version)
+	}
+	return manifest, nil
+}
+
+// Validate checks that manifest can boot a manager with factories, see
+// NewFromManifest: that its version is supported and its durations valid,
+// that its workers have distinct names and a factory, failing with
+// ErrInvalidManifest or ErrNoFactory otherwise, and that their dependencies
+// are known and acyclic, failing with ErrDependency otherwise.
+func (manifest Manifest) Validate(factories map[string]WorkerFactory) error {
+	if manifest.Version < 1 || manifest.Version > ManifestVersion {
+		return fmt.Errorf("%w %d", ErrManifestVersion, manifest.Version)
+	}
+	if _, err := manifest.options(); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(manifest.Workers))
+	nodes := make([]dependencyNode, 0, len(manifest.Workers))
+	for _, mw := range manifest.Workers {
+		switch {
+		case mw.Name == "":
+			return fmt.Errorf("%w: worker without a name", ErrInvalidManifest)
+		case names[mw.Name]:
+			return fmt.Errorf("%w: duplicate worker %q", ErrInvalidManifest, mw.Name)
+		}
+		names[mw.Name] = true
+
+		if _, ok := factories[mw.factory()]; !ok {
+			return fmt.Errorf("%w %q of the manifest", ErrNoFactory, mw.factory())
+		}
+		if _, err := mw.options(); err != nil {
+			return err
+		}
+		nodes = append(nodes, dependencyNode{name: mw.Name, group: mw.Group, dependsOn: mw.DependsOn})
+	}
+
+	return checkDependencies(nodes, nil)
+}
+
+// NewFromManifest returns a new Manager booted from manifest: configured with
+// the given options overridden by those the manifest describes, and running a worker
+// returned by the factory of each worker, with the options of the manifest.
+// It fails when the manifest is invalid, see Manifest.Validate, or a factory
+// fails.
+func NewFromManifest(manifest Manifest, factories map[string]WorkerFactory, opts ...Option) (*Manager, error) {
+	if err := manifest.Validate(factories); err != nil {
+		return nil, err
+	}
+
+	manifestOpts, err := manifest.options()
+	if err != nil {
+		return nil, err
+	}
+	m := New(append(opts, manifestOpts...)...)
+
+	for _, mw := range manifest.Workers {
+		factory := factories[mw.factory()]
+
+		workerOpts, err := mw.options()
+		if err != nil {
+			return nil, err
+		}
+
+		worker, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("create worker %q of the manifest: %w", mw.Name, err)
+		}
+		m.Add(worker, workerOpts...)
+	}
+
+	return m, nil
+}
+
+// writeManifestFile writes the manifest of the manager to the manifest
+// file, if any.
+func (m *Manager) writeManifestFile(ctx context.Context) {
+	if m.opts.manifestFile == "" {
+		return
+	}
+
+	f, err := os.Create(m.opts.manifestFile)
+	if err != nil {
+		m.log(ctx, slog.LevelError, "writing manifest failed", "path", m.opts.manifestFile, "error", err)
+		return
+	}
+	defer f.Close()
+
+	if err := WriteManifest(f, m.Manifest(), m.opts.manifestCodec); err != nil {
+		m.log(ctx, slog.LevelError, "writing manifest failed", "path", m.opts.manifestFile, "error", err)
+	}
+}
+
+// options returns the options described by the manifest, leaving out those
+// of its empty fields.
+func (manifest Manifest) options() ([]Option, error) {
+	var opts []Option
+	for _, d := range []struct {
+		field, s string
+		option   func(time.Duration) Option
+	}{
+		{"start_timeout", manifest.StartTimeout, WithStartTimeout},
+		{"halt_timeout", manifest.HaltTimeout, WithHaltTimeout},
+		{"shutdown_delay", manifest.ShutdownDelay, WithShutdownDelay},
+		{"slow_start_warning", manifest.SlowStartWarning, WithSlowStartWarning},
+		{"slow_halt_warning", manifest.SlowHaltWarning, WithSlowHaltWarning},
+		{"health_interval", manifest.HealthInterval, WithHealthInterval},
+	} {
+		if d.s == "" {
+			continue
+		}
+		v, err := parseManifestDuration(d.field, d.s)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, d.option(v))
+	}
+
+	for _, s := range []struct {
+		field  string
+		names  []string
+		option func(...os.Signal) Option
+	}{
+		{"signals", manifest.Signals, WithSignals},
+		{"reload_signals", manifest.ReloadSignals, WithReloadSignals},
+		{"dump_signals", manifest.DumpSignals, WithDumpSignals},
+	} {
+		if s.names == nil {
+			continue
+		}
+		sigs, err := parseManifestSignals(s.field, s.names)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, s.option(sigs...))
+	}
+	if manifest.ShutdownOnDump {
+		opts = append(opts, WithShutdownOnDump())
+	}
+	if manifest.JoinErrors {
+		opts = append(opts, WithJoinedErrors())
+	}
+	if manifest.NamespaceLimit != 0 {
+		opts = append(opts, WithNamespaceLimit(manifest.NamespaceLimit))
+	}
+	if manifest.RestartPolicy != nil {
+		policy, err := manifest.RestartPolicy.policy()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithRestartPolicy(policy))
+	}
+
+	return opts, nil
+}
+
+// factory returns the name of the factory of the manifest worker.
+func (mw ManifestWorker) factory() string {
+	if mw.Factory != "" {
+		return mw.Factory
+	}
+	return mw.Name
+}
+
+// options returns the options described by the manifest worker.
+func (mw ManifestWorker) options() ([]WorkerOption, error) {
+	opts := []WorkerOption{WithName(mw.Name), WithGroup(mw.Group), WithDependsOn(mw.DependsOn...), WithPriority(mw.Priority)}
+
+	if mw.StartTimeout != "" {
+		startTimeout, err := parseManifestDuration("start_timeout of worker "+mw.Name, mw.StartTimeout)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithWorkerStartTimeout(startTimeout))
+	}
+
+	if mw.RestartPolicy != nil {
+		policy, err := mw.RestartPolicy.policy()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithWorkerRestartPolicy(policy))
+	}
+
+	return opts, nil
+}
+
+// policy returns the described RestartPolicy.
+func (p ManifestRestartPolicy) policy() (RestartPolicy, error) {
+	backoff, err := parseManifestDuration("backoff", p.Backoff)
+	if err != nil {
+		return RestartPolicy{}, err
+	}
+	return RestartPolicy{MaxRestarts: p.MaxRestarts, Backoff: backoff}, nil
+}
+
+// manifestRestartPolicy returns the description of p, or nil if it is the default.
+func manifestRestartPolicy(p RestartPolicy) *ManifestRestartPolicy {
+	if p == (RestartPolicy{}) {
+		return nil
+	}
+	mp := &ManifestRestartPolicy{MaxRestarts: p.MaxRestarts}
+	if p.Backoff > 0 {
+		mp.Backoff = p.Backoff.String()
+	}
+	return mp
+}
+
+// parseManifestDuration parses the duration s of field, an empty string
+// being zero.
+func parseManifestDuration(field, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s in manifest: %w", field, err)
+	}
+	return d, nil
+}
+
+// signalNamesOf returns the names of sigs, see signalName.
+func signalNamesOf(sigs []os.Signal) []string {
+	names := make([]string, 0, len(sigs))
+	for _, sig := range sigs {
+		if name, ok := signalName(sig); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseManifestSignals parses the signals of the manifest field called
+// field, rejecting the names which are not known on this platform.
+func parseManifestSignals(field string, names []string) ([]os.Signal, error) {
+	sigs := make([]os.Signal, 0, len(names))
+	for _, name := range names {
+		sig, err := parseSignal(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in manifest: %w", field, err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
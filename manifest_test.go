@@ -0,0 +1,236 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManifest(t *testing.T) {
+	t.Run("a manifest must describe the manager and its workers", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(
+			flex.WithSignals(syscall.SIGTERM),
+			flex.WithStartTimeout(5*time.Second),
+			flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 3, Backoff: time.Second}),
+		)
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"), flex.WithPriority(1))
+		m.Add(&mockWorker{t: t, name: "jobs"}, flex.WithName("jobs"), flex.WithWorkerStartTimeout(time.Minute))
+		if err := m.Namespace("tenant").Add(&mockWorker{t: t, name: "tenant"}); err != nil {
+			t.Fatal(err)
+		}
+
+		manifest := m.Manifest()
+		if manifest.Version != flex.ManifestVersion || manifest.StartTimeout != "5s" {
+			t.Errorf("unexpected manifest: %+v", manifest)
+		}
+		if expected := []string{"SIGTERM"}; !reflect.DeepEqual(manifest.Signals, expected) {
+			t.Errorf("expected %v but got: %v", expected, manifest.Signals)
+		}
+		if expected := (flex.ManifestRestartPolicy{MaxRestarts: 3, Backoff: "1s"}); manifest.RestartPolicy == nil || *manifest.RestartPolicy != expected {
+			t.Errorf("expected %+v but got: %+v", expected, manifest.RestartPolicy)
+		}
+
+		expected := []flex.ManifestWorker{
+			{Name: "api", Type: "*flex_test.mockWorker", Priority: 1},
+			{Name: "jobs", Type: "*flex_test.mockWorker", StartTimeout: "1m0s"},
+		}
+		if !reflect.DeepEqual(manifest.Workers, expected) {
+			t.Errorf("expected %+v but got: %+v", expected, manifest.Workers)
+		}
+	})
+	t.Run("a manager booted from a manifest must match the original", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithSignals(syscall.SIGTERM), flex.WithJoinedErrors(),
+			flex.WithShutdownDelay(time.Second), flex.WithSlowHaltWarning(time.Minute))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"), flex.WithPriority(1),
+			flex.WithWorkerRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}))
+
+		var buf bytes.Buffer
+		if err := flex.WriteManifest(&buf, m.Manifest(), flex.JSON); err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := flex.ReadManifest(&buf, flex.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		booted, err := flex.NewFromManifest(manifest, map[string]flex.WorkerFactory{
+			"api": func() (flex.Worker, error) { return &mockWorker{t: t, name: "api"}, nil },
+		}, flex.WithSignals(syscall.SIGINT), flex.WithShutdownDelay(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(booted.Manifest(), m.Manifest()) {
+			t.Errorf("expected %+v but got: %+v", m.Manifest(), booted.Manifest())
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := booted.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("options the manifest does not describe must be kept", func(t *testing.T) {
+		t.Parallel()
+
+		booted, err := flex.NewFromManifest(flex.Manifest{Version: flex.ManifestVersion}, nil,
+			flex.WithSignals(syscall.SIGINT), flex.WithHaltTimeout(time.Minute),
+			flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 5}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifest := booted.Manifest()
+		if expected := []string{"SIGINT"}; !reflect.DeepEqual(manifest.Signals, expected) {
+			t.Errorf("expected %v but got: %v", expected, manifest.Signals)
+		}
+		if manifest.HaltTimeout != "1m0s" {
+			t.Errorf("expected %q but got: %q", "1m0s", manifest.HaltTimeout)
+		}
+		if expected := (flex.ManifestRestartPolicy{MaxRestarts: 5}); manifest.RestartPolicy == nil || *manifest.RestartPolicy != expected {
+			t.Errorf("expected %+v but got: %+v", expected, manifest.RestartPolicy)
+		}
+	})
+	t.Run("booting a worker without a factory must fail", func(t *testing.T) {
+		t.Parallel()
+
+		manifest := flex.Manifest{Version: flex.ManifestVersion, Workers: []flex.ManifestWorker{{Name: "api"}}}
+		if _, err := flex.NewFromManifest(manifest, nil); !errors.Is(err, flex.ErrNoFactory) || !strings.Contains(err.Error(), `"api"`) {
+			t.Errorf("expected an error naming the worker but got: %v", err)
+		}
+	})
+	t.Run("booting unknown signals must fail", func(t *testing.T) {
+		t.Parallel()
+
+		manifest := flex.Manifest{Version: flex.ManifestVersion, DumpSignals: []string{"SIGNOPE"}}
+		if _, err := flex.NewFromManifest(manifest, nil); err == nil || !strings.Contains(err.Error(), "dump_signals") {
+			t.Errorf("expected an error naming the field but got: %v", err)
+		}
+	})
+	t.Run("booting a worker whose factory fails must fail", func(t *testing.T) {
+		t.Parallel()
+
+		boom := errors.New("boom")
+		manifest := flex.Manifest{Version: flex.ManifestVersion, Workers: []flex.ManifestWorker{{Name: "api"}}}
+		_, err := flex.NewFromManifest(manifest, map[string]flex.WorkerFactory{
+			"api": func() (flex.Worker, error) { return nil, boom },
+		})
+		if !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+	})
+	t.Run("groups and dependencies must be booted from a manifest", func(t *testing.T) {
+		t.Parallel()
+
+		manifest, err := flex.ReadManifest(strings.NewReader(`{
+			"version": 1,
+			"workers": [
+				{"name": "api", "depends_on": ["storage"]},
+				{"name": "orders-db", "factory": "db", "group": "storage"},
+				{"name": "users-db", "factory": "db", "group": "storage"}
+			]
+		}`), flex.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var created int
+		m, err := flex.NewFromManifest(manifest, map[string]flex.WorkerFactory{
+			"api": func() (flex.Worker, error) { return &mockWorker{t: t, name: "api"}, nil },
+			"db": func() (flex.Worker, error) {
+				created++
+				return &mockWorker{t: t, name: "db"}, nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created != 2 {
+			t.Errorf("expected the shared factory to be called %d times but got: %d", 2, created)
+		}
+
+		expected := []flex.ManifestWorker{
+			{Name: "api", Type: "*flex_test.mockWorker", DependsOn: []string{"storage"}},
+			{Name: "orders-db", Type: "*flex_test.mockWorker", Group: "storage"},
+			{Name: "users-db", Type: "*flex_test.mockWorker", Group: "storage"},
+		}
+		if workers := m.Manifest().Workers; !reflect.DeepEqual(workers, expected) {
+			t.Errorf("expected %+v but got: %+v", expected, workers)
+		}
+	})
+	t.Run("invalid manifests must be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		factories := map[string]flex.WorkerFactory{
+			"api": func() (flex.Worker, error) { return &mockWorker{t: t, name: "api"}, nil },
+			"db":  func() (flex.Worker, error) { return &mockWorker{t: t, name: "db"}, nil },
+		}
+		for _, tc := range []struct {
+			workers []flex.ManifestWorker
+			err     error
+		}{
+			{workers: []flex.ManifestWorker{{Name: ""}}, err: flex.ErrInvalidManifest},
+			{workers: []flex.ManifestWorker{{Name: "api"}, {Name: "api"}}, err: flex.ErrInvalidManifest},
+			{workers: []flex.ManifestWorker{{Name: "api", Factory: "web"}}, err: flex.ErrNoFactory},
+			{workers: []flex.ManifestWorker{{Name: "api", DependsOn: []string{"cache"}}}, err: flex.ErrDependency},
+			{workers: []flex.ManifestWorker{{Name: "api", DependsOn: []string{"db"}}, {Name: "db", DependsOn: []string{"api"}}}, err: flex.ErrDependency},
+		} {
+			manifest := flex.Manifest{Version: flex.ManifestVersion, Workers: tc.workers}
+			if err := manifest.Validate(factories); !errors.Is(err, tc.err) {
+				t.Errorf("expected %v for %+v but got: %v", tc.err, tc.workers, err)
+			}
+		}
+
+		manifest := flex.Manifest{Version: flex.ManifestVersion, Workers: []flex.ManifestWorker{{Name: "api", StartTimeout: "soon"}}}
+		if err := manifest.Validate(factories); err == nil {
+			t.Error("expected an error for an invalid duration")
+		}
+	})
+	t.Run("manifests of a newer version must be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := flex.ReadManifest(strings.NewReader(`{"version": 99}`), flex.JSON); !errors.Is(err, flex.ErrManifestVersion) {
+			t.Errorf("expected %v but got: %v", flex.ErrManifestVersion, err)
+		}
+	})
+	t.Run("the manifest must be written when starting", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+
+		m := flex.New(flex.WithSignals(), flex.WithManifestFile(path, flex.JSON))
+		m.Add(&mockWorker{t: t, name: "api"}, flex.WithName("api"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := m.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		manifest, err := flex.ReadManifest(f, flex.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Workers) != 1 || manifest.Workers[0].Name != "api" {
+			t.Errorf("unexpected manifest: %+v", manifest)
+		}
+	})
+}
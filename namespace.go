@@ -0,0 +1,220 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrNamespaceFull is returned when adding a worker to a namespace which
+	// already holds as many workers as allowed by WithNamespaceLimit.
+	ErrNamespaceFull = errors.New("namespace is full")
+	// ErrNamespaceRunning is returned when adding a worker to, or starting, a
+	// namespace whose workers are running.
+	ErrNamespaceRunning = errors.New("namespace is running")
+	// ErrNotRunning is returned when starting a namespace of a manager which
+	// is not running.
+	ErrNotRunning = errors.New("manager is not running")
+)
+
+// Namespace is a group of workers of a Manager, such as those of a tenant,
+// which is started, halted and reported on independently of the manager's
+// other workers.
+//
+// The workers of a namespace are started along with the manager and halted
+// when it shuts down, like any other worker. A worker of a namespace failing
+// only halts the workers of its namespace, and does not shut the manager
+// down; the error is reported by Dump, and returned by Start once the
+// manager shuts down.
+type Namespace struct {
+	name  string
+	m     *Manager
+	limit int
+
+	mu      sync.Mutex
+	parent  context.Context
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// Namespace returns the namespace called name, creating it if it does not
+// exist yet. Namespaces must be created before the manager is started.
+func (m *Manager) Namespace(name string) *Namespace {
+	for _, worker := range m.workers {
+		if nw, ok := worker.Worker.(*namespaceWorker); ok && nw.ns.name == name {
+			return nw.ns
+		}
+	}
+
+	// The workers of a namespace run under a manager of their own, which
+	// leaves signals, policies, init jobs, the manifest file, the banner and
+	// the shutdown report to the parent manager.
+	child := &Manager{opts: m.opts, started: make(chan struct{})}
+	child.opts.signals = nil
+	child.opts.reloadSignals = nil
+	child.opts.dumpSignals = nil
+	child.opts.dumpShutdown = false
+	child.opts.signalHandlers = nil
+	child.opts.policy = nil
+	child.opts.initJobs = nil
+	child.opts.manifestFile = ""
+	child.opts.banner = false
+	child.opts.reportWriter = nil
+
+	ns := &Namespace{name: name, m: child, limit: m.opts.namespaceLimit}
+	m.Add(&namespaceWorker{ns: ns})
+	return ns
+}
+
+// Name returns the name of the namespace.
+func (ns *Namespace) Name() string { return ns.name }
+
+// Add registers a worker with the namespace. It fails with ErrNamespaceFull
+// when the namespace is full, and with ErrNamespaceRunning while the
+// workers of the namespace are running.
+func (ns *Namespace) Add(w Worker, opts ...WorkerOption) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.running {
+		return fmt.Errorf("%w: %s", ErrNamespaceRunning, ns.name)
+	}
+	if ns.limit > 0 && len(ns.m.workers) >= ns.limit {
+		return fmt.Errorf("%w: %s holds %d workers", ErrNamespaceFull, ns.name, ns.limit)
+	}
+
+	ns.m.Add(w, opts...)
+	return nil
+}
+
+// Workers returns the workers added to the namespace, in the order they were added.
+func (ns *Namespace) Workers() []Worker {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.m.Workers()
+}
+
+// Start starts the workers of the namespace again once they have been
+// halted, or have failed, while the manager keeps running. It returns once
+// they have been launched, and fails with ErrNotRunning when the manager is
+// not running and with ErrNamespaceRunning when they are already running.
+func (ns *Namespace) Start() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.parent == nil {
+		return fmt.Errorf("%w: cannot start namespace %s", ErrNotRunning, ns.name)
+	}
+	if ns.running {
+		return fmt.Errorf("%w: %s", ErrNamespaceRunning, ns.name)
+	}
+	if len(ns.m.workers) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ns.parent)
+	done := make(chan struct{})
+	ns.running, ns.cancel, ns.done, ns.err = true, cancel, done, nil
+
+	go func() {
+		defer close(done)
+		defer cancel()
+
+		err := ns.m.Start(ctx)
+
+		ns.mu.Lock()
+		ns.running, ns.err = false, err
+		ns.mu.Unlock()
+	}()
+
+	return nil
+}
+
+// Halt halts the workers of the namespace while the manager keeps running,
+// and returns the errors they returned once they have all returned from Run
+// and Halt, or the context's error if it is done first.
+func (ns *Namespace) Halt(ctx context.Context) error {
+	ns.mu.Lock()
+	cancel, done := ns.cancel, ns.done
+	ns.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.err
+}
+
+// Dump writes a diagnostic dump of the namespace to w, see Manager.Dump.
+func (ns *Namespace) Dump(w io.Writer) error {
+	return ns.m.Dump(w)
+}
+
+// state returns the state of the namespace, and the error its workers last
+// stopped with.
+func (ns *Namespace) state() (WorkerState, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	switch {
+	case ns.running:
+		return StateRunning, nil
+	case ns.err != nil:
+		return StateFailed, ns.err
+	case ns.done != nil:
+		return StateStopped, nil
+	default:
+		return StateIdle, nil
+	}
+}
+
+// namespaceWorker runs a namespace as a worker of its parent manager.
+type namespaceWorker struct{ ns *Namespace }
+
+// Run starts the workers of the namespace, and keeps the namespace
+// available for starting until the manager shuts down.
+func (w *namespaceWorker) Run(ctx context.Context) error {
+	w.ns.mu.Lock()
+	w.ns.parent = ctx
+	w.ns.mu.Unlock()
+
+	if err := w.ns.Start(); err != nil {
+		return err
+	}
+	Ready(ctx)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Halt halts the workers of the namespace, waiting for them regardless of
+// ctx, which the manager cancels before halting.
+func (w *namespaceWorker) Halt(context.Context) error {
+	w.ns.mu.Lock()
+	w.ns.parent = nil
+	w.ns.mu.Unlock()
+
+	if err := w.ns.Halt(context.Background()); err != nil {
+		return fmt.Errorf("namespace %s: %w", w.ns.name, err)
+	}
+	return nil
+}
+
+// Reload reloads the workers of the namespace.
+func (w *namespaceWorker) Reload(ctx context.Context) error {
+	return w.ns.m.Reload(ctx)
+}
@@ -0,0 +1,227 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// countingMockWorker counts its runs and halts, and blocks in Run until its
+// context is done.
+type countingMockWorker struct {
+	mockWorker
+	runs, halts atomic.Int32
+}
+
+func (c *countingMockWorker) Run(ctx context.Context) error {
+	c.runs.Add(1)
+	<-ctx.Done()
+	return nil
+}
+
+func (c *countingMockWorker) Halt(context.Context) error {
+	c.halts.Add(1)
+	return nil
+}
+
+// eventually waits for cond to hold, failing the test after a second.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	t.Run("namespaced workers must run along with the manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}})
+		if err := m.Namespace("tenant-a").Add(worker); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+		cancel()
+
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if worker.halts.Load() != 1 {
+			t.Errorf("expected the worker to be halted once, but got %d", worker.halts.Load())
+		}
+	})
+	t.Run("a namespace must be halted and started independently", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		var (
+			worker = &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+			other  = &countingMockWorker{mockWorker: mockWorker{t: t, name: "bar"}}
+		)
+
+		m := flex.New(flex.WithSignals())
+		ns := m.Namespace("tenant-a")
+		if err := ns.Add(worker); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Namespace("tenant-b").Add(other); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+
+		if err := ns.Halt(ctx); err != nil {
+			t.Error(err)
+		}
+		if worker.halts.Load() != 1 || other.halts.Load() != 0 {
+			t.Errorf("expected only tenant-a to be halted, but got %d and %d halts", worker.halts.Load(), other.halts.Load())
+		}
+
+		if err := ns.Start(); err != nil {
+			t.Error(err)
+		}
+		eventually(t, func() bool { return worker.runs.Load() == 2 })
+
+		if err := ns.Start(); !errors.Is(err, flex.ErrNamespaceRunning) {
+			t.Errorf("expected %v but got: %v", flex.ErrNamespaceRunning, err)
+		}
+
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a failing namespace must not stop the manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		worker := &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals())
+		m.Add(worker)
+		ns := m.Namespace("tenant-a")
+		if err := ns.Add(&failingMockWorker{mockWorker{t: t, name: "bar"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		var dump bytes.Buffer
+		eventually(t, func() bool {
+			dump.Reset()
+			_ = m.Dump(&dump)
+			return strings.Contains(dump.String(), "namespace=tenant-a state=failed")
+		})
+		if !strings.Contains(dump.String(), `namespace=tenant-a worker=*flex_test.failingMockWorker state=failed`) {
+			t.Errorf("expected the failed worker to be dumped but got: %q", dump.String())
+		}
+
+		select {
+		case <-errC:
+			t.Fatal("expected the manager to keep running")
+		case <-time.After(20 * time.Millisecond):
+		}
+		cancel()
+
+		if err := <-errC; err == nil || !strings.Contains(err.Error(), "namespace tenant-a") {
+			t.Errorf("expected the namespace's error to be returned but got: %v", err)
+		}
+	})
+	t.Run("a namespace must not overwrite the manifest of the manager", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		worker := &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+
+		m := flex.New(flex.WithSignals(), flex.WithManifestFile(path, flex.JSON))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}, flex.WithName("api"))
+		if err := m.Namespace("tenant-a").Add(worker, flex.WithName("tenant-worker")); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		manifest, err := flex.ReadManifest(f, flex.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Workers) != 1 || manifest.Workers[0].Name != "api" {
+			t.Errorf("unexpected manifest: %+v", manifest)
+		}
+	})
+	t.Run("a namespace must not hold more workers than its limit", func(t *testing.T) {
+		t.Parallel()
+
+		ns := flex.New(flex.WithNamespaceLimit(1)).Namespace("tenant-a")
+		if err := ns.Add(&mockWorker{t: t, name: "foo"}); err != nil {
+			t.Error(err)
+		}
+		if err := ns.Add(&mockWorker{t: t, name: "bar"}); !errors.Is(err, flex.ErrNamespaceFull) {
+			t.Errorf("expected %v but got: %v", flex.ErrNamespaceFull, err)
+		}
+	})
+	t.Run("a namespace must not start before the manager", func(t *testing.T) {
+		t.Parallel()
+
+		ns := flex.New().Namespace("tenant-a")
+		if err := ns.Start(); !errors.Is(err, flex.ErrNotRunning) {
+			t.Errorf("expected %v but got: %v", flex.ErrNotRunning, err)
+		}
+	})
+	t.Run("namespaces must be looked up by name", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New()
+		if m.Namespace("tenant-a") != m.Namespace("tenant-a") {
+			t.Error("expected the same namespace to be returned")
+		}
+		if len(m.Workers()) != 0 {
+			t.Errorf("expected namespaces not to be listed as workers, but got %v", m.Workers())
+		}
+	})
+}
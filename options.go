@@ -0,0 +1,414 @@
+package flex
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Option configures a Manager.
+type Option func(*options)
+
+// options holds the configuration of a Manager.
+type options struct {
+	startTimeout   time.Duration
+	haltTimeout    time.Duration
+	haltMargin     *time.Duration
+	signals        []os.Signal
+	reloadSignals  []os.Signal
+	dumpSignals    []os.Signal
+	dumpShutdown   bool
+	signalHandlers []signalHandler
+	runtime        *Runtime
+	policy         Policy
+	namespaceLimit int
+	initJobs       []InitJob
+	joinErrors     bool
+	restartPolicy  RestartPolicy
+	recoverable    func(error) bool
+	errorHandler   ErrorHandler
+	errorReporter  ErrorReporter
+	panicHandler   PanicHandler
+	manifestFile   string
+	manifestCodec  Codec
+	identity       *Identity
+	rawErrors      bool
+	ignoredErrors  []error
+	heartbeat      HeartbeatAction
+	healthInterval time.Duration
+	shutdownDelay  time.Duration
+	logger         Logger
+	logTransitions bool
+	reportWriter   io.Writer
+	slowStart      time.Duration
+	slowHalt       time.Duration
+	banner         bool
+	logLevel       slog.Leveler
+	enabled        []string
+	disabled       []string
+	profile        string
+}
+
+// signalHandler is a function to call when a signal is received.
+type signalHandler struct {
+	sig os.Signal
+	fn  func(context.Context) error
+}
+
+// WithStartTimeout sets how long each worker is given to start, that is to
+// call Ready or to return from Run, which workers then wait for rather than
+// starting once their Run is entered. A worker which does not start in time
+// fails with ErrStartTimeout and triggers a shutdown.
+// A zero duration, the default, disables the timeout.
+func WithStartTimeout(d time.Duration) Option {
+	return func(o *options) { o.startTimeout = d }
+}
+
+// DefaultReturnTimeout is how long Start waits for the halted workers to
+// return from Run when no halt timeout is configured, so that a worker whose
+// Run ignores both its context and Halt does not block Start forever.
+const DefaultReturnTimeout = 5 * time.Second
+
+// WithHaltTimeout sets how long each worker is given to return from Halt,
+// which is passed a context expiring after the timeout. A worker which does
+// not return in time fails with ErrHaltTimeout, and is abandoned: Start
+// returns without waiting for it to return from Halt or Run.
+// A zero duration, the default, disables the timeout, in which case Halt is
+// passed the manager's context, which is done by then, and Start waits at
+// most DefaultReturnTimeout for the halted workers to return from Run.
+//
+// In a known runtime, see WithRuntime, workers are given at most until the
+// halt margin before the runtime kills the process to return from Halt, even
+// without a halt timeout.
+func WithHaltTimeout(d time.Duration) Option {
+	return func(o *options) { o.haltTimeout = d }
+}
+
+// WithHaltMargin sets how long before the runtime kills the process, see
+// WithRuntime, the workers which have not returned from Halt are abandoned,
+// leaving the manager time to return from Start. It defaults to a tenth of
+// the grace period of the runtime.
+func WithHaltMargin(d time.Duration) Option {
+	return func(o *options) { o.haltMargin = &d }
+}
+
+// DefaultSignals are the signals which trigger a shutdown unless configured
+// otherwise with WithSignals. SIGKILL is deliberately absent, as it cannot be
+// caught. On Windows, console close, logoff and shutdown events are delivered
+// as SIGTERM, so the same set applies on every platform.
+var DefaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// WithSignals sets the signals which trigger a shutdown, replacing DefaultSignals.
+// Calling WithSignals without any signal disables signal handling entirely,
+// which is useful when flex is embedded in an application that owns signals
+// and shuts flex down by cancelling the context instead.
+func WithSignals(sig ...os.Signal) Option {
+	return func(o *options) { o.signals = sig }
+}
+
+// WithReloadSignals sets the signals which reload every worker implementing
+// Reloader, replacing DefaultReloadSignals. Calling WithReloadSignals without
+// any signal disables reloading on signals.
+func WithReloadSignals(sig ...os.Signal) Option {
+	return func(o *options) { o.reloadSignals = sig }
+}
+
+// WithDumpSignals sets the signals which write a diagnostic dump of the
+// manager, see Manager.Dump, to the logger, replacing DefaultDumpSignals. The
+// dump is logged as one entry for the manager and one for every worker, with
+// their fields as attributes.
+// Handling SIGQUIT replaces the Go runtime's default of dumping every
+// goroutine's stack and exiting. Calling WithDumpSignals without any signal
+// disables diagnostic dumps on signals, restoring that default.
+func WithDumpSignals(sig ...os.Signal) Option {
+	return func(o *options) { o.dumpSignals = sig }
+}
+
+// WithShutdownOnDump makes dump signals trigger a shutdown once the dump is
+// written, instead of the manager continuing to run.
+func WithShutdownOnDump() Option {
+	return func(o *options) { o.dumpShutdown = true }
+}
+
+// OnSignal registers fn to be called with the manager's context whenever sig
+// is received while the manager is running. Handlers are called one at a
+// time, in the order they were registered, and their errors are logged and
+// returned by Start alongside the workers' errors. Handlers of a shutdown
+// signal are called as the shutdown begins.
+func OnSignal(sig os.Signal, fn func(context.Context) error) Option {
+	return func(o *options) { o.signalHandlers = append(o.signalHandlers, signalHandler{sig: sig, fn: fn}) }
+}
+
+// WithRuntime sets the runtime the process runs in, overriding the one
+// detected by DetectRuntime, see Deadline. On Lambda, whose grace period is
+// an approximation of the remaining time of the invocation, see
+// RuntimeLambda, it can be given the grace period the function is known to
+// have.
+func WithRuntime(r Runtime) Option {
+	return func(o *options) { o.runtime = &r }
+}
+
+// WithPolicy sets the policy consulted before acting on lifecycle decisions.
+func WithPolicy(p Policy) Option {
+	return func(o *options) { o.policy = p }
+}
+
+// WithNamespaceLimit sets how many workers each namespace may hold, see
+// Namespace.Add. A limit of zero, the default, means no limit.
+func WithNamespaceLimit(n int) Option {
+	return func(o *options) { o.namespaceLimit = n }
+}
+
+// WithInitJob adds an init job, run before any worker starts, after the init
+// jobs added before it.
+func WithInitJob(job InitJob) Option {
+	return func(o *options) { o.initJobs = append(o.initJobs, job) }
+}
+
+// WithJoinedErrors makes Start and Reload return the errors of the workers
+// joined with errors.Join, rather than in a MultiError, for callers which
+// prefer the error semantics of the standard library over flex types.
+func WithJoinedErrors() Option {
+	return func(o *options) { o.joinErrors = true }
+}
+
+// WithHeartbeatAction sets what is done when a worker implementing Heartbeater
+// misses a heartbeat. By default, HeartbeatReport, it is only reported by
+// Manager.Health.
+func WithHeartbeatAction(action HeartbeatAction) Option {
+	return func(o *options) { o.heartbeat = action }
+}
+
+// WithHealthInterval sets how often the health of the workers is observed
+// while the manager runs, so that EventHealthChanged is emitted even when
+// Manager.Health is not otherwise called. Each observation is given the
+// interval to complete. A zero duration, the default, disables it.
+func WithHealthInterval(d time.Duration) Option {
+	return func(o *options) { o.healthInterval = d }
+}
+
+// WithShutdownDelay sets how long the manager waits, once a shutdown has been
+// requested, before halting the workers. Meanwhile the workers keep running
+// but the manager reports itself not ready, see Manager.Health, giving load
+// balancers time to stop routing traffic to it. The delay counts from the
+// request, and is cut short by the shutdown deadline of the runtime, if any.
+func WithShutdownDelay(d time.Duration) Option {
+	return func(o *options) { o.shutdownDelay = d }
+}
+
+// WithLogger sets the logger receiving the lifecycle logs of the manager,
+// such as a *slog.Logger. By default they are written to stderr.
+//
+// The lifecycle transitions of the manager and its workers, such as workers
+// starting, failing and halting, are only logged to a logger set with
+// WithLogger, with the worker, phase, duration and error as attributes.
+// Workers retrieve the logger, scoped to them, with LoggerFrom.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger, o.logTransitions = l, true }
+}
+
+// WithBanner makes Start log a single "service starting" entry listing every
+// worker, with its name, type and the address it listens on if known, each
+// namespace, under "namespace.<name>" with the number of its workers, and the
+// names of the disabled workers, see WithDisabledWorkers, along with the
+// identity of the service, see WithIdentity, its profile, see WithProfile, and
+// the version, VCS revision and Go version it was built with, so that what a
+// deployed binary runs can be confirmed at a glance.
+func WithBanner() Option {
+	return func(o *options) { o.banner = true }
+}
+
+// WithSlowStartWarning logs a warning, with the name of the worker and the
+// elapsed time, for each worker which has not started, see Manager.Started,
+// within d, so that stragglers are visible before the
+// start timeout, if any, fires. A zero duration, the default, disables the
+// warning.
+func WithSlowStartWarning(d time.Duration) Option {
+	return func(o *options) { o.slowStart = d }
+}
+
+// WithSlowHaltWarning logs a warning, with the name of the worker and the
+// elapsed time, for each worker which has not returned from Halt within d, so
+// that stragglers are visible before the halt timeout, if any, fires. A zero
+// duration, the default, disables the warning.
+func WithSlowHaltWarning(d time.Duration) Option {
+	return func(o *options) { o.slowHalt = d }
+}
+
+// WithShutdownReport makes Start write the report of each shutdown to w, such
+// as os.Stdout, as a single line of JSON once every worker has been halted,
+// see Manager.ShutdownReport. Failing to write it is logged.
+func WithShutdownReport(w io.Writer) Option {
+	return func(o *options) { o.reportWriter = w }
+}
+
+// WithLogLevel sets the minimum level of the lifecycle logs written to stderr
+// by default, such as slog.LevelWarn to only log warnings and errors. It does
+// not apply to a logger set with WithLogger, which filters entries itself.
+func WithLogLevel(level slog.Level) Option {
+	return func(o *options) { o.logLevel = level }
+}
+
+// WithEnabledWorkers makes the manager only run the workers with the given
+// names, see WithName, or groups, see WithGroup, skipping every other worker
+// passed to Add. Skipped workers are listed by Manager.DisabledWorkers and in
+// the banner, see WithBanner. Calling it without names enables every worker,
+// the default.
+func WithEnabledWorkers(names ...string) Option {
+	return func(o *options) { o.enabled = names }
+}
+
+// WithDisabledWorkers makes the manager skip the workers with the given names,
+// see WithName, or groups, see WithGroup, when they are passed to Add, so
+// that optional workers, such as a profiling endpoint, can be switched off
+// per environment. Skipped workers are listed by Manager.DisabledWorkers and
+// in the banner, see WithBanner.
+func WithDisabledWorkers(names ...string) Option {
+	return func(o *options) { o.disabled = names }
+}
+
+// WithRestartPolicy sets how workers failing with a recoverable error are
+// restarted, see Recoverable. By default workers are never restarted.
+func WithRestartPolicy(p RestartPolicy) Option {
+	return func(o *options) { o.restartPolicy = p }
+}
+
+// WithRecoverable makes the errors for which fn returns true recoverable, as
+// if they were marked with Recoverable, for workers whose errors cannot be
+// wrapped. Errors marked with Fatal remain fatal.
+func WithRecoverable(fn func(error) bool) Option {
+	return func(o *options) { o.recoverable = fn }
+}
+
+// WithIgnoredErrors sets the errors which are ignored, as matched by
+// errors.Is, when workers return them from Run or Halt once a shutdown has
+// been requested, replacing DefaultIgnoredErrors. Calling WithIgnoredErrors
+// without any error makes every error count as a failure.
+func WithIgnoredErrors(errs ...error) Option {
+	return func(o *options) { o.ignoredErrors = errs }
+}
+
+// WithErrorHandler sets a function called with every error returned by the
+// workers' Run, Halt and Reload as it happens, for example to report it with
+// application context, rather than only once Start returns. It may be called
+// concurrently, and must not block.
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(o *options) { o.errorHandler = fn }
+}
+
+// WithErrorReporter sets a function called with every error returned by the
+// workers' Run, Halt and Reload as it happens, before it is passed to the
+// error handler and before the manager acts on it, such as by restarting the
+// worker or shutting down. It is given the context of the failure, carrying
+// the identity of the service, see WithIdentity, to report errors to services
+// such as Sentry, Rollbar or Bugsnag. It may be called concurrently, and must
+// not block.
+func WithErrorReporter(fn ErrorReporter) Option {
+	return func(o *options) { o.errorReporter = fn }
+}
+
+// WithPanicHandler makes the manager recover the panics of the workers' Run,
+// Halt and Reload, calling fn with the name of the worker, the recovered
+// value and the stack trace. The worker then fails with a *PanicError, which
+// is handled like any other error. By default panics are not recovered, and
+// crash the process. fn may be called concurrently, and must not block.
+func WithPanicHandler(fn PanicHandler) Option {
+	return func(o *options) { o.panicHandler = fn }
+}
+
+// WithIdentity seeds the context passed to the workers and init jobs with
+// id, see IdentityFromContext.
+func WithIdentity(id Identity) Option {
+	return func(o *options) { o.identity = &id }
+}
+
+// WithManifestFile makes Start write the manifest of the manager to path,
+// serialized with codec, before running anything. Failing to write it is
+// logged, but does not prevent the manager from starting.
+func WithManifestFile(path string, codec Codec) Option {
+	return func(o *options) { o.manifestFile, o.manifestCodec = path, codec }
+}
+
+// WorkerOption configures a single worker added to a Manager.
+type WorkerOption func(*workerOptions)
+
+// workerOptions holds the configuration of a single worker.
+type workerOptions struct {
+	name          string
+	group         string
+	dependsOn     []string
+	startTimeout  *time.Duration
+	priority      int
+	restartPolicy *RestartPolicy
+	readyStarted  bool
+	// unhealthyRestart is how long the worker may be unhealthy before it is
+	// restarted, see WithRestartWhenUnhealthy.
+	unhealthyRestart time.Duration
+}
+
+// WithName sets the name of a worker, as reported to the error handler, in
+// diagnostic dumps and as the pprof label "worker" of its Run and Halt, and of
+// the goroutines they start. It defaults to the type of the worker.
+func WithName(name string) WorkerOption {
+	return func(o *workerOptions) { o.name = name }
+}
+
+// WithGroup adds a worker to a group, such as "observability", which other
+// workers may depend on as a whole, see WithDependsOn, and which may be
+// enabled or disabled as a whole, see WithDisabledWorkers.
+func WithGroup(group string) WorkerOption {
+	return func(o *workerOptions) { o.group = group }
+}
+
+// WithDependsOn makes a worker depend on the workers, or groups of workers,
+// with the given names, see WithName and WithGroup: its Run is only called
+// once they have all started, see WithStartedOnReady, and
+// within a shutdown band, see WithPriority, it is halted before them.
+// Dependencies on disabled workers are ignored, and Start fails with
+// ErrDependency when a worker depends on an unknown worker, or on itself.
+// The start timeout of the worker includes the time spent waiting.
+func WithDependsOn(names ...string) WorkerOption {
+	return func(o *workerOptions) { o.dependsOn = append(o.dependsOn, names...) }
+}
+
+// WithWorkerStartTimeout overrides the manager's start timeout for a single
+// worker. A zero duration disables the timeout for that worker.
+func WithWorkerStartTimeout(d time.Duration) WorkerOption {
+	return func(o *workerOptions) { o.startTimeout = &d }
+}
+
+// WithPriority sets the shutdown priority of a worker.
+// Workers are halted in bands of equal priority, lowest first: all workers of
+// one band are halted in parallel, and the next band is only halted once every
+// worker of the previous one has returned from Halt. The default priority is 0.
+func WithPriority(priority int) WorkerOption {
+	return func(o *workerOptions) { o.priority = priority }
+}
+
+// WithStartedOnReady makes a worker only count as started, and as running and
+// ready, as reported by Manager.Started and Manager.Health, once it has called
+// Ready, rather than once its Run was entered. It suits workers which are slow
+// to start, such as those warming a cache, and which call Ready once done.
+// Workers with a start timeout, see WithWorkerStartTimeout, and workers
+// implementing ReadyReporter wait for Ready as well.
+func WithStartedOnReady() WorkerOption {
+	return func(o *workerOptions) { o.readyStarted = true }
+}
+
+// WithRestartWhenUnhealthy restarts a worker which is observed unhealthy
+// while running, as reported by Manager.Health, for longer than threshold:
+// the worker is halted, and then fails with a recoverable ErrUnhealthy, so
+// that it is run again according to its restart policy, or shuts the manager
+// down if it has none. The health of the worker is observed every
+// WithHealthInterval, or every half threshold if no interval is set.
+func WithRestartWhenUnhealthy(threshold time.Duration) WorkerOption {
+	return func(o *workerOptions) { o.unhealthyRestart = threshold }
+}
+
+// WithWorkerRestartPolicy overrides the manager's restart policy for a single worker.
+func WithWorkerRestartPolicy(p RestartPolicy) WorkerOption {
+	return func(o *workerOptions) { o.restartPolicy = &p }
+}
@@ -0,0 +1,52 @@
+package flex
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrPanicked is the error of workers which panicked, when panics are
+// recovered with WithPanicHandler.
+var ErrPanicked = errors.New("worker panicked")
+
+// PanicError is the error of a worker whose Run, Halt or Reload panicked,
+// when panics are recovered with WithPanicHandler. It matches ErrPanicked,
+// and the recovered value if it is an error.
+type PanicError struct {
+	// Value is the value the worker panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine which panicked.
+	Stack []byte
+}
+
+// Error returns a string representation of the PanicError.
+func (e *PanicError) Error() string { return fmt.Sprintf("%v: %v", ErrPanicked, e.Value) }
+
+// Unwrap returns ErrPanicked, and the recovered value if it is an error.
+func (e *PanicError) Unwrap() []error {
+	if err, ok := e.Value.(error); ok {
+		return []error{ErrPanicked, err}
+	}
+	return []error{ErrPanicked}
+}
+
+// PanicHandler is called with the name of the worker, the value it panicked
+// with and the stack trace whenever a worker panics, see WithPanicHandler.
+type PanicHandler func(workerName string, recovered any, stack []byte)
+
+// recoverPanic recovers a panic of worker, if panics are recovered, passing it
+// to the panic handler and setting *err to a *PanicError. It must be deferred.
+func (m *Manager) recoverPanic(worker *managedWorker, err *error) {
+	if m.opts.panicHandler == nil {
+		return
+	}
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	m.opts.panicHandler(worker.name(), r, stack)
+	*err = &PanicError{Value: r, Stack: stack}
+}
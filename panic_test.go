@@ -0,0 +1,97 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// panickingMockWorker panics when run.
+type panickingMockWorker struct{ mockWorker }
+
+func (p *panickingMockWorker) Run(context.Context) error { panic("boom") }
+
+func TestWithPanicHandler(t *testing.T) {
+	t.Run("a panicking worker must fail with a panic error", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu        sync.Mutex
+			name      string
+			recovered any
+			stack     []byte
+		)
+		m := flex.New(flex.WithSignals(), flex.WithPanicHandler(func(workerName string, r any, s []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			name, recovered, stack = workerName, r, s
+		}))
+		m.Add(&panickingMockWorker{mockWorker{t: t, name: "foo"}}, flex.WithName("foo"))
+
+		err := m.Start(context.Background())
+		if !errors.Is(err, flex.ErrPanicked) {
+			t.Fatalf("expected %v but got: %v", flex.ErrPanicked, err)
+		}
+		var perr *flex.PanicError
+		if !errors.As(err, &perr) || perr.Value != "boom" || !strings.Contains(string(perr.Stack), "panickingMockWorker") {
+			t.Errorf("unexpected panic error: %#v", perr)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if name != "foo" || recovered != "boom" || len(stack) == 0 {
+			t.Errorf("unexpected panic handled: %v, %v", name, recovered)
+		}
+	})
+	t.Run("a panic error must match the recovered error", func(t *testing.T) {
+		t.Parallel()
+
+		err := &flex.PanicError{Value: context.Canceled}
+		if !errors.Is(err, flex.ErrPanicked) || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v to match both %v and %v", err, flex.ErrPanicked, context.Canceled)
+		}
+	})
+}
+
+func TestWithErrorReporter(t *testing.T) {
+	t.Run("errors must be reported before being handled", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu    sync.Mutex
+			calls []string
+		)
+		record := func(call string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, call)
+		}
+
+		m := flex.New(flex.WithSignals(),
+			flex.WithIdentity(flex.Identity{Service: "billing"}),
+			flex.WithErrorReporter(func(ctx context.Context, workerName string, phase flex.Phase, err error) {
+				if id, ok := flex.IdentityFromContext(ctx); !ok || id.Service != "billing" {
+					t.Errorf("expected the context to carry the identity but got: %+v", id)
+				}
+				record("report " + workerName + " " + phase.String())
+			}),
+			flex.WithErrorHandler(func(workerName string, phase flex.Phase, err error) {
+				record("handle " + workerName + " " + phase.String())
+			}))
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "foo"}}, flex.WithName("foo"))
+
+		if err := m.Start(context.Background()); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(calls) != 2 || calls[0] != "report foo run" || calls[1] != "handle foo run" {
+			t.Errorf("unexpected calls: %v", calls)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package flex
+
+import (
+	"context"
+	"time"
+)
+
+// policyRetryInterval is how long the manager waits before consulting the
+// policy again after it did not allow a decision.
+const policyRetryInterval = time.Second
+
+// DecisionKind identifies a lifecycle decision a Policy is consulted on.
+type DecisionKind int
+
+const (
+	// DecisionShutdown is consulted once a shutdown has been requested, by a
+	// signal, a failing worker or the cancellation of the manager's context,
+	// before any worker is halted. Not allowing it delays the shutdown: the
+	// workers keep running and the policy is consulted again shortly after.
+	DecisionShutdown DecisionKind = iota + 1
+	// DecisionRestart is consulted before restarting a worker which failed
+	// with a recoverable error, see Recoverable. Not allowing it makes the
+	// error fatal, triggering a shutdown.
+	DecisionRestart
+)
+
+// String returns a string representation of the DecisionKind.
+func (k DecisionKind) String() string {
+	switch k {
+	case DecisionShutdown:
+		return "shutdown"
+	case DecisionRestart:
+		return "restart"
+	default:
+		return "unknown"
+	}
+}
+
+// Decision describes a lifecycle decision a Policy is consulted on.
+type Decision struct {
+	Kind DecisionKind
+	// Cause is why the decision is being made, such as a *SignalError or the
+	// error of a failing worker.
+	Cause error
+}
+
+// Policy encodes operational rules the manager abides by when making
+// lifecycle decisions, such as not shutting down while a critical job is
+// running, without having to fork flex.
+type Policy interface {
+	// Allow reports whether the manager may proceed with the decision.
+	// It may block until it is able to decide, but must return once ctx is
+	// done, which happens when the runtime's shutdown deadline, if any, has
+	// passed. Errors are logged and treated as allowing the decision, so that
+	// a failing policy engine cannot wedge the service.
+	Allow(ctx context.Context, d Decision) (bool, error)
+}
+
+// PolicyFunc is an adapter allowing the use of an ordinary function as a Policy.
+type PolicyFunc func(ctx context.Context, d Decision) (bool, error)
+
+// Allow calls f(ctx, d).
+func (f PolicyFunc) Allow(ctx context.Context, d Decision) (bool, error) { return f(ctx, d) }
@@ -0,0 +1,123 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// haltTrackingMockWorker blocks in Run until its context is done, and records
+// whether it was halted.
+type haltTrackingMockWorker struct {
+	mockWorker
+	halted atomic.Bool
+}
+
+func (h *haltTrackingMockWorker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (h *haltTrackingMockWorker) Halt(context.Context) error {
+	h.halted.Store(true)
+	return nil
+}
+
+func TestPolicy(t *testing.T) {
+	t.Run("a blocking policy must delay the shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var (
+			worker  = &haltTrackingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+			release = make(chan struct{})
+			asked   = make(chan flex.Decision, 1)
+		)
+
+		m := flex.New(flex.WithPolicy(flex.PolicyFunc(func(_ context.Context, d flex.Decision) (bool, error) {
+			asked <- d
+			<-release
+			return true, nil
+		})))
+		m.Add(worker)
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		decision := <-asked
+		if decision.Kind != flex.DecisionShutdown || !errors.Is(decision.Cause, context.Canceled) {
+			t.Errorf("unexpected decision: %+v", decision)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if worker.halted.Load() {
+			t.Error("expected the worker not to be halted before the policy allowed it")
+		}
+
+		close(release)
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+		if !worker.halted.Load() {
+			t.Error("expected the worker to be halted")
+		}
+	})
+	t.Run("a policy must be consulted again after denying", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var calls atomic.Int32
+		m := flex.New(flex.WithPolicy(flex.PolicyFunc(func(context.Context, flex.Decision) (bool, error) {
+			return calls.Add(1) > 1, nil
+		})))
+		m.Add(&haltTrackingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected the policy to be consulted twice, but got %d", calls.Load())
+		}
+	})
+	t.Run("a failing policy must not prevent the shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m := flex.New(flex.WithPolicy(flex.PolicyFunc(func(context.Context, flex.Decision) (bool, error) {
+			return false, errors.New("policy engine unreachable")
+		})))
+		m.Add(&haltTrackingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}})
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("a failing worker must be the cause of the shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		var cause error
+		m := flex.New(flex.WithPolicy(flex.PolicyFunc(func(_ context.Context, d flex.Decision) (bool, error) {
+			cause = d.Cause
+			return true, nil
+		})))
+		m.Add(&failingMockWorker{mockWorker{t: t, name: "foo"}})
+
+		_ = m.Start(ctx)
+		if cause == nil || cause.Error() != "run failed" {
+			t.Errorf("expected the worker's error to be the cause, but got: %v", cause)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package flex
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Names of the profiles registered by flex.
+const (
+	// DevelopmentProfile favours feedback: short timeouts and verbose logs
+	// written as text, including every lifecycle transition.
+	DevelopmentProfile = "dev"
+	// ProductionProfile favours graceful shutdowns: a long halt timeout and
+	// logs written as JSON, including every lifecycle transition.
+	ProductionProfile = "prod"
+)
+
+// Profile is a named set of options, so that services share their defaults
+// per environment rather than copying lists of options, see WithProfile.
+type Profile struct {
+	Name    string
+	Options []Option
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]Profile{
+		DevelopmentProfile: {Name: DevelopmentProfile, Options: []Option{
+			WithStartTimeout(10 * time.Second),
+			WithHaltTimeout(5 * time.Second),
+			WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			WithBanner(),
+			WithSlowStartWarning(2 * time.Second),
+			WithSlowHaltWarning(time.Second),
+		}},
+		ProductionProfile: {Name: ProductionProfile, Options: []Option{
+			WithHaltTimeout(30 * time.Second),
+			WithLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))),
+			WithBanner(),
+			WithSlowStartWarning(30 * time.Second),
+			WithSlowHaltWarning(10 * time.Second),
+		}},
+	}
+)
+
+// RegisterProfile registers p, replacing the profile of the same name, if
+// any, so that it can be selected with WithProfile or ProfileEnv. It is
+// typically called from an init function of a package shared by services.
+func RegisterProfile(p Profile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles[p.Name] = p
+}
+
+// LookupProfile returns the profile registered under name, and whether there
+// is one.
+func LookupProfile(name string) (Profile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// WithProfile applies the options of the profile registered under name, such
+// as DevelopmentProfile, where it is given: options following it override
+// those of the profile. Unknown profiles are ignored, and logged. The profile
+// is reported by Manager.Profile and in the banner, see WithBanner.
+func WithProfile(name string) Option {
+	return func(o *options) {
+		p, ok := LookupProfile(name)
+		if !ok {
+			logger.Printf("ignoring unknown profile %q", name)
+			return
+		}
+		for _, opt := range p.Options {
+			opt(o)
+		}
+		o.profile = p.Name
+	}
+}
+
+// Profile returns the name of the profile the manager was configured with,
+// see WithProfile, or an empty string if none.
+func (m *Manager) Profile() string {
+	return m.opts.profile
+}
@@ -0,0 +1,68 @@
+package flex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestWithProfile(t *testing.T) {
+	t.Run("the options of the profile must apply", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithProfile(flex.DevelopmentProfile))
+		if manifest := m.Manifest(); manifest.StartTimeout != "10s" || manifest.HaltTimeout != "5s" {
+			t.Errorf("expected the timeouts of the profile but got: %s and %s", manifest.StartTimeout, manifest.HaltTimeout)
+		}
+		if m.Profile() != flex.DevelopmentProfile {
+			t.Errorf("expected %q but got: %q", flex.DevelopmentProfile, m.Profile())
+		}
+	})
+	t.Run("options following the profile must override it", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithProfile(flex.ProductionProfile), flex.WithHaltTimeout(time.Minute))
+		if manifest := m.Manifest(); manifest.HaltTimeout != "1m0s" {
+			t.Errorf("expected %s but got: %s", "1m0s", manifest.HaltTimeout)
+		}
+	})
+	t.Run("registered profiles must be selectable", func(t *testing.T) {
+		t.Parallel()
+
+		flex.RegisterProfile(flex.Profile{Name: "test-staging", Options: []flex.Option{flex.WithHaltTimeout(15 * time.Second)}})
+		if _, ok := flex.LookupProfile("test-staging"); !ok {
+			t.Fatal("expected the profile to be registered")
+		}
+
+		m := flex.New(flex.WithProfile("test-staging"))
+		if manifest := m.Manifest(); manifest.HaltTimeout != "15s" || m.Profile() != "test-staging" {
+			t.Errorf("unexpected manager: %s and %q", manifest.HaltTimeout, m.Profile())
+		}
+	})
+	t.Run("unknown profiles must be ignored", func(t *testing.T) {
+		t.Parallel()
+
+		m := flex.New(flex.WithProfile("test-unknown"))
+		if manifest := m.Manifest(); manifest.HaltTimeout != "" || m.Profile() != "" {
+			t.Errorf("unexpected manager: %s and %q", manifest.HaltTimeout, m.Profile())
+		}
+	})
+}
+
+func TestProfileEnv(t *testing.T) {
+	t.Setenv(flex.ProfileEnv, flex.ProductionProfile)
+	t.Setenv(flex.StartTimeoutEnv, "20s")
+
+	m := flex.New()
+	if manifest := m.Manifest(); manifest.HaltTimeout != "30s" || manifest.StartTimeout != "20s" {
+		t.Errorf("expected the profile and the environment to apply but got: %s and %s", manifest.HaltTimeout, manifest.StartTimeout)
+	}
+	if m.Profile() != flex.ProductionProfile {
+		t.Errorf("expected %q but got: %q", flex.ProductionProfile, m.Profile())
+	}
+
+	if m := flex.New(flex.WithProfile(flex.DevelopmentProfile)); m.Profile() != flex.DevelopmentProfile {
+		t.Errorf("expected the option to override the environment but got: %q", m.Profile())
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !js
+
+package flex
+
+import (
+	"os"
+	"syscall"
+)
+
+// DefaultReloadSignals are the signals which reload workers unless configured
+// otherwise with WithReloadSignals.
+var DefaultReloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,7 @@
+package flex
+
+import "os"
+
+// DefaultReloadSignals are the signals which reload workers unless configured
+// otherwise with WithReloadSignals. There are none on this platform.
+var DefaultReloadSignals []os.Signal
@@ -0,0 +1,134 @@
+package flex
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// ShutdownReport summarizes a shutdown of a Manager, telling how each worker
+// was halted, so that operators can see why it took as long as it did.
+type ShutdownReport struct {
+	// Cause is why the shutdown was requested.
+	Cause error
+	// Began is when the shutdown was requested, and Duration how long it
+	// lasted, including any shutdown policy and delay.
+	Began    time.Time
+	Duration time.Duration
+	// Workers holds how each worker was halted, in the order they were added.
+	Workers []WorkerShutdown
+	// Err is the error Start returned.
+	Err error
+}
+
+// WorkerShutdown tells how a worker was halted.
+type WorkerShutdown struct {
+	Name string
+	// HaltDuration is how long the worker took to return from Halt, or was
+	// given if it was forced.
+	HaltDuration time.Duration
+	// Err is the error the worker was halted with, if any.
+	Err error
+	// Forced is set when the worker did not halt in time and was abandoned,
+	// see WithHaltTimeout, rather than halted gracefully.
+	Forced bool
+}
+
+// Slowest returns the worker which took the longest to halt, and false if
+// there is none.
+func (r ShutdownReport) Slowest() (WorkerShutdown, bool) {
+	var slowest WorkerShutdown
+	for _, ws := range r.Workers {
+		if ws.HaltDuration > slowest.HaltDuration {
+			slowest = ws
+		}
+	}
+	return slowest, slowest.Name != ""
+}
+
+// Forced returns how many workers were forced, see WorkerShutdown.Forced.
+func (r ShutdownReport) Forced() int {
+	var n int
+	for _, ws := range r.Workers {
+		if ws.Forced {
+			n++
+		}
+	}
+	return n
+}
+
+// MarshalJSON encodes the report with errors as their messages and durations
+// in seconds.
+func (r ShutdownReport) MarshalJSON() ([]byte, error) {
+	type workerJSON struct {
+		Name            string  `json:"name"`
+		DurationSeconds float64 `json:"duration_seconds"`
+		Error           string  `json:"error,omitempty"`
+		Forced          bool    `json:"forced"`
+	}
+	type reportJSON struct {
+		Cause           string       `json:"cause,omitempty"`
+		Began           time.Time    `json:"began"`
+		DurationSeconds float64      `json:"duration_seconds"`
+		Workers         []workerJSON `json:"workers"`
+		Error           string       `json:"error,omitempty"`
+	}
+
+	rep := reportJSON{
+		Cause:           errorString(r.Cause),
+		Began:           r.Began,
+		DurationSeconds: r.Duration.Seconds(),
+		Workers:         make([]workerJSON, len(r.Workers)),
+		Error:           errorString(r.Err),
+	}
+	for i, ws := range r.Workers {
+		rep.Workers[i] = workerJSON{Name: ws.Name, DurationSeconds: ws.HaltDuration.Seconds(), Error: errorString(ws.Err), Forced: ws.Forced}
+	}
+	return json.Marshal(rep)
+}
+
+// errorString returns the message of err, or an empty string if it is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// ShutdownReport returns the report of the last shutdown of the manager, and
+// false if it has not shut down yet.
+func (m *Manager) ShutdownReport() (ShutdownReport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.report == nil {
+		return ShutdownReport{}, false
+	}
+	return *m.report, true
+}
+
+// reportShutdown records report as that of the last shutdown, and writes it
+// as JSON to the writer set with WithShutdownReport, if any.
+func (m *Manager) reportShutdown(ctx context.Context, report ShutdownReport) {
+	m.mu.Lock()
+	m.report = &report
+	m.mu.Unlock()
+
+	if m.opts.reportWriter == nil {
+		return
+	}
+	if err := writeShutdownReport(m.opts.reportWriter, report); err != nil {
+		m.log(ctx, slog.LevelWarn, "writing shutdown report failed", "error", err)
+	}
+}
+
+// writeShutdownReport writes report to w as a single line of JSON.
+func writeShutdownReport(w io.Writer, report ShutdownReport) error {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,105 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManagerShutdownReport(t *testing.T) {
+	t.Run("the halt of every worker must be reported", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		stuck := &stuckMockWorker{mockWorker: mockWorker{t: t, name: "stuck"}, release: make(chan struct{})}
+		defer close(stuck.release)
+
+		var out bytes.Buffer
+		m := flex.New(flex.WithSignals(), flex.WithHaltTimeout(20*time.Millisecond), flex.WithShutdownReport(&out))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}, flex.WithName("api"))
+		m.Add(stuck, flex.WithName("stuck"))
+
+		if _, ok := m.ShutdownReport(); ok {
+			t.Error("expected no report before the manager has shut down")
+		}
+
+		err := m.Start(ctx)
+
+		report, ok := m.ShutdownReport()
+		if !ok {
+			t.Fatal("expected a report once the manager has shut down")
+		}
+		if !errors.Is(report.Cause, context.Canceled) || report.Err == nil || report.Err.Error() != err.Error() || report.Duration <= 0 || report.Began.IsZero() {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if len(report.Workers) != 2 {
+			t.Fatalf("unexpected workers: %+v", report.Workers)
+		}
+		if api := report.Workers[0]; api.Name != "api" || api.Forced || api.Err != nil {
+			t.Errorf("expected api to be halted gracefully but got: %+v", api)
+		}
+		if stuck := report.Workers[1]; stuck.Name != "stuck" || !stuck.Forced || !errors.Is(stuck.Err, flex.ErrHaltTimeout) {
+			t.Errorf("expected stuck to be forced but got: %+v", stuck)
+		}
+		if slowest, ok := report.Slowest(); !ok || slowest.Name != "stuck" {
+			t.Errorf("expected stuck to be the slowest but got: %+v", slowest)
+		}
+		if n := report.Forced(); n != 1 {
+			t.Errorf("expected %d forced worker but got: %d", 1, n)
+		}
+
+		var written struct {
+			Cause   string `json:"cause"`
+			Workers []struct {
+				Name            string  `json:"name"`
+				DurationSeconds float64 `json:"duration_seconds"`
+				Error           string  `json:"error"`
+				Forced          bool    `json:"forced"`
+			} `json:"workers"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &written); err != nil {
+			t.Fatalf("expected the report to be written as JSON but got: %v: %s", err, out.Bytes())
+		}
+		if written.Cause != context.Canceled.Error() || written.Error == "" || len(written.Workers) != 2 {
+			t.Errorf("unexpected written report: %s", out.Bytes())
+		}
+		if w := written.Workers[1]; w.Name != "stuck" || !w.Forced || w.Error == "" || w.DurationSeconds <= 0 {
+			t.Errorf("unexpected written worker: %+v", w)
+		}
+	})
+	t.Run("namespaces must not write reports of their own", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		var out bytes.Buffer
+		worker := &countingMockWorker{mockWorker: mockWorker{t: t, name: "foo"}}
+		m := flex.New(flex.WithSignals(), flex.WithShutdownReport(&out))
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}, flex.WithName("api"))
+		if err := m.Namespace("tenant-a").Add(worker); err != nil {
+			t.Fatal(err)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		eventually(t, func() bool { return worker.runs.Load() == 1 })
+		cancel()
+		if err := <-errC; err != nil {
+			t.Fatal(err)
+		}
+
+		if n := bytes.Count(out.Bytes(), []byte("\n")); n != 1 {
+			t.Errorf("expected a single report but got %d: %s", n, out.Bytes())
+		}
+	})
+}
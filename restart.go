@@ -0,0 +1,150 @@
+package flex
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// fatalError marks an error as fatal, see Fatal.
+type fatalError struct{ err error }
+
+func (e *fatalError) Error() string { return e.err.Error() }
+func (e *fatalError) Unwrap() error { return e.err }
+
+// recoverableError marks an error as recoverable, see Recoverable.
+type recoverableError struct{ err error }
+
+func (e *recoverableError) Error() string { return e.err.Error() }
+func (e *recoverableError) Unwrap() error { return e.err }
+
+// Fatal marks err as fatal: a worker returning it from Run is never restarted
+// and triggers a shutdown, even if it would otherwise be recoverable.
+// Fatal returns nil if err is nil.
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fatalError{err: err}
+}
+
+// Recoverable marks err as recoverable: a worker returning it from Run is
+// restarted according to its RestartPolicy, and only triggers a shutdown once
+// the policy gives up on it. Recoverable returns nil if err is nil.
+func Recoverable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &recoverableError{err: err}
+}
+
+// IsFatal reports whether err, or any error it wraps, was marked with Fatal.
+func IsFatal(err error) bool {
+	var fatal *fatalError
+	return errors.As(err, &fatal)
+}
+
+// IsRecoverable reports whether err, or any error it wraps, was marked with
+// Recoverable, and none was marked with Fatal.
+func IsRecoverable(err error) bool {
+	var recoverable *recoverableError
+	return errors.As(err, &recoverable) && !IsFatal(err)
+}
+
+// RestartPolicy decides how workers failing with a recoverable error are restarted.
+type RestartPolicy struct {
+	// MaxRestarts is how many times a worker is restarted over a run of the
+	// manager, after which its recoverable errors are treated as fatal.
+	// Zero, the default, means workers are never restarted.
+	MaxRestarts int
+	// Backoff is how long to wait before restarting a worker.
+	Backoff time.Duration
+}
+
+// recoverable reports whether err is recoverable, either because it was
+// marked with Recoverable, or because the manager's classifier says so.
+func (m *Manager) recoverable(err error) bool {
+	if IsFatal(err) {
+		return false
+	}
+	if IsRecoverable(err) {
+		return true
+	}
+	return m.opts.recoverable != nil && m.opts.recoverable(err)
+}
+
+// restart reports whether worker, which failed with err after having been
+// restarted restarts times, is to be run again, once its backoff has passed.
+func (m *Manager) restart(ctx context.Context, worker *managedWorker, err error, restarts int) bool {
+	if !m.recoverable(err) {
+		return false
+	}
+
+	policy := worker.restartPolicy(m.opts)
+	if restarts >= policy.MaxRestarts {
+		return false
+	}
+
+	if m.opts.policy != nil {
+		allow, err := m.opts.policy.Allow(ctx, Decision{Kind: DecisionRestart, Cause: err})
+		if err != nil {
+			m.log(ctx, slog.LevelWarn, "policy failed, proceeding with restart", "worker", worker.name(), "error", err)
+		} else if !allow {
+			return false
+		}
+	}
+
+	timer := time.NewTimer(policy.Backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// restartPolicy returns the restart policy for the worker, preferring its own
+// override over the manager-wide setting.
+func (w *managedWorker) restartPolicy(opts options) RestartPolicy {
+	if w.opts.restartPolicy != nil {
+		return *w.opts.restartPolicy
+	}
+	return opts.restartPolicy
+}
+
+// haltForRestart halts worker, unless it is already being halted to be
+// restarted, so that it then fails with err marked as recoverable, and is
+// restarted according to its restart policy.
+func (m *Manager) haltForRestart(ctx context.Context, worker *managedWorker, err error) {
+	worker.mu.Lock()
+	if worker.restartErr != nil {
+		worker.mu.Unlock()
+		return
+	}
+	worker.restartErr = Recoverable(err)
+	worker.mu.Unlock()
+
+	m.log(ctx, slog.LevelWarn, "halting worker to be restarted", "worker", worker.name(), "error", err)
+	go func() {
+		ctx := context.WithValue(context.WithoutCancel(ctx), workerKey{}, worker)
+		start := time.Now()
+		err := m.call(ctx, worker, worker.Halt)
+		duration := time.Since(start)
+		worker.setHaltDuration(duration)
+		m.emit(Event{Kind: EventWorkerHalted, Worker: worker.Worker, WorkerName: worker.name(), Err: err, Duration: duration})
+		m.handleError(ctx, worker, PhaseHalt, err)
+	}()
+}
+
+// takeRestartErr returns the error the worker was halted for to be restarted,
+// if any, and clears it.
+func (w *managedWorker) takeRestartErr() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.restartErr
+	w.restartErr = nil
+	return err
+}
@@ -0,0 +1,179 @@
+package flex_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// flakyMockWorker fails with err the first failures times it is run, then
+// blocks until its context is done.
+type flakyMockWorker struct {
+	mockWorker
+	err      error
+	failures int32
+	runs     atomic.Int32
+}
+
+func (f *flakyMockWorker) Run(ctx context.Context) error {
+	if f.runs.Add(1) <= f.failures {
+		return f.err
+	}
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func TestErrorClassification(t *testing.T) {
+	t.Run("errors must be classified by their outermost marks", func(t *testing.T) {
+		t.Parallel()
+
+		err := errors.New("boom")
+
+		if flex.IsRecoverable(err) || flex.IsFatal(err) {
+			t.Error("expected an unmarked error to be neither recoverable nor fatal")
+		}
+		if !flex.IsRecoverable(flex.Recoverable(err)) {
+			t.Error("expected a recoverable error to be recoverable")
+		}
+		if !flex.IsFatal(flex.Fatal(err)) {
+			t.Error("expected a fatal error to be fatal")
+		}
+		if flex.IsRecoverable(flex.Fatal(flex.Recoverable(err))) || flex.IsRecoverable(flex.Recoverable(flex.Fatal(err))) {
+			t.Error("expected fatal to take precedence over recoverable")
+		}
+		if !errors.Is(flex.Recoverable(err), err) || flex.Recoverable(err).Error() != err.Error() {
+			t.Error("expected the marked error to wrap the original one")
+		}
+		if flex.Recoverable(nil) != nil || flex.Fatal(nil) != nil {
+			t.Error("expected marking a nil error to return nil")
+		}
+	})
+}
+
+func TestManagerRestart(t *testing.T) {
+	t.Run("a worker failing with a recoverable error must be restarted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		worker := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: flex.Recoverable(errors.New("boom")), failures: 2}
+
+		m := flex.New(flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 3, Backoff: time.Millisecond}))
+		m.Add(worker)
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the manager to keep running after a recoverable error")
+		}
+		if runs := worker.runs.Load(); runs != 3 {
+			t.Errorf("expected %d runs but got: %d", 3, runs)
+		}
+
+		var dump bytes.Buffer
+		if err := m.Dump(&dump); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(dump.String(), "restarts=2") {
+			t.Errorf("expected the dump to report the restarts but got: %s", dump.String())
+		}
+	})
+	t.Run("a worker exceeding its restarts must trigger a shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		boom := errors.New("boom")
+		worker := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: flex.Recoverable(boom), failures: 5}
+
+		m := flex.New(flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 2}))
+		m.Add(worker)
+
+		if err := m.Start(ctx); !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+		if runs := worker.runs.Load(); runs != 3 {
+			t.Errorf("expected %d runs but got: %d", 3, runs)
+		}
+	})
+	t.Run("a worker failing with a fatal error must not be restarted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		boom := errors.New("boom")
+		worker := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: flex.Fatal(boom), failures: 1}
+
+		m := flex.New(
+			flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 2}),
+			flex.WithRecoverable(func(error) bool { return true }),
+		)
+		m.Add(worker)
+
+		if err := m.Start(ctx); !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+		if runs := worker.runs.Load(); runs != 1 {
+			t.Errorf("expected %d run but got: %d", 1, runs)
+		}
+	})
+	t.Run("errors classified as recoverable must be restarted", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		boom := errors.New("boom")
+		worker := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: boom, failures: 1}
+
+		m := flex.New(flex.WithRecoverable(func(err error) bool { return errors.Is(err, boom) }))
+		m.Add(worker, flex.WithWorkerRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}))
+
+		if err := m.Start(ctx); err != nil {
+			t.Error(err)
+		}
+		if runs := worker.runs.Load(); runs != 2 {
+			t.Errorf("expected %d runs but got: %d", 2, runs)
+		}
+	})
+	t.Run("a restart the policy does not allow must trigger a shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		boom := errors.New("boom")
+		worker := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "foo"}, err: flex.Recoverable(boom), failures: 1}
+
+		var asked atomic.Bool
+		m := flex.New(
+			flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 1}),
+			flex.WithPolicy(flex.PolicyFunc(func(_ context.Context, d flex.Decision) (bool, error) {
+				if d.Kind == flex.DecisionRestart {
+					asked.Store(true)
+					return false, nil
+				}
+				return true, nil
+			})),
+		)
+		m.Add(worker)
+
+		if err := m.Start(ctx); !errors.Is(err, boom) {
+			t.Errorf("expected %v but got: %v", boom, err)
+		}
+		if !asked.Load() {
+			t.Error("expected the policy to be consulted on the restart")
+		}
+	})
+}
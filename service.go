@@ -6,10 +6,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
-	"strings"
+	"slices"
 	"sync"
-	"syscall"
+	"time"
 )
 
 var logger = log.New(os.Stderr, "flex: ", 0)
@@ -17,6 +16,7 @@ var logger = log.New(os.Stderr, "flex: ", 0)
 // Runner represents the behaviour for running a service worker.
 type Runner interface {
 	// Run should run start processing the worker and be a blocking operation.
+	// It must return once its context is done or the worker has been halted.
 	Run(context.Context) error
 }
 
@@ -32,93 +32,161 @@ type Worker interface {
 	Halter
 }
 
-// MustStart is like Start, but panics if there is an error.
-func MustStart(ctx context.Context, workers ...Worker) {
-	if err := Start(ctx, workers...); err != nil {
-		logger.Fatal(err)
+// RunnerFunc adapts a function into a Runner.
+type RunnerFunc func(context.Context) error
+
+// Run calls f(ctx).
+func (f RunnerFunc) Run(ctx context.Context) error { return f(ctx) }
+
+// HalterFunc adapts a function into a Halter.
+type HalterFunc func(context.Context) error
+
+// Halt calls f(ctx).
+func (f HalterFunc) Halt(ctx context.Context) error { return f(ctx) }
+
+// NewWorker returns a Worker running run and halted by halt, so that small
+// workers can be declared inline:
+//
+//	m.Add(flex.NewWorker(func(ctx context.Context) error {
+//		return consume(ctx, queue)
+//	}, nil))
+//
+// A nil halt does nothing, in which case run must return once its context is
+// done, which happens before workers are halted. A nil run blocks until its
+// context is done.
+func NewWorker(run RunnerFunc, halt HalterFunc) Worker {
+	return &funcWorker{run: run, halt: halt}
+}
+
+// funcWorker is the Worker returned by NewWorker.
+type funcWorker struct {
+	run  RunnerFunc
+	halt HalterFunc
+}
+
+func (w *funcWorker) Run(ctx context.Context) error {
+	if w.run == nil {
+		<-ctx.Done()
+		return nil
 	}
+	return w.run(ctx)
 }
 
-// Start is a blocking operation that will start processing the workers.
-func Start(ctx context.Context, workers ...Worker) error {
-	if len(workers) < 1 {
-		return errors.New("need at least 1 worker")
+func (w *funcWorker) Halt(ctx context.Context) error {
+	if w.halt == nil {
+		return nil
 	}
+	return w.halt(ctx)
+}
 
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, os.Kill, syscall.SIGTERM)
-	defer cancel()
+// Reloader represents the behaviour for reloading a service worker, for
+// example to re-read its configuration or reopen its log files.
+// Workers implementing it are reloaded whenever a reload signal is received.
+type Reloader interface {
+	// Reload should apply the worker's new configuration without stopping it.
+	Reload(context.Context) error
+}
 
-	var (
-		errC     = make(chan error, len(workers))
-		runErrC  = make(chan error, len(workers))
-		haltErrC = make(chan error, len(workers))
-	)
+// Validator represents the behaviour for validating a service worker before
+// any worker runs, such as checking its configuration, so that a
+// misconfigured service fails before binding ports or consuming messages.
+// Start calls Validate on every worker implementing it, before running the
+// init jobs, and returns without running any worker if one fails.
+type Validator interface {
+	// Validate should return why the worker cannot run, or nil if it can.
+	// It should not have side effects.
+	Validate(context.Context) error
+}
 
-	for _, worker := range workers {
-		if worker == nil {
-			return errors.New("received a nil worker")
-		}
-
-		go func(worker Worker) {
-			if err := worker.Run(ctx); err != nil {
-				runErrC <- err
-				cancel()
-			}
-		}(worker)
-	}
+// Describer represents the behaviour for describing the state of a service
+// worker beyond its lifecycle, such as how many connections it serves.
+// The details of workers implementing it are included in diagnostic dumps.
+type Describer interface {
+	// Describe should return the current details of the worker, by name.
+	Describe() map[string]string
+}
 
-loop:
-	for {
-		select {
-		case err, ok := <-haltErrC:
-			if ok {
-				errC <- err
-			}
-		case err, ok := <-runErrC:
-			if ok {
-				errC <- err
-			}
-		case <-ctx.Done():
-			var wg sync.WaitGroup
-			wg.Add(len(workers))
-
-			for _, worker := range workers {
-				go func(worker Worker) {
-					defer wg.Done()
-					err := worker.Halt(ctx)
-					haltErrC <- err
-				}(worker)
-			}
-
-			wg.Wait()
-
-			break loop
-		}
-	}
+// HealthReporter represents the behaviour for reporting the health of a
+// service worker beyond its lifecycle, such as whether it is connected to its
+// broker. Workers reporting an error are not ready, see Manager.Health.
+type HealthReporter interface {
+	// Health should return why the worker cannot serve, or nil if it can.
+	Health(context.Context) error
+}
 
-	close(errC)
+// LivenessReporter represents the behaviour for reporting whether a service
+// worker is alive, that is able to recover without the process being
+// restarted. Workers reporting an error are not alive, see Manager.Health.
+type LivenessReporter interface {
+	// Live should return why the worker cannot recover, or nil if it can.
+	Live(context.Context) error
+}
 
-	if err := newMultiErrorFromChan(errC); err.Valid() {
-		return err
-	}
+// Heartbeater represents the behaviour of a service worker which checks in
+// regularly by calling Heartbeat, so that it is noticed when it is stuck
+// despite being alive, such as a consumer whose subscription silently stalled.
+// Workers missing a heartbeat are neither alive nor ready, see Manager.Health,
+// and are acted upon according to WithHeartbeatAction.
+type Heartbeater interface {
+	// HeartbeatInterval should return the longest the worker may go without
+	// calling Heartbeat once it has started.
+	HeartbeatInterval() time.Duration
+}
 
-	return nil
+// ReadyReporter represents the behaviour of a service worker which calls
+// Ready once it is up, such as a server once its listener is bound, so that it
+// only counts as started once it does rather than once its Run is entered, as
+// workers configured with WithStartedOnReady.
+type ReadyReporter interface {
+	// ReportsReady should report whether the worker calls Ready.
+	ReportsReady() bool
 }
 
-// MultiError holds a slice of errors and implements the error interface.
-type MultiError struct{ Errors []error }
+// v1Options are the options Start runs its manager with, so that it keeps the
+// semantics it had before the Manager was introduced: only shutdown signals
+// are handled, and every error of the workers is returned with its own
+// message, except for DefaultIgnoredErrors returned once the shutdown was
+// requested, such as the http.ErrServerClosed of a server returning
+// ListenAndServe. The errors are still annotated with their phase. The manager
+// is not configured from the environment, nor from the detected runtime, so
+// that workers are never abandoned by Start.
+// Features added since are opted into by using a Manager instead.
+var v1Options = []Option{
+	WithReloadSignals(),
+	WithDumpSignals(),
+	func(o *options) { o.rawErrors = true },
+}
 
-// newMultiErrorFromChan creates a new MultiError from a channel of errors.
-func newMultiErrorFromChan(errC chan error) MultiError {
-	var errors []error
-	for err := range errC {
-		if err != nil {
-			errors = append(errors, err)
-		}
+// MustStart is like Start, but logs the error and exits with status 1 if
+// there is an error.
+func MustStart(ctx context.Context, workers ...Worker) {
+	if err := Start(ctx, workers...); err != nil {
+		logger.Fatal(err)
 	}
-	return MultiError{Errors: errors}
 }
 
+// Start is a blocking operation that will start processing the workers.
+// It is a stable shorthand for adding the workers to a new Manager and
+// starting it, which keeps the semantics of the first versions of flex:
+// signals other than DefaultSignals are not handled, environment variables
+// such as HaltTimeoutEnv are not read, and every error returned by the workers
+// is collected into the MultiError with its own message, though it is a
+// *WorkerError telling its phase, see MultiError.RunErrors. Unlike the first
+// versions, Start waits for the halted workers to return from Run, for at most
+// DefaultReturnTimeout, so that their errors are collected as well.
+func Start(ctx context.Context, workers ...Worker) error {
+	m := newManager(v1Options...)
+	for _, worker := range workers {
+		m.Add(worker)
+	}
+	return m.Start(ctx)
+}
+
+// MultiError holds a slice of errors and implements the error interface.
+// It is what Start returns by default, WithJoinedErrors makes it return the
+// errors joined with errors.Join instead.
+type MultiError struct{ Errors []error }
+
 // Valid returns true if the MultiError Errors slice is not empty.
 func (e MultiError) Valid() bool { return len(e.Errors) > 0 }
 
@@ -134,26 +202,47 @@ func (e MultiError) Error() string {
 	}
 }
 
-// Unwrap returns an error from Error (or nil if there are no errors).
-// This error returned will further support Unwrap to get the next error,
-// etc. The order will match the order of Errors in the multierror.Error
-// at the time of calling.
-func (e MultiError) Unwrap() error {
-	// no errors, move along.
-	if len(e.Errors) == 0 {
-		return nil
-	}
+// RunErrors returns the errors which happened while the workers were running,
+// as opposed to while they were being halted, see PhaseErrors.
+func (e MultiError) RunErrors() []error { return PhaseErrors(e, PhaseRun) }
 
-	// 1 error, return it directly.
-	if len(e.Errors) == 1 {
-		return e.Errors[0]
-	}
+// HaltErrors returns the errors which happened while the workers were being
+// halted, see PhaseErrors.
+func (e MultiError) HaltErrors() []error { return PhaseErrors(e, PhaseHalt) }
+
+// Unwrap returns the errors held by the MultiError, so that errors.Is and
+// errors.As match against any of them.
+func (e MultiError) Unwrap() []error { return e.Errors }
+
+// collector collects errors from concurrent goroutines.
+type collector struct {
+	// join makes err return the errors joined with errors.Join rather
+	// than in a MultiError.
+	join bool
+
+	mu     sync.Mutex
+	errors []error
+}
 
-	// many errors, return a formatted chain.
-	var errChain []string
-	for _, err := range e.Errors {
-		errChain = append(errChain, err.Error())
+// add records err, unless it is nil.
+func (c *collector) add(err error) {
+	if err == nil {
+		return
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, err)
+}
 
-	return fmt.Errorf("multiple errors: %v", strings.Join(errChain, "; next => "))
+// err returns a MultiError holding all collected errors, or nil if there are none.
+func (c *collector) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.join {
+		return errors.Join(c.errors...)
+	}
+	if err := (MultiError{Errors: slices.Clone(c.errors)}); err.Valid() {
+		return err
+	}
+	return nil
 }
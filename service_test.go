@@ -3,6 +3,9 @@ package flex_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -46,6 +49,14 @@ func defaultCtx() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 2*time.Second)
 }
 
+// httpMockWorker is the worker of the README, which returns the
+// http.ErrServerClosed of ListenAndServe once halted.
+type httpMockWorker struct{ *http.Server }
+
+func (s *httpMockWorker) Run(context.Context) error { return s.ListenAndServe() }
+
+func (s *httpMockWorker) Halt(ctx context.Context) error { return s.Shutdown(ctx) }
+
 func TestStart(t *testing.T) {
 	t.Run("nil worker must not panic", func(t *testing.T) {
 		t.Parallel()
@@ -60,8 +71,8 @@ func TestStart(t *testing.T) {
 		defer cancel()
 
 		err := flex.Start(ctx, nil)
-		if err == nil {
-			t.Error("expected an error but did not get one")
+		if !errors.Is(err, flex.ErrNilWorker) {
+			t.Errorf("expected %v but got: %v", flex.ErrNilWorker, err)
 		}
 	})
 	t.Run("zero workers must return an error", func(t *testing.T) {
@@ -71,8 +82,8 @@ func TestStart(t *testing.T) {
 		defer cancel()
 
 		err := flex.Start(ctx)
-		if err == nil {
-			t.Error("expected an error but did not get one")
+		if !errors.Is(err, flex.ErrNoWorkers) {
+			t.Errorf("expected %v but got: %v", flex.ErrNoWorkers, err)
 		}
 	})
 	t.Run("one worker must run and halt successfully", func(t *testing.T) {
@@ -143,6 +154,33 @@ func TestStart(t *testing.T) {
 			t.Errorf("expected an error of type %T, but got: %T", flex.MultiError{}, err)
 		}
 	})
+	t.Run("a server returning ListenAndServe must halt successfully", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		err := flex.Start(ctx, &httpMockWorker{&http.Server{Addr: "127.0.0.1:0"}})
+		if err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+	})
+	t.Run("errors must be returned as the workers returned them", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		err := flex.Start(ctx, &failingMockWorker{mockWorker{t: t, name: "foo"}})
+
+		var merr flex.MultiError
+		if !errors.As(err, &merr) || len(merr.Errors) != 1 {
+			t.Fatalf("expected a single error but got: %v", err)
+		}
+		if merr.Errors[0].Error() != "run failed" {
+			t.Errorf("expected %q but got: %q", "run failed", merr.Errors[0].Error())
+		}
+	})
 }
 
 func TestMultiError(t *testing.T) {
@@ -184,4 +222,96 @@ func TestMultiError(t *testing.T) {
 			t.Errorf("expected error to contain %q, but got %q", errs[0].Error(), err.Error())
 		}
 	})
+	t.Run("errors.Is must match any contained error", func(t *testing.T) {
+		t.Parallel()
+
+		target := errors.New("target")
+		err := error(flex.MultiError{[]error{
+			errors.New("foo"),
+			fmt.Errorf("wrapped: %w", target),
+		}})
+
+		if !errors.Is(err, target) {
+			t.Errorf("expected %v to match %v", err, target)
+		}
+		if errors.Is(err, errors.New("target")) {
+			t.Errorf("expected %v not to match a different error", err)
+		}
+	})
+	t.Run("errors.As must match any contained error", func(t *testing.T) {
+		t.Parallel()
+
+		sigErr := &flex.SignalError{Signal: os.Interrupt}
+		err := error(flex.MultiError{[]error{errors.New("foo"), sigErr}})
+
+		var target *flex.SignalError
+		if !errors.As(err, &target) || target != sigErr {
+			t.Errorf("expected %v to contain %v", err, sigErr)
+		}
+	})
+}
+
+func TestNewWorker(t *testing.T) {
+	t.Run("the functions must be called with their context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		halted := make(chan struct{})
+		runErr, haltErr := errors.New("run failed"), errors.New("halt failed")
+		w := flex.NewWorker(func(context.Context) error {
+			<-halted
+			return runErr
+		}, func(context.Context) error {
+			close(halted)
+			return haltErr
+		})
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		if err := w.Halt(ctx); !errors.Is(err, haltErr) {
+			t.Errorf("expected %v but got: %v", haltErr, err)
+		}
+		if err := <-errC; !errors.Is(err, runErr) {
+			t.Errorf("expected %v but got: %v", runErr, err)
+		}
+	})
+	t.Run("nil functions must run until the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		w := flex.NewWorker(nil, nil)
+
+		errC := make(chan error, 1)
+		go func() { errC <- w.Run(ctx) }()
+
+		if err := w.Halt(ctx); err != nil {
+			t.Error(err)
+		}
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("adapted functions must satisfy the interfaces", func(t *testing.T) {
+		t.Parallel()
+
+		var called []string
+		var r flex.Runner = flex.RunnerFunc(func(context.Context) error {
+			called = append(called, "run")
+			return nil
+		})
+		var h flex.Halter = flex.HalterFunc(func(context.Context) error {
+			called = append(called, "halt")
+			return nil
+		})
+		_ = r.Run(context.Background())
+		_ = h.Halt(context.Background())
+
+		if got := strings.Join(called, ","); got != "run,halt" {
+			t.Errorf("expected %q but got: %q", "run,halt", got)
+		}
+	})
 }
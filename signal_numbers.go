@@ -0,0 +1,19 @@
+//go:build !plan9
+
+package flex
+
+import (
+	"os"
+	"syscall"
+)
+
+// numberedSignal returns the signal numbered n.
+func numberedSignal(n int) (os.Signal, bool) {
+	return syscall.Signal(n), true
+}
+
+// signalNumber returns the number of sig.
+func signalNumber(sig os.Signal) (int, bool) {
+	n, ok := sig.(syscall.Signal)
+	return int(n), ok
+}
@@ -0,0 +1,15 @@
+package flex
+
+import "os"
+
+// numberedSignal returns the signal numbered n. Signals are notes on this
+// platform, which are not numbered.
+func numberedSignal(int) (os.Signal, bool) {
+	return nil, false
+}
+
+// signalNumber returns the number of sig, which is never numbered on this
+// platform.
+func signalNumber(os.Signal) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,41 @@
+package flex
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// warnSlowStart logs a warning if worker has not started within the slow
+// start threshold, see WithSlowStartWarning, unless ctx is done first.
+func (m *Manager) warnSlowStart(ctx context.Context, worker *managedWorker) {
+	threshold := m.opts.slowStart
+	if threshold <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(threshold)
+	defer timer.Stop()
+
+	select {
+	case <-worker.started:
+	case <-ctx.Done():
+	case <-timer.C:
+		worker.logger.Log(ctx, slog.LevelWarn, "worker slow to start", "elapsed", threshold)
+	}
+}
+
+// warnSlowHalt logs a warning if worker has not halted within the slow halt
+// threshold, see WithSlowHaltWarning, and returns a function to call once it
+// has.
+func (m *Manager) warnSlowHalt(ctx context.Context, worker *managedWorker) (halted func()) {
+	threshold := m.opts.slowHalt
+	if threshold <= 0 {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(threshold, func() {
+		worker.logger.Log(ctx, slog.LevelWarn, "worker slow to halt", "elapsed", threshold)
+	})
+	return func() { timer.Stop() }
+}
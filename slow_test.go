@@ -0,0 +1,73 @@
+package flex_test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+// sluggishMockWorker takes delay to start and to halt.
+type sluggishMockWorker struct {
+	mockWorker
+	delay time.Duration
+}
+
+func (s *sluggishMockWorker) Run(ctx context.Context) error {
+	time.Sleep(s.delay)
+	flex.Ready(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+func (s *sluggishMockWorker) Halt(context.Context) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func TestManagerSlowWarnings(t *testing.T) {
+	t.Run("slow workers must be warned about", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		logger := &recordingLogger{}
+		m := flex.New(flex.WithSignals(), flex.WithLogger(logger),
+			flex.WithSlowStartWarning(10*time.Millisecond), flex.WithSlowHaltWarning(10*time.Millisecond))
+		m.Add(&sluggishMockWorker{mockWorker: mockWorker{t: t, name: "slow"}, delay: 50 * time.Millisecond}, flex.WithName("slow"), flex.WithStartedOnReady())
+		m.Add(&blockingMockWorker{mockWorker: mockWorker{t: t, name: "fast"}, ready: true}, flex.WithName("fast"))
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		for _, msg := range []string{"worker slow to start", "worker slow to halt"} {
+			var warned []any
+			logger.mu.Lock()
+			for _, e := range logger.entries {
+				if e.msg == msg {
+					if e.level != slog.LevelWarn {
+						t.Errorf("expected %q to be a warning but got: %v", msg, e.level)
+					}
+					warned = append(warned, e.args[1])
+				}
+			}
+			logger.mu.Unlock()
+
+			if !slices.Equal(warned, []any{"slow"}) {
+				t.Errorf("expected %q to be logged for the slow worker only but got: %v", msg, warned)
+			}
+		}
+		if e, _ := logger.find("worker slow to halt"); !slices.Contains(e.args, any(10*time.Millisecond)) {
+			t.Errorf("expected the elapsed time to be logged but got: %v", e.args)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package flex
+
+import "time"
+
+// WorkerState is the lifecycle state of a worker.
+type WorkerState int
+
+// Worker states.
+const (
+	// StateIdle is the state of a worker which has not been run yet.
+	StateIdle WorkerState = iota
+	// StateStarting is the state of a worker being run which has not started
+	// yet: which has not entered Run, or not called Ready if it waits for it,
+	// see WithStartedOnReady.
+	StateStarting
+	// StateRunning is the state of a worker which has started.
+	StateRunning
+	// StateStopping is the state of a worker which is being halted.
+	StateStopping
+	// StateStopped is the state of a worker which returned from Run without an error.
+	StateStopped
+	// StateFailed is the state of a worker which returned an error from Run.
+	StateFailed
+)
+
+// String returns the name of the state, as used by flexapi.
+func (s WorkerState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// workerStatus is a snapshot of the state of a worker.
+type workerStatus struct {
+	state     WorkerState
+	runAt     time.Time
+	startedAt time.Time
+	stoppedAt time.Time
+	lastErr   error
+	restarts  int
+	lastBeat  time.Time
+}
+
+// uptime returns how long the worker has been, or was, running.
+func (s workerStatus) uptime(now time.Time) time.Duration {
+	switch {
+	case s.startedAt.IsZero():
+		return 0
+	case !s.stoppedAt.IsZero():
+		return s.stoppedAt.Sub(s.startedAt)
+	default:
+		return now.Sub(s.startedAt)
+	}
+}
+
+// setState moves the worker to state, and reports whether it did.
+func (w *managedWorker) setState(state WorkerState) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	switch state {
+	case StateStarting:
+		w.status = workerStatus{state: state, runAt: now, lastErr: w.status.lastErr}
+	case StateRunning:
+		// Ready may be called after the worker was halted or returned.
+		if w.status.state != StateStarting {
+			return false
+		}
+		w.status.startedAt = now
+	case StateStopping:
+		// Workers which have already returned from Run stay as they are.
+		if w.status.state != StateStarting && w.status.state != StateRunning {
+			return false
+		}
+	case StateStopped, StateFailed:
+		if !w.status.startedAt.IsZero() {
+			w.status.stoppedAt = now
+		}
+	}
+	w.status.state = state
+	return true
+}
+
+// restart moves the worker back to StateStarting to be run again after
+// failing, counting the restart.
+func (w *managedWorker) restart() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = workerStatus{state: StateStarting, runAt: time.Now(), lastErr: w.status.lastErr, restarts: w.status.restarts + 1}
+}
+
+// setError records err as the last error of the worker, if it is not nil.
+func (w *managedWorker) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.lastErr = err
+}
+
+// snapshot returns the current status of the worker.
+func (w *managedWorker) snapshot() workerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// WorkerStatus is a snapshot of the lifecycle of a worker.
+type WorkerStatus struct {
+	Name  string
+	State WorkerState
+	// StartedAt is when the worker last started, zero if it has not.
+	StartedAt time.Time
+	// Uptime is how long the worker has been, or was, running.
+	Uptime time.Duration
+	// Restarts is how many times the worker was restarted.
+	Restarts int
+	// LastErr is the last error the worker failed with, if any.
+	LastErr error
+}
+
+// Status returns a snapshot of the lifecycle of each worker, in the order they
+// were added, as Health does.
+func (m *Manager) Status() []WorkerStatus {
+	now := time.Now()
+	statuses := make([]WorkerStatus, len(m.workers))
+	for i, worker := range m.workers {
+		status := worker.snapshot()
+		statuses[i] = WorkerStatus{
+			Name:      worker.name(),
+			State:     status.state,
+			StartedAt: status.startedAt,
+			Uptime:    status.uptime(now),
+			Restarts:  status.restarts,
+			LastErr:   status.lastErr,
+		}
+	}
+	return statuses
+}
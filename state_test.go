@@ -0,0 +1,50 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManagerStatus(t *testing.T) {
+	t.Run("the status of every worker must be reported", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		boom := flex.Recoverable(errors.New("boom"))
+		flaky := &flakyMockWorker{mockWorker: mockWorker{t: t, name: "flaky"}, err: boom, failures: 1}
+
+		m := flex.New(flex.WithSignals(), flex.WithRestartPolicy(flex.RestartPolicy{MaxRestarts: 1, Backoff: time.Millisecond}))
+		m.Add(flaky, flex.WithName("flaky"), flex.WithStartedOnReady())
+		m.Add(&mockWorker{t: t, name: "idle"}, flex.WithName("idle"))
+
+		if statuses := m.Status(); len(statuses) != 2 || statuses[0].State != flex.StateIdle || statuses[0].Uptime != 0 {
+			t.Fatalf("unexpected statuses before starting: %+v", statuses)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+		<-m.Started()
+
+		status := m.Status()[0]
+		if status.Name != "flaky" || status.State != flex.StateRunning || status.Restarts != 1 || !errors.Is(status.LastErr, boom) {
+			t.Errorf("unexpected status: %+v", status)
+		}
+		if status.StartedAt.IsZero() || status.Uptime < 0 {
+			t.Errorf("expected the worker to have started but got: %+v", status)
+		}
+
+		cancel()
+		if err := <-errC; err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			t.Error(err)
+		}
+		if status := m.Status()[0]; status.State != flex.StateStopped {
+			t.Errorf("expected %v but got: %v", flex.StateStopped, status.State)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package flex
+
+import "time"
+
+// Stats holds the timings of the phases of the workers of a Manager, so that
+// regressions in how long a service takes to start or stop can be measured.
+type Stats struct {
+	// Workers holds the timings of each worker, in the order they were added.
+	Workers []WorkerStats
+	// ShutdownDuration is how long the last shutdown lasted, zero if the
+	// manager has not shut down yet.
+	ShutdownDuration time.Duration
+}
+
+// WorkerStats holds the timings of the phases of a worker.
+type WorkerStats struct {
+	Name string
+	// StartDuration is how long the worker last took to start, from Run being
+	// called to it calling Ready, zero if it has not started.
+	StartDuration time.Duration
+	// HaltDuration is how long the worker last took to return from Halt, or
+	// was given before being abandoned, zero if it has not been halted.
+	HaltDuration time.Duration
+}
+
+// Stats returns the timings of the phases of the workers. They are kept from
+// one Start to the next, until they are measured again.
+func (m *Manager) Stats() Stats {
+	stats := Stats{Workers: make([]WorkerStats, len(m.workers))}
+	for i, worker := range m.workers {
+		worker.mu.Lock()
+		stats.Workers[i] = WorkerStats{Name: worker.name(), StartDuration: worker.startDuration, HaltDuration: worker.haltDuration}
+		worker.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	if m.report != nil {
+		stats.ShutdownDuration = m.report.Duration
+	}
+	m.mu.Unlock()
+	return stats
+}
+
+// setStartDuration records how long the worker took to start.
+func (w *managedWorker) setStartDuration(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.startDuration = d
+}
+
+// setHaltDuration records how long the worker took to halt.
+func (w *managedWorker) setHaltDuration(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.haltDuration = d
+}
@@ -0,0 +1,46 @@
+package flex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-flexible/flex"
+)
+
+func TestManagerStats(t *testing.T) {
+	t.Run("the timings of each phase must be recorded", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := defaultCtx()
+		defer cancel()
+
+		const delay = 30 * time.Millisecond
+
+		m := flex.New(flex.WithSignals())
+		m.Add(&sluggishMockWorker{mockWorker: mockWorker{t: t, name: "slow"}, delay: delay}, flex.WithName("slow"), flex.WithStartedOnReady())
+		events := m.Subscribe()
+
+		if stats := m.Stats(); len(stats.Workers) != 1 || stats.Workers[0].StartDuration != 0 || stats.ShutdownDuration != 0 {
+			t.Fatalf("unexpected stats before starting: %+v", stats)
+		}
+
+		errC := make(chan error, 1)
+		go func() { errC <- m.Start(ctx) }()
+
+		if e := await(events, flex.EventWorkerStarted); e.Duration < delay {
+			t.Errorf("expected the start to take at least %s but got: %s", delay, e.Duration)
+		}
+		cancel()
+		if err := <-errC; err != nil {
+			t.Error(err)
+		}
+
+		stats := m.Stats()
+		if slow := stats.Workers[0]; slow.Name != "slow" || slow.StartDuration < delay || slow.HaltDuration < delay {
+			t.Errorf("expected the start and halt to take at least %s but got: %+v", delay, slow)
+		}
+		if stats.ShutdownDuration < stats.Workers[0].HaltDuration {
+			t.Errorf("expected the shutdown to last at least the halt but got: %+v", stats)
+		}
+	})
+}
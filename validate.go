@@ -0,0 +1,17 @@
+package flex
+
+import "context"
+
+// validateWorkers calls Validate on every worker implementing Validator, in
+// the order they were added, and returns their errors.
+func (m *Manager) validateWorkers(ctx context.Context) error {
+	errs := collector{join: m.opts.joinErrors}
+	for _, worker := range m.workers {
+		if validator, ok := worker.Worker.(Validator); ok {
+			err := m.call(ctx, worker, validator.Validate)
+			m.handleError(ctx, worker, PhaseValidate, err)
+			errs.add(m.annotate(worker, PhaseValidate, err))
+		}
+	}
+	return errs.err()
+}
@@ -0,0 +1,78 @@
+package flex_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-flexible/flex"
+)
+
+// validatingMockWorker fails validation with err, and records whether it ran.
+type validatingMockWorker struct {
+	mockWorker
+	err error
+	ran atomic.Bool
+}
+
+func (v *validatingMockWorker) Validate(context.Context) error { return v.err }
+
+func (v *validatingMockWorker) Run(ctx context.Context) error {
+	v.ran.Store(true)
+	return v.mockWorker.Run(ctx)
+}
+
+func TestValidator(t *testing.T) {
+	t.Run("an invalid worker must prevent every worker from running", func(t *testing.T) {
+		t.Parallel()
+
+		missingDSN, missingTopic := errors.New("missing dsn"), errors.New("missing topic")
+		valid := &validatingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}
+		db := &validatingMockWorker{mockWorker: mockWorker{t: t, name: "db"}, err: missingDSN}
+		consumer := &validatingMockWorker{mockWorker: mockWorker{t: t, name: "consumer"}, err: missingTopic}
+
+		var initialized bool
+		m := flex.New(flex.WithSignals(), flex.WithInitJob(flex.InitJob{Name: "migrate", Run: func(context.Context) error {
+			initialized = true
+			return nil
+		}}))
+		m.Add(valid, flex.WithName("api"))
+		m.Add(db, flex.WithName("db"))
+		m.Add(consumer, flex.WithName("consumer"))
+
+		err := m.Start(context.Background())
+		if !errors.Is(err, missingDSN) || !errors.Is(err, missingTopic) {
+			t.Errorf("expected both validation errors but got: %v", err)
+		}
+		if errs := flex.PhaseErrors(err, flex.PhaseValidate); len(errs) != 2 {
+			t.Errorf("expected %d errors of the validate phase but got: %v", 2, errs)
+		}
+		if valid.ran.Load() || db.ran.Load() || consumer.ran.Load() {
+			t.Error("expected no worker to run")
+		}
+		if initialized {
+			t.Error("expected no init job to run")
+		}
+	})
+	t.Run("valid workers must run", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var handled []flex.Phase
+		valid := &validatingMockWorker{mockWorker: mockWorker{t: t, name: "api"}}
+		m := flex.New(flex.WithSignals(), flex.WithErrorHandler(func(_ string, phase flex.Phase, _ error) {
+			handled = append(handled, phase)
+		}))
+		m.Add(valid, flex.WithName("api"))
+
+		if err := m.Start(ctx); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+		if len(handled) != 0 {
+			t.Errorf("expected no error to be handled but got: %v", handled)
+		}
+	})
+}